@@ -0,0 +1,1588 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/queue"
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/rpc"
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/sqlio"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+// command — команда программы: первый аргумент командной строки, например
+// bench. У каждой команды свой набор флагов.
+type command interface {
+	// flags определяет флаги команды в fs
+	flags(fs *flag.FlagSet)
+	// run выполняет команду после разбора флагов; args — аргументы после
+	// флагов, результат выводится в w
+	run(w io.Writer, args []string) error
+}
+
+// commandInfo описывает команду для выбора по имени и для справки.
+type commandInfo struct {
+	name    string
+	summary string         // строка в списке команд
+	help    string         // описание в справке команды
+	new     func() command // создаёт команду со значениями флагов по умолчанию
+}
+
+// commands — команды программы в порядке справки; первая выполняется,
+// если команда не указана.
+var commands = []commandInfo{
+	{
+		name:    "run",
+		summary: "запустить конвейер и вывести отчёт (по умолчанию)",
+		help:    "Запускает конвейер с настройками из флагов, выводит итоговый отчёт и проверяет, что ни одно число не потеряно.",
+		new:     func() command { return &runCmd{} },
+	},
+	{
+		name:    "verify",
+		summary: "воспроизвести запуск, записанный run -record, и проверить его целостность",
+		help: "Воспроизводит запуск, записанный run -record, из файла, заданного аргументом или -replay, с проверкой номеров " +
+			"чисел -verify-seq и подтверждений -ack и завершается с ошибкой, если хоть одно число потеряно или продублировано.",
+		new: func() command { return &verifyCmd{} },
+	},
+	{
+		name:    "serve",
+		summary: "принимать числа по HTTP или gRPC до остановки сигналом",
+		help: "Запускает конвейер без ограничения времени с источником -source http (POST /values на -http-addr) или " +
+			"grpc (Produce на -grpc-addr); конвейер работает, пока его не остановит SIGINT или SIGTERM.",
+		new: func() command { return &serveCmd{} },
+	},
+	{
+		name:    "selftest",
+		summary: "проверить, что повторные запуски передают одни и те же числа",
+		help:    "Запускает конвейер -runs раз по -limit чисел и проверяет, что каждый запуск передал те же числа.",
+		new:     func() command { return &selftestCmd{} },
+	},
+	{
+		name:    "replay",
+		summary: "повторить запуск, сохранённый run -save, и сравнить результат",
+		help:    "Повторяет запуск, сохранённый run -save в файл, заданный аргументом, и завершается с ошибкой, если итог разошёлся с сохранённым.",
+		new:     func() command { return &replayCmd{} },
+	},
+	{
+		name:    "bench",
+		summary: "сравнить производительность сочетаний обработчиков, буферов, пачек и раздачи чисел",
+		help:    "Запускает конвейер для каждого сочетания значений из списков флагов и выводит таблицу производительности.",
+		new:     func() command { return &benchCmd{} },
+	},
+	{
+		name:    "supervise",
+		summary: "запустить рядом несколько именованных конвейеров и вывести таблицу итогов",
+		help: "Запускает одновременно конвейеры, заданные аргументами вида имя:ключ=значение,..., например " +
+			"fast:workers=8 slow:workers=1, и выводит таблицу их итогов.",
+		new: func() command { return &superviseCmd{} },
+	},
+}
+
+// parseCommand выбирает команду по первому аргументу args и разбирает её
+// флаги; если args пусты или начинаются с флага, выполняется run. Ошибки
+// разбора и справка по -h выводятся в output; help <команда> выводит
+// справку команды, как <команда> -h. Возвращает имя команды, команду и
+// аргументы после флагов.
+func parseCommand(args []string, output io.Writer) (string, command, []string, error) {
+	if len(args) > 0 && args[0] == "help" {
+		if len(args) == 1 {
+			writeUsage(output)
+			return "", nil, nil, flag.ErrHelp
+		}
+		return parseCommand([]string{args[1], "-h"}, output)
+	}
+	info := commands[0]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		found := false
+		for _, c := range commands {
+			if c.name == args[0] {
+				info, found = c, true
+				break
+			}
+		}
+		if !found {
+			writeUsage(output)
+			return "", nil, nil, fmt.Errorf("неизвестная команда %q", args[0])
+		}
+		args = args[1:]
+	}
+
+	cmd := info.new()
+	fs := flag.NewFlagSet(info.name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		writeUsage(output)
+		fmt.Fprintf(output, "\n%s\n\nФлаги команды %s:\n", info.help, info.name)
+		fs.PrintDefaults()
+	}
+	cmd.flags(fs)
+	if err := fs.Parse(args); err != nil {
+		return "", nil, nil, err
+	}
+	return info.name, cmd, fs.Args(), nil
+}
+
+// writeUsage выводит в w общую справку со списком команд.
+func writeUsage(w io.Writer) {
+	fmt.Fprintln(w, "Использование: go-project-sprint-9 [команда] [флаги]")
+	fmt.Fprintln(w, "\nКоманды:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nСправку и флаги команды выводит go-project-sprint-9 help <команда> или go-project-sprint-9 <команда> -h.")
+}
+
+// sourceFlags — флаги выбора источника чисел.
+type sourceFlags struct {
+	name  string // -source
+	seed  int64  // -seed
+	max   int64  // -max
+	input string // -input
+	addr  string // -http-addr
+	// grpc — сервис, числа потока Produce которого служат источником
+	// grpc; запускается командой
+	grpc *rpc.Server
+}
+
+func (s *sourceFlags) flags(fs *flag.FlagSet) {
+	fs.StringVar(&s.name, "source", "seq", "источник чисел: seq, random, fib, primes, stdin, file, http, grpc, а при сборке с тегами nats и kafka — nats и kafka, или несколько через запятую, которые генерируют числа одновременно")
+	fs.Int64Var(&s.seed, "seed", 1, "начальное значение для -source random")
+	fs.Int64Var(&s.max, "max", 0, "верхняя граница чисел для -source random (0 — без ограничения)")
+	fs.StringVar(&s.input, "input", "", "путь к файлу с числами для -source file; для нескольких file — пути через запятую")
+	fs.StringVar(&s.addr, "http-addr", ":8080", "адрес HTTP-сервера, принимающего числа запросами POST /values для -source http")
+}
+
+// replayable сообщает, даёт ли источник при повторе те же числа. Несколько
+// источников генерируют числа одновременно, и то, сколько чисел даст каждый,
+// при повторе не совпадёт, а прочитанные и присланные числа не повторить.
+func (s sourceFlags) replayable() bool {
+	switch s.name {
+	case "seq", "random", "fib", "primes":
+		return true
+	}
+	return false
+}
+
+// open создаёт выбранные источники, по одному на имя из -source через
+// запятую; файлы для file берутся из -input по порядку, для http
+// запускается сервер -http-addr, а для grpc числа берутся из потоков Produce
+// сервиса s.grpc. Для stdin и file возвращаются и сами
+// *pipeline.ReaderSource, а для брокеров сообщений — их источники, чтобы
+// после запуска проверить ошибки чтения; close закрывает открытые файлы,
+// подключения к брокерам и сервер, отвечая ждущим запросам.
+func (s sourceFlags) open() (srcs []pipeline.Source[int64], checked []interface{ Err() error }, close func() error, err error) {
+	var files []*os.File
+	var closers []func() error // закрывают подключения к брокерам сообщений
+	var httpSrc *pipeline.HTTPSource
+	var server *http.Server
+	var grpcUsed bool
+	close = func() error {
+		var errs []error
+		for _, f := range files {
+			errs = append(errs, f.Close())
+		}
+		if httpSrc != nil {
+			httpSrc.Close()
+			errs = append(errs, server.Close())
+		}
+		for _, c := range closers {
+			errs = append(errs, c())
+		}
+		return errors.Join(errs...)
+	}
+	inputs := strings.Split(s.input, ",")
+	for _, name := range strings.Split(s.name, ",") {
+		var src pipeline.Source[int64]
+		switch name {
+		case "seq":
+			src = pipeline.Sequential()
+		case "random":
+			src = pipeline.Random(s.seed, s.max)
+		case "fib":
+			src = pipeline.Fibonacci()
+		case "primes":
+			src = pipeline.Primes()
+		case "stdin", "file":
+			var r io.Reader = os.Stdin
+			if name == "file" {
+				path := inputs[0]
+				if len(inputs) > 1 {
+					inputs = inputs[1:]
+				}
+				f, err := os.Open(path)
+				if err != nil {
+					close()
+					return nil, nil, nil, err
+				}
+				files = append(files, f)
+				r = f
+			}
+			reader := pipeline.NewReaderSource(r)
+			checked = append(checked, reader)
+			src = reader
+		case "http":
+			if httpSrc != nil {
+				close()
+				return nil, nil, nil, errors.New("источник http задан несколько раз")
+			}
+			ln, err := net.Listen("tcp", s.addr)
+			if err != nil {
+				close()
+				return nil, nil, nil, fmt.Errorf("сервер чисел: %w", err)
+			}
+			httpSrc = pipeline.NewHTTPSource()
+			mux := http.NewServeMux()
+			mux.Handle("/values", httpSrc)
+			server = &http.Server{Handler: mux}
+			go server.Serve(ln)
+			src = httpSrc
+		case "grpc":
+			if s.grpc == nil || grpcUsed {
+				close()
+				return nil, nil, nil, errors.New("источник grpc задан несколько раз или без gRPC-сервера")
+			}
+			grpcUsed = true
+			src = s.grpc.Source()
+		default:
+			b, ok := brokers[name]
+			if !ok {
+				close()
+				return nil, nil, nil, fmt.Errorf("неизвестный источник чисел %q", name)
+			}
+			var closeSrc func() error
+			if src, closeSrc, err = b.source(); err != nil {
+				close()
+				return nil, nil, nil, fmt.Errorf("подключение к %s: %w", name, err)
+			}
+			closers = append(closers, closeSrc)
+			if c, ok := src.(interface{ Err() error }); ok {
+				checked = append(checked, c)
+			}
+		}
+		srcs = append(srcs, src)
+	}
+	return srcs, checked, close, nil
+}
+
+// runCmd — команда run: обычный запуск конвейера с отчётом.
+type runCmd struct {
+	cfg          pipeline.Config // настройки конвейера из флагов
+	timeoutSet   bool            // -timeout задан явно
+	source       sourceFlags
+	sink         string                  // -sink
+	lineBuffered bool                    // -line-buffered
+	sinkFile     string                  // -sink-file
+	window       string                  // -window
+	windowSlide  string                  // -window-slide
+	rotate       pipeline.RotationPolicy // -rotate-size, -rotate-every, -rotate-gzip
+	sinkURL      string                  // -sink-url
+	sinkBatch    int                     // -sink-batch
+	sinkLimit    int64                   // -sink-concurrency
+	sinkBuffer   int                     // -sink-buffer
+	sinkSlow     pipeline.SlowSinkPolicy // -sink-slow
+	sinkRetry    int                     // -sink-retry
+	grpcAddr     string                  // -grpc-addr
+	sqlDriver    string                  // -sql-driver
+	sqlDSN       string                  // -sql-dsn
+	sqlBatch     int                     // -sql-batch
+	record       string                  // -record
+	replay       string                  // -replay
+	transform    string                  // -transform
+	metricsAddr  string                  // -metrics-addr
+	debugAddr    string                  // -debug-addr
+	statsEvery   time.Duration           // -stats-interval
+	pprofAddr    string                  // -pprof
+	output       string                  // -output
+	tui          bool                    // -tui
+	logFormat    string                  // -log-format
+	save         string                  // -save
+	config       string                  // -config
+	printConfig  bool                    // -print-config
+	distribute   string                  // -distribute
+	priority     string                  // -priority-weights
+	reduce       string                  // -reduce
+	reduceTop    int                     // -reduce-top
+	reduceBounds string                  // -reduce-bounds
+	chaosDelay   string                  // -chaos-delay
+	batch        int                     // -batch
+	linger       time.Duration           // -linger
+	spill        queue.Options           // -spill-dir, -spill-memory, -spill-max-bytes
+	resume       bool                    // -resume
+	deadLetters  bool                    // -dead-letters
+	deadFile     string                  // -dead-letter-file
+	// fs — флаги команды: их дополняют файл настроек и окружение, а
+	// значения записываются в таблицу runs; nil, если команда создана не
+	// разбором флагов
+	fs *flag.FlagSet
+	// check проверяет настройки команды, выполняющей run, после файла
+	// настроек и окружения; nil — без проверки
+	check func() error
+}
+
+func (c *runCmd) flags(fs *flag.FlagSet) {
+	c.cfg = pipeline.DefaultConfig()
+	c.fs = fs
+	fs.IntVar(&c.cfg.NumWorkers, "workers", c.cfg.NumWorkers, "количество обрабатывающих горутин и каналов")
+	fs.Var(valueFlag{
+		get: func() string { return c.cfg.Timeout.String() },
+		set: func(s string) (err error) {
+			c.cfg.Timeout, err = time.ParseDuration(s)
+			c.timeoutSet = true
+			return err
+		},
+	}, "timeout", "время генерации чисел (0 — без ограничения; с -source stdin по умолчанию — до конца ввода)")
+	fs.DurationVar(&c.cfg.Warmup, "warmup", 0, "прогрев перед -timeout: числа, полученные за это время, не входят в производительность и задержку (0 — без прогрева)")
+	fs.IntVar(&c.cfg.BufferSize, "buffer", c.cfg.BufferSize, "размер буфера каналов chIn и outs[i]")
+	fs.IntVar(&c.cfg.OutBufferSize, "buffer-out", c.cfg.OutBufferSize, "размер буфера каналов outs[i] (0 — как -buffer, меньше 0 — без буфера)")
+	fs.IntVar(&c.cfg.ResultBufferSize, "buffer-result", c.cfg.ResultBufferSize, "размер буфера результирующего канала (0 — по количеству обработчиков, меньше 0 — без буфера)")
+	fs.Float64Var(&c.cfg.AdaptiveBuffer.Target, "adaptive-buffer", c.cfg.AdaptiveBuffer.Target, "экспериментально: подбирать буфер chIn так, чтобы генератор ждал отправки не больше заданной доли времени, например 0.1 (0 — выключено)")
+	fs.DurationVar(&c.cfg.ItemTimeout, "item-timeout", c.cfg.ItemTimeout, "наибольшее время обработки одного числа; число, не обработанное вовремя, считается неудачным (0 — без ограничения)")
+	fs.DurationVar(&c.cfg.LeakTimeout, "leak-timeout", c.cfg.LeakTimeout, "сколько ждать завершения горутин конвейера после остановки обработки, прежде чем сообщить об утечке (0 — ждать без ограничения)")
+	fs.DurationVar(&c.cfg.Watchdog.Stall, "stall", c.cfg.Watchdog.Stall, "считать обработчик зависшим, если одно число обрабатывается дольше заданного (0 — не следить)")
+	fs.BoolVar(&c.cfg.Watchdog.Cancel, "stall-cancel", c.cfg.Watchdog.Cancel, "отменять обработку числа, на котором обработчик завис по -stall")
+	fs.IntVar(&c.cfg.Retry.Attempts, "retry", c.cfg.Retry.Attempts, "сколько попыток обработки числа делать при ошибке (0 или 1 — без повторов)")
+	fs.DurationVar(&c.cfg.Retry.Backoff, "retry-backoff", 10*time.Millisecond, "пауза перед первым повтором при -retry; каждая следующая вдвое длиннее")
+	fs.DurationVar(&c.cfg.Retry.MaxBackoff, "retry-max-backoff", time.Second, "наибольшая пауза между повторами при -retry (0 — без ограничения)")
+	fs.Float64Var(&c.cfg.Retry.Jitter, "retry-jitter", 0.2, "доля случайного отклонения пауз между повторами, от 0 до 1")
+	fs.Float64Var(&c.cfg.Chaos.DelayProbability, "chaos-delay-prob", 0, "хаос: вероятность задержать обработку числа на время из -chaos-delay, от 0 до 1")
+	c.chaosDelay = "exp:5ms"
+	c.cfg.Chaos.Delay = pipeline.ExponentialDelay(5 * time.Millisecond)
+	fs.Var(valueFlag{
+		get: func() string { return c.chaosDelay },
+		set: func(s string) (err error) {
+			if c.cfg.Chaos.Delay, err = pipeline.ParseDelayDistribution(s); err == nil {
+				c.chaosDelay = s
+			}
+			return err
+		},
+	}, "chaos-delay", "хаос: распределение задержек — длительность (5ms), диапазон (1ms-10ms) или экспоненциальное со средним (exp:5ms)")
+	fs.Float64Var(&c.cfg.Chaos.ErrorProbability, "chaos-error", 0, "хаос: вероятность неудачной обработки числа, от 0 до 1")
+	fs.Float64Var(&c.cfg.Chaos.PanicProbability, "chaos-panic", 0, "хаос: вероятность паники при обработке числа, от 0 до 1")
+	fs.Int64Var(&c.cfg.Chaos.Seed, "chaos-seed", 0, "хаос: начальное значение случайных чисел для повторяемых сбоев (0 — от текущего времени)")
+	fs.BoolVar(&c.deadLetters, "dead-letters", false, "не останавливать конвейер при неудачной обработке числа, а записывать такие числа в журнал")
+	fs.StringVar(&c.deadFile, "dead-letter-file", "", "файл, в который дописываются необработанные числа, как с -dead-letters (пусто — не записывать)")
+	fs.StringVar(&c.spill.Dir, "spill-dir", "", "каталог очереди между генератором и обработчиками с вытеснением на диск: генератор не ждёт обработчиков (пусто — выключено)")
+	fs.IntVar(&c.spill.MemoryRecords, "spill-memory", 0, "сколько чисел очередь -spill-dir хранит в памяти (0 — 1024)")
+	fs.Int64Var(&c.spill.MaxDiskBytes, "spill-max-bytes", 0, "сколько байт очередь -spill-dir может занять на диске (0 — без ограничения)")
+	fs.DurationVar(&c.cfg.WorkerDelay, "worker-delay", c.cfg.WorkerDelay, "пауза обработчика после каждого числа")
+	fs.Var(valueFlag{
+		get: func() string { return joinFactors(c.cfg.WorkerDelayFactors) },
+		set: func(s string) (err error) {
+			c.cfg.WorkerDelayFactors, err = parseFactors(s)
+			return err
+		},
+	}, "worker-delay-factors", "во сколько раз медленнее работает каждый обработчик, через запятую, например 5,1,1 (пусто — одинаково)")
+	fs.IntVar(&c.cfg.Autoscale.MinWorkers, "min-workers", c.cfg.Autoscale.MinWorkers, "наименьшее количество обработчиков при -max-workers (0 — 1)")
+	fs.IntVar(&c.cfg.Autoscale.MaxWorkers, "max-workers", c.cfg.Autoscale.MaxWorkers, "наибольшее количество обработчиков: их число меняется по давлению на входе (0 — постоянно -workers)")
+	fs.Float64Var(&c.cfg.Rate, "rate", c.cfg.Rate, "ограничение частоты генерации, чисел в секунду (0 — без ограничения)")
+	fs.IntVar(&c.cfg.Burst, "burst", c.cfg.Burst, "сколько чисел можно сгенерировать подряд без ожидания при -rate")
+	fs.Int64Var(&c.cfg.Limit, "limit", 0, "сколько чисел сгенерировать (0 — до истечения -timeout)")
+	fs.StringVar(&c.cfg.Checkpoint.Path, "checkpoint", "", "файл, в который периодически сохраняется состояние генерации (пусто — не сохранять)")
+	fs.DurationVar(&c.cfg.Checkpoint.Interval, "checkpoint-interval", 0, "период сохранения состояния в -checkpoint (0 — 1s)")
+	fs.BoolVar(&c.resume, "resume", false, "продолжить генерацию с состояния, сохранённого в -checkpoint")
+	fs.Int64Var(&c.cfg.MaxValue, "max-value", 0, "остановить генерацию на первом числе больше заданного (0 — без ограничения)")
+	fs.Var(valueFlag{
+		get: func() string { return c.cfg.Drain.String() },
+		set: func(s string) (err error) {
+			c.cfg.Drain, err = pipeline.ParseDrainPolicy(s)
+			return err
+		},
+	}, "drain", "дообработка после остановки генерации: all, drop или длительность, например 50ms")
+	c.distribute = "shared"
+	fs.Var(valueFlag{
+		get: func() string { return c.distribute },
+		set: func(s string) (err error) {
+			if c.cfg.Distributor, err = newDistributor(s); err == nil {
+				c.distribute = s
+			}
+			return err
+		},
+	}, "distribute", "раздача чисел обработчикам: shared (общий канал), round-robin, least-loaded или work-stealing")
+	fs.Var(valueFlag{
+		get: func() string { return c.priority },
+		set: func(s string) (err error) {
+			if c.cfg.Priority, err = newPriorityPolicy(s); err == nil {
+				c.priority = s
+			}
+			return err
+		},
+	}, "priority-weights", "веса классов приоритета от высшего к низшему через запятую, например 4,1: число v получает класс v по модулю количества классов (пусто — без приоритетов)")
+	fs.IntVar(&c.cfg.Dedup.Window, "dedup-window", 0, "подавлять повторы среди заданного количества последних различных чисел результирующего канала перед приёмником (0 — выключено)")
+	fs.BoolVar(&c.cfg.Dedup.Bloom, "dedup-bloom", false, "помнить числа -dedup-window в фильтрах Блума: меньше памяти, но редкие уникальные числа считаются повторами")
+	fs.Float64Var(&c.cfg.Dedup.FalsePositive, "dedup-false-positive", pipeline.DefaultDedupFalsePositive, "допустимая доля ложных повторов при -dedup-bloom")
+	fs.StringVar(&c.reduce, "reduce", "", "свёртки чисел результирующего канала через запятую, итоги которых выводятся в отчёте: count, sum, minmax, mean, hist (гистограмма по -reduce-bounds), top (-reduce-top наибольших) или sample (случайная выборка -reduce-top чисел)")
+	fs.IntVar(&c.reduceTop, "reduce-top", 10, "сколько чисел оставляют свёртки -reduce top и sample")
+	fs.StringVar(&c.reduceBounds, "reduce-bounds", "", "верхние границы корзин гистограммы -reduce hist по возрастанию через запятую (пусто — степени десяти)")
+	fs.IntVar(&c.batch, "batch", 0, "пакетный режим: передавать числа пачками заданного размера (0 — по одному)")
+	fs.DurationVar(&c.linger, "linger", 0, "наибольшее время ожидания заполнения пачки при -batch (0 — ждать заполнения)")
+	fs.BoolVar(&c.cfg.VerifySequence, "verify-seq", false, "проверять номера чисел и сообщать, какие именно потеряны или продублированы")
+	fs.BoolVar(&c.cfg.Ordered, "ordered", false, "передавать числа в приёмник в порядке их генерации, а не в порядке прихода из обработчиков")
+	fs.BoolVar(&c.cfg.CheckMonotonic, "check-monotonic", false, "проверять, что числа результирующего канала строго возрастают (имеет смысл с -ordered или одним обработчиком)")
+	fs.BoolVar(&c.cfg.DetectGaps, "detect-gaps", false, "считать пропущенные целые числа среди возрастающих чисел результирующего канала")
+	fs.Float64Var(&c.cfg.Trend.Alpha, "trend-alpha", 0, "считать скользящее среднее (EWMA) чисел результирующего канала с этим коэффициентом сглаживания от 0 до 1 (0 — не считать)")
+	fs.BoolVar(&c.cfg.BigSums, "big-sums", false, "собирать точные суммы чисел без ограничения int64 для долгих запусков")
+	fs.BoolVar(&c.cfg.Ack, "ack", false, "подтверждать учёт каждого числа по его ID и сообщать о неподтверждённых и повторно подтверждённых")
+	c.source.flags(fs)
+	fs.StringVar(&c.sink, "sink", "discard", "куда записывать числа результирующего канала: discard (только подсчитывать), stdout, file, http, grpc (подписчикам Consume), sql (в базу данных -sql-dsn), а при сборке с тегами — nats или kafka; несколько через запятую получают каждое число")
+	fs.StringVar(&c.sinkFile, "sink-file", "", "путь к файлу, в который -sink file записывает числа; при -rotate-size или -rotate-every — префикс файлов")
+	fs.StringVar(&c.window, "window", "", "записывать в -sink stdout или file вместо чисел итоги окон JSON-строками: длительность окна, например 1s, или количество чисел, например 100 (пусто — сами числа)")
+	fs.StringVar(&c.windowSlide, "window-slide", "", "сдвиг скользящего окна -window в тех же единицах (пусто — окна не перекрываются)")
+	fs.Int64Var(&c.rotate.MaxBytes, "rotate-size", 0, "начинать новый файл -sink-file, когда текущий достигает заданного размера в байтах (0 — не ограничивать)")
+	fs.DurationVar(&c.rotate.Interval, "rotate-every", 0, "начинать новый файл -sink-file через заданное время (0 — не ограничивать)")
+	fs.BoolVar(&c.rotate.Compress, "rotate-gzip", false, "сжимать gzip закрытые файлы при -rotate-size или -rotate-every")
+	fs.StringVar(&c.sinkURL, "sink-url", "", "адрес, на который -sink http отправляет пачки чисел запросами POST")
+	fs.IntVar(&c.sinkBatch, "sink-batch", 100, "размер пачки чисел для -sink http")
+	fs.Int64Var(&c.sinkLimit, "sink-concurrency", 0, "при -sink http каждый обработчик отправляет числа сам, а одновременно выполняется не больше заданного количества отправок (0 — числа отправляет сборка по одной пачке)")
+	fs.IntVar(&c.sinkBuffer, "sink-buffer", pipeline.DefaultBroadcastBuffer, "сколько чисел ждут каждого из нескольких приёмников -sink")
+	fs.Var(valueFlag{
+		get: func() string { return c.sinkSlow.String() },
+		set: func(s string) (err error) {
+			c.sinkSlow, err = pipeline.ParseSlowSinkPolicy(s)
+			return err
+		},
+	}, "sink-slow", "что делать с числом, если буфер одного из нескольких приёмников -sink заполнен: block (ждать) или drop (не передавать ему число)")
+	fs.IntVar(&c.sinkRetry, "sink-retry", 3, "сколько попыток отправки пачки делать при -sink http")
+	fs.StringVar(&c.sqlDriver, "sql-driver", "sqlite", "драйвер базы данных для -sql-dsn: sqlite или postgres (при сборке с тегами sqlite и postgres)")
+	fs.StringVar(&c.sqlDSN, "sql-dsn", "", "база данных, в таблицу runs которой записываются настройки и итоги запуска, а при -sink sql — и числа в run_values (пусто — не записывать)")
+	fs.IntVar(&c.sqlBatch, "sql-batch", 100, "размер пачки чисел для -sink sql")
+	fs.StringVar(&c.record, "record", "", "файл, в который записываются сгенерированные числа и обработчики, которым они достались, для -replay (пусто — не записывать)")
+	fs.StringVar(&c.replay, "replay", "", "воспроизвести запуск, записанный -record: те же числа в том же порядке раздаются тем же обработчикам вместо -source; -workers и -timeout по умолчанию берутся из записи и 0")
+	fs.StringVar(&c.grpcAddr, "grpc-addr", ":9090", "адрес gRPC-сервера для -source grpc и -sink grpc")
+	for _, name := range slices.Sorted(maps.Keys(brokers)) {
+		brokers[name].flags(fs)
+	}
+	fs.StringVar(&c.transform, "transform", "none", "обработка чисел: none, square, hash, even (только чётные)")
+	fs.StringVar(&c.metricsAddr, "metrics-addr", "", "адрес HTTP-сервера с метриками Prometheus на /metrics, например :2112 (пусто — выключено)")
+	fs.StringVar(&c.debugAddr, "debug-addr", "", "адрес отладочного HTTP-сервера с живой статистикой на /debug/vars, например :6060 (пусто — выключено)")
+	fs.DurationVar(&c.statsEvery, "stats-interval", 500*time.Millisecond, "период отправки живой статистики по WebSocket на /debug/stats сервера -debug-addr")
+	fs.StringVar(&c.pprofAddr, "pprof", "", "адрес HTTP-сервера net/http/pprof на /debug/pprof/, например :6060 (пусто — выключено)")
+	fs.BoolVar(&c.lineBuffered, "line-buffered", false, "при -sink stdout выводить каждое число сразу, а не накапливать в буфере")
+	fs.StringVar(&c.output, "output", "text", "формат итогового отчёта в stdout, а при -sink stdout — в stderr: text, json или csv")
+	fs.BoolVar(&c.tui, "tui", false, "показывать в терминале (stderr) живую панель конвейера: полосы чисел обработчиков, частоту генерации и результатов, давление на входе; журнал выводится после остановки")
+	fs.StringVar(&c.logFormat, "log-format", "text", "формат журнала в stderr: text или json")
+	fs.StringVar(&c.save, "save", "", "сохранить настройки и итог запуска в файл для команды replay; нужен -limit")
+	fs.StringVar(&c.config, "config", "", "файл настроек YAML (.yaml, .yml) или TOML (.toml) со значениями флагов по именам; флаги командной строки и переменные окружения PIPELINE_<ФЛАГ> важнее файла (пусто — PIPELINE_CONFIG)")
+	fs.BoolVar(&c.printConfig, "print-config", false, "проверить и вывести действующие настройки в формате YAML и завершить работу")
+}
+
+func (c *runCmd) run(w io.Writer, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("лишние аргументы: %q", args)
+	}
+	if c.fs != nil {
+		path := c.config
+		if path == "" {
+			path = os.Getenv(envName("config"))
+		}
+		if err := errors.Join(applySettings(c.fs, path, os.LookupEnv), c.cfg.Validate()); err != nil {
+			return fmt.Errorf("некорректные настройки:\n%w", err)
+		}
+	}
+	if c.check != nil {
+		if err := c.check(); err != nil {
+			return err
+		}
+	}
+	if c.printConfig {
+		return printSettings(w, c.fs)
+	}
+	if c.save != "" && c.cfg.Limit <= 0 {
+		return errors.New("-save требует -limit: запуск, остановленный по времени, не повторить")
+	}
+	if c.save != "" && c.replay != "" {
+		return errors.New("-save не работает с -replay: повторяйте сам файл записи")
+	}
+	if c.save != "" && !c.source.replayable() {
+		return fmt.Errorf("-save не работает с -source %s: прочитанные числа не повторить", c.source.name)
+	}
+	sinkNames := strings.Split(c.sink, ",")
+	// -window заменяет числа в -sink stdout или file итогами окон
+	var windows *pipeline.WindowPolicy
+	if c.window != "" {
+		policy, err := pipeline.ParseWindowPolicy(c.window, c.windowSlide)
+		switch {
+		case err != nil:
+			return fmt.Errorf("-window: %w", err)
+		case slices.ContainsFunc(sinkNames, func(name string) bool { return name != "stdout" && name != "file" }):
+			return fmt.Errorf("-window несовместим с -sink %s: итоги окон записываются только в stdout или файл", c.sink)
+		case c.rotate.MaxBytes > 0 || c.rotate.Interval > 0:
+			return errors.New("-window несовместим с -rotate-size и -rotate-every: итоги окон записываются в один файл")
+		}
+		windows = &policy
+	}
+	if c.sinkLimit > 0 && !slices.Contains(sinkNames, "http") {
+		return fmt.Errorf("-sink-concurrency несовместим с -sink %s: одновременные отправки задаются только для -sink http", c.sink)
+	}
+	var (
+		reducerNames []string
+		reducers     []pipeline.Reducer
+		sample       *pipeline.ReservoirReducer
+	)
+	if c.reduce != "" {
+		var err error
+		if reducerNames, reducers, sample, err = newReducers(c.reduce, c.reduceTop, c.reduceBounds, c.source.seed); err != nil {
+			return fmt.Errorf("-reduce: %w", err)
+		}
+	}
+	writeReport, ok := reportWriters[c.output]
+	if !ok {
+		return fmt.Errorf("неизвестный формат отчёта %q", c.output)
+	}
+	// журнал задерживается, пока в терминале рисуется панель -tui
+	logs := &heldWriter{w: os.Stderr}
+	logger, err := newLogger(logs, c.logFormat)
+	if err != nil {
+		return err
+	}
+
+	// -replay заменяет источники числами записанного запуска и раздаёт их
+	// тем же обработчикам
+	var replay *pipeline.Replay
+	if c.replay != "" {
+		if c.set("distribute") {
+			return errors.New("-replay несовместим с -distribute: раздача чисел задаётся записью запуска")
+		}
+		if c.cfg.Priority.Weights != nil {
+			return errors.New("-replay несовместим с -priority-weights: раздача чисел задаётся записью запуска")
+		}
+		if replay, err = pipeline.LoadReplay(c.replay); err != nil {
+			return fmt.Errorf("запись запуска: %w", err)
+		}
+	}
+
+	// gRPC-сервер общий для -source grpc и -sink grpc
+	source := c.source
+	var grpcService *rpc.Server
+	if replay == nil && slices.Contains(strings.Split(source.name, ","), "grpc") || slices.Contains(sinkNames, "grpc") {
+		var stop func()
+		if grpcService, stop, err = serveGRPC(logger, c.grpcAddr); err != nil {
+			return err
+		}
+		defer stop()
+		source.grpc = grpcService
+	}
+	var (
+		srcs     []pipeline.Source[int64]
+		checked  []interface{ Err() error }
+		closeSrc = func() error { return nil }
+	)
+	if replay != nil {
+		srcs = []pipeline.Source[int64]{replay.Source()}
+	} else if srcs, checked, closeSrc, err = source.open(); err != nil {
+		return err
+	}
+	// источники брокеров фиксируют подтверждения учтённых чисел при
+	// закрытии
+	defer func() {
+		if err := closeSrc(); err != nil {
+			logger.Error("ошибка закрытия источника чисел", "err", err)
+		}
+	}()
+	cfg := c.cfg
+	cfg.Reducers = reducers
+	cfg.Reservoir = sample
+	// stdin читается до конца, если время генерации не задано явно
+	if source.name == "stdin" && !c.timeoutSet {
+		cfg.Timeout = 0
+	}
+	if replay != nil {
+		if !c.set("workers") {
+			cfg.NumWorkers = replay.Workers
+		}
+		if !c.timeoutSet {
+			cfg.Timeout = 0
+		}
+		cfg.Distributor = replay.Distributor()
+		logger.Info("воспроизведение запуска", "path", c.replay, "values", len(replay.Values), "workers", cfg.NumWorkers)
+	}
+	if c.record != "" {
+		record, err := pipeline.CreateReplayFile(c.record, cfg.NumWorkers)
+		if err != nil {
+			return fmt.Errorf("файл записи запуска: %w", err)
+		}
+		defer func() {
+			if err := record.Close(); err != nil {
+				logger.Error("не удалось записать ход запуска", "path", c.record, "err", err)
+			}
+		}()
+		cfg.Record = record
+	}
+	if c.resume {
+		if cfg.Checkpoint.Path == "" {
+			return errors.New("-resume требует -checkpoint")
+		}
+		switch cfg.Resume, err = pipeline.LoadCheckpoint(cfg.Checkpoint.Path); {
+		case errors.Is(err, os.ErrNotExist):
+			logger.Info("файла состояния нет, генерация начинается с начала", "path", cfg.Checkpoint.Path)
+		case err != nil:
+			return fmt.Errorf("не удалось прочитать состояние: %w", err)
+		default:
+			logger.Info("генерация продолжается", "generated", cfg.Resume.Generated, "last", cfg.Resume.Last, "at", cfg.Resume.At)
+		}
+	}
+	if len(srcs) == 1 {
+		cfg.Source = srcs[0]
+	} else {
+		cfg.Sources = srcs
+	}
+	cfg.Logger = logger
+	if cfg.Process, err = newTransform(c.transform, cfg.WorkerDelay); err != nil {
+		return fmt.Errorf("неизвестная обработка %w", err)
+	}
+	if c.metricsAddr != "" {
+		if cfg.Metrics, err = serveMetrics(logger, c.metricsAddr); err != nil {
+			return err
+		}
+	}
+	if c.pprofAddr != "" {
+		servePprof(logger, c.pprofAddr)
+	}
+	switch {
+	case c.deadFile != "":
+		f, err := pipeline.OpenDeadLetterFile(c.deadFile)
+		if err != nil {
+			return fmt.Errorf("файл необработанных чисел: %w", err)
+		}
+		defer f.Close()
+		cfg.DeadLetters = f
+	case c.deadLetters:
+		cfg.DeadLetters = pipeline.MemoryDeadLetters
+	}
+	// запуск записывается в базу данных до подключения приёмника sql,
+	// которому нужен идентификатор запуска
+	var sqlRun *sqlio.Run
+	if c.sqlDSN != "" {
+		db, err := sqlio.Open(context.Background(), c.sqlDriver, c.sqlDSN)
+		if err != nil {
+			return fmt.Errorf("база данных (сборка с тегом: go build -tags %s): %w", c.sqlDriver, err)
+		}
+		defer db.Close()
+		if sqlRun, err = db.StartRun(context.Background(), time.Now(), flagValues(c.fs)); err != nil {
+			return err
+		}
+		logger.Info("запуск записывается в базу данных", "run", sqlRun.ID)
+	}
+	// несколько приёмников через запятую получают каждое число через
+	// BroadcastSink
+	var targets []pipeline.BroadcastTarget
+	for _, name := range sinkNames {
+		if slices.ContainsFunc(targets, func(t pipeline.BroadcastTarget) bool { return t.Name == name }) {
+			return fmt.Errorf("приёмник %q задан дважды", name)
+		}
+		var sink pipeline.Sink
+		switch name {
+		case "", "discard":
+		case "stdout":
+			if windows != nil {
+				if sink, err = pipeline.NewWindowSink(*windows, nil, pipeline.NewWindowWriter(w)); err != nil {
+					return err
+				}
+				break
+			}
+			if c.lineBuffered {
+				sink = pipeline.NewLineWriterSink(w)
+				break
+			}
+			sink = pipeline.NewWriterSink(w)
+		case "file":
+			if c.sinkFile == "" {
+				return errors.New("-sink file требует -sink-file")
+			}
+			if windows != nil {
+				f, err := os.Create(c.sinkFile)
+				if err != nil {
+					return fmt.Errorf("файл для итогов окон: %w", err)
+				}
+				defer f.Close()
+				if sink, err = pipeline.NewWindowSink(*windows, nil, pipeline.NewWindowWriter(f)); err != nil {
+					return err
+				}
+				break
+			}
+			if c.rotate.MaxBytes > 0 || c.rotate.Interval > 0 {
+				f, err := pipeline.OpenRotatingFileSink(c.sinkFile, c.rotate, nil)
+				if err != nil {
+					return fmt.Errorf("смена файлов для чисел: %w", err)
+				}
+				sink = f
+				break
+			}
+			f, err := pipeline.CreateFileSink(c.sinkFile)
+			if err != nil {
+				return fmt.Errorf("файл для чисел: %w", err)
+			}
+			// буфер дописывается конвейером, здесь файл только закрывается
+			defer f.Close()
+			sink = f
+		case "http":
+			if c.sinkURL == "" {
+				return errors.New("-sink http требует -sink-url")
+			}
+			retry := pipeline.RetryPolicy{Attempts: c.sinkRetry, Backoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second, Jitter: 0.2}
+			client := &http.Client{Timeout: 10 * time.Second}
+			s, err := pipeline.NewHTTPSink(c.sinkURL, c.sinkBatch, retry, client)
+			if err != nil {
+				return fmt.Errorf("отправка чисел: %w", err)
+			}
+			if c.sinkLimit > 0 {
+				// у каждого обработчика своя пачка, а семафор ограничивает
+				// одновременные запросы; настройки уже проверены первым
+				// приёмником
+				cfg.WorkerSinks = func(int) pipeline.Sink {
+					s, _ := pipeline.NewHTTPSink(c.sinkURL, c.sinkBatch, retry, client)
+					return s
+				}
+				cfg.SinkLimit = pipeline.SinkLimit{Semaphore: pipeline.NewSemaphore(c.sinkLimit)}
+				break
+			}
+			sink = s
+		case "grpc":
+			sink = grpcService.Sink()
+		case "sql":
+			if sqlRun == nil {
+				return errors.New("-sink sql требует -sql-dsn")
+			}
+			sink = sqlRun.Sink(c.sqlBatch)
+		default:
+			b, ok := brokers[name]
+			if !ok {
+				return fmt.Errorf("неизвестный приёмник чисел %q", name)
+			}
+			s, closeSink, err := b.sink()
+			if err != nil {
+				return fmt.Errorf("подключение к %s: %w", name, err)
+			}
+			defer func() {
+				if err := closeSink(); err != nil {
+					logger.Error("ошибка закрытия приёмника чисел", "err", err)
+				}
+			}()
+			sink = s
+		}
+		if sink != nil {
+			targets = append(targets, pipeline.BroadcastTarget{Name: name, Sink: sink, Buffer: c.sinkBuffer, Slow: c.sinkSlow})
+		}
+	}
+	var tee *pipeline.BroadcastSink
+	switch {
+	case len(targets) == 1:
+		cfg.Sink = targets[0].Sink
+	case len(targets) > 1:
+		if tee, err = pipeline.NewBroadcastSink(nil, targets...); err != nil {
+			return err
+		}
+		cfg.Sink = tee
+	}
+	if c.spill.Dir != "" {
+		spill, err := queue.Open(c.spill)
+		if err != nil {
+			return fmt.Errorf("очередь с вытеснением на диск: %w", err)
+		}
+		defer spill.Close()
+		cfg.Spill = spill
+	}
+	p := pipeline.New(cfg)
+	if c.debugAddr != "" {
+		serveDebug(logger, c.debugAddr, p, c.statsEvery)
+	}
+	run := p.Run
+	if c.batch > 0 {
+		run = func(ctx context.Context) (pipeline.Result, error) {
+			return p.RunBatched(ctx, c.batch, c.linger)
+		}
+	}
+	var dash *dashboard
+	if c.tui {
+		dash = startDashboard(os.Stderr, p, logs)
+	}
+	stats, err := runStoppable(logger, p, run)
+	if dash != nil {
+		switch verifyErr := stats.Verify(); {
+		case err != nil:
+			dash.close("Конвейер остановлен с ошибкой: " + err.Error())
+		case verifyErr != nil:
+			dash.close("Проверка не пройдена: " + verifyErr.Error())
+		default:
+			dash.close("Проверка пройдена")
+		}
+	}
+	// итоги записываются и при ошибке конвейера или проверки
+	finishRun := func(verifyErr error) {
+		if sqlRun == nil {
+			return
+		}
+		if err := sqlRun.Finish(context.Background(), stats, verifyErr); err != nil {
+			logger.Error("не удалось записать итоги запуска в базу данных", "err", err)
+		}
+	}
+	if err != nil {
+		finishRun(err)
+		stage, cause := errorStage(err)
+		return fmt.Errorf("на этапе «%s»: %w", stage, cause)
+	}
+	for _, dl := range stats.DeadLetters {
+		logger.Warn("число не обработано", "value", dl.Value, "attempts", dl.Attempts, "err", dl.Err)
+	}
+	for _, c := range checked {
+		if c.Err() != nil {
+			return fmt.Errorf("чтение чисел: %w", c.Err())
+		}
+	}
+
+	// проверка результатов; отчёт выводится и при неудачной проверке, а
+	// количество и суммы по этапам записывает в журнал сам конвейер
+	verifyErr := stats.Verify()
+	finishRun(verifyErr)
+	// при -sink stdout вывод занят числами, поэтому отчёт выводится в
+	// stderr рядом с журналом
+	reportOut := w
+	if slices.Contains(sinkNames, "stdout") {
+		reportOut = os.Stderr
+	}
+	rep := newReport(stats, verifyErr)
+	rep.Reduced = reductions(reducerNames, stats.Reduced)
+	if tee != nil {
+		rep.Sinks = sinkReports(tee.Stats())
+	}
+	if err := writeReport(reportOut, rep); err != nil {
+		return fmt.Errorf("вывод отчёта: %w", err)
+	}
+	counts := slog.Group("count", "input", stats.InputCount, "output", stats.OutputCount)
+	sums := slog.Group("sum", "input", stats.InputSum, "output", stats.OutputSum)
+	if err := verifyErr; err != nil {
+		logger.Error("проверка не пройдена", "err", err, counts, sums, "drain", stats.Drain.String())
+		return err
+	}
+	logger.Info("проверка пройдена", counts, sums)
+	if c.save != "" {
+		return saveRun(c.save, newSavedRun(c.cfg, c.source, c.transform, stats))
+	}
+	return nil
+}
+
+// set сообщает, задан ли флаг name явно: в командной строке, файле
+// настроек или окружении.
+func (c *runCmd) set(name string) bool {
+	if c.fs == nil {
+		return false
+	}
+	set := false
+	c.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// parseFactors разбирает множители, перечисленные через запятую; пусто —
+// без множителей.
+func parseFactors(s string) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	factors := make([]float64, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный множитель %q", part)
+		}
+		factors[i] = f
+	}
+	return factors, nil
+}
+
+// joinFactors перечисляет множители через запятую, как их разбирает
+// parseFactors.
+func joinFactors(factors []float64) string {
+	parts := make([]string, len(factors))
+	for i, f := range factors {
+		parts[i] = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// flagValues возвращает значения всех флагов fs по именам, включая
+// значения по умолчанию.
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// serveMetrics создаёт метрики конвейера и запускает HTTP-сервер, отдающий
+// их на /metrics по адресу addr. Ошибка сервера только записывается в
+// logger: конвейер работает и без метрик.
+func serveMetrics(logger *slog.Logger, addr string) (*pipeline.PrometheusMetrics, error) {
+	reg := prometheus.NewRegistry()
+	metrics, err := pipeline.NewPrometheusMetrics(reg)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("ошибка сервера метрик", "addr", addr, "err", err)
+		}
+	}()
+	return metrics, nil
+}
+
+// broker — подключение к брокеру сообщений для -source и -sink. flags
+// добавляет флаги подключения к флагам команды run; source и sink
+// возвращают источник или приёмник и функцию закрытия подключения.
+type broker struct {
+	flags  func(fs *flag.FlagSet)
+	source func() (pipeline.Source[int64], func() error, error)
+	sink   func() (pipeline.Sink, func() error, error)
+}
+
+// brokers — брокеры сообщений по именам; заполняются в файлах, собираемых
+// с тегами nats и kafka, например go build -tags nats,kafka.
+var brokers = map[string]broker{}
+
+// serveGRPC запускает gRPC-сервер сервиса Pipeline по адресу addr; ошибка
+// сервера записывается в logger. Функция stop завершает потоки Produce и
+// ждёт, пока подписчики Consume получат оставшиеся числа, но не дольше 5
+// секунд.
+func serveGRPC(logger *slog.Logger, addr string) (svc *rpc.Server, stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("gRPC-сервер: %w", err)
+	}
+	svc = rpc.NewServer()
+	srv := grpc.NewServer()
+	rpc.RegisterPipelineServer(srv, svc)
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			logger.Error("ошибка gRPC-сервера", "addr", addr, "err", err)
+		}
+	}()
+	return svc, func() {
+		svc.Close()
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			srv.Stop()
+		}
+	}, nil
+}
+
+// serveDebug публикует живую статистику конвейера p через expvar на
+// /debug/vars и по WebSocket на /debug/stats каждые statsEvery, состояние
+// конвейера на /debug/state и запускает отладочный HTTP-сервер по адресу
+// addr; ошибка сервера записывается в logger.
+func serveDebug(logger *slog.Logger, addr string, p *pipeline.Pipeline, statsEvery time.Duration) {
+	pipeline.PublishExpvar("pipeline", p)
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/debug/stats", pipeline.StatsHandler(p, statsEvery, nil))
+	mux.Handle("/debug/state", pipeline.StateHandler(p))
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("ошибка отладочного сервера", "addr", addr, "err", err)
+		}
+	}()
+}
+
+// servePprof запускает HTTP-сервер с профилями net/http/pprof по адресу addr
+// и включает профилирование блокировок на каналах и мьютексах. Ошибка
+// сервера записывается в logger.
+func servePprof(logger *slog.Logger, addr string) {
+	runtime.SetBlockProfileRate(1)
+	runtime.SetMutexProfileFraction(1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("ошибка сервера pprof", "addr", addr, "err", err)
+		}
+	}()
+}
+
+// newDistributor возвращает раздачу чисел -distribute с именем name; для
+// shared — nil, общий канал по умолчанию.
+func newDistributor(name string) (pipeline.Distributor[pipeline.Event], error) {
+	switch name {
+	case "shared":
+		return nil, nil
+	case "round-robin":
+		return pipeline.RoundRobin[pipeline.Event](), nil
+	case "least-loaded":
+		return pipeline.LeastLoaded[pipeline.Event](), nil
+	case "work-stealing":
+		return pipeline.WorkStealing[pipeline.Event](), nil
+	}
+	return nil, fmt.Errorf("неизвестная раздача чисел %q", name)
+}
+
+// newPriorityPolicy возвращает классы приоритета -priority-weights с весами
+// weights через запятую: число v получает класс v по модулю количества
+// классов. Пусто — без приоритетов.
+func newPriorityPolicy(weights string) (pipeline.PriorityPolicy, error) {
+	if weights == "" {
+		return pipeline.PriorityPolicy{}, nil
+	}
+	ws, err := parseInts(weights, 1)
+	if err != nil {
+		return pipeline.PriorityPolicy{}, err
+	}
+	lanes := int64(len(ws))
+	return pipeline.PriorityPolicy{
+		Weights: ws,
+		Classify: func(v int64) int {
+			return int((v%lanes + lanes) % lanes)
+		},
+	}, nil
+}
+
+// newReducers возвращает имена и свёртки -reduce из списка names через
+// запятую: top и sample оставляют top чисел, а hist раскладывает числа по
+// корзинам с границами bounds через запятую. Выборка sample — случайная с
+// начальным значением seed; она возвращается отдельно для
+// Config.Reservoir, а не среди свёрток.
+func newReducers(names string, top int, bounds string, seed int64) ([]string, []pipeline.Reducer, *pipeline.ReservoirReducer, error) {
+	var (
+		list     []string
+		reducers []pipeline.Reducer
+		sample   *pipeline.ReservoirReducer
+	)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if slices.Contains(list, name) || name == "sample" && sample != nil {
+			return nil, nil, nil, fmt.Errorf("свёртка %s задана дважды", name)
+		}
+		var (
+			r   pipeline.Reducer
+			err error
+		)
+		switch name {
+		case "count":
+			r = pipeline.NewCountReducer()
+		case "sum":
+			r = pipeline.NewSumReducer()
+		case "minmax":
+			r = pipeline.NewMinMaxReducer()
+		case "mean":
+			r = pipeline.NewMeanReducer()
+		case "hist":
+			var bs []int64
+			for _, part := range strings.Split(bounds, ",") {
+				if part = strings.TrimSpace(part); part == "" {
+					continue
+				}
+				b, perr := strconv.ParseInt(part, 10, 64)
+				if perr != nil {
+					return nil, nil, nil, fmt.Errorf("некорректная граница корзины %q", part)
+				}
+				bs = append(bs, b)
+			}
+			r, err = pipeline.NewHistogramReducer(bs...)
+		case "top":
+			r, err = pipeline.NewTopKReducer(top)
+		case "sample":
+			if top < 1 {
+				return nil, nil, nil, fmt.Errorf("%s: размер выборки должен быть положительным: %d", name, top)
+			}
+			sample = pipeline.NewReservoirReducer(top, nil, seed)
+			continue
+		default:
+			return nil, nil, nil, fmt.Errorf("неизвестная свёртка %q", name)
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		list = append(list, name)
+		reducers = append(reducers, r)
+	}
+	return list, reducers, sample, nil
+}
+
+// newLogger создаёт журнал в w в формате format: text или json.
+func newLogger(w io.Writer, format string) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	}
+	return nil, fmt.Errorf("неизвестный формат журнала %q", format)
+}
+
+// newTransform возвращает обработку -transform с именем name; обработка
+// выполняется перед паузой delay. Для none возвращает nil — только пауза.
+func newTransform(name string, delay time.Duration) (func(context.Context, int64) (int64, error), error) {
+	switch name {
+	case "none":
+		return nil, nil
+	case "square":
+		return pipeline.Chain(pipeline.Square, pipeline.Delay[int64](delay)), nil
+	case "hash":
+		return pipeline.Chain(pipeline.Hash, pipeline.Delay[int64](delay)), nil
+	case "even":
+		return pipeline.Chain(pipeline.Filter(func(v int64) bool { return v%2 == 0 }), pipeline.Delay[int64](delay)), nil
+	}
+	return nil, fmt.Errorf("%q", name)
+}
+
+// runStoppable запускает конвейер p: первый сигнал SIGINT или SIGTERM
+// останавливает генерацию так же, как истечение таймаута, и числа
+// дообрабатываются, а второй прерывает конвейер немедленно. Сигнал
+// снимка статистики (SIGUSR1) записывает её в журнал, не останавливая
+// конвейер. Сигналы записываются в logger. run — запуск p: Run или
+// RunBatched.
+func runStoppable(logger *slog.Logger, p *pipeline.Pipeline, run func(context.Context) (pipeline.Result, error)) (pipeline.Result, error) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Info("получен сигнал, останавливаем генерацию", "signal", sig.String())
+			p.StopCause(fmt.Errorf("получен сигнал %s", sig))
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case sig := <-signals:
+			logger.Info("получен сигнал, прерываем обработку", "signal", sig.String())
+			cancel(fmt.Errorf("получен повторный сигнал %s", sig))
+		case <-ctx.Done():
+		}
+	}()
+	if len(dumpSignals) > 0 {
+		dumps := make(chan os.Signal, 1)
+		signal.Notify(dumps, dumpSignals...)
+		defer signal.Stop(dumps)
+		go func() {
+			for {
+				select {
+				case sig := <-dumps:
+					logSnapshot(logger, p, sig)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return run(ctx)
+}
+
+// logSnapshot записывает в журнал текущее состояние конвейера p и
+// количество горутин по сигналу sig.
+func logSnapshot(logger *slog.Logger, p *pipeline.Pipeline, sig os.Signal) {
+	state := p.Snapshot()
+	snap := state.Stats
+	logger.Info("снимок статистики",
+		"signal", sig.String(),
+		"uptime", state.Uptime,
+		"last", state.LastValue,
+		slog.Group("count", "input", snap.InputCount, "output", snap.OutputCount,
+			"dropped", snap.DroppedCount, "skipped", snap.SkippedCount, "failed", snap.FailedCount),
+		slog.Group("sum", "input", snap.InputSum, "output", snap.OutputSum),
+		"inFlight", snap.InputCount-snap.OutputCount-snap.DroppedCount-snap.SkippedCount-snap.FailedCount,
+		"perWorker", snap.PerWorker,
+		"generatorBlocked", snap.GeneratorBlocked,
+		slog.Group("queues", "input", state.Queues.Input, "workers", state.Queues.Workers, "outputs", state.Queues.Outputs, "result", state.Queues.Result),
+		"workers", state.Workers,
+		"running", state.Goroutines,
+		"paused", state.Paused,
+		"goroutines", runtime.NumGoroutine(),
+	)
+}
+
+// savedRun — запуск, сохранённый run -save для команды replay.
+type savedRun struct {
+	Workers     int    `json:"workers"`
+	Limit       int64  `json:"limit"`
+	MaxValue    int64  `json:"max_value,omitempty"`
+	Source      string `json:"source"`
+	Seed        int64  `json:"seed,omitempty"`
+	Max         int64  `json:"max,omitempty"`
+	Transform   string `json:"transform,omitempty"`
+	InputSum    int64  `json:"input_sum"`
+	InputCount  int64  `json:"input_count"`
+	OutputSum   int64  `json:"output_sum"`
+	OutputCount int64  `json:"output_count"`
+}
+
+// newSavedRun описывает запуск конвейера с настройками cfg, источником src
+// и обработкой transform, завершившийся со статистикой stats.
+func newSavedRun(cfg pipeline.Config, src sourceFlags, transform string, stats pipeline.Result) savedRun {
+	r := savedRun{
+		Workers:     cfg.NumWorkers,
+		Limit:       cfg.Limit,
+		MaxValue:    cfg.MaxValue,
+		Source:      src.name,
+		InputSum:    stats.InputSum,
+		InputCount:  stats.InputCount,
+		OutputSum:   stats.OutputSum,
+		OutputCount: stats.OutputCount,
+	}
+	if src.name == "random" {
+		r.Seed, r.Max = src.seed, src.max
+	}
+	if transform != "none" {
+		r.Transform = transform
+	}
+	return r
+}
+
+// saveRun записывает запуск r в файл path в формате JSON.
+func saveRun(path string, r savedRun) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// loadRun читает запуск, сохранённый saveRun, из файла path.
+func loadRun(path string) (savedRun, error) {
+	var r savedRun
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("%s: %w", path, err)
+	}
+	if r.Workers < 1 || r.Limit < 1 {
+		return r, fmt.Errorf("%s: нужны положительные workers и limit: %d и %d", path, r.Workers, r.Limit)
+	}
+	return r, nil
+}
+
+// selftestCmd — команда selftest: проверка повторяемости запусков
+// VerifyDeterministic.
+type selftestCmd struct {
+	workers int
+	limit   int64
+	runs    int
+}
+
+func (c *selftestCmd) flags(fs *flag.FlagSet) {
+	fs.IntVar(&c.workers, "workers", 5, "количество обрабатывающих горутин и каналов")
+	fs.Int64Var(&c.limit, "limit", 10000, "сколько чисел генерировать в каждом запуске")
+	fs.IntVar(&c.runs, "runs", 3, "сколько раз запускать конвейер")
+}
+
+func (c *selftestCmd) run(w io.Writer, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("лишние аргументы: %q", args)
+	}
+	if err := pipeline.VerifyDeterministic(pipeline.Config{NumWorkers: c.workers, Limit: c.limit}, c.runs); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Самопроверка пройдена: запусков %d, чисел в каждом %d\n", c.runs, c.limit)
+	return nil
+}
+
+// replayCmd — команда replay: повтор запуска, сохранённого run -save.
+type replayCmd struct{}
+
+func (c *replayCmd) flags(*flag.FlagSet) {}
+
+func (c *replayCmd) run(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("нужен один аргумент — файл, сохранённый run -save")
+	}
+	want, err := loadRun(args[0])
+	if err != nil {
+		return err
+	}
+	source := sourceFlags{name: want.Source, seed: want.Seed, max: want.Max}
+	if !source.replayable() {
+		return fmt.Errorf("источник %q не повторить", want.Source)
+	}
+	srcs, _, _, err := source.open()
+	if err != nil {
+		return err
+	}
+	cfg := pipeline.Config{NumWorkers: want.Workers, Limit: want.Limit, MaxValue: want.MaxValue, Source: srcs[0]}
+	transform := want.Transform
+	if transform == "" {
+		transform = "none"
+	}
+	if cfg.Process, err = newTransform(transform, 0); err != nil {
+		return fmt.Errorf("неизвестная обработка %w", err)
+	}
+	stats, err := pipeline.Run(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Количество чисел", stats.InputCount, stats.OutputCount)
+	fmt.Fprintln(w, "Сумма чисел", stats.InputSum, stats.OutputSum)
+	if got := newSavedRun(cfg, source, transform, stats); got != want {
+		return fmt.Errorf("итог расходится с сохранённым: %+v, сохранён %+v", got, want)
+	}
+	fmt.Fprintln(w, "Итог совпадает с сохранённым")
+	return nil
+}
+
+// verifyCmd — команда verify: run с воспроизведением записанного запуска
+// и проверкой номеров и подтверждений каждого числа.
+type verifyCmd struct {
+	runCmd
+}
+
+func (c *verifyCmd) flags(fs *flag.FlagSet) {
+	c.runCmd.flags(fs)
+	setDefault(fs, "verify-seq", "true")
+	setDefault(fs, "ack", "true")
+	c.check = func() error {
+		if c.replay == "" {
+			return errors.New("не задан файл записи запуска: verify <файл> или -replay")
+		}
+		return nil
+	}
+}
+
+// run воспроизводит запуск, записанный в файл args[0] или -replay, как run.
+func (c *verifyCmd) run(w io.Writer, args []string) error {
+	switch {
+	case len(args) > 1:
+		return fmt.Errorf("лишние аргументы: %q", args[1:])
+	case len(args) == 0:
+	case c.replay != "" && c.replay != args[0]:
+		return errors.New("файл записи задан и аргументом, и -replay")
+	case c.fs != nil:
+		// заданный аргументом файл важнее файла настроек и окружения
+		if err := c.fs.Set("replay", args[0]); err != nil {
+			return err
+		}
+	default:
+		c.replay = args[0]
+	}
+	return c.runCmd.run(w, nil)
+}
+
+// serveCmd — команда serve: run с приёмом чисел по HTTP или gRPC без
+// ограничения времени.
+type serveCmd struct {
+	runCmd
+}
+
+func (c *serveCmd) flags(fs *flag.FlagSet) {
+	c.runCmd.flags(fs)
+	setDefault(fs, "source", "http")
+	setDefault(fs, "timeout", "0s")
+	// значение по умолчанию не считается заданным явно
+	c.timeoutSet = false
+	c.check = func() error {
+		if c.replay != "" {
+			return errors.New("команда serve не воспроизводит запись: используйте verify")
+		}
+		for _, name := range strings.Split(c.source.name, ",") {
+			if name != "http" && name != "grpc" {
+				return fmt.Errorf("команда serve принимает числа только из -source http и grpc: %q", name)
+			}
+		}
+		return nil
+	}
+}
+
+// setDefault заменяет значение флага name из fs по умолчанию на value, не
+// отмечая флаг заданным: файл настроек и окружение по-прежнему важнее.
+func setDefault(fs *flag.FlagSet, name, value string) {
+	f := fs.Lookup(name)
+	// значения по умолчанию команд заведомо корректны
+	f.Value.Set(value)
+	f.DefValue = value
+}
+
+// benchCmd — команда bench: сравнение производительности сочетаний
+// количества обработчиков, размера буфера, размера пачки и раздачи чисел.
+type benchCmd struct {
+	workers     []int
+	buffers     []int
+	batches     []int // 0 — по одному числу
+	distributes []string
+	delay       time.Duration
+	duration    time.Duration
+	warmup      time.Duration
+}
+
+func (c *benchCmd) flags(fs *flag.FlagSet) {
+	c.workers = []int{1, 2, 5, 10}
+	c.buffers = []int{0, 64, 1024}
+	c.batches = []int{0, 64}
+	c.distributes = []string{"shared", "round-robin", "least-loaded", "work-stealing"}
+	fs.Func("workers", "количества обработчиков через запятую (по умолчанию 1,2,5,10)", func(s string) (err error) {
+		c.workers, err = parseInts(s, 1)
+		return err
+	})
+	fs.Func("buffers", "размеры буфера каналов через запятую (по умолчанию 0,64,1024)", func(s string) (err error) {
+		c.buffers, err = parseInts(s, 0)
+		return err
+	})
+	fs.Func("batch", "размеры пачек через запятую, 0 — по одному числу (по умолчанию 0,64)", func(s string) (err error) {
+		c.batches, err = parseInts(s, 0)
+		return err
+	})
+	fs.Func("distribute", "раздачи чисел через запятую (по умолчанию shared,round-robin,least-loaded,work-stealing)", func(s string) error {
+		c.distributes = nil
+		for _, name := range strings.Split(s, ",") {
+			name = strings.TrimSpace(name)
+			if _, err := newDistributor(name); err != nil {
+				return err
+			}
+			c.distributes = append(c.distributes, name)
+		}
+		return nil
+	})
+	fs.DurationVar(&c.delay, "worker-delay", 0, "пауза обработки каждого числа перед его отправкой (0 — измеряются накладные расходы самого конвейера)")
+	fs.DurationVar(&c.duration, "duration", time.Second, "время генерации в каждом запуске")
+	fs.DurationVar(&c.warmup, "warmup", 0, "прогрев перед -duration в каждом запуске, не входящий в производительность (0 — без прогрева)")
+}
+
+// benchRow — результат одного запуска команды bench.
+type benchRow struct {
+	workers, buffer, batch int
+	distribute             string
+	count                  int64   // чисел результирующего канала
+	throughput             float64 // чисел результирующего канала в секунду
+	err                    error   // ошибка запуска или проверки
+}
+
+// run запускает конвейер для каждого сочетания настроек и выводит таблицу
+// производительности. Пачки передаются только через общий канал, поэтому
+// пачки с другой раздачей не запускаются. Возвращает ошибку, если
+// какой-либо запуск не прошёл проверку.
+func (c *benchCmd) run(w io.Writer, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("лишние аргументы: %q", args)
+	}
+	if c.duration <= 0 {
+		return fmt.Errorf("время запуска должно быть положительным: %v", c.duration)
+	}
+	if c.delay < 0 {
+		return fmt.Errorf("пауза обработчика не может быть отрицательной: %v", c.delay)
+	}
+	if c.warmup < 0 {
+		return fmt.Errorf("время прогрева не может быть отрицательным: %v", c.warmup)
+	}
+	var rows []benchRow
+	for _, workers := range c.workers {
+		for _, buffer := range c.buffers {
+			for _, batch := range c.batches {
+				for _, name := range c.distributes {
+					if batch > 0 && name != "shared" {
+						continue
+					}
+					rows = append(rows, c.runOne(workers, buffer, batch, name))
+				}
+			}
+		}
+	}
+
+	var best float64
+	for _, r := range rows {
+		best = max(best, r.throughput)
+	}
+	var failed int
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "обработчиков\tбуфер\tпачка\tраздача\tчисел\tчисел/с\tот лучшего\tпроверка\t")
+	for _, r := range rows {
+		verdict := "пройдена"
+		if r.err != nil {
+			verdict = r.err.Error()
+			failed++
+		}
+		var share float64
+		if best > 0 {
+			share = 100 * r.throughput / best
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%d\t%.0f\t%.0f%%\t%s\t\n", r.workers, r.buffer, r.batch, r.distribute, r.count, r.throughput, share, verdict)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("проверка не пройдена в %d запусках из %d", failed, len(rows))
+	}
+	return nil
+}
+
+// runOne запускает конвейер с workers обработчиками, буфером buffer и
+// раздачей name пачками по batch чисел (0 — по одному).
+func (c *benchCmd) runOne(workers, buffer, batch int, name string) benchRow {
+	row := benchRow{workers: workers, buffer: buffer, batch: batch, distribute: name}
+	distributor, _ := newDistributor(name)
+	p := pipeline.New(pipeline.Config{
+		NumWorkers:  workers,
+		BufferSize:  buffer,
+		Distributor: distributor,
+		Timeout:     c.duration,
+		Warmup:      c.warmup,
+		WorkerDelay: c.delay,
+	})
+	var (
+		res pipeline.Result
+		err error
+	)
+	if batch > 0 {
+		res, err = p.RunBatched(context.Background(), batch, 0)
+	} else {
+		res, err = p.Run(context.Background())
+	}
+	if err == nil {
+		err = res.Verify()
+	}
+	row.count, row.throughput, row.err = res.OutputCount, res.Throughput(), err
+	return row
+}
+
+// parseInts разбирает целые числа не меньше least, перечисленные через
+// запятую.
+func parseInts(s string, least int) ([]int, error) {
+	var ns []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < least {
+			return nil, fmt.Errorf("некорректное значение %q", part)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
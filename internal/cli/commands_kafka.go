@@ -0,0 +1,43 @@
+//go:build kafka
+
+package cli
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/kafkaio"
+	"github.com/segmentio/kafka-go"
+)
+
+func init() {
+	var addrs, topic, group, outTopic string
+	brokers["kafka"] = broker{
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&addrs, "kafka-brokers", "localhost:9092", "адреса брокеров Kafka через запятую для -source kafka и -sink kafka")
+			fs.StringVar(&topic, "kafka-topic", "numbers", "тема Kafka, из которой -source kafka получает числа")
+			fs.StringVar(&group, "kafka-group", "pipeline", "группа потребителей -source kafka, за которой фиксируются смещения учтённых чисел")
+			fs.StringVar(&outTopic, "kafka-out-topic", "processed", "тема Kafka, в которую -sink kafka записывает числа")
+		},
+		source: func() (pipeline.Source[int64], func() error, error) {
+			src, err := kafkaio.NewSource(kafka.ReaderConfig{
+				Brokers: strings.Split(addrs, ","),
+				Topic:   topic,
+				GroupID: group,
+			}, 0)
+			if err != nil {
+				return nil, nil, err
+			}
+			return src, src.Close, nil
+		},
+		sink: func() (pipeline.Sink, func() error, error) {
+			w := &kafka.Writer{
+				Addr:     kafka.TCP(strings.Split(addrs, ",")...),
+				Topic:    outTopic,
+				Balancer: &kafka.LeastBytes{},
+			}
+			return kafkaio.NewSink(w, 0), w.Close, nil
+		},
+	}
+}
@@ -0,0 +1,56 @@
+//go:build nats
+
+package cli
+
+import (
+	"flag"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/natsio"
+	"github.com/nats-io/nats.go"
+)
+
+func init() {
+	var url, subject, durable, outSubject string
+	brokers["nats"] = broker{
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&url, "nats-url", nats.DefaultURL, "адрес сервера NATS для -source nats и -sink nats")
+			fs.StringVar(&subject, "nats-subject", "numbers", "тема NATS, из которой -source nats получает числа")
+			fs.StringVar(&durable, "nats-durable", "", "имя потребителя JetStream для -source nats: сообщения подтверждаются по мере учёта чисел (пусто — обычная подписка без подтверждений)")
+			fs.StringVar(&outSubject, "nats-out-subject", "processed", "тема NATS, в которую -sink nats публикует числа")
+		},
+		source: func() (pipeline.Source[int64], func() error, error) {
+			nc, err := nats.Connect(url)
+			if err != nil {
+				return nil, nil, err
+			}
+			var src *natsio.Source
+			if durable != "" {
+				var js nats.JetStreamContext
+				if js, err = nc.JetStream(); err == nil {
+					src, err = natsio.SubscribeJetStream(js, subject, durable)
+				}
+			} else {
+				src, err = natsio.Subscribe(nc, subject)
+			}
+			if err != nil {
+				nc.Close()
+				return nil, nil, err
+			}
+			return src, func() error {
+				defer nc.Close()
+				return src.Close()
+			}, nil
+		},
+		sink: func() (pipeline.Sink, func() error, error) {
+			nc, err := nats.Connect(url)
+			if err != nil {
+				return nil, nil, err
+			}
+			return natsio.NewSink(nc, outSubject), func() error {
+				nc.Close()
+				return nil
+			}, nil
+		},
+	}
+}
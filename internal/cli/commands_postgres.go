@@ -0,0 +1,6 @@
+//go:build postgres
+
+package cli
+
+// драйвер postgres для -sql-driver postgres
+import _ "github.com/lib/pq"
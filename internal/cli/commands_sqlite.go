@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package cli
+
+// драйвер sqlite для -sql-driver sqlite
+import _ "modernc.org/sqlite"
@@ -0,0 +1,863 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/queue"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantName string
+		wantArgs []string
+		check    func(t *testing.T, cmd command)
+		wantErr  bool
+	}{
+		{"без команды", nil, "run", nil, func(t *testing.T, cmd command) {
+			if c, def := cmd.(*runCmd), pipeline.DefaultConfig(); c.cfg.NumWorkers != def.NumWorkers || c.cfg.Timeout != def.Timeout || c.cfg.WorkerDelay != def.WorkerDelay || c.cfg.Limit != 0 || c.save != "" {
+				t.Errorf("run = %+v, want значения по умолчанию", c)
+			}
+		}, false},
+		{"флаги без команды", []string{"-limit", "10"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.cfg.Limit != 10 {
+				t.Errorf("limit = %d, want 10", c.cfg.Limit)
+			}
+		}, false},
+		{"run", []string{"run", "-limit", "10", "-save", "run.json"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.cfg.Limit != 10 || c.save != "run.json" {
+				t.Errorf("run = %+v, want limit 10 и save run.json", c)
+			}
+		}, false},
+		{"источник", []string{"-source", "random", "-seed", "7", "-max", "100"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.source != (sourceFlags{name: "random", seed: 7, max: 100, addr: ":8080"}) {
+				t.Errorf("source = %+v, want random с seed 7 и max 100", c.source)
+			}
+		}, false},
+		{"настройки конвейера", []string{"-workers", "15", "-timeout", "2s", "-buffer", "10", "-worker-delay", "0", "-drain", "drop"}, "run", nil, func(t *testing.T, cmd command) {
+			c := cmd.(*runCmd)
+			if c.cfg.NumWorkers != 15 || c.cfg.Timeout != 2*time.Second || !c.timeoutSet || c.cfg.BufferSize != 10 || c.cfg.WorkerDelay != 0 || c.cfg.Drain != pipeline.DropRemaining {
+				t.Errorf("cfg = %+v, want 15 обработчиков, таймаут 2s, буфер 10, без паузы и drop", c.cfg)
+			}
+		}, false},
+		{"буферы", []string{"-buffer-out", "4", "-buffer-result", "-1", "-adaptive-buffer", "0.1"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.cfg.OutBufferSize != 4 || c.cfg.ResultBufferSize != -1 || c.cfg.AdaptiveBuffer.Target != 0.1 {
+				t.Errorf("buffer-out = %d, buffer-result = %d, adaptive-buffer = %v, want 4, -1 и 0.1",
+					c.cfg.OutBufferSize, c.cfg.ResultBufferSize, c.cfg.AdaptiveBuffer.Target)
+			}
+		}, false},
+		{"повторы", []string{"-retry", "3", "-retry-backoff", "5ms", "-retry-jitter", "0", "-dead-letters"}, "run", nil, func(t *testing.T, cmd command) {
+			c := cmd.(*runCmd)
+			r := c.cfg.Retry
+			if r.Attempts != 3 || r.Backoff != 5*time.Millisecond || r.MaxBackoff != time.Second || r.Jitter != 0 || !c.deadLetters {
+				t.Errorf("retry = %+v, dead-letters = %v, want 3 попытки, 5ms, 1s, 0 и true", r, c.deadLetters)
+			}
+		}, false},
+		{"зависания", []string{"-stall", "2s", "-stall-cancel", "-item-timeout", "5s", "-leak-timeout", "3s"}, "run", nil, func(t *testing.T, cmd command) {
+			c := cmd.(*runCmd)
+			if w := c.cfg.Watchdog; w.Stall != 2*time.Second || !w.Cancel {
+				t.Errorf("watchdog = %+v, want 2s и отмену", w)
+			}
+			if c.cfg.ItemTimeout != 5*time.Second || c.cfg.LeakTimeout != 3*time.Second {
+				t.Errorf("item-timeout = %v, leak-timeout = %v, want 5s и 3s", c.cfg.ItemTimeout, c.cfg.LeakTimeout)
+			}
+		}, false},
+		{"подтверждение", []string{"-ack"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); !c.cfg.Ack {
+				t.Error("ack = false, want true")
+			}
+		}, false},
+		{"файл необработанных", []string{"-dead-letter-file", "dead.jsonl"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.deadFile != "dead.jsonl" {
+				t.Errorf("dead-letter-file = %q, want dead.jsonl", c.deadFile)
+			}
+		}, false},
+		{"очередь на диске", []string{"-spill-dir", "spill", "-spill-memory", "16", "-spill-max-bytes", "4096"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.spill != (queue.Options{Dir: "spill", MemoryRecords: 16, MaxDiskBytes: 4096}) {
+				t.Errorf("spill = %+v, want spill, 16 и 4096", c.spill)
+			}
+		}, false},
+		{"продолжение", []string{"-checkpoint", "state.json", "-checkpoint-interval", "5s", "-resume"}, "run", nil, func(t *testing.T, cmd command) {
+			c := cmd.(*runCmd)
+			if c.cfg.Checkpoint != (pipeline.CheckpointPolicy{Path: "state.json", Interval: 5 * time.Second}) || !c.resume {
+				t.Errorf("checkpoint = %+v, resume = %v, want state.json, 5s и true", c.cfg.Checkpoint, c.resume)
+			}
+		}, false},
+		{"ограничение значений", []string{"-max-value", "50"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.cfg.MaxValue != 50 {
+				t.Errorf("max-value = %d, want 50", c.cfg.MaxValue)
+			}
+		}, false},
+		{"ограничение частоты", []string{"-rate", "100", "-burst", "5"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.cfg.Rate != 100 || c.cfg.Burst != 5 {
+				t.Errorf("rate = %v, burst = %d, want 100 и 5", c.cfg.Rate, c.cfg.Burst)
+			}
+		}, false},
+		{"обработка", []string{"-transform", "even"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.transform != "even" {
+				t.Errorf("transform = %q, want even", c.transform)
+			}
+		}, false},
+		{"раздача", []string{"-distribute", "round-robin"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.cfg.Distributor == nil {
+				t.Error("distributor = nil, want round-robin")
+			}
+		}, false},
+		{"приоритеты", []string{"-priority-weights", "4,1"}, "run", nil, func(t *testing.T, cmd command) {
+			p := cmd.(*runCmd).cfg.Priority
+			if !slices.Equal(p.Weights, []int{4, 1}) || p.Classify(3) != 1 || p.Classify(-4) != 0 {
+				t.Errorf("Priority = %+v, want веса 4,1 и класс по чётности", p)
+			}
+		}, false},
+		{"подавление повторов", []string{"-dedup-window", "100", "-dedup-bloom"}, "run", nil, func(t *testing.T, cmd command) {
+			d := cmd.(*runCmd).cfg.Dedup
+			if d.Window != 100 || !d.Bloom || d.FalsePositive != pipeline.DefaultDedupFalsePositive {
+				t.Errorf("Dedup = %+v, want окно 100 в фильтрах Блума", d)
+			}
+		}, false},
+		{"масштабирование", []string{"-workers", "2", "-min-workers", "1", "-max-workers", "8"}, "run", nil, func(t *testing.T, cmd command) {
+			if a := cmd.(*runCmd).cfg.Autoscale; a.MinWorkers != 1 || a.MaxWorkers != 8 {
+				t.Errorf("Autoscale = %+v, want пределы 1 и 8", a)
+			}
+		}, false},
+		{"пачки", []string{"-batch", "64", "-linger", "5ms"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.batch != 64 || c.linger != 5*time.Millisecond {
+				t.Errorf("batch = %d, linger = %v, want 64 и 5ms", c.batch, c.linger)
+			}
+		}, false},
+		{"серверы", []string{"-metrics-addr", ":2112", "-debug-addr", ":6060", "-pprof", ":6061"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.metricsAddr != ":2112" || c.debugAddr != ":6060" || c.pprofAddr != ":6061" {
+				t.Errorf("адреса = %q, %q, %q, want :2112, :6060 и :6061", c.metricsAddr, c.debugAddr, c.pprofAddr)
+			}
+		}, false},
+		{"selftest", []string{"selftest", "-runs", "5", "-workers", "2"}, "selftest", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*selftestCmd); c.runs != 5 || c.workers != 2 || c.limit != 10000 {
+				t.Errorf("selftest = %+v, want runs 5, workers 2 и limit 10000", c)
+			}
+		}, false},
+		{"replay", []string{"replay", "run.json"}, "replay", []string{"run.json"}, func(t *testing.T, cmd command) {
+			if _, ok := cmd.(*replayCmd); !ok {
+				t.Errorf("команда %T, want *replayCmd", cmd)
+			}
+		}, false},
+		{"bench", []string{"bench", "-workers", "1,4", "-duration", "10ms"}, "bench", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*benchCmd); !slices.Equal(c.workers, []int{1, 4}) || c.duration != 10*time.Millisecond {
+				t.Errorf("bench = %+v, want workers [1 4] и duration 10ms", c)
+			}
+		}, false},
+		{"bench по умолчанию", []string{"bench"}, "bench", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*benchCmd); !slices.Equal(c.workers, []int{1, 2, 5, 10}) || !slices.Equal(c.buffers, []int{0, 64, 1024}) ||
+				!slices.Equal(c.batches, []int{0, 64}) || len(c.distributes) != 4 || c.delay != 0 {
+				t.Errorf("bench = %+v", c)
+			}
+		}, false},
+		{"приёмник", []string{"-sink", "file", "-sink-file", "out.txt"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.sink != "file" || c.sinkFile != "out.txt" {
+				t.Errorf("sink = %q, sink-file = %q, want file и out.txt", c.sink, c.sinkFile)
+			}
+		}, false},
+		{"HTTP", []string{"-source", "http", "-http-addr", ":9000", "-sink", "http", "-sink-url", "http://localhost:9001/values", "-sink-batch", "10"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); c.source.addr != ":9000" || c.sinkURL != "http://localhost:9001/values" || c.sinkBatch != 10 || c.sinkRetry != 3 || c.grpcAddr != ":9090" {
+				t.Errorf("http-addr = %q, sink-url = %q, sink-batch = %d, sink-retry = %d", c.source.addr, c.sinkURL, c.sinkBatch, c.sinkRetry)
+			}
+		}, false},
+		{"фильтр оболочки", []string{"-source", "stdin", "-sink", "stdout", "-line-buffered"}, "run", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*runCmd); !c.lineBuffered || c.timeoutSet {
+				t.Errorf("line-buffered = %v, timeout задан = %v, want true и false", c.lineBuffered, c.timeoutSet)
+			}
+		}, false},
+		{"хаос", []string{"-chaos-error", "0.1", "-chaos-delay-prob", "0.5", "-chaos-delay", "1ms-3ms", "-chaos-seed", "7"}, "run", nil, func(t *testing.T, cmd command) {
+			if ch := cmd.(*runCmd).cfg.Chaos; ch.ErrorProbability != 0.1 || ch.DelayProbability != 0.5 || ch.Delay == nil || ch.Seed != 7 {
+				t.Errorf("Chaos = %+v", ch)
+			}
+		}, false},
+		{"некорректный таймаут", []string{"-timeout", "секунда"}, "", nil, nil, true},
+		{"замедление обработчиков", []string{"-worker-delay-factors", "5, 1,2.5"}, "run", nil, func(t *testing.T, cmd command) {
+			if f := cmd.(*runCmd).cfg.WorkerDelayFactors; !slices.Equal(f, []float64{5, 1, 2.5}) {
+				t.Errorf("WorkerDelayFactors = %v, want [5 1 2.5]", f)
+			}
+		}, false},
+		{"некорректное замедление", []string{"-worker-delay-factors", "5,x"}, "", nil, nil, true},
+		{"некорректные задержки хаоса", []string{"-chaos-delay", "часто"}, "", nil, nil, true},
+		{"verify по умолчанию", []string{"verify", "rec.jsonl"}, "verify", []string{"rec.jsonl"}, func(t *testing.T, cmd command) {
+			if c := cmd.(*verifyCmd); !c.cfg.VerifySequence || !c.cfg.Ack || c.set("ack") {
+				t.Errorf("verify-seq = %v, ack = %v, ack задан = %v, want true, true и false", c.cfg.VerifySequence, c.cfg.Ack, c.set("ack"))
+			}
+		}, false},
+		{"serve по умолчанию", []string{"serve", "-source", "grpc"}, "serve", nil, func(t *testing.T, cmd command) {
+			if c := cmd.(*serveCmd); c.source.name != "grpc" || c.cfg.Timeout != 0 || c.timeoutSet {
+				t.Errorf("source = %q, timeout = %v, timeout задан = %v, want grpc, 0 и false", c.source.name, c.cfg.Timeout, c.timeoutSet)
+			}
+		}, false},
+		{"неизвестная команда", []string{"deploy"}, "", nil, nil, true},
+		{"чужой флаг", []string{"run", "-runs", "2"}, "", nil, nil, true},
+		{"некорректная раздача", []string{"-distribute", "random"}, "", nil, nil, true},
+		{"некорректная дообработка", []string{"-drain", "всё"}, "", nil, nil, true},
+		{"некорректные веса приоритета", []string{"-priority-weights", "4,0"}, "", nil, nil, true},
+		{"некорректный список", []string{"bench", "-workers", "1,x"}, "", nil, nil, true},
+		{"некорректная раздача для bench", []string{"bench", "-distribute", "shared,random"}, "", nil, nil, true},
+		{"отрицательный буфер для bench", []string{"bench", "-buffers", "-1"}, "", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, cmd, args, err := parseCommand(tt.args, io.Discard)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCommand = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if name != tt.wantName || !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("parseCommand = %q, %q, want %q и %q", name, args, tt.wantName, tt.wantArgs)
+			}
+			tt.check(t, cmd)
+		})
+	}
+
+	var help bytes.Buffer
+	if _, _, _, err := parseCommand([]string{"selftest", "-h"}, &help); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("parseCommand(-h) = %v, want flag.ErrHelp", err)
+	}
+	if !strings.Contains(help.String(), "replay") || !strings.Contains(help.String(), "-runs") {
+		t.Errorf("справка selftest без списка команд или флагов:\n%s", help.String())
+	}
+
+	help.Reset()
+	if _, _, _, err := parseCommand([]string{"help", "serve"}, &help); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("parseCommand(help serve) = %v, want flag.ErrHelp", err)
+	}
+	if !strings.Contains(help.String(), "SIGINT") || !strings.Contains(help.String(), `(default "http")`) {
+		t.Errorf("справка serve без описания или значения -source по умолчанию:\n%s", help.String())
+	}
+	if _, _, _, err := parseCommand([]string{"help", "deploy"}, io.Discard); err == nil || errors.Is(err, flag.ErrHelp) {
+		t.Errorf("parseCommand(help deploy) = %v, want ошибку неизвестной команды", err)
+	}
+}
+
+// TestSaveReplay проверяет, что запуск, сохранённый run -save,
+// повторяется командой replay, а испорченный итог обнаруживается.
+func TestSaveReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := (&runCmd{cfg: pipeline.Config{NumWorkers: 5, Limit: 100}, source: sourceFlags{name: "random", seed: 3, max: 1000}, transform: "none", output: "text", logFormat: "text", save: path}).run(io.Discard, nil); err != nil {
+		t.Fatalf("run -save = %v", err)
+	}
+	if err := (&replayCmd{}).run(io.Discard, []string{path}); err != nil {
+		t.Errorf("replay = %v", err)
+	}
+
+	r, err := loadRun(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.OutputSum++
+	if err := saveRun(path, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&replayCmd{}).run(io.Discard, []string{path}); err == nil || !strings.Contains(err.Error(), "расходится") {
+		t.Errorf("replay испорченного итога = %v, want расхождение", err)
+	}
+
+	if err := (&runCmd{cfg: pipeline.DefaultConfig(), source: sourceFlags{name: "seq"}, transform: "none", save: path}).run(io.Discard, nil); err == nil {
+		t.Error("run -save без -limit без ошибки")
+	}
+	if err := (&runCmd{cfg: pipeline.Config{NumWorkers: 1, Limit: 10}, source: sourceFlags{name: "stdin"}, transform: "none", save: path}).run(io.Discard, nil); err == nil {
+		t.Error("run -save с -source stdin без ошибки")
+	}
+	if err := (&runCmd{cfg: pipeline.Config{NumWorkers: 1, Limit: 10}, source: sourceFlags{name: "http"}, transform: "none", save: path}).run(io.Discard, nil); err == nil {
+		t.Error("run -save с -source http без ошибки")
+	}
+	if err := (&runCmd{cfg: pipeline.Config{NumWorkers: 1, Limit: 10}, source: sourceFlags{name: "seq,fib"}, transform: "none", save: path}).run(io.Discard, nil); err == nil {
+		t.Error("run -save с несколькими источниками без ошибки")
+	}
+}
+
+// TestRunFileSource проверяет, что run читает числа из файла -input и
+// сообщает об ошибке разбора.
+func TestRunFileSource(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.txt")
+	bad := filepath.Join(dir, "bad.txt")
+	if err := os.WriteFile(good, []byte("1\n2\n3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("1 два 3"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	c := &runCmd{cfg: pipeline.Config{NumWorkers: 2}, source: sourceFlags{name: "file", input: good}, transform: "none", output: "text", logFormat: "text"}
+	if err := c.run(&out, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	if !strings.Contains(out.String(), "Сумма чисел 6 6") {
+		t.Errorf("отчёт без суммы 6:\n%s", out.String())
+	}
+	for _, line := range []string{"Производительность", "p50", "p95"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("отчёт без %q:\n%s", line, out.String())
+		}
+	}
+
+	c.source.input = bad
+	if err := c.run(io.Discard, nil); err == nil || !strings.Contains(err.Error(), "число 2") {
+		t.Errorf("run с испорченным файлом = %v, want ошибку числа 2", err)
+	}
+
+	// несколько файлов читаются одновременно
+	out.Reset()
+	c.source = sourceFlags{name: "file,file", input: good + "," + good}
+	if err := c.run(&out, nil); err != nil {
+		t.Fatalf("run с двумя файлами = %v", err)
+	}
+	for _, line := range []string{"Сумма чисел 12 12", "Разбивка по источникам [3 3]"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("отчёт без %q:\n%s", line, out.String())
+		}
+	}
+	c.source = sourceFlags{name: "file,file", input: good + "," + bad}
+	if err := c.run(io.Discard, nil); err == nil || !strings.Contains(err.Error(), "число 2") {
+		t.Errorf("run со вторым испорченным файлом = %v, want ошибку числа 2", err)
+	}
+}
+
+// TestRunSink проверяет, что -sink file записывает числа результирующего
+// канала в -sink-file, а -sink stdout — в вывод без отчёта; файлы,
+// начатые по -rotate-size, перечисляются в отчёте.
+func TestRunSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	c := &runCmd{cfg: pipeline.Config{NumWorkers: 3, Limit: 20}, source: sourceFlags{name: "seq"}, sink: "file", sinkFile: path, transform: "none", output: "text", logFormat: "text"}
+	if err := c.run(io.Discard, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	src := pipeline.NewReaderSource(f)
+	var got []int64
+	for v, ok := src.Next(context.Background()); ok; v, ok = src.Next(context.Background()) {
+		got = append(got, v)
+	}
+	slices.Sort(got)
+	if src.Err() != nil || len(got) != 20 || got[0] != 1 || got[19] != 20 {
+		t.Errorf("в файле %v, ошибка %v, want числа от 1 до 20", got, src.Err())
+	}
+
+	var out bytes.Buffer
+	c = &runCmd{cfg: pipeline.Config{NumWorkers: 1, Limit: 3}, source: sourceFlags{name: "seq"}, sink: "stdout", transform: "none", output: "text", logFormat: "text"}
+	if err := c.run(&out, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	if out.String() != "1\n2\n3\n" {
+		t.Errorf("вывод %q, want только числа: отчёт выводится в stderr", out.String())
+	}
+
+	// со сменой файлов их список выводится в отчёте
+	out.Reset()
+	c.sink, c.sinkFile, c.rotate = "file", path, pipeline.RotationPolicy{MaxBytes: 4, Compress: true}
+	if err := c.run(&out, nil); err != nil {
+		t.Fatalf("run со сменой файлов = %v", err)
+	}
+	if !strings.Contains(out.String(), "Файлы результатов ["+path+".000001.gz") {
+		t.Errorf("отчёт без файлов результатов:\n%s", out.String())
+	}
+
+	c.sinkFile = ""
+	if err := c.run(io.Discard, nil); err == nil {
+		t.Error("-sink file без -sink-file без ошибки")
+	}
+	c.sink = "http"
+	if err := c.run(io.Discard, nil); err == nil {
+		t.Error("-sink http без -sink-url без ошибки")
+	}
+	// без подписчиков Consume числа -sink grpc отбрасываются
+	c.sink, c.grpcAddr = "grpc", "127.0.0.1:0"
+	if err := c.run(io.Discard, nil); err != nil {
+		t.Errorf("run с -sink grpc = %v", err)
+	}
+	c.sink = "sql"
+	if err := c.run(io.Discard, nil); err == nil {
+		t.Error("-sink sql без -sql-dsn без ошибки")
+	}
+	// драйвер postgres подключается только сборкой с тегом postgres
+	c.sqlDriver, c.sqlDSN = "postgres", "postgres://localhost/runs"
+	if err := c.run(io.Discard, nil); err == nil || !strings.Contains(err.Error(), "-tags postgres") {
+		t.Errorf("run без драйвера базы данных = %v, want подсказку о теге сборки", err)
+	}
+	c.sqlDSN = ""
+	c.sink = "kafka"
+	if err := c.run(io.Discard, nil); err == nil {
+		t.Error("run с неизвестным приёмником без ошибки")
+	}
+}
+
+// TestSinkConcurrencyList проверяет, что -sink-concurrency принимается со
+// списком приёмников, в котором есть http, и что числа получают все
+// приёмники списка.
+func TestSinkConcurrencyList(t *testing.T) {
+	var sent atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []int64
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sent.Add(int64(len(batch)))
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "out.txt")
+	c := parseRun(t, "-limit", "100", "-sink", "http,file", "-sink-url", srv.URL, "-sink-file", path, "-sink-concurrency", "2")
+	if err := c.run(io.Discard, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); sent.Load() != 100 || lines != 100 {
+		t.Errorf("отправлено по HTTP %d чисел, в файле %d, want по 100", sent.Load(), lines)
+	}
+}
+
+// TestSourceHTTP проверяет, что -source http запускает сервер и не
+// задаётся дважды, а -source grpc требует gRPC-сервер.
+func TestSourceHTTP(t *testing.T) {
+	srcs, _, closeSrc, err := sourceFlags{name: "http", addr: "127.0.0.1:0"}.open()
+	if err != nil {
+		t.Fatalf("open = %v", err)
+	}
+	if err := closeSrc(); err != nil {
+		t.Errorf("close = %v", err)
+	}
+	if _, ok := srcs[0].Next(context.Background()); ok {
+		t.Error("Next после close вернул число")
+	}
+	if _, _, _, err := (sourceFlags{name: "http,http", addr: "127.0.0.1:0"}).open(); err == nil {
+		t.Error("open с двумя http без ошибки")
+	}
+	if _, _, _, err := (sourceFlags{name: "grpc"}).open(); err == nil {
+		t.Error("open с grpc без gRPC-сервера без ошибки")
+	}
+}
+
+func TestReplayable(t *testing.T) {
+	for name, want := range map[string]bool{
+		"seq": true, "random": true, "fib": true, "primes": true,
+		"stdin": false, "file": false, "http": false, "grpc": false,
+		"nats": false, "kafka": false, "seq,fib": false,
+	} {
+		if got := (sourceFlags{name: name}).replayable(); got != want {
+			t.Errorf("replayable(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// TestRunResume проверяет, что -resume продолжает генерацию с состояния
+// -checkpoint, а без -checkpoint отклоняется.
+func TestRunResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c := &runCmd{
+		cfg:    pipeline.Config{NumWorkers: 2, Limit: 10, Checkpoint: pipeline.CheckpointPolicy{Path: path}},
+		source: sourceFlags{name: "seq"}, transform: "none", output: "json", logFormat: "text", resume: true,
+	}
+	// файла состояния ещё нет: генерация начинается с начала
+	if err := c.run(io.Discard, nil); err != nil {
+		t.Fatalf("первый запуск = %v", err)
+	}
+	c.cfg.Limit = 15
+	var out bytes.Buffer
+	if err := c.run(&out, nil); err != nil {
+		t.Fatalf("продолжение = %v", err)
+	}
+	var r report
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.InputCount != 5 || r.InputSum != 11+12+13+14+15 {
+		t.Errorf("продолжение: %d чисел с суммой %d, want 5 и 65", r.InputCount, r.InputSum)
+	}
+
+	c.cfg.Checkpoint.Path = ""
+	if err := c.run(io.Discard, nil); err == nil {
+		t.Error("-resume без -checkpoint не вернул ошибку")
+	}
+}
+
+// TestRunTransform проверяет обработку -transform: отфильтрованные числа
+// попадают в отчёт, а запуск с обработкой повторяется командой replay.
+func TestRunTransform(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("1 2 3 4"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	c := &runCmd{cfg: pipeline.Config{NumWorkers: 2}, source: sourceFlags{name: "file", input: input}, transform: "even", output: "text", logFormat: "text"}
+	if err := c.run(&out, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	if !strings.Contains(out.String(), "Сумма чисел 10 6") || !strings.Contains(out.String(), "Отфильтровано чисел 2") {
+		t.Errorf("отчёт без сумм 10 и 6 или без отфильтрованных чисел:\n%s", out.String())
+	}
+
+	c.transform = "cube"
+	if err := c.run(io.Discard, nil); err == nil {
+		t.Error("run с неизвестной обработкой без ошибки")
+	}
+
+	path := filepath.Join(dir, "run.json")
+	c = &runCmd{cfg: pipeline.Config{NumWorkers: 3, Limit: 50}, source: sourceFlags{name: "seq"}, transform: "square", output: "text", logFormat: "text", save: path}
+	if err := c.run(io.Discard, nil); err != nil {
+		t.Fatalf("run -save = %v", err)
+	}
+	if err := (&replayCmd{}).run(io.Discard, []string{path}); err != nil {
+		t.Errorf("replay = %v", err)
+	}
+}
+
+// TestNewLogger проверяет форматы журнала -log-format.
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"text", "msg=проверка workers=2", false},
+		{"json", `"msg":"проверка","workers":2`, false},
+		{"xml", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := newLogger(&buf, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLogger = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			logger.Info("проверка", "workers", 2)
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("журнал %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLogSnapshot(t *testing.T) {
+	p := pipeline.New(pipeline.Config{NumWorkers: 2, Limit: 10})
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logSnapshot(logger, p, os.Interrupt)
+	for _, want := range []string{"снимок статистики", "count.input=10", "sum.output=55", "inFlight=0", "last=10", "queues.result=0", "goroutines="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("в журнале нет %q: %s", want, buf.String())
+		}
+	}
+}
+
+// TestRunRecordReplay проверяет, что -replay повторяет числа и количество
+// обработчиков запуска, записанного -record.
+func TestRunRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	rec := filepath.Join(dir, "rec.jsonl")
+	if err := parseRun(t, "-workers", "4", "-limit", "50", "-source", "random", "-record", rec, "-big-sums", "-output", "json").run(io.Discard, nil); err != nil {
+		t.Fatalf("run с -record = %v", err)
+	}
+	orig, err := pipeline.LoadReplay(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again := filepath.Join(dir, "again.jsonl")
+	var out bytes.Buffer
+	if err := parseRun(t, "-replay", rec, "-record", again, "-big-sums", "-output", "json").run(&out, nil); err != nil {
+		t.Fatalf("run с -replay = %v", err)
+	}
+	got, err := pipeline.LoadReplay(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Workers != 4 || !slices.Equal(got.Values, orig.Values) || !slices.Equal(got.Assign, orig.Assign) {
+		t.Errorf("воспроизведение: обработчиков %d, числа и назначения совпадают: %v, %v", got.Workers, slices.Equal(got.Values, orig.Values), slices.Equal(got.Assign, orig.Assign))
+	}
+
+	for _, args := range [][]string{
+		{"-replay", rec, "-distribute", "round-robin"},
+		{"-replay", rec, "-priority-weights", "2,1"},
+		{"-replay", rec, "-limit", "10", "-save", filepath.Join(dir, "run.json")},
+		{"-replay", filepath.Join(dir, "нет.jsonl")},
+	} {
+		if err := parseRun(t, args...).run(io.Discard, nil); err == nil {
+			t.Errorf("run %q без ошибки", args)
+		}
+	}
+}
+
+// TestVerifyServe проверяет, что verify воспроизводит запуск, записанный
+// -record, с проверкой номеров и подтверждений, а verify и serve отвергают
+// настройки, с которыми не работают.
+func TestVerifyServe(t *testing.T) {
+	rec := filepath.Join(t.TempDir(), "rec.jsonl")
+	if err := parseRun(t, "-workers", "3", "-limit", "40", "-record", rec).run(io.Discard, nil); err != nil {
+		t.Fatalf("run с -record = %v", err)
+	}
+	var out bytes.Buffer
+	_, cmd, args, err := parseCommand([]string{"verify", "-output", "json", rec}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.run(&out, args); err != nil {
+		t.Fatalf("verify = %v", err)
+	}
+	var r report
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Verified || r.OutputCount != 40 {
+		t.Errorf("verify: проверка %v, дошло %d, want true и 40", r.Verified, r.OutputCount)
+	}
+
+	for _, args := range [][]string{
+		{"verify"},
+		{"verify", rec, rec},
+		{"verify", "-replay", rec, filepath.Join(t.TempDir(), "другой.jsonl")},
+		{"serve", "-source", "random"},
+		{"serve", "-source", "http,seq"},
+		{"serve", "-replay", rec},
+	} {
+		_, cmd, rest, err := parseCommand(args, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.run(io.Discard, rest); err == nil {
+			t.Errorf("%q без ошибки", args)
+		}
+	}
+}
+
+// TestBenchRun проверяет, что bench запускает каждое сочетание настроек,
+// кроме пачек с раздачей не через общий канал, и выводит строку таблицы на
+// каждый запуск.
+func TestBenchRun(t *testing.T) {
+	c := &benchCmd{
+		workers:     []int{1, 2},
+		buffers:     []int{0},
+		batches:     []int{0, 8},
+		distributes: []string{"shared", "round-robin"},
+		duration:    10 * time.Millisecond,
+		warmup:      5 * time.Millisecond,
+	}
+	var out bytes.Buffer
+	if err := c.run(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	// заголовок и по 3 запуска на количество обработчиков
+	if len(lines) != 7 || strings.Count(out.String(), "пройдена") != 6 || !strings.Contains(out.String(), "100%") {
+		t.Errorf("таблица:\n%s", out.String())
+	}
+}
+
+// TestRunWarmup проверяет, что -warmup выводит прогрев в отчёт.
+func TestRunWarmup(t *testing.T) {
+	var out bytes.Buffer
+	if err := parseRun(t, "-warmup", "20ms", "-timeout", "20ms", "-worker-delay", "0", "-output", "json").run(&out, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	var r report
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.WarmupSeconds < 0.02 || r.WarmupOutputCount == 0 || !r.Verified {
+		t.Errorf("отчёт %+v, want прогрев не меньше 20ms", r)
+	}
+	if err := parseRun(t, "-warmup", "-1s").run(io.Discard, nil); err == nil || !strings.Contains(err.Error(), "время прогрева") {
+		t.Errorf("run с отрицательным прогревом = %v", err)
+	}
+}
+
+// TestRunWindow проверяет, что с -window приёмник получает итоги окон, а
+// несовместимые с окнами приёмники отклоняются.
+func TestRunWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "windows.jsonl")
+	if err := parseRun(t, "-limit", "10", "-window", "5", "-sink", "file", "-sink-file", path, "-output", "json").run(io.Discard, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count, sum int64
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for _, line := range lines {
+		var a struct{ Count, Sum int64 }
+		if err := json.Unmarshal([]byte(line), &a); err != nil {
+			t.Fatal(err)
+		}
+		count += a.Count
+		sum += a.Sum
+	}
+	if len(lines) != 2 || count != 10 || sum != 55 {
+		t.Errorf("окон %d, в них %d чисел с суммой %d, want 2, 10 и 55", len(lines), count, sum)
+	}
+
+	for _, args := range [][]string{
+		{"-window", "5", "-sink", "discard"},
+		{"-window", "5", "-window-slide", "2", "-sink", "stdout"},
+		{"-window", "1s", "-sink", "file", "-sink-file", path, "-rotate-size", "100"},
+		{"-sink-concurrency", "2", "-sink", "stdout"},
+	} {
+		if err := parseRun(t, args...).run(io.Discard, nil); err == nil {
+			t.Errorf("run %q без ошибки", args)
+		}
+	}
+}
+
+func TestParsePipelines(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []pipelineSpec
+		wantErr bool
+	}{
+		{"настройки", []string{"fast:workers=8,buffer=64", "slow"}, []pipelineSpec{
+			{"fast", map[string]string{"workers": "8", "buffer": "64"}},
+			{"slow", map[string]string{}},
+		}, false},
+		{"обработка", []string{"sq:transform=square"}, []pipelineSpec{
+			{"sq", map[string]string{"transform": "square"}},
+		}, false},
+		{"без имени", []string{":workers=2"}, nil, true},
+		{"повтор имени", []string{"a", "a:workers=2"}, nil, true},
+		{"неизвестная настройка", []string{"a:source=stdin"}, nil, true},
+		{"без значения", []string{"a:workers"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePipelines(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePipelines = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePipelines = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSuperviseRun проверяет, что supervise запускает каждый конвейер со
+// своими настройками и выводит строку итогов каждого и сводку.
+func TestSuperviseRun(t *testing.T) {
+	_, cmd, args, err := parseCommand([]string{"supervise", "-limit", "100", "-worker-delay", "0", "a:workers=2", "b:workers=3,limit=50,distribute=round-robin"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := cmd.run(&out, args); err != nil {
+		t.Fatalf("run = %v\n%s", err, out.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || strings.Count(out.String(), "пройдена") != 2 {
+		t.Fatalf("таблица:\n%s", out.String())
+	}
+	for i, want := range []string{"a 2 100", "b 3 50", "всего 5 150"} {
+		if got := strings.Join(strings.Fields(lines[i+1])[:3], " "); got != want {
+			t.Errorf("строка начинается с %q, want %q", got, want)
+		}
+	}
+
+	for _, args := range [][]string{nil, {"a:workers=x"}, {"a:transform=cube"}, {"a:workers=0"}} {
+		if err := (&superviseCmd{cfg: pipeline.DefaultConfig(), distribute: "shared", transform: "none", logFormat: "text"}).run(io.Discard, args); err == nil {
+			t.Errorf("run(%q) = nil, want ошибку", args)
+		}
+	}
+}
+
+// TestRunReduce проверяет, что итоги свёрток -reduce выводятся в отчёте, а
+// некорректный список свёрток отклоняется.
+func TestRunReduce(t *testing.T) {
+	var out bytes.Buffer
+	if err := parseRun(t, "-limit", "100", "-worker-delay", "0", "-reduce", "count,minmax,top,sample", "-reduce-top", "2", "-output", "json").run(&out, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	var r struct {
+		Reduced map[string]json.RawMessage `json:"reduced"`
+		Sample  []int64                    `json:"sample"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"count": "100", "minmax": `{"min":1,"max":100}`, "top": "[100,99]"}
+	for name, v := range want {
+		if string(r.Reduced[name]) != v {
+			t.Errorf("свёртка %s = %s, want %s", name, r.Reduced[name], v)
+		}
+	}
+	if _, ok := r.Reduced["sample"]; ok || len(r.Sample) != 2 {
+		t.Errorf("выборка %v, свёртки %v, want 2 числа в sample", r.Sample, r.Reduced)
+	}
+
+	for _, args := range [][]string{
+		{"-reduce", "median"},
+		{"-reduce", "sum,sum"},
+		{"-reduce", "sample,sample"},
+		{"-reduce", "sample", "-reduce-top", "0"},
+		{"-reduce", "hist", "-reduce-bounds", "10,x"},
+		{"-reduce", "top", "-reduce-top", "0"},
+	} {
+		if err := parseRun(t, append([]string{"-limit", "10"}, args...)...).run(io.Discard, nil); err == nil {
+			t.Errorf("run %q без ошибки", args)
+		}
+	}
+}
+
+// TestRunBroadcast проверяет, что несколько приёмников -sink получают все
+// числа, а отчёт выводит статистику каждого.
+func TestRunBroadcast(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.txt")
+	var out bytes.Buffer
+	if err := parseRun(t, "-limit", "50", "-worker-delay", "0", "-sink", "stdout,file", "-sink-file", path, "-sink-slow", "block", "-output", "json").run(&out, nil); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 50 || strings.Count(out.String(), "\n") != 50 {
+		t.Errorf("в файле %d чисел, в stdout %d строк, want по 50", n, strings.Count(out.String(), "\n"))
+	}
+
+	for _, sink := range []string{"stdout,stdout", "stdout,nowhere"} {
+		if err := parseRun(t, "-limit", "10", "-sink", sink).run(io.Discard, nil); err == nil {
+			t.Errorf("run -sink %s без ошибки", sink)
+		}
+	}
+	if _, _, _, err := parseCommand([]string{"run", "-sink-slow", "wait"}, io.Discard); err == nil {
+		t.Error("-sink-slow wait разобран без ошибки")
+	}
+}
@@ -0,0 +1,451 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+// report — итоговый отчёт программы со стабильной схемой для скриптов и CI.
+type report struct {
+	InputCount      int64   `json:"inputCount"`
+	InputSum        int64   `json:"inputSum"`
+	OutputCount     int64   `json:"outputCount"`
+	OutputSum       int64   `json:"outputSum"`
+	PerWorker       []int64 `json:"perWorker"`
+	PerSource       []int64 `json:"perSource,omitempty"`
+	DroppedCount    int64   `json:"droppedCount"`
+	SkippedCount    int64   `json:"skippedCount"`
+	FailedCount     int64   `json:"failedCount"`
+	Retries         []int64 `json:"retries"`
+	Stalls          []int64 `json:"stalls"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Throughput      float64 `json:"throughput"`
+	// время ожидания отправки: генератора в chIn и каждого обработчика в
+	// outs[i]
+	GeneratorBlockedSeconds float64   `json:"generatorBlockedSeconds"`
+	WorkerBlockedSeconds    []float64 `json:"workerBlockedSeconds"`
+	Drain                   string    `json:"drain"`
+	StopCause               string    `json:"stopCause,omitempty"`
+	Files                   []string  `json:"files,omitempty"`
+	Verified                bool      `json:"verified"`
+	Error                   string    `json:"error,omitempty"`
+	// SumOverflow — суммы переполнили int64, inputSum и outputSum даны по
+	// модулю 2^64
+	SumOverflow bool `json:"sumOverflow,omitempty"`
+	// BigSums — точные суммы десятичными строками при -big-sums
+	BigSums *bigSumsReport `json:"bigSums,omitempty"`
+	// контрольные суммы сгенерированных чисел и чисел результирующего
+	// канала шестнадцатеричными строками
+	InputChecksum  string `json:"inputChecksum"`
+	OutputChecksum string `json:"outputChecksum"`
+	// по обработчикам: взятые в обработку числа, ошибки обработки, время
+	// обработки, ожидания чисел и ожидания отправки, загрузка от 0 до 1
+	WorkerItems          []int64   `json:"workerItems"`
+	WorkerErrors         []int64   `json:"workerErrors"`
+	WorkerBusySeconds    []float64 `json:"workerBusySeconds"`
+	WorkerIdleSeconds    []float64 `json:"workerIdleSeconds"`
+	WorkerSendingSeconds []float64 `json:"workerSendingSeconds"`
+	WorkerUtilization    []float64 `json:"workerUtilization"`
+	// по классам приоритета при -priority-weights: сгенерированные числа,
+	// числа результирующего канала и задержка p99 в секундах
+	PerPriority        []int64   `json:"perPriority,omitempty"`
+	PriorityOut        []int64   `json:"priorityOut,omitempty"`
+	PriorityP99Seconds []float64 `json:"priorityP99Seconds,omitempty"`
+	// Duplicates — повторы, подавленные -dedup-window
+	Duplicates int64 `json:"duplicates"`
+	// SinkBlockedSeconds — ожидание приёмниками семафора -sink-concurrency
+	SinkBlockedSeconds float64 `json:"sinkBlockedSeconds"`
+	// Reduced — итоги свёрток -reduce по именам
+	Reduced map[string]any `json:"reduced,omitempty"`
+	// Sample — случайная выборка -reduce sample
+	Sample []int64 `json:"sample,omitempty"`
+	// Sinks — статистика каждого из нескольких приёмников -sink
+	Sinks []sinkReport `json:"sinks,omitempty"`
+	// прогрев -warmup: его время и числа, пришедшие за него в
+	// результирующий канал; они не входят в throughput
+	WarmupSeconds     float64 `json:"warmupSeconds,omitempty"`
+	WarmupOutputCount int64   `json:"warmupOutputCount,omitempty"`
+	// нарушения возрастания -check-monotonic и пропуски -detect-gaps
+	MonotonicViolations int64 `json:"monotonicViolations,omitempty"`
+	Gaps                int64 `json:"gaps,omitempty"`
+	// скользящее среднее -trend-alpha после последнего числа
+	Trend *float64 `json:"trend,omitempty"`
+
+	res pipeline.Result // исходный результат для текстового отчёта
+}
+
+// bigSumsReport — точные суммы отчёта. Они записаны строками, потому что
+// могут не поместиться в числа JSON.
+type bigSumsReport struct {
+	Input   string `json:"input"`
+	Output  string `json:"output"`
+	Dropped string `json:"dropped"`
+	Skipped string `json:"skipped"`
+	Failed  string `json:"failed"`
+}
+
+// newReport собирает отчёт из результата res и ошибки его проверки verifyErr.
+func newReport(res pipeline.Result, verifyErr error) report {
+	r := report{
+		InputCount:              res.InputCount,
+		InputSum:                res.InputSum,
+		OutputCount:             res.OutputCount,
+		OutputSum:               res.OutputSum,
+		PerWorker:               res.PerWorker,
+		PerSource:               res.PerSource,
+		DroppedCount:            res.DroppedCount,
+		SkippedCount:            res.SkippedCount,
+		FailedCount:             res.FailedCount,
+		Retries:                 res.Retries,
+		Stalls:                  res.Stalls,
+		DurationSeconds:         res.Duration.Seconds(),
+		Throughput:              res.Throughput(),
+		GeneratorBlockedSeconds: res.GeneratorBlocked.Seconds(),
+		WorkerBlockedSeconds:    seconds(res.WorkerBlocked),
+		WorkerItems:             res.WorkerItems,
+		WorkerErrors:            res.WorkerErrors,
+		WorkerBusySeconds:       seconds(res.WorkerBusy),
+		WorkerIdleSeconds:       seconds(res.WorkerIdle),
+		WorkerSendingSeconds:    seconds(res.WorkerSending),
+		WorkerUtilization:       res.WorkerUtilization(),
+		Drain:                   res.Drain.String(),
+		Files:                   res.Files,
+		Verified:                verifyErr == nil,
+		SumOverflow:             res.SumOverflow,
+		Duplicates:              res.Duplicates,
+		SinkBlockedSeconds:      res.SinkBlocked.Seconds(),
+		Sample:                  res.Sample,
+		InputChecksum:           fmt.Sprintf("%016x", res.InputChecksum),
+		OutputChecksum:          fmt.Sprintf("%016x", res.OutputChecksum),
+		res:                     res,
+	}
+	if b := res.BigSums; b != nil {
+		r.BigSums = &bigSumsReport{
+			Input:   b.Input.String(),
+			Output:  b.Output.String(),
+			Dropped: b.Dropped.String(),
+			Skipped: b.Skipped.String(),
+			Failed:  b.Failed.String(),
+		}
+	}
+	if res.PerPriority != nil {
+		r.PerPriority, r.PriorityOut = res.PerPriority, res.PriorityOut
+		for _, l := range res.PriorityLatency {
+			r.PriorityP99Seconds = append(r.PriorityP99Seconds, l.P99.Seconds())
+		}
+	}
+	if res.StopCause != nil {
+		r.StopCause = res.StopCause.Error()
+	}
+	if w := res.Warmup; w != nil {
+		r.WarmupSeconds, r.WarmupOutputCount = w.Duration.Seconds(), w.Output
+	}
+	if o := res.Order; o != nil {
+		r.MonotonicViolations, r.Gaps = o.Violations, o.Gaps
+	}
+	if t := res.Trend; t != nil {
+		r.Trend = &t.Last
+	}
+	if verifyErr != nil {
+		r.Error = verifyErr.Error()
+	}
+	return r
+}
+
+// sinkReport — статистика одного из нескольких приёмников отчёта.
+type sinkReport struct {
+	Name           string  `json:"name"`
+	Written        int64   `json:"written"`
+	Dropped        int64   `json:"dropped"`
+	BlockedSeconds float64 `json:"blockedSeconds"`
+
+	blocked time.Duration // ожидание для текстового отчёта
+}
+
+// sinkReports собирает статистику приёмников stats для отчёта.
+func sinkReports(stats []pipeline.BroadcastStats) []sinkReport {
+	r := make([]sinkReport, len(stats))
+	for i, s := range stats {
+		r[i] = sinkReport{Name: s.Name, Written: s.Written, Dropped: s.Dropped, BlockedSeconds: s.Blocked.Seconds(), blocked: s.Blocked}
+	}
+	return r
+}
+
+// reductions сопоставляет итоги свёрток results их именам names; nil, если
+// свёрток нет.
+func reductions(names []string, results []any) map[string]any {
+	if len(names) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(names))
+	for i, name := range names {
+		m[name] = results[i]
+	}
+	return m
+}
+
+// seconds переводит длительности ds в секунды.
+func seconds(ds []time.Duration) []float64 {
+	s := make([]float64, len(ds))
+	for i, d := range ds {
+		s[i] = d.Seconds()
+	}
+	return s
+}
+
+// reportWriters — форматы отчёта для флага -output.
+var reportWriters = map[string]func(io.Writer, report) error{
+	"text": writeTextReport,
+	"json": writeJSONReport,
+	"csv":  writeCSVReport,
+}
+
+// writeTextReport выводит отчёт в исходном виде, удобном для чтения.
+func writeTextReport(w io.Writer, r report) error {
+	res := r.res
+	fmt.Fprintln(w, "Количество чисел", res.InputCount, res.OutputCount)
+	switch {
+	case res.BigSums != nil:
+		fmt.Fprintln(w, "Сумма чисел", res.BigSums.Input, res.BigSums.Output)
+	case res.SumOverflow:
+		fmt.Fprintln(w, "Сумма чисел по модулю 2^64", res.InputSum, res.OutputSum)
+	default:
+		fmt.Fprintln(w, "Сумма чисел", res.InputSum, res.OutputSum)
+	}
+	fmt.Fprintln(w, "Контрольная сумма", r.InputChecksum, r.OutputChecksum)
+	fmt.Fprintln(w, "Разбивка по каналам", res.PerWorker)
+	if res.PerSource != nil {
+		fmt.Fprintln(w, "Разбивка по источникам", res.PerSource)
+	}
+	if res.PerPriority != nil {
+		fmt.Fprintln(w, "Разбивка по классам приоритета", res.PerPriority, "дошло", res.PriorityOut)
+	}
+	if r.StopCause != "" {
+		fmt.Fprintln(w, "Причина остановки:", r.StopCause)
+	}
+	if res.Duplicates > 0 {
+		fmt.Fprintln(w, "Подавлено повторов", res.Duplicates)
+	}
+	if res.SkippedCount > 0 {
+		fmt.Fprintln(w, "Отфильтровано чисел", res.SkippedCount)
+	}
+	if res.DroppedCount > 0 {
+		fmt.Fprintln(w, "Отброшено чисел", res.DroppedCount, "политика", res.Drain)
+	}
+	if res.FailedCount > 0 {
+		fmt.Fprintln(w, "Не обработано чисел", res.FailedCount)
+	}
+	if anyPositive(res.Retries) {
+		fmt.Fprintln(w, "Повторы обработки", res.Retries)
+	}
+	if anyPositive(res.Stalls) {
+		fmt.Fprintln(w, "Зависания обработчиков", res.Stalls)
+	}
+	if len(res.Files) > 0 {
+		fmt.Fprintln(w, "Файлы результатов", res.Files)
+	}
+	if o := res.Order; o != nil {
+		fmt.Fprintln(w, "Порядок чисел: нарушений возрастания", o.Violations, "пропусков", o.Gaps)
+	}
+	if t := res.Trend; t != nil {
+		fmt.Fprintf(w, "Скользящее среднее %.2f\n", t.Last)
+	}
+	if wu := res.Warmup; wu != nil {
+		fmt.Fprintf(w, "Прогрев %v: сгенерировано %d, дошло %d — не учтены в производительности и задержке\n", wu.Duration.Round(time.Millisecond), wu.Generated, wu.Output)
+	}
+	fmt.Fprintf(w, "Производительность %.0f чисел/с, по каналам %.0f\n", res.Throughput(), res.WorkerThroughput())
+	lat := res.Latency
+	fmt.Fprintln(w, "Задержка min", lat.Min, "avg", lat.Mean, "max", lat.Max, "p50", lat.P50, "p95", lat.P95, "p99", lat.P99)
+	for i, l := range res.PriorityLatency {
+		fmt.Fprintln(w, "Задержка класса приоритета", i, "p50", l.P50, "p95", l.P95, "p99", l.P99)
+	}
+	fmt.Fprintln(w, "Ожидание отправки: генератор", res.GeneratorBlocked, "обработчики", res.WorkerBlocked)
+	if res.SinkBlocked > 0 {
+		fmt.Fprintln(w, "Ожидание одновременных отправок приёмника", res.SinkBlocked)
+	}
+	fmt.Fprintln(w, "Загрузка обработчиков", percents(r.WorkerUtilization))
+	if anyPositive(res.WorkerErrors) {
+		fmt.Fprintln(w, "Ошибки обработки", res.WorkerErrors)
+	}
+	for _, s := range r.Sinks {
+		fmt.Fprintf(w, "Приёмник %s: записано %d, отброшено %d, ожидание %v\n", s.Name, s.Written, s.Dropped, s.blocked)
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.Reduced)) {
+		b, err := json.Marshal(r.Reduced[name])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Свёртка %s %s\n", name, b)
+	}
+	if r.Sample != nil {
+		fmt.Fprintln(w, "Выборка", r.Sample)
+	}
+	_, err := fmt.Fprintln(w, "Проверка", verdict(r))
+	return err
+}
+
+// percents перечисляет доли fs в процентах.
+func percents(fs []float64) []string {
+	p := make([]string, len(fs))
+	for i, f := range fs {
+		p[i] = fmt.Sprintf("%.0f%%", 100*f)
+	}
+	return p
+}
+
+// anyPositive сообщает, есть ли среди ns положительные значения.
+func anyPositive(ns []int64) bool {
+	for _, n := range ns {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// verdict возвращает итог проверки для текстового отчёта.
+func verdict(r report) string {
+	if r.Verified {
+		return "пройдена"
+	}
+	return "не пройдена: " + r.Error
+}
+
+// writeJSONReport выводит отчёт одним JSON-объектом.
+func writeJSONReport(w io.Writer, r report) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// csvHeader — столбцы CSV-отчёта; perWorker, workerBlockedSeconds, retries и
+// stalls перечисляют значения по обработчикам через точку с запятой,
+// perSource — по источникам, files — файлы результатов, bigInputSum и
+// bigOutputSum — точные суммы при -big-sums, inputChecksum и
+// outputChecksum — контрольные суммы, а workerItems, workerErrors,
+// workerBusySeconds, workerIdleSeconds, workerSendingSeconds и
+// workerUtilization перечисляют значения по обработчикам через точку с
+// запятой, а perPriority, priorityOut и priorityP99Seconds — по классам
+// приоритета; duplicates — подавленные повторы, sinkBlockedSeconds —
+// ожидание семафора приёмников, reduced — итоги свёрток -reduce
+// JSON-объектом, sinkWritten и sinkDropped — записанные и отброшенные
+// числа каждого из нескольких приёмников -sink через точку с запятой, а
+// warmupSeconds и warmupOutputCount — время прогрева -warmup и числа,
+// пришедшие за него в результирующий канал; monotonicViolations и gaps —
+// нарушения возрастания -check-monotonic и пропуски -detect-gaps, trend —
+// скользящее среднее -trend-alpha, sample — выборка -reduce sample через
+// точку с запятой.
+var csvHeader = []string{
+	"inputCount", "inputSum", "outputCount", "outputSum", "perWorker",
+	"droppedCount", "skippedCount", "durationSeconds", "throughput",
+	"drain", "verified", "error",
+	"generatorBlockedSeconds", "workerBlockedSeconds",
+	"failedCount", "retries", "stopCause", "stalls", "perSource",
+	"files", "sumOverflow", "bigInputSum", "bigOutputSum",
+	"inputChecksum", "outputChecksum",
+	"workerItems", "workerErrors", "workerBusySeconds", "workerIdleSeconds",
+	"workerSendingSeconds", "workerUtilization",
+	"perPriority", "priorityOut", "priorityP99Seconds", "duplicates",
+	"sinkBlockedSeconds", "reduced", "sinkWritten", "sinkDropped",
+	"warmupSeconds", "warmupOutputCount", "monotonicViolations", "gaps",
+	"trend", "sample",
+}
+
+// writeCSVReport выводит отчёт строкой заголовка и строкой значений.
+func writeCSVReport(w io.Writer, r report) error {
+	var bigInput, bigOutput string
+	if r.BigSums != nil {
+		bigInput, bigOutput = r.BigSums.Input, r.BigSums.Output
+	}
+	var reduced []byte
+	if r.Reduced != nil {
+		var err error
+		if reduced, err = json.Marshal(r.Reduced); err != nil {
+			return err
+		}
+	}
+	var sinkWritten, sinkDropped []int64
+	for _, s := range r.Sinks {
+		sinkWritten, sinkDropped = append(sinkWritten, s.Written), append(sinkDropped, s.Dropped)
+	}
+	var trend string
+	if r.Trend != nil {
+		trend = strconv.FormatFloat(*r.Trend, 'f', -1, 64)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	cw.Write([]string{
+		strconv.FormatInt(r.InputCount, 10),
+		strconv.FormatInt(r.InputSum, 10),
+		strconv.FormatInt(r.OutputCount, 10),
+		strconv.FormatInt(r.OutputSum, 10),
+		joinInts(r.PerWorker),
+		strconv.FormatInt(r.DroppedCount, 10),
+		strconv.FormatInt(r.SkippedCount, 10),
+		strconv.FormatFloat(r.DurationSeconds, 'f', -1, 64),
+		strconv.FormatFloat(r.Throughput, 'f', -1, 64),
+		r.Drain,
+		strconv.FormatBool(r.Verified),
+		r.Error,
+		strconv.FormatFloat(r.GeneratorBlockedSeconds, 'f', -1, 64),
+		joinFloats(r.WorkerBlockedSeconds),
+		strconv.FormatInt(r.FailedCount, 10),
+		joinInts(r.Retries),
+		r.StopCause,
+		joinInts(r.Stalls),
+		joinInts(r.PerSource),
+		strings.Join(r.Files, ";"),
+		strconv.FormatBool(r.SumOverflow),
+		bigInput,
+		bigOutput,
+		r.InputChecksum,
+		r.OutputChecksum,
+		joinInts(r.WorkerItems),
+		joinInts(r.WorkerErrors),
+		joinFloats(r.WorkerBusySeconds),
+		joinFloats(r.WorkerIdleSeconds),
+		joinFloats(r.WorkerSendingSeconds),
+		joinFloats(r.WorkerUtilization),
+		joinInts(r.PerPriority),
+		joinInts(r.PriorityOut),
+		joinFloats(r.PriorityP99Seconds),
+		strconv.FormatInt(r.Duplicates, 10),
+		strconv.FormatFloat(r.SinkBlockedSeconds, 'f', -1, 64),
+		string(reduced),
+		joinInts(sinkWritten),
+		joinInts(sinkDropped),
+		strconv.FormatFloat(r.WarmupSeconds, 'f', -1, 64),
+		strconv.FormatInt(r.WarmupOutputCount, 10),
+		strconv.FormatInt(r.MonotonicViolations, 10),
+		strconv.FormatInt(r.Gaps, 10),
+		trend,
+		joinInts(r.Sample),
+	})
+	cw.Flush()
+	return cw.Error()
+}
+
+// joinInts перечисляет ns через точку с запятой для CSV-отчёта.
+func joinInts(ns []int64) string {
+	parts := make([]string, len(ns))
+	for i, n := range ns {
+		parts[i] = strconv.FormatInt(n, 10)
+	}
+	return strings.Join(parts, ";")
+}
+
+// joinFloats перечисляет fs через точку с запятой для CSV-отчёта.
+func joinFloats(fs []float64) string {
+	parts := make([]string, len(fs))
+	for i, f := range fs {
+		parts[i] = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+// TestReportWriters проверяет схему отчёта во всех форматах -output.
+func TestReportWriters(t *testing.T) {
+	res := pipeline.Result{
+		Snapshot: pipeline.Snapshot{
+			InputCount: 4, InputSum: 10,
+			OutputCount: 3, OutputSum: 6,
+			InputChecksum: 0xff, OutputChecksum: 0x1a,
+			PerWorker:    []int64{2, 1},
+			PerSource:    []int64{3, 1},
+			PerPriority:  []int64{3, 1},
+			PriorityOut:  []int64{2, 1},
+			DroppedCount: 1, DroppedSum: 4,
+			GeneratorBlocked: time.Second,
+			WorkerBlocked:    []time.Duration{500 * time.Millisecond, 0},
+			FailedCount:      1, FailedSum: 5,
+			Retries:       []int64{3, 0},
+			Stalls:        []int64{0, 2},
+			WorkerItems:   []int64{3, 2},
+			WorkerErrors:  []int64{0, 1},
+			WorkerBusy:    []time.Duration{time.Second, 0},
+			WorkerIdle:    []time.Duration{time.Second, time.Second},
+			WorkerSending: []time.Duration{0, 0},
+			Duplicates:    2,
+			SinkBlocked:   250 * time.Millisecond,
+		},
+		Drain:           pipeline.DropRemaining,
+		Duration:        2 * time.Second,
+		StopCause:       pipeline.ErrTimeout,
+		Files:           []string{"out.000001.gz", "out.000002.gz"},
+		PriorityLatency: []pipeline.LatencySummary{{P99: time.Second}, {P99: 2 * time.Second}},
+		Warmup:          &pipeline.WarmupReport{Duration: time.Second, Generated: 2, Output: 1, PerWorker: []int64{1, 0}, Complete: true},
+		Order:           &pipeline.OrderReport{Violations: 2, Gaps: 5},
+		Trend:           &pipeline.TrendReport{Last: 2.5},
+		Sample:          []int64{3, 1},
+	}
+	r := newReport(res, errors.New("суммы не совпадают"))
+	r.Reduced = reductions([]string{"top", "count"}, []any{[]int64{3, 2}, int64(3)})
+	r.Sinks = sinkReports([]pipeline.BroadcastStats{{Name: "file", Written: 3}, {Name: "http", Written: 1, Dropped: 2, Blocked: time.Second}})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeJSONReport(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+		var got report
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.OutputSum != 6 || !slices.Equal(got.PerWorker, []int64{2, 1}) || got.Throughput != 2 ||
+			got.Verified || got.Error != "суммы не совпадают" ||
+			got.GeneratorBlockedSeconds != 1 || !slices.Equal(got.WorkerBlockedSeconds, []float64{0.5, 0}) ||
+			got.FailedCount != 1 || !slices.Equal(got.Retries, []int64{3, 0}) ||
+			got.StopCause != pipeline.ErrTimeout.Error() || !slices.Equal(got.Stalls, []int64{0, 2}) ||
+			!slices.Equal(got.PerSource, []int64{3, 1}) || !slices.Equal(got.Files, res.Files) ||
+			got.InputChecksum != "00000000000000ff" || got.OutputChecksum != "000000000000001a" ||
+			!slices.Equal(got.WorkerItems, []int64{3, 2}) || !slices.Equal(got.WorkerUtilization, []float64{0.5, 0}) ||
+			!slices.Equal(got.PriorityOut, []int64{2, 1}) || !slices.Equal(got.PriorityP99Seconds, []float64{1, 2}) ||
+			got.Reduced["count"] != float64(3) || len(got.Sinks) != 2 || got.Sinks[1].BlockedSeconds != 1 ||
+			got.WarmupSeconds != 1 || got.WarmupOutputCount != 1 || got.MonotonicViolations != 2 || got.Gaps != 5 ||
+			got.Trend == nil || *got.Trend != 2.5 || !slices.Equal(got.Sample, []int64{3, 1}) {
+			t.Errorf("отчёт %+v", got)
+		}
+	})
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeCSVReport(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 2 || !slices.Equal(rows[0], csvHeader) {
+			t.Fatalf("строки %q", rows)
+		}
+		row := rows[1]
+		if row[4] != "2;1" || row[7] != "2" || row[9] != "drop" || row[10] != "false" ||
+			row[12] != "1" || row[13] != "0.5;0" || row[14] != "1" || row[15] != "3;0" ||
+			row[16] != pipeline.ErrTimeout.Error() || row[17] != "0;2" || row[18] != "3;1" ||
+			row[19] != "out.000001.gz;out.000002.gz" || row[23] != "00000000000000ff" || row[24] != "000000000000001a" ||
+			row[25] != "3;2" || row[26] != "0;1" || row[27] != "1;0" || row[28] != "1;1" || row[30] != "0.5;0" ||
+			row[31] != "3;1" || row[32] != "2;1" || row[33] != "1;2" || row[34] != "2" || row[35] != "0.25" ||
+			row[36] != `{"count":3,"top":[3,2]}` || row[37] != "3;1" || row[38] != "0;2" ||
+			row[8] != "2" || row[39] != "1" || row[40] != "1" || row[41] != "2" || row[42] != "5" || row[43] != "2.5" || row[44] != "3;1" {
+			t.Errorf("значения %q", row)
+		}
+	})
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeTextReport(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"Количество чисел 4 3", "Контрольная сумма 00000000000000ff 000000000000001a",
+			"Загрузка обработчиков [50% 0%]", "Ошибки обработки [0 1]",
+			"Отброшено чисел 1 политика drop",
+			"Не обработано чисел 1", "Повторы обработки [3 0]",
+			"Причина остановки: истёк таймаут генерации", "Зависания обработчиков [0 2]", "Разбивка по источникам [3 1]",
+			"Разбивка по классам приоритета [3 1] дошло [2 1]", "Подавлено повторов 2", "Порядок чисел: нарушений возрастания 2 пропусков 5", "Скользящее среднее 2.50", "Ожидание одновременных отправок приёмника 250ms", "Задержка класса приоритета 1 p50 0s p95 0s p99 2s",
+			"Файлы результатов [out.000001.gz out.000002.gz]", "Свёртка count 3\nСвёртка top [3,2]\nВыборка [3 1]\n", "Приёмник http: записано 1, отброшено 2, ожидание 1s",
+			"Прогрев 1s: сгенерировано 2, дошло 1 — не учтены в производительности и задержке", "Производительность 2 чисел/с, по каналам [1 1]", "Проверка не пройдена: суммы не совпадают"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("отчёт %q не содержит %q", buf.String(), want)
+			}
+		}
+	})
+}
+
+// TestReportBigSums проверяет вывод переполненных и точных сумм.
+func TestReportBigSums(t *testing.T) {
+	sum, _ := new(big.Int).SetString("18446744073709551616", 10)
+	tests := []struct {
+		name     string
+		res      pipeline.Result
+		wantText string
+		wantCSV  []string // sumOverflow, bigInputSum, bigOutputSum
+	}{
+		{"переполнение", pipeline.Result{Snapshot: pipeline.Snapshot{SumOverflow: true}},
+			"Сумма чисел по модулю 2^64 0 0", []string{"true", "", ""}},
+		{"точные суммы", pipeline.Result{Snapshot: pipeline.Snapshot{SumOverflow: true, BigSums: &pipeline.BigSums{
+			Input: sum, Output: sum, Dropped: new(big.Int), Skipped: new(big.Int), Failed: new(big.Int),
+		}}}, "Сумма чисел 18446744073709551616 18446744073709551616", []string{"true", sum.String(), sum.String()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReport(tt.res, nil)
+			var text, csvBuf bytes.Buffer
+			if err := writeTextReport(&text, r); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(text.String(), tt.wantText) {
+				t.Errorf("отчёт %q не содержит %q", text.String(), tt.wantText)
+			}
+			if err := writeCSVReport(&csvBuf, r); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(&csvBuf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rows[1][20:23]; !slices.Equal(got, tt.wantCSV) {
+				t.Errorf("столбцы %q, want %q", got, tt.wantCSV)
+			}
+		})
+	}
+}
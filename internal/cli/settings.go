@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// settingsEnvPrefix — префикс переменных окружения с настройками:
+// PIPELINE_WORKERS задаёт -workers, PIPELINE_SINK_FILE — -sink-file.
+const settingsEnvPrefix = "PIPELINE_"
+
+// settingsFlags — флаги, которые задаются только в командной строке и
+// окружении, но не в файле настроек и не выводятся -print-config.
+var settingsFlags = map[string]bool{"config": true, "print-config": true}
+
+// envName возвращает имя переменной окружения для флага name.
+func envName(name string) string {
+	return settingsEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// valueFlag — флаг, значение которого разбирает set, а выводит get. В
+// отличие от flag.Func значение такого флага видно в -print-config и в
+// таблице runs.
+type valueFlag struct {
+	get func() string
+	set func(string) error
+}
+
+func (f valueFlag) String() string {
+	// flag.PrintDefaults вызывает String у нулевого значения
+	if f.get == nil {
+		return ""
+	}
+	return f.get()
+}
+
+func (f valueFlag) Set(s string) error { return f.set(s) }
+
+// applySettings задаёт флагам fs, не указанным в командной строке, значения
+// из файла настроек path (пусто — без файла), а затем из переменных
+// окружения PIPELINE_<ФЛАГ>, поэтому окружение важнее файла, а командная
+// строка — важнее обоих. Возвращает сразу все ошибки: неизвестные
+// параметры файла и некорректные значения.
+func applySettings(fs *flag.FlagSet, path string, lookupEnv func(string) (string, bool)) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	var errs []error
+	if path != "" {
+		values, err := readSettingsFile(path)
+		if values == nil {
+			return err
+		}
+		errs = append(errs, err)
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			switch {
+			case fs.Lookup(name) == nil || settingsFlags[name]:
+				errs = append(errs, fmt.Errorf("%s: неизвестный параметр %s", path, name))
+			case explicit[name]:
+			default:
+				if err := setFlag(fs, name, values[name]); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s: некорректное значение %q: %w", path, name, values[name], err))
+				}
+			}
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := lookupEnv(envName(f.Name))
+		if !ok || explicit[f.Name] || f.Name == "config" {
+			return
+		}
+		if err := setFlag(fs, f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: некорректное значение %q: %w", envName(f.Name), value, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// setFlag задаёт флагу name из fs значение value. Флаги некоторых типов
+// при ошибке разбора сбрасываются в нулевое значение, поэтому при ошибке
+// восстанавливается прежнее, чтобы оно не дало лишних ошибок проверки.
+func setFlag(fs *flag.FlagSet, name, value string) error {
+	prev := fs.Lookup(name).Value.String()
+	if err := fs.Set(name, value); err != nil {
+		fs.Set(name, prev)
+		return err
+	}
+	return nil
+}
+
+// readSettingsFile читает файл настроек в формате YAML (.yaml, .yml) или
+// TOML (.toml) и возвращает значения флагов по именам. Имена — имена флагов
+// без дефиса, подчёркивания в них можно использовать вместо дефисов;
+// списки, например для source и input, объединяются через запятую. Если
+// файл прочитан, но некоторые значения не поддерживаются, возвращает
+// остальные значения и ошибки неподдерживаемых.
+func readSettingsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("%s: неизвестный формат файла настроек %q, ожидается .yaml, .yml или .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make(map[string]string, len(raw))
+	var errs []error
+	for _, key := range keys {
+		name := strings.ReplaceAll(key, "_", "-")
+		s, err := settingValue(raw[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+			continue
+		}
+		values[name] = s
+	}
+	return values, errors.Join(errs...)
+}
+
+// settingValue возвращает значение параметра файла настроек в виде
+// значения флага.
+func settingValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := settingValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("значение %v типа %T не поддерживается: вложенные разделы и даты не используются", v, v)
+	}
+}
+
+// printSettings выводит действующие значения всех флагов fs в формате
+// YAML, который можно использовать как файл настроек.
+func printSettings(w io.Writer, fs *flag.FlagSet) error {
+	values := make(map[string]any)
+	fs.VisitAll(func(f *flag.Flag) {
+		if settingsFlags[f.Name] {
+			return
+		}
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			values[f.Name] = f.Value.String()
+			return
+		}
+		v := getter.Get()
+		if d, ok := v.(time.Duration); ok {
+			v = d.String()
+		}
+		values[f.Name] = v
+	})
+	// yaml.Marshal упорядочивает ключи
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseRun разбирает флаги команды run.
+func parseRun(t *testing.T, args ...string) *runCmd {
+	t.Helper()
+	_, cmd, _, err := parseCommand(append([]string{"run"}, args...), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd.(*runCmd)
+}
+
+// writeSettings записывает файл настроек name во временный каталог.
+func writeSettings(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestApplySettings проверяет порядок важности настроек: командная строка,
+// окружение, файл.
+func TestApplySettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		file, data  string
+		args        []string
+		env         map[string]string
+		wantWorkers int
+		wantTimeout time.Duration
+		wantSource  string
+		wantErr     []string
+	}{
+		{"yaml", "s.yaml", "workers: 8\ntimeout: 0s\nsource: [fib, primes]\n", nil, nil, 8, 0, "fib,primes", nil},
+		{"toml", "s.toml", "workers = 8\nsink_file = \"out.txt\"\nsource = \"fib\"\n", nil, nil, 8, time.Second, "fib", nil},
+		{"окружение важнее файла", "s.yaml", "workers: 8\n", nil, map[string]string{"PIPELINE_WORKERS": "4", "PIPELINE_TIMEOUT": "2s"}, 4, 2 * time.Second, "seq", nil},
+		{"флаги важнее всего", "s.yaml", "workers: 8\n", []string{"-workers", "3"}, map[string]string{"PIPELINE_WORKERS": "4"}, 3, time.Second, "seq", nil},
+		{"все ошибки сразу", "s.yaml", "workers: x\nbogus: 1\nconfig: other.yaml\n", nil, map[string]string{"PIPELINE_RATE": "abc"}, 0, 0, "", []string{"workers: некорректное значение", "неизвестный параметр bogus", "неизвестный параметр config", "PIPELINE_RATE"}},
+		{"вложенный раздел", "s.yaml", "retry:\n  attempts: 3\n", nil, nil, 0, 0, "", []string{"не поддерживается"}},
+		{"неизвестный формат", "s.ini", "workers=8\n", nil, nil, 0, 0, "", []string{"неизвестный формат"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := parseRun(t, tt.args...)
+			path := writeSettings(t, tt.file, tt.data)
+			err := applySettings(c.fs, path, func(name string) (string, bool) {
+				v, ok := tt.env[name]
+				return v, ok
+			})
+			if len(tt.wantErr) > 0 {
+				for _, want := range tt.wantErr {
+					if err == nil || !strings.Contains(err.Error(), want) {
+						t.Errorf("applySettings = %v, want ошибку %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.cfg.NumWorkers != tt.wantWorkers || c.cfg.Timeout != tt.wantTimeout || c.source.name != tt.wantSource {
+				t.Errorf("workers %d, timeout %v, source %q, want %d, %v, %q", c.cfg.NumWorkers, c.cfg.Timeout, c.source.name, tt.wantWorkers, tt.wantTimeout, tt.wantSource)
+			}
+		})
+	}
+}
+
+// TestPrintSettings проверяет, что вывод -print-config читается как файл
+// настроек и задаёт те же значения.
+func TestPrintSettings(t *testing.T) {
+	c := parseRun(t, "-workers", "7", "-timeout", "3s", "-drain", "drop", "-distribute", "round-robin", "-source", "fib,primes")
+	var out bytes.Buffer
+	if err := printSettings(&out, c.fs); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "print-config") {
+		t.Errorf("-print-config в выводе:\n%s", out.String())
+	}
+	path := writeSettings(t, "s.yaml", out.String())
+	got := parseRun(t)
+	if err := applySettings(got.fs, path, func(string) (string, bool) { return "", false }); err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	if err := printSettings(&again, got.fs); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != out.String() {
+		t.Errorf("настройки из вывода -print-config:\n%s\nwant:\n%s", again.String(), out.String())
+	}
+	if got.cfg.Timeout != 3*time.Second || got.cfg.Drain.String() != "drop" || got.distribute != "round-robin" {
+		t.Errorf("timeout %v, drain %v, distribute %s", got.cfg.Timeout, got.cfg.Drain, got.distribute)
+	}
+}
+
+// TestRunSettingsErrors проверяет, что run сообщает об ошибках файла
+// настроек и проверки Config вместе.
+func TestRunSettingsErrors(t *testing.T) {
+	path := writeSettings(t, "s.yaml", "bogus: 1\nbuffer: -1\n")
+	c := parseRun(t, "-config", path, "-workers", "0")
+	err := c.run(io.Discard, nil)
+	for _, want := range []string{"неизвестный параметр bogus", "количество обработчиков", "размер буфера"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("run = %v, want ошибку %q", err, want)
+		}
+	}
+}
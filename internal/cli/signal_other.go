@@ -0,0 +1,9 @@
+//go:build !unix
+
+package cli
+
+import "os"
+
+// dumpSignals — сигналы, по которым статистика записывается в журнал; вне
+// Unix SIGUSR1 нет.
+var dumpSignals []os.Signal
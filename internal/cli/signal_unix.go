@@ -0,0 +1,11 @@
+//go:build unix
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignals — сигналы, по которым статистика записывается в журнал.
+var dumpSignals = []os.Signal{syscall.SIGUSR1}
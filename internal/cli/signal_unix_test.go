@@ -0,0 +1,57 @@
+//go:build unix
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+// syncBuffer — bytes.Buffer, безопасный для записи из нескольких горутин.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestRunStoppableDump проверяет, что SIGUSR1 записывает снимок статистики
+// в журнал и не останавливает конвейер.
+func TestRunStoppableDump(t *testing.T) {
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	p := pipeline.New(pipeline.Config{NumWorkers: 2, Limit: 10})
+	_, err := runStoppable(logger, p, func(ctx context.Context) (pipeline.Result, error) {
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+		for !strings.Contains(logs.String(), "снимок статистики") {
+			time.Sleep(time.Millisecond)
+		}
+		return p.Run(ctx)
+	})
+	if err != nil {
+		t.Fatalf("конвейер после SIGUSR1: %v", err)
+	}
+	if !strings.Contains(logs.String(), syscall.SIGUSR1.String()) {
+		t.Errorf("журнал %q без сигнала", logs.String())
+	}
+}
@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+// superviseCmd — команда supervise: несколько независимых именованных
+// конвейеров одновременно в одном процессе и таблица их итогов.
+type superviseCmd struct {
+	cfg        pipeline.Config // общие настройки конвейеров
+	distribute string
+	transform  string
+	logFormat  string
+}
+
+func (c *superviseCmd) flags(fs *flag.FlagSet) {
+	c.cfg = pipeline.DefaultConfig()
+	fs.IntVar(&c.cfg.NumWorkers, "workers", c.cfg.NumWorkers, "количество обработчиков каждого конвейера")
+	fs.IntVar(&c.cfg.BufferSize, "buffer", c.cfg.BufferSize, "размер буфера каналов каждого конвейера")
+	fs.DurationVar(&c.cfg.WorkerDelay, "worker-delay", c.cfg.WorkerDelay, "пауза обработчика после каждого числа")
+	fs.DurationVar(&c.cfg.Timeout, "timeout", c.cfg.Timeout, "время генерации чисел (0 — без ограничения)")
+	fs.Int64Var(&c.cfg.Limit, "limit", 0, "сколько чисел сгенерировать каждому конвейеру (0 — без ограничения)")
+	fs.Float64Var(&c.cfg.Rate, "rate", 0, "сколько чисел в секунду генерировать каждому конвейеру (0 — без ограничения)")
+	fs.StringVar(&c.distribute, "distribute", "shared", "раздача чисел обработчикам: shared, round-robin, least-loaded или work-stealing")
+	fs.StringVar(&c.transform, "transform", "none", "обработка числа: none, square, hash или even")
+	fs.StringVar(&c.logFormat, "log-format", "text", "формат журнала в stderr: text или json")
+}
+
+// run запускает конвейеры args одновременно и выводит таблицу их итогов.
+// Первый сигнал SIGINT или SIGTERM останавливает генерацию всех
+// конвейеров, второй прерывает обработку. Возвращает ошибку, если
+// какой-либо конвейер не прошёл проверку.
+func (c *superviseCmd) run(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("не заданы конвейеры, например fast:workers=8 slow:workers=1")
+	}
+	specs, err := parsePipelines(args)
+	if err != nil {
+		return err
+	}
+	logger, err := newLogger(os.Stderr, c.logFormat)
+	if err != nil {
+		return err
+	}
+	cfg := c.cfg
+	cfg.Logger = logger
+	if cfg.Distributor, err = newDistributor(c.distribute); err != nil {
+		return err
+	}
+	s, err := newSupervisor(cfg, c.transform, specs)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	abort, abortAll := context.WithCancel(context.Background())
+	defer abortAll()
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for _, cancel := range []func(){stop, abortAll} {
+			select {
+			case sig := <-signals:
+				logger.Info("получен сигнал", "signal", sig.String())
+				cancel()
+			case <-abort.Done():
+				return
+			}
+		}
+	}()
+	statuses := runSupervisor(ctx, abort, s)
+	failed, err := writeSupervisorReport(w, statuses)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("проверка не пройдена в %d конвейерах из %d", failed, len(statuses))
+	}
+	return nil
+}
+
+// pipelineSpec — конвейер команды supervise: имя и настройки, которыми он
+// отличается от общих.
+type pipelineSpec struct {
+	name      string
+	overrides map[string]string
+}
+
+// pipelineOverrides — настройки, которые задаются отдельным конвейерам
+// команды supervise, и их применение к cfg; кроме них задаётся transform.
+var pipelineOverrides = map[string]func(cfg *pipeline.Config, value string) error{
+	"workers": func(cfg *pipeline.Config, value string) (err error) {
+		cfg.NumWorkers, err = strconv.Atoi(value)
+		return err
+	},
+	"buffer": func(cfg *pipeline.Config, value string) (err error) {
+		cfg.BufferSize, err = strconv.Atoi(value)
+		return err
+	},
+	"worker-delay": func(cfg *pipeline.Config, value string) (err error) {
+		cfg.WorkerDelay, err = time.ParseDuration(value)
+		return err
+	},
+	"timeout": func(cfg *pipeline.Config, value string) (err error) {
+		cfg.Timeout, err = time.ParseDuration(value)
+		return err
+	},
+	"limit": func(cfg *pipeline.Config, value string) (err error) {
+		cfg.Limit, err = strconv.ParseInt(value, 10, 64)
+		return err
+	},
+	"rate": func(cfg *pipeline.Config, value string) (err error) {
+		cfg.Rate, err = strconv.ParseFloat(value, 64)
+		return err
+	},
+	"distribute": func(cfg *pipeline.Config, value string) (err error) {
+		cfg.Distributor, err = newDistributor(value)
+		return err
+	},
+}
+
+// parsePipelines разбирает конвейеры команды supervise: у каждого — имя и,
+// после двоеточия, настройки ключ=значение через запятую, например
+// fast:workers=8,buffer=64.
+func parsePipelines(args []string) ([]pipelineSpec, error) {
+	var specs []pipelineSpec
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		name, settings, _ := strings.Cut(strings.TrimSpace(arg), ":")
+		if name == "" {
+			return nil, fmt.Errorf("у конвейера %q нет имени", arg)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("конвейер %s задан дважды", name)
+		}
+		seen[name] = true
+		spec := pipelineSpec{name: name, overrides: make(map[string]string)}
+		for _, kv := range strings.Split(settings, ",") {
+			if kv = strings.TrimSpace(kv); kv == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(kv, "=")
+			if _, known := pipelineOverrides[key]; !ok || !known && key != "transform" {
+				return nil, fmt.Errorf("конвейер %s: неизвестная настройка %q", name, kv)
+			}
+			spec.overrides[key] = value
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// newSupervisor создаёт Supervisor с конвейерами specs: каждый получает
+// копию настроек cfg со своими изменениями и обработку transform, если
+// она не задана ему отдельно.
+func newSupervisor(cfg pipeline.Config, transform string, specs []pipelineSpec) (*pipeline.Supervisor, error) {
+	s := pipeline.NewSupervisor()
+	for _, spec := range specs {
+		c := cfg
+		name := transform
+		for key, value := range spec.overrides {
+			if key == "transform" {
+				name = value
+				continue
+			}
+			if err := pipelineOverrides[key](&c, value); err != nil {
+				return nil, fmt.Errorf("конвейер %s: %s: %w", spec.name, key, err)
+			}
+		}
+		// обработка включает паузу конвейера, поэтому создаётся после
+		// изменений
+		var err error
+		if c.Process, err = newTransform(name, c.WorkerDelay); err != nil {
+			return nil, fmt.Errorf("конвейер %s: неизвестная обработка: %w", spec.name, err)
+		}
+		if err := s.Add(spec.name, c); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// writeSupervisorReport выводит в w таблицу итогов конвейеров statuses и
+// строку сводки по всем. Возвращает, сколько конвейеров не прошли проверку.
+func writeSupervisorReport(w io.Writer, statuses []pipeline.PipelineStatus) (int, error) {
+	var (
+		failed     int
+		throughput float64
+	)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "конвейер\tобработчиков\tчисел\tчисел/с\tp99\tпричина остановки\tпроверка\t")
+	for _, st := range statuses {
+		res := pipeline.Result{Snapshot: st.Stats}
+		err := errors.New("не запускался")
+		if st.Result != nil {
+			res = *st.Result
+			if err = st.Err; err == nil {
+				err = res.Verify()
+			}
+		}
+		verdict := "пройдена"
+		if err != nil {
+			verdict = err.Error()
+			failed++
+		}
+		throughput += res.Throughput()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f\t%v\t%v\t%s\t\n", st.Name, len(res.PerWorker), res.OutputCount, res.Throughput(), res.Latency.P99, res.StopCause, verdict)
+	}
+	total := pipeline.TotalStats(statuses)
+	fmt.Fprintf(tw, "всего\t%d\t%d\t%.0f\t\t\t\t\n", len(total.PerWorker), total.OutputCount, throughput)
+	return failed, tw.Flush()
+}
+
+// runSupervisor запускает все конвейеры s одновременно и ждёт их
+// завершения: отмена ctx останавливает генерацию всех конвейеров, а
+// отмена abort прерывает их обработку.
+func runSupervisor(ctx, abort context.Context, s *pipeline.Supervisor) []pipeline.PipelineStatus {
+	// конвейеры ещё не запускались, поэтому StartAll не вернёт ошибку
+	s.StartAll(abort)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.StopAll()
+		case <-done:
+		}
+	}()
+	return s.WaitAll()
+}
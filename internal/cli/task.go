@@ -0,0 +1,50 @@
+// Package cli содержит команды программы: разбор флагов и файла настроек,
+// запуск конвейеров пакета pipeline, отчёты и их вывод.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+// errorStage возвращает этап конвейера, на котором произошла ошибка err, и
+// её причину; для ошибок вне этапов — "конвейер" и саму err.
+func errorStage(err error) (string, error) {
+	var (
+		gen  *pipeline.GeneratorError
+		work *pipeline.WorkerError
+		sink *pipeline.SinkError
+	)
+	switch {
+	case errors.As(err, &gen):
+		return "генератор", gen.Err
+	case errors.As(err, &work):
+		return fmt.Sprintf("обработчик %d", work.Index), work.Err
+	case errors.As(err, &sink):
+		return "приёмник", sink.Err
+	}
+	return "конвейер", err
+}
+
+// Main выполняет команду из аргументов args и возвращает код завершения
+// программы: 0 — успех или вывод справки, 1 — ошибка команды, 2 — ошибка
+// в аргументах.
+func Main(args []string) int {
+	_, cmd, args, err := parseCommand(args, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		return 2
+	}
+	if err := cmd.run(os.Stdout, args); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+func TestErrorStage(t *testing.T) {
+	cause := errors.New("сбой")
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&pipeline.GeneratorError{Err: cause}, "генератор"},
+		{fmt.Errorf("запуск: %w", &pipeline.WorkerError{Index: 2, Err: cause}), "обработчик 2"},
+		{&pipeline.SinkError{Err: cause}, "приёмник"},
+		{cause, "конвейер"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			stage, got := errorStage(tt.err)
+			if stage != tt.want || got != cause {
+				t.Errorf("errorStage(%v) = %q, %v, want %q и %v", tt.err, stage, got, tt.want, cause)
+			}
+		})
+	}
+}
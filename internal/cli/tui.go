@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+// dashboardInterval — период обновления панели -tui.
+const dashboardInterval = 250 * time.Millisecond
+
+// dashboardBar — ширина самой длинной полосы обработчика в символах.
+const dashboardBar = 40
+
+// dashboard — живая панель конвейера в терминале для -tui: количество чисел
+// каждого обработчика полосами, частота генерации и результирующего канала,
+// давление на входе и время работы. Панель перерисовывается на месте
+// управляющими последовательностями ANSI.
+type dashboard struct {
+	w    io.Writer
+	p    *pipeline.Pipeline
+	logs *heldWriter // журнал, задержанный на время работы панели
+
+	start  time.Time
+	prev   pipeline.Snapshot // статистика предыдущего кадра
+	prevAt time.Time
+
+	stop chan struct{} // закрывается методом close
+	done chan struct{} // закрывается после последнего кадра
+}
+
+// startDashboard начинает перерисовывать в w панель конвейера p. Записи
+// журнала в logs задерживаются до вызова close.
+func startDashboard(w io.Writer, p *pipeline.Pipeline, logs *heldWriter) *dashboard {
+	now := time.Now()
+	d := &dashboard{
+		w:      w,
+		p:      p,
+		logs:   logs,
+		start:  now,
+		prevAt: now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	logs.hold()
+	// скрываем курсор и очищаем экран
+	fmt.Fprint(w, "\x1b[?25l\x1b[2J")
+	go d.loop()
+	return d
+}
+
+// loop перерисовывает панель каждые dashboardInterval до вызова close.
+func (d *dashboard) loop() {
+	defer close(d.done)
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+	for {
+		d.draw("")
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// close рисует последний кадр с итогом verdict, возвращает курсор и
+// выводит задержанный журнал.
+func (d *dashboard) close(verdict string) {
+	close(d.stop)
+	<-d.done
+	// в последнем кадре — средние значения за всё время работы
+	d.prev, d.prevAt = pipeline.Snapshot{}, d.start
+	d.draw(verdict)
+	fmt.Fprint(d.w, "\x1b[?25h")
+	d.logs.release()
+}
+
+// draw рисует кадр по текущей статистике; verdict — строка итога под
+// панелью, пусто — без неё.
+func (d *dashboard) draw(verdict string) {
+	state, now := d.p.Snapshot(), time.Now()
+	snap := state.Stats
+	elapsed := now.Sub(d.prevAt).Seconds()
+	rate := func(cur, prev int64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return float64(cur-prev) / elapsed
+	}
+	// доля времени, которое генератор ждал свободного обработчика
+	var pressure float64
+	if elapsed > 0 {
+		pressure = (snap.GeneratorBlocked - d.prev.GeneratorBlocked).Seconds() / elapsed
+	}
+	inFlight := snap.InputCount - snap.OutputCount - snap.DroppedCount - snap.SkippedCount - snap.FailedCount
+
+	var b bytes.Buffer
+	// каждая строка стирается до конца, остаток прошлого кадра — после
+	// последней строки
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\x1b[K\n")
+	}
+	b.WriteString("\x1b[H")
+	line("Конвейер: %v, последнее число %d", state.Uptime.Round(100*time.Millisecond), state.LastValue)
+	line("")
+	line("Генерация:  %10.0f чисел/с, всего %d", rate(snap.InputCount, d.prev.InputCount), snap.InputCount)
+	line("Результат:  %10.0f чисел/с, всего %d", rate(snap.OutputCount, d.prev.OutputCount), snap.OutputCount)
+	line("В каналах:  %10d, ожидание генератора %3.0f%%", inFlight, 100*pressure)
+	line("Очереди:    вход %d, результат %d", state.Queues.Input, state.Queues.Result)
+	if snap.DroppedCount+snap.SkippedCount+snap.FailedCount > 0 {
+		line("Отброшено %d, отфильтровано %d, не обработано %d", snap.DroppedCount, snap.SkippedCount, snap.FailedCount)
+	}
+	line("")
+	line("Обработчики:")
+	var most int64
+	for _, n := range snap.PerWorker {
+		most = max(most, n)
+	}
+	for i, n := range snap.PerWorker {
+		width := 0
+		if most > 0 {
+			width = int(n * dashboardBar / most)
+		}
+		var ws pipeline.WorkerState
+		if i < len(state.Workers) {
+			ws = state.Workers[i]
+		}
+		line("%3d %-*s %d %s", i, dashboardBar, strings.Repeat("█", width), n, ws)
+	}
+	if verdict != "" {
+		line("")
+		line("%s", verdict)
+	}
+	b.WriteString("\x1b[J")
+	d.w.Write(b.Bytes())
+	d.prev, d.prevAt = snap, now
+}
+
+// heldWriter — io.Writer, который передаёт записи в w, а между вызовами
+// hold и release накапливает их в памяти. Так журнал не портит панель -tui,
+// пока она рисуется в том же терминале.
+type heldWriter struct {
+	w io.Writer
+
+	mu   sync.Mutex
+	held bool
+	buf  bytes.Buffer
+}
+
+// Write записывает p в w или, после hold, в память.
+func (h *heldWriter) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.held {
+		return h.buf.Write(p)
+	}
+	return h.w.Write(p)
+}
+
+// hold начинает накапливать записи в памяти.
+func (h *heldWriter) hold() {
+	h.mu.Lock()
+	h.held = true
+	h.mu.Unlock()
+}
+
+// release выводит накопленные записи в w; следующие записи передаются в w
+// сразу.
+func (h *heldWriter) release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.held = false
+	h.w.Write(h.buf.Bytes())
+	h.buf.Reset()
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+func TestHeldWriter(t *testing.T) {
+	var out bytes.Buffer
+	h := &heldWriter{w: &out}
+	h.Write([]byte("1 "))
+	h.hold()
+	h.Write([]byte("2 "))
+	if out.String() != "1 " {
+		t.Errorf("до release выведено %q, want %q", out.String(), "1 ")
+	}
+	h.release()
+	h.Write([]byte("3"))
+	if out.String() != "1 2 3" {
+		t.Errorf("выведено %q, want %q", out.String(), "1 2 3")
+	}
+}
+
+// TestDashboard проверяет, что последний кадр панели показывает полосы
+// обработчиков и итог, а журнал выводится после него.
+func TestDashboard(t *testing.T) {
+	p := pipeline.New(pipeline.Config{NumWorkers: 3, Limit: 30})
+	var screen, logs bytes.Buffer
+	held := &heldWriter{w: &logs}
+	d := startDashboard(&screen, p, held)
+	held.Write([]byte("запись журнала\n"))
+	if logs.Len() != 0 {
+		t.Errorf("журнал выведен во время работы панели: %q", logs.String())
+	}
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	d.close("Проверка пройдена")
+
+	frame := screen.String()
+	frame = frame[strings.LastIndex(frame, "\x1b[H"):]
+	for _, want := range []string{"Результат:", "всего 30", "последнее число 30", "Очереди:", "Обработчики:", "  2 ", "stopped", "Проверка пройдена"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("в последнем кадре нет %q:\n%s", want, frame)
+		}
+	}
+	if !strings.HasSuffix(screen.String(), "\x1b[?25h") {
+		t.Error("курсор не возвращён")
+	}
+	if logs.String() != "запись журнала\n" {
+		t.Errorf("журнал после панели %q", logs.String())
+	}
+}
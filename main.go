@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(os.Args[1:]))
+}
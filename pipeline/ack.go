@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Acker — источник, которому нужно знать судьбу каждого полученного из него
+// числа, например чтобы подтвердить сообщение брокера или зафиксировать
+// смещение. Run проверяет Config.Source и Config.Sources на этот интерфейс.
+type Acker interface {
+	// Ack сообщает, что n-е (начиная с 1) число, возвращённое Next,
+	// окончательно учтено. processed — число пришло в результирующий
+	// канал, отфильтровано или передано в Config.DeadLetters; false — число
+	// отброшено или не отправлено генератором, и его стоит получить снова.
+	// Ack вызывается ровно один раз для каждого числа, в том числе из
+	// разных горутин и не по порядку.
+	Ack(n int64, processed bool)
+}
+
+// isAcker сообщает, подтверждает ли источник src числа.
+func isAcker(src Source[int64]) bool {
+	_, ok := src.(Acker)
+	return ok
+}
+
+// AckReport — результат подтверждения доставки: какие выданные числа так и
+// не были подтверждены, какие подтверждены больше одного раза и какие
+// подтверждены, но не выдавались. В отличие от сравнения количеств и сумм,
+// потерянное число здесь не может быть скрыто продублированным.
+type AckReport struct {
+	Unacked        []int64 // ID неподтверждённых чисел, не больше maxSequenceReport
+	UnackedCount   int64   // количество неподтверждённых чисел
+	Duplicate      []int64 // ID чисел, подтверждённых повторно, не больше maxSequenceReport
+	DuplicateCount int64   // количество повторных подтверждений
+	Unknown        []int64 // ID подтверждённых, но не выданных чисел, не больше maxSequenceReport
+	UnknownCount   int64   // количество таких чисел
+}
+
+// Err возвращает ошибку, если какие-то числа не подтверждены или
+// подтверждены неверно.
+func (r *AckReport) Err() error {
+	if r == nil || (r.UnackedCount == 0 && r.DuplicateCount == 0 && r.UnknownCount == 0) {
+		return nil
+	}
+	return fmt.Errorf("не подтверждено чисел %d %v, подтверждено повторно %d %v, подтверждено без выдачи %d %v",
+		r.UnackedCount, r.Unacked, r.DuplicateCount, r.Duplicate, r.UnknownCount, r.Unknown)
+}
+
+// AckTracker выдаёт числам ID и принимает подтверждения их окончательного
+// учёта: прихода в результирующий канал, отбрасывания, фильтрации или
+// отправки в приёмник необработанных. Подтверждение может прийти раньше
+// выдачи, если генератор отмечает выдачу уже после отправки числа. Память
+// расходуется на неподтверждённые числа и по биту на каждый ID.
+// Безопасен для конкурентного использования.
+type AckTracker struct {
+	mu      sync.Mutex
+	pending map[int64]struct{} // выданные, но не подтверждённые ID
+	early   map[int64]struct{} // ID, подтверждённые до выдачи
+	acked   []uint64           // бит id-1 установлен, если ID подтверждён
+	dup     []int64
+	dupN    int64
+}
+
+// NewAckTracker создаёт пустой AckTracker.
+func NewAckTracker() *AckTracker {
+	return &AckTracker{pending: make(map[int64]struct{}), early: make(map[int64]struct{})}
+}
+
+// Issue отмечает, что число с ID id выдано и ждёт подтверждения. ID
+// начинаются с 1.
+func (t *AckTracker) Issue(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.early[id]; ok {
+		delete(t.early, id)
+		return
+	}
+	t.pending[id] = struct{}{}
+}
+
+// Ack подтверждает окончательный учёт числа с ID id.
+func (t *AckTracker) Ack(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i, bit := (id-1)/64, uint64(1)<<((id-1)%64)
+	for int64(len(t.acked)) <= i {
+		t.acked = append(t.acked, 0)
+	}
+	if t.acked[i]&bit != 0 {
+		t.dupN++
+		if len(t.dup) < maxSequenceReport {
+			t.dup = append(t.dup, id)
+		}
+		return
+	}
+	t.acked[i] |= bit
+	if _, ok := t.pending[id]; ok {
+		delete(t.pending, id)
+		return
+	}
+	t.early[id] = struct{}{}
+}
+
+// Report возвращает текущий результат подтверждения; после остановки
+// конвейера он окончательный.
+func (t *AckTracker) Report() *AckReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &AckReport{
+		Unacked:        firstIDs(t.pending),
+		UnackedCount:   int64(len(t.pending)),
+		Duplicate:      slices.Clone(t.dup),
+		DuplicateCount: t.dupN,
+		Unknown:        firstIDs(t.early),
+		UnknownCount:   int64(len(t.early)),
+	}
+}
+
+// firstIDs возвращает не больше maxSequenceReport наименьших ID из ids по
+// возрастанию.
+func firstIDs(ids map[int64]struct{}) []int64 {
+	var out []int64
+	for id := range ids {
+		out = append(out, id)
+	}
+	slices.Sort(out)
+	if len(out) > maxSequenceReport {
+		out = out[:maxSequenceReport]
+	}
+	return out
+}
@@ -0,0 +1,199 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAckTracker(t *testing.T) {
+	tests := []struct {
+		name  string
+		issue []int64
+		ack   []int64
+		want  AckReport
+	}{
+		{"все подтверждены", ints(1, 5), ints(1, 5), AckReport{}},
+		{"подтверждение до выдачи", []int64{2}, []int64{2, 1}, AckReport{Unknown: []int64{1}, UnknownCount: 1}},
+		{"не подтверждены", ints(1, 5), []int64{2, 4}, AckReport{Unacked: []int64{1, 3, 5}, UnackedCount: 3}},
+		{"повторно", ints(1, 2), []int64{1, 2, 2, 1, 2}, AckReport{Duplicate: []int64{2, 1, 2}, DuplicateCount: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewAckTracker()
+			for _, id := range tt.issue {
+				tr.Issue(id)
+			}
+			for _, id := range tt.ack {
+				tr.Ack(id)
+			}
+			got := tr.Report()
+			if !slices.Equal(got.Unacked, tt.want.Unacked) || got.UnackedCount != tt.want.UnackedCount ||
+				!slices.Equal(got.Duplicate, tt.want.Duplicate) || got.DuplicateCount != tt.want.DuplicateCount ||
+				!slices.Equal(got.Unknown, tt.want.Unknown) || got.UnknownCount != tt.want.UnknownCount {
+				t.Errorf("Report = %+v, want %+v", got, tt.want)
+			}
+			if (got.Err() != nil) != (tt.want.UnackedCount+tt.want.DuplicateCount+tt.want.UnknownCount > 0) {
+				t.Errorf("Err = %v", got.Err())
+			}
+		})
+	}
+}
+
+// TestAckTrackerEarly проверяет, что подтверждение, пришедшее раньше
+// выдачи, закрывается выдачей.
+func TestAckTrackerEarly(t *testing.T) {
+	tr := NewAckTracker()
+	tr.Ack(1)
+	if rep := tr.Report(); rep.UnknownCount != 1 {
+		t.Errorf("до выдачи UnknownCount = %d, want 1", rep.UnknownCount)
+	}
+	tr.Issue(1)
+	if err := tr.Report().Err(); err != nil {
+		t.Errorf("после выдачи: %v", err)
+	}
+}
+
+func TestFirstIDs(t *testing.T) {
+	ids := make(map[int64]struct{})
+	for id := int64(2 * maxSequenceReport); id > 0; id-- {
+		ids[id] = struct{}{}
+	}
+	if got := firstIDs(ids); !slices.Equal(got, ints(1, maxSequenceReport)) {
+		t.Errorf("firstIDs = %v, want 1..%d", got, maxSequenceReport)
+	}
+	if got := firstIDs(nil); got != nil {
+		t.Errorf("firstIDs(nil) = %v", got)
+	}
+}
+
+// TestRunAck проверяет, что при Config.Ack подтверждается учёт каждого
+// числа: дошедшего, отфильтрованного, отброшенного и необработанного.
+func TestRunAck(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"фильтр", Config{NumWorkers: 4, Limit: 500, Process: Filter(func(v int64) bool { return v%2 == 0 })}},
+		{"отбрасывание", Config{NumWorkers: 2, BufferSize: 16, Timeout: 20 * time.Millisecond, WorkerDelay: time.Millisecond, Drain: DropRemaining}},
+		{"необработанные", Config{NumWorkers: 2, Limit: 100, DeadLetters: MemoryDeadLetters, Process: func(_ context.Context, v int64) (int64, error) {
+			if v%3 == 0 {
+				return 0, errOdd
+			}
+			return v, nil
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Ack = true
+			res, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Acks == nil {
+				t.Fatal("Acks = nil при Ack")
+			}
+			if err := res.Verify(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	if res, err := Run(context.Background(), Config{NumWorkers: 1, Limit: 5}); err != nil || res.Acks != nil {
+		t.Errorf("без Ack: Acks = %+v, err %v, want nil", res.Acks, err)
+	}
+}
+
+// ackingSource — источник последовательных чисел, запоминающий
+// подтверждения Acker.
+type ackingSource struct {
+	mu        sync.Mutex
+	n         int64          // количество выданных чисел
+	processed map[int64]bool // подтверждения по номерам чисел
+	repeated  int            // повторные подтверждения
+}
+
+func (s *ackingSource) Next(ctx context.Context) (int64, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	return s.n, true
+}
+
+func (s *ackingSource) Ack(n int64, processed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.processed[n]; ok {
+		s.repeated++
+	}
+	s.processed[n] = processed
+}
+
+// TestRunAcker проверяет, что источник Acker получает ровно одно
+// подтверждение на каждое выданное число и processed — только для
+// учтённых обработанными.
+func TestRunAcker(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		sources int // количество источников
+	}{
+		{"все обработаны", Config{NumWorkers: 4, Limit: 200}, 1},
+		{"фильтр", Config{NumWorkers: 4, Limit: 200, Process: Filter(func(v int64) bool { return v%2 == 0 })}, 1},
+		{"наибольшее число", Config{NumWorkers: 2, MaxValue: 50}, 1},
+		{"отбрасывание", Config{NumWorkers: 2, BufferSize: 16, Timeout: 20 * time.Millisecond, WorkerDelay: time.Millisecond, Drain: DropRemaining}, 1},
+		{"несколько источников", Config{NumWorkers: 2, Limit: 100}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			var srcs []*ackingSource
+			for range tt.sources {
+				srcs = append(srcs, &ackingSource{processed: map[int64]bool{}})
+				cfg.Sources = append(cfg.Sources, srcs[len(srcs)-1])
+			}
+			if tt.sources == 1 {
+				cfg.Source, cfg.Sources = cfg.Sources[0], nil
+			}
+			res, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var issued, processed int64
+			for i, src := range srcs {
+				if src.repeated != 0 || int64(len(src.processed)) != src.n {
+					t.Errorf("источник %d: выдано %d, подтверждено %d, повторно %d", i, src.n, len(src.processed), src.repeated)
+				}
+				issued += src.n
+				for _, ok := range src.processed {
+					if ok {
+						processed++
+					}
+				}
+			}
+			if want := res.OutputCount + res.SkippedCount + res.FailedCount; processed != want {
+				t.Errorf("подтверждено обработанными %d, want %d", processed, want)
+			}
+			if issued <= res.InputCount && tt.cfg.MaxValue != 0 {
+				t.Errorf("выдано %d, want больше сгенерированных %d: число больше MaxValue", issued, res.InputCount)
+			}
+		})
+	}
+}
+
+// TestAckerUnsupported проверяет, что источник Acker нельзя использовать
+// при продолжении генерации и в пакетном режиме.
+func TestAckerUnsupported(t *testing.T) {
+	src := &ackingSource{processed: map[int64]bool{}}
+	if err := (Config{NumWorkers: 1, Source: src, Resume: Checkpoint{Generated: 5}}).Validate(); err == nil {
+		t.Error("Validate с Resume и Acker без ошибки")
+	}
+	if _, err := New(Config{NumWorkers: 1, Limit: 5, Source: src}).RunBatched(context.Background(), 2, 0); err == nil {
+		t.Error("RunBatched с Acker без ошибки")
+	}
+}
@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Значения AdaptiveBufferPolicy по умолчанию.
+const (
+	defaultBufferInterval = 100 * time.Millisecond
+	defaultMaxBuffer      = 4096
+)
+
+// AdaptiveBufferPolicy — экспериментальная настройка буфера между
+// генератором и обработчиками: вместо буфера канала chIn используется
+// управляемая очередь, ёмкость которой раз в Interval удваивается, если
+// генератор ждал отправки дольше доли Target этого времени, и уменьшается
+// вдвое, если ждал меньше Target/4, в пределах MinSize и MaxSize.
+// Нулевое значение выключает настройку.
+type AdaptiveBufferPolicy struct {
+	Target   float64       // допустимая доля времени ожидания генератора; 0 — настройка выключена
+	MinSize  int           // наименьшая ёмкость очереди; 0 — BufferSize
+	MaxSize  int           // наибольшая ёмкость очереди; 0 — 4096
+	Interval time.Duration // период измерения; 0 — 100 мс
+}
+
+// BufferEvent — изменение ёмкости управляемой очереди.
+type BufferEvent struct {
+	At       time.Duration // время от запуска конвейера
+	Size     int           // ёмкость очереди после изменения
+	Pressure float64       // доля времени ожидания генератора за период
+}
+
+// enabled сообщает, включена ли настройка.
+func (a AdaptiveBufferPolicy) enabled() bool {
+	return a.Target > 0
+}
+
+// withDefaults возвращает настройку, в которой нулевые поля заменены
+// значениями по умолчанию для буфера bufferSize.
+func (a AdaptiveBufferPolicy) withDefaults(bufferSize int) AdaptiveBufferPolicy {
+	if a.MinSize == 0 {
+		a.MinSize = bufferSize
+	}
+	if a.MaxSize == 0 {
+		a.MaxSize = max(defaultMaxBuffer, a.MinSize)
+	}
+	if a.Interval == 0 {
+		a.Interval = defaultBufferInterval
+	}
+	return a
+}
+
+// validate проверяет настройку.
+func (a AdaptiveBufferPolicy) validate(bufferSize int) error {
+	if !a.enabled() {
+		if a.Target < 0 {
+			return fmt.Errorf("доля ожидания не может быть отрицательной: %v", a.Target)
+		}
+		return nil
+	}
+	a = a.withDefaults(bufferSize)
+	if a.Target > 1 {
+		return fmt.Errorf("доля ожидания должна быть не больше 1: %v", a.Target)
+	}
+	if a.MinSize < 0 || a.MinSize > a.MaxSize {
+		return fmt.Errorf("пределы ёмкости очереди должны удовлетворять 0 <= %d <= %d", a.MinSize, a.MaxSize)
+	}
+	if a.Interval < 0 {
+		return fmt.Errorf("период настройки очереди не может быть отрицательным: %v", a.Interval)
+	}
+	return nil
+}
+
+// tune раз в Interval сравнивает время ожидания генератора по stats с Target
+// и меняет ёмкость q. Изменения передаются в record. Завершается при отмене
+// ctx или закрытии done.
+func (a AdaptiveBufferPolicy) tune(ctx context.Context, clock Clock, done <-chan struct{}, stats *Stats, q *ringQueue[Event], record func(BufferEvent)) {
+	begin := clock.Now()
+	var last int64 // ожидание генератора на начало периода, нс
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-clock.After(a.Interval):
+		}
+		blocked, _ := stats.genBlocked.load()
+		pressure := float64(blocked-last) / float64(a.Interval)
+		last = blocked
+
+		size := q.capacity()
+		switch {
+		case pressure > a.Target && size < a.MaxSize:
+			size = min(max(2*size, 1), a.MaxSize)
+		case pressure < a.Target/4 && size > a.MinSize:
+			size = max(size/2, a.MinSize)
+		default:
+			continue
+		}
+		q.setCapacity(size)
+		record(BufferEvent{At: clock.Now().Sub(begin), Size: size, Pressure: pressure})
+	}
+}
+
+// ringQueue — очередь с изменяемой ёмкостью на кольцевом буфере, которая
+// пересылает значения из канала in в канал out, заменяя буфер канала.
+type ringQueue[T any] struct {
+	mu     sync.Mutex
+	size   int           // ёмкость очереди
+	resize chan struct{} // сигнал изменения ёмкости
+
+	buf        []T // кольцевой буфер
+	head, used int // начало очереди и количество значений в ней
+}
+
+// newRingQueue создаёт очередь ёмкостью size.
+func newRingQueue[T any](size int) *ringQueue[T] {
+	return &ringQueue[T]{size: size, resize: make(chan struct{}, 1)}
+}
+
+// capacity возвращает ёмкость очереди.
+func (q *ringQueue[T]) capacity() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// setCapacity меняет ёмкость очереди. Если значений в очереди больше новой
+// ёмкости, новые значения не принимаются, пока очередь не разгрузится.
+func (q *ringQueue[T]) setCapacity(size int) {
+	q.mu.Lock()
+	q.size = size
+	q.mu.Unlock()
+	select {
+	case q.resize <- struct{}{}:
+	default:
+	}
+}
+
+// run пересылает значения из in в out через очередь и закрывает out, когда
+// in закрыт и очередь пуста. При отмене ctx значения очереди и оставшиеся в
+// in передаются в drop.
+func (q *ringQueue[T]) run(ctx context.Context, in <-chan T, out chan<- T, drop func(T)) {
+	defer close(out)
+	for in != nil || q.used > 0 {
+		// читаем из in, только если в очереди есть место, и пишем в out,
+		// только если очередь не пуста
+		recv, send := in, out
+		if q.used >= max(q.capacity(), 1) {
+			recv = nil
+		}
+		var head T
+		if q.used > 0 {
+			head = q.buf[q.head]
+		} else {
+			send = nil
+		}
+		select {
+		case <-ctx.Done():
+			for ; q.used > 0; q.used-- {
+				drop(q.buf[q.head])
+				q.head = (q.head + 1) % len(q.buf)
+			}
+			if in != nil {
+				for v := range in {
+					drop(v)
+				}
+			}
+			return
+		case <-q.resize:
+		case v, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			q.push(v)
+		case send <- head:
+			var zero T
+			q.buf[q.head] = zero
+			q.head = (q.head + 1) % len(q.buf)
+			q.used--
+		}
+	}
+}
+
+// push добавляет v в конец очереди, расширяя кольцевой буфер при нехватке
+// места.
+func (q *ringQueue[T]) push(v T) {
+	if q.used == len(q.buf) {
+		buf := make([]T, max(2*len(q.buf), 1))
+		for i := 0; i < q.used; i++ {
+			buf[i] = q.buf[(q.head+i)%len(q.buf)]
+		}
+		q.buf, q.head = buf, 0
+	}
+	q.buf[(q.head+q.used)%len(q.buf)] = v
+	q.used++
+}
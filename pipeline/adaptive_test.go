@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestRingQueue проверяет, что очередь пересылает значения по порядку, не
+// принимает значений сверх ёмкости и принимает больше после её
+// увеличения.
+func TestRingQueue(t *testing.T) {
+	q := newRingQueue[int](2)
+	in, out := make(chan int), make(chan int)
+	go q.run(context.Background(), in, out, func(int) { t.Error("значение отброшено") })
+
+	in <- 1
+	in <- 2
+	select {
+	case in <- 3:
+		t.Fatal("очередь приняла значение сверх ёмкости 2")
+	case <-time.After(10 * time.Millisecond):
+	}
+	q.setCapacity(4)
+	in <- 3
+	in <- 4
+	close(in)
+	var got []int
+	for v := range out {
+		got = append(got, v)
+	}
+	if want := []int{1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("получено %v, want %v", got, want)
+	}
+}
+
+// TestRingQueueCancel проверяет, что при отмене значения очереди и
+// оставшиеся во входном канале отбрасываются, а выходной канал
+// закрывается.
+func TestRingQueueCancel(t *testing.T) {
+	q := newRingQueue[int](4)
+	in, out := make(chan int, 2), make(chan int)
+	ctx, cancel := context.WithCancel(context.Background())
+	var dropped []int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.run(ctx, in, out, func(v int) { dropped = append(dropped, v) })
+	}()
+	in <- 1
+	in <- 2
+	// ждём, пока очередь заберёт оба числа
+	for len(in) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	in <- 3
+	close(in)
+	<-done
+	if _, ok := <-out; ok {
+		t.Error("выходной канал не закрыт")
+	}
+	if slices.Sort(dropped); !slices.Equal(dropped, []int{1, 2, 3}) {
+		t.Errorf("отброшено %v, want [1 2 3]", dropped)
+	}
+}
+
+func TestAdaptiveBufferValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  AdaptiveBufferPolicy
+		wantErr bool
+	}{
+		{"выключена", AdaptiveBufferPolicy{}, false},
+		{"по умолчанию", AdaptiveBufferPolicy{Target: 0.1}, false},
+		{"отрицательная доля", AdaptiveBufferPolicy{Target: -0.1}, true},
+		{"доля больше 1", AdaptiveBufferPolicy{Target: 2}, true},
+		{"пределы", AdaptiveBufferPolicy{Target: 0.1, MinSize: 10, MaxSize: 5}, true},
+		{"отрицательный период", AdaptiveBufferPolicy{Target: 0.1, Interval: -time.Second}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.validate(0); (err != nil) != tt.wantErr {
+				t.Errorf("validate = %v, want ошибку %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRunAdaptiveBuffer проверяет, что очередь растёт, пока генератор
+// ждёт медленных обработчиков, и все числа учитываются.
+func TestRunAdaptiveBuffer(t *testing.T) {
+	cfg := Config{
+		NumWorkers: 1, Limit: 200, WorkerDelay: 100 * time.Microsecond,
+		AdaptiveBuffer: AdaptiveBufferPolicy{Target: 0.1, MaxSize: 64, Interval: time.Millisecond},
+	}
+	res, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if res.OutputCount != 200 {
+		t.Errorf("OutputCount = %d, want 200", res.OutputCount)
+	}
+	// после остановки генерации очередь может снова уменьшиться, поэтому
+	// рост проверяется по наибольшей ёмкости
+	largest := 0
+	for _, ev := range res.BufferResizes {
+		if ev.Size > 64 {
+			t.Errorf("ёмкость %d больше предела 64", ev.Size)
+		}
+		largest = max(largest, ev.Size)
+	}
+	if len(res.BufferResizes) < 2 || largest <= res.BufferResizes[0].Size {
+		t.Errorf("изменения ёмкости %+v, want рост очереди", res.BufferResizes)
+	}
+}
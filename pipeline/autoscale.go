@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Значения AutoscalePolicy по умолчанию.
+const (
+	defaultScaleInterval = 100 * time.Millisecond
+	defaultScaleUp       = 0.5
+	defaultScaleDown     = 0.1
+)
+
+// AutoscalePolicy — правила изменения количества обработчиков по давлению на
+// входе: доле времени, которую генератор провёл в ожидании отправки числа в
+// chIn. Раз в Interval, если давление не меньше ScaleUp, добавляется один
+// обработчик, а если не больше ScaleDown — один завершается, не выходя за
+// пределы MinWorkers и MaxWorkers. Нулевое значение выключает автоматическое
+// масштабирование.
+type AutoscalePolicy struct {
+	MinWorkers int           // наименьшее количество обработчиков; 0 — 1
+	MaxWorkers int           // наибольшее количество обработчиков; 0 — масштабирование выключено
+	Interval   time.Duration // период измерения давления; 0 — 100 мс
+	ScaleUp    float64       // давление, при котором обработчик добавляется; 0 — 0.5
+	ScaleDown  float64       // давление, при котором обработчик завершается; 0 — 0.1
+}
+
+// enabled сообщает, включено ли масштабирование.
+func (a AutoscalePolicy) enabled() bool {
+	return a.MaxWorkers > 0
+}
+
+// withDefaults возвращает правила, в которых нулевые поля заменены
+// значениями по умолчанию.
+func (a AutoscalePolicy) withDefaults() AutoscalePolicy {
+	if a.MinWorkers == 0 {
+		a.MinWorkers = 1
+	}
+	if a.Interval == 0 {
+		a.Interval = defaultScaleInterval
+	}
+	if a.ScaleUp == 0 {
+		a.ScaleUp = defaultScaleUp
+	}
+	if a.ScaleDown == 0 {
+		a.ScaleDown = defaultScaleDown
+	}
+	return a
+}
+
+// validate проверяет правила для начального количества обработчиков
+// workers.
+func (a AutoscalePolicy) validate(workers int) error {
+	if !a.enabled() {
+		return nil
+	}
+	a = a.withDefaults()
+	if a.MinWorkers < 1 || a.MinWorkers > workers || workers > a.MaxWorkers {
+		return fmt.Errorf("количество обработчиков %d должно быть в пределах масштабирования [%d, %d]", workers, a.MinWorkers, a.MaxWorkers)
+	}
+	if a.Interval < 0 {
+		return fmt.Errorf("период масштабирования не может быть отрицательным: %v", a.Interval)
+	}
+	if a.ScaleDown < 0 || a.ScaleDown >= a.ScaleUp || a.ScaleUp > 1 {
+		return fmt.Errorf("пороги масштабирования должны удовлетворять 0 <= %v < %v <= 1", a.ScaleDown, a.ScaleUp)
+	}
+	return nil
+}
+
+// autoscale раз в Interval измеряет давление по времени ожидания отправки
+// blocked, накопленному генератором, и меняет количество обработчиков pool.
+// Завершается при отмене ctx или закрытии done.
+func (a AutoscalePolicy) autoscale(ctx context.Context, clock Clock, done <-chan struct{}, blocked *atomic.Int64, pool *workerPool) {
+	a = a.withDefaults()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-clock.After(a.Interval):
+		}
+		pressure := float64(blocked.Swap(0)) / float64(a.Interval)
+		n := pool.workers()
+		switch {
+		case pressure >= a.ScaleUp && n < a.MaxWorkers:
+			pool.adjust(1, ScaleEvent{Pressure: pressure})
+		case pressure <= a.ScaleDown && n > a.MinWorkers:
+			pool.adjust(-1, ScaleEvent{Pressure: pressure})
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Batch собирает значения из канала in в пачки до size значений и
+// отправляет их в канал out. Неполная пачка отправляется, если с прихода её
+// первого значения по часам clock прошло linger (0 — ждать заполнения) или
+// канал in закрыт. При отмене контекста сборка прекращается.
+// Параметры
+// ctx - контекст
+// in - канал, откуда будут прочитаны значения
+// out - канал, куда будут записаны пачки
+// size - наибольший размер пачки; значения меньше 1 трактуются как 1
+// linger - наибольшее время ожидания заполнения пачки
+// clock - часы для linger
+// Возвращает значения, прочитанные из in, но не отправленные из-за отмены
+// контекста.
+func Batch[T any](ctx context.Context, in <-chan T, out chan<- []T, size int, linger time.Duration, clock Clock) []T {
+	defer close(out) // перед выходом из функции закрываем канал out
+
+	if size < 1 {
+		size = 1
+	}
+	var (
+		batch    []T              // текущая пачка
+		deadline <-chan time.Time // истечение linger для текущей пачки
+		flush    = func() bool {  // отправляет пачку; false — контекст отменён
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- batch:
+				batch, deadline = nil, nil
+				return true
+			}
+		}
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return batch
+		case <-deadline:
+			if !flush() {
+				return batch
+			}
+		case v, ok := <-in:
+			if !ok {
+				if len(batch) > 0 && !flush() {
+					return batch
+				}
+				return nil
+			}
+			if len(batch) == 0 && linger > 0 {
+				deadline = clock.After(linger)
+			}
+			batch = append(batch, v)
+			if len(batch) == size && !flush() {
+				return batch
+			}
+		}
+	}
+}
+
+// ForEach превращает обработку отдельных значений в обработку пачки:
+// process применяется к каждому значению по порядку, отфильтрованные с
+// помощью ErrSkip значения исключаются из результата и после успешной
+// обработки всей пачки передаются в onSkip, если он задан. Другая ошибка
+// прерывает обработку пачки и возвращается.
+func ForEach[T any](process func(context.Context, T) (T, error), onSkip func(T)) func(context.Context, []T) ([]T, error) {
+	return func(ctx context.Context, batch []T) ([]T, error) {
+		res := make([]T, 0, len(batch))
+		var skipped []T
+		for _, v := range batch {
+			r, err := process(ctx, v)
+			switch {
+			case errors.Is(err, ErrSkip):
+				skipped = append(skipped, v)
+				continue
+			case err != nil:
+				return batch, err
+			}
+			res = append(res, r)
+		}
+		if onSkip != nil {
+			for _, v := range skipped {
+				onSkip(v)
+			}
+		}
+		return res, nil
+	}
+}
+
+// RunBatched запускает конвейер в пакетном режиме: числа собираются в
+// пачки до size штук (неполная пачка отправляется по истечении linger), и
+// каналы между генератором, обработчиками и сборкой передают пачки, а не
+// отдельные числа, что снижает накладные расходы на каждое число.
+// Config.Process и пауза WorkerDelay применяются к каждому числу пачки, а
+// политика Retry повторяет обработку всей пачки. Учитываются настройки
+// NumWorkers, Timeout, Warmup, BufferSize, OutBufferSize, ResultBufferSize,
+// WorkerDelay, WorkerDelayFunc, WorkerDelayFactors, Process, Middleware,
+// Retry, Chaos, BigSums, LeakTimeout, Source, Ready, Limit, MaxValue, Rate,
+// Burst, Collect, Sink, SinkLimit, Reducers, Reservoir, Logger и Clock, а
+// также Stop, Pause и Stats.
+// Остальные возможности Run в пакетном режиме не поддерживаются, и
+// RunBatched возвращает ошибку, если они заданы; числа всегда
+// дообрабатываются полностью (DrainAll), а задержка и ожидание отправки не
+// измеряются.
+func (p *Pipeline) RunBatched(ctx context.Context, size int, linger time.Duration) (Result, error) {
+	cfg := p.cfg
+	if err := cfg.Validate(); err != nil {
+		return Result{}, err
+	}
+	if size < 1 {
+		return Result{}, fmt.Errorf("размер пачки должен быть положительным: %d", size)
+	}
+	if linger < 0 {
+		return Result{}, fmt.Errorf("время ожидания пачки не может быть отрицательным: %v", linger)
+	}
+	if err := cfg.validateBatched(); err != nil {
+		return Result{}, err
+	}
+	numWorkers := cfg.NumWorkers
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	// генерация останавливается по таймауту или Stop, а обработка — только
+	// при отмене ctx или ошибке этапа
+	genCtx, stopGen := context.WithCancelCause(ctx)
+	defer stopGen(nil)
+	workCtx, stopWork := context.WithCancelCause(ctx)
+	defer stopWork(nil)
+
+	// g — горутины конвейера; ошибка любой из них останавливает весь
+	// конвейер, а первая возвращается из RunBatched
+	g := newGroup(func(err error) {
+		logger.Error("ошибка этапа", "err", err)
+		stopGen(err)
+		stopWork(err)
+	})
+	g.Go("таймаут", func() error {
+		var timeout <-chan time.Time
+		if cfg.Timeout > 0 {
+			timeout = clock.After(cfg.Warmup + cfg.Timeout)
+		}
+		select {
+		case <-p.stop:
+			stopGen(p.stopCause)
+		case <-timeout:
+			stopGen(ErrTimeout)
+		case <-genCtx.Done():
+		}
+		return nil
+	})
+
+	src := cfg.Source
+	if src == nil {
+		src = Sequential()
+	}
+	stats := NewStats(numWorkers)
+	if cfg.BigSums {
+		stats.EnableBigSums()
+	}
+	p.stats.Store(stats)
+	warm := startWarmup(g, cfg.Warmup, clock, stats, genCtx.Done())
+	state := newRunState(clock, g, numWorkers)
+	p.state.Store(state)
+	defer state.finish()
+
+	genOpts := []GeneratorOption[int64]{WithReadiness[int64](cfg.Ready), WithLimit[int64](cfg.Limit), WithRateLimit[int64](p.gate)}
+	if cfg.MaxValue != 0 {
+		genOpts = append(genOpts, WithMaxValue(cfg.MaxValue))
+	}
+	if cfg.Rate > 0 {
+		genOpts = append(genOpts, WithRateLimit[int64](NewTokenBucket(cfg.Rate, cfg.Burst, clock)))
+	}
+	genOpts = append(genOpts, WithHooks[int64](
+		GeneratorHookFuncs[int64]{After: stats.RecordIn},
+		GeneratorHookFuncs[int64]{After: func(v int64) { state.last.Store(v) }},
+	))
+	logger.Info("конвейер запущен",
+		"workers", numWorkers,
+		"buffer", cfg.BufferSize,
+		"timeout", cfg.Timeout,
+		"warmup", cfg.Warmup,
+		"limit", cfg.Limit,
+		"batch", size,
+		"linger", linger,
+	)
+
+	chIn := make(chan int64, cfg.BufferSize)
+	g.Go("генератор", func() error {
+		err := protect(func() error {
+			Generator(genCtx, chIn, src, genOpts...)
+			return nil
+		})
+		if err == nil && genCtx.Err() == nil {
+			stopGen(generationEnd(stats.Snapshot().InputCount, cfg.Limit))
+		}
+		logger.Info("генерация остановлена", "generated", stats.Snapshot().InputCount, "cause", context.Cause(genCtx))
+		if err != nil {
+			return &GeneratorError{Err: err}
+		}
+		return nil
+	})
+
+	// batches — пачки чисел для обработчиков; unsent — числа, собранные в
+	// пачку, но не отправленные из-за остановки обработки
+	batches := make(chan []int64, cfg.BufferSize)
+	unsent := make(chan []int64, 1)
+	g.Go("сборка пачек", func() error {
+		unsent <- Batch(workCtx, chIn, batches, size, linger, clock)
+		return nil
+	})
+
+	process := cfg.Process
+	if process == nil {
+		delay := cfg.WorkerDelayFunc
+		if delay == nil {
+			delay = func() time.Duration { return cfg.WorkerDelay }
+		}
+		process = DelayFunc[int64](clock, delay)
+	}
+	if cfg.Chaos.enabled() {
+		process = Chaos[int64](cfg.Chaos, clock)(process)
+	}
+	process = Wrap(process, cfg.Middleware...)
+	// dropBatch учитывает пачку b, отброшенную обработчиком i; пачки,
+	// отброшенные вне обработчиков, учитываются с i = 0
+	dropBatch := func(i int, b []int64) {
+		for _, v := range b {
+			stats.RecordDrop(i, v)
+		}
+	}
+
+	outs := make([]<-chan []int64, numWorkers)
+	for i := range outs {
+		out := make(chan []int64, bufferSize(cfg.OutBufferSize, cfg.BufferSize))
+		outs[i] = out
+		g.Go(fmt.Sprintf("обработчик %d", i), func() error {
+			err := protect(func() error {
+				return Worker(workCtx, batches, out,
+					WithProcess(ForEach(cfg.slowdown(i, clock, process), func(v int64) { stats.RecordSkip(i, v) })),
+					WithOnDrop(func(b []int64) { dropBatch(i, b) }),
+					WithLimiter[[]int64](p.gate),
+					WithRetry[[]int64](cfg.Retry),
+					WithClock[[]int64](clock),
+					WithOnRetry[[]int64](func(int, error) {
+						stats.RecordRetry(i)
+					}),
+					WithOnItem[[]int64](func(t WorkerTiming) {
+						stats.RecordWorkerItem(i, t)
+					}),
+					WithOnState[[]int64](func(s WorkerState) {
+						state.setWorker(i, s)
+					}))
+			})
+			if err != nil {
+				return &WorkerError{Index: i, Err: err}
+			}
+			return nil
+		})
+	}
+	merge := newMerger(func(i int, b []int64) {
+		for _, v := range b {
+			stats.RecordOut(i, v)
+		}
+	}, func(err error) {
+		g.fail(fmt.Errorf("сборка результатов: %w", err))
+	}, bufferSize(cfg.ResultBufferSize, numWorkers))
+	for i, out := range outs {
+		merge.add(i, out, nil)
+	}
+	merge.seal()
+	chOut := merge.out
+	state.setDepths(func() QueueDepths {
+		d := QueueDepths{Input: len(chIn), Result: len(chOut), Workers: make([]int, numWorkers), Outputs: make([]int, numWorkers)}
+		for i, out := range outs {
+			d.Workers[i], d.Outputs[i] = len(batches), len(out)
+		}
+		return d
+	})
+
+	// после ошибки Collect или Sink числа в них больше не передаются
+	collect := cfg.Collect
+	sink := cfg.sinkWriter(ctx, cfg.Sink, clock, g.fail, func(d time.Duration) {
+		stats.recordSinkBlock(0, d)
+	})
+	reducers := startReducers(g, cfg.Reducers, g.fail, ctx.Done())
+	// leaked закрывается, если после остановки обработки горутины
+	// конвейера не завершились за LeakTimeout
+	finished := make(chan struct{})
+	defer close(finished)
+	leaked := cfg.leakDeadline(workCtx, clock, finished)
+	start := clock.Now()
+	drainUntil(chOut, leaked, func(b []int64) {
+		for _, v := range b {
+			if cfg.Reservoir != nil {
+				cfg.Reservoir.Add(v)
+			}
+			reducers.add(v)
+			sink.write(v)
+			if collect == nil {
+				continue
+			}
+			if err := protect(func() error { return collect(v) }); err != nil {
+				g.fail(&SinkError{Err: err})
+				collect = nil
+			}
+		}
+	})
+	elapsed := clock.Now().Sub(start)
+	sink.flush()
+	reduced := reducers.close(leaked)
+
+	// обработчики завершились; всё, что не дошло до них, отброшено
+	cause := context.Cause(genCtx)
+	stopGen(nil)
+	stopWork(nil)
+	if b, ok := recvUntil(unsent, leaked); ok {
+		dropBatch(0, b)
+	}
+	drainUntil(batches, leaked, func(b []int64) { dropBatch(0, b) })
+	drainUntil(chIn, leaked, func(v int64) { stats.RecordDrop(0, v) })
+	// дожидаемся всех горутин конвейера; первая ошибка любой из них,
+	// если она была, возвращается из RunBatched
+	err := g.WaitUntil(leaked)
+	var sample []int64
+	if cfg.Reservoir != nil {
+		sample = cfg.Reservoir.Sample()
+	}
+	snap := stats.Snapshot()
+	res := Result{
+		Snapshot:    snap,
+		Sample:      sample,
+		Drain:       DrainAll,
+		Transformed: cfg.Process != nil,
+		Duration:    elapsed,
+		StopCause:   cause,
+		Files:       sink.files(),
+		Reduced:     reduced,
+		Warmup:      warm.result(snap, start, elapsed),
+	}
+	logger.Info("конвейер остановлен",
+		slog.Group("input", "count", res.InputCount, "sum", res.InputSum),
+		slog.Group("output", "count", res.OutputCount, "sum", res.OutputSum),
+		slog.Group("dropped", "count", res.DroppedCount, "sum", res.DroppedSum),
+		slog.Group("skipped", "count", res.SkippedCount, "sum", res.SkippedSum),
+		"duration", res.Duration,
+		"cause", res.StopCause,
+	)
+	if res.SumOverflow {
+		logBigSums(logger, res.Snapshot)
+	}
+	return res, err
+}
+
+// validateBatched проверяет, что заданы только настройки, которые
+// поддерживает RunBatched, и сообщает обо всех остальных сразу.
+func (c Config) validateBatched() error {
+	unsupported := []struct {
+		set  bool
+		what string
+	}{
+		{c.Drain != DrainAll, "политика дообработки " + c.Drain.String()},
+		{len(c.Sources) > 0, "несколько источников"},
+		{isAcker(c.Source), "источник, подтверждающий числа"},
+		{c.Ordered || c.ReorderWindow != 0, "сохранение порядка чисел"},
+		{c.VerifySequence, "проверка номеров чисел"},
+		{c.CheckMonotonic || c.DetectGaps, "проверка порядка чисел"},
+		{c.Trend.enabled(), "скользящее среднее"},
+		{c.Ack, "подтверждение доставки"},
+		{c.Distributor != nil, "раздача чисел обработчикам"},
+		{c.Priority.enabled(), "приоритеты чисел"},
+		{len(c.GeneratorHooks) > 0, "обработчики событий генерации"},
+		{c.Dedup.enabled(), "подавление повторов"},
+		{c.WorkerSinks != nil, "приёмники обработчиков"},
+		{c.Autoscale.enabled() || c.MaxWorkers != 0, "масштабирование обработчиков"},
+		{c.AdaptiveBuffer.enabled(), "подстройка буфера"},
+		{c.Spill != nil, "очередь с вытеснением на диск"},
+		{c.DeadLetters != nil, "канал необработанных чисел"},
+		{c.ItemTimeout != 0, "время обработки числа"},
+		{c.Watchdog.enabled(), "наблюдение за зависшими обработчиками"},
+		{c.Checkpoint.enabled(), "сохранение состояния"},
+		{c.Resume != Checkpoint{}, "продолжение генерации"},
+		{c.Record != nil, "запись хода запуска"},
+		{c.SpillThreshold > 0, "вытеснение чисел на диск"},
+		{c.Metrics != nil, "метрики Prometheus"},
+		{c.Tracer != nil, "трассировка"},
+	}
+	var errs []error
+	for _, u := range unsupported {
+		if u.set {
+			errs = append(errs, fmt.Errorf("%s не поддерживается в пакетном режиме", u.what))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,301 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/queue"
+)
+
+// TestBatch проверяет разбиение чисел на пачки.
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		size   int
+		want   [][]int64
+	}{
+		{"пусто", nil, 3, nil},
+		{"ровно", ints(1, 6), 3, [][]int64{{1, 2, 3}, {4, 5, 6}}},
+		{"неполная последняя", ints(1, 5), 2, [][]int64{{1, 2}, {3, 4}, {5}}},
+		{"размер меньше 1", ints(1, 2), 0, [][]int64{{1}, {2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, out := make(chan int64, len(tt.values)), make(chan []int64, len(tt.values)+1)
+			for _, v := range tt.values {
+				in <- v
+			}
+			close(in)
+			if rest := Batch(context.Background(), in, out, tt.size, 0, nil); rest != nil {
+				t.Errorf("неотправленные числа %v", rest)
+			}
+			if got := collect(out); !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("пачки %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestForEach проверяет обработку пачки по одному числу.
+func TestForEach(t *testing.T) {
+	process := func(_ context.Context, v int64) (int64, error) {
+		switch {
+		case v < 0:
+			return 0, errOdd
+		case v%2 != 0:
+			return 0, ErrSkip
+		}
+		return 10 * v, nil
+	}
+	tests := []struct {
+		name    string
+		batch   []int64
+		want    []int64
+		skipped []int64
+		wantErr bool
+	}{
+		{"пачка", ints(1, 4), []int64{20, 40}, []int64{1, 3}, false},
+		{"ошибка возвращает исходную пачку", []int64{1, 2, -1}, []int64{1, 2, -1}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var skipped []int64
+			got, err := ForEach(process, func(v int64) { skipped = append(skipped, v) })(context.Background(), tt.batch)
+			if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) || !slices.Equal(skipped, tt.skipped) {
+				t.Errorf("ForEach = %v, %v, отфильтрованы %v; want %v, отфильтрованы %v", got, err, skipped, tt.want, tt.skipped)
+			}
+		})
+	}
+}
+
+// TestRunBatched проверяет, что при любом размере пачки все числа доходят
+// до результирующего канала и Collect.
+func TestRunBatched(t *testing.T) {
+	for _, size := range []int{1, 7, 100} {
+		var mu sync.Mutex
+		var collected int64
+		cfg := Config{NumWorkers: 3, Limit: 1000, Collect: func(int64) error {
+			mu.Lock()
+			collected++
+			mu.Unlock()
+			return nil
+		}}
+		res, err := New(cfg).RunBatched(context.Background(), size, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := res.Verify(); err != nil {
+			t.Fatalf("пачки по %d: %v", size, err)
+		}
+		if res.OutputCount != 1000 || collected != 1000 {
+			t.Errorf("пачки по %d: дошло %d чисел, в Collect %d", size, res.OutputCount, collected)
+		}
+	}
+}
+
+// TestBatchLinger проверяет, что неполная пачка отправляется по истечении
+// linger.
+func TestBatchLinger(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	in, out := make(chan int64), make(chan []int64)
+	go Batch(context.Background(), in, out, 10, time.Second, clock)
+	in <- 1
+	in <- 2
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+	if got := <-out; !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("пачка по linger %v, want [1 2]", got)
+	}
+	close(in)
+	if _, ok := <-out; ok {
+		t.Error("после закрытия in отправлена пустая пачка")
+	}
+}
+
+// TestRunBatchedError проверяет, что ошибка обработки останавливает
+// пакетный конвейер, а её пачка учитывается как отброшенная.
+func TestRunBatchedError(t *testing.T) {
+	cfg := Config{NumWorkers: 2, Limit: 100, Process: func(_ context.Context, v int64) (int64, error) {
+		if v == 50 {
+			return 0, errOdd
+		}
+		return v, nil
+	}}
+	res, err := New(cfg).RunBatched(context.Background(), 10, 0)
+	var we *WorkerError
+	if !errors.As(err, &we) || !errors.Is(err, errOdd) {
+		t.Fatalf("RunBatched = %v, want *WorkerError с errOdd", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	if res.DroppedCount < 10 {
+		t.Errorf("отброшено %d чисел, want хотя бы пачку с ошибкой", res.DroppedCount)
+	}
+}
+
+// TestRunBatchedRetry проверяет, что политика Config.Retry повторяет
+// обработку пачки, а без неё ошибка останавливает конвейер.
+func TestRunBatchedRetry(t *testing.T) {
+	// process не обрабатывает с первой попытки первое число каждой пачки
+	// по 10
+	newProcess := func() func(context.Context, int64) (int64, error) {
+		var mu sync.Mutex
+		failed := make(map[int64]bool)
+		return func(_ context.Context, v int64) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if v%10 == 1 && !failed[v] {
+				failed[v] = true
+				return 0, errOdd
+			}
+			return v, nil
+		}
+	}
+	tests := []struct {
+		name    string
+		retry   RetryPolicy
+		wantErr bool
+	}{
+		{"без повторов", RetryPolicy{}, true},
+		{"с повторами", RetryPolicy{Attempts: 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{NumWorkers: 2, Limit: 100, Process: newProcess(), Retry: tt.retry}
+			res, err := New(cfg).RunBatched(context.Background(), 10, 0)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, errOdd)) {
+				t.Fatalf("RunBatched = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if err := res.Verify(); err != nil {
+				t.Fatal(err)
+			}
+			var retries int64
+			for _, n := range res.Retries {
+				retries += n
+			}
+			if res.OutputCount != 100 || retries != 10 {
+				t.Errorf("дошло %d чисел, повторов %d, want 100 и 10", res.OutputCount, retries)
+			}
+		})
+	}
+}
+
+// TestRunBatchedUnsupported проверяет, что RunBatched отказывается от
+// настроек, которые не поддерживает, а не пропускает их молча.
+func TestRunBatchedUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(c *Config)
+	}{
+		{"Drain", func(c *Config) { c.Drain = DropRemaining }},
+		{"Sources", func(c *Config) { c.Sources = []Source[int64]{Sequential(), Fibonacci()} }},
+		{"Ordered", func(c *Config) { c.Ordered = true }},
+		{"VerifySequence", func(c *Config) { c.VerifySequence = true }},
+		{"Ack", func(c *Config) { c.Ack = true }},
+		{"CheckMonotonic", func(c *Config) { c.CheckMonotonic = true }},
+		{"DetectGaps", func(c *Config) { c.DetectGaps = true }},
+		{"Trend", func(c *Config) { c.Trend.Alpha = 0.5 }},
+		{"ItemTimeout", func(c *Config) { c.ItemTimeout = time.Second }},
+		{"Watchdog", func(c *Config) { c.Watchdog.Stall = time.Second }},
+		{"Distributor", func(c *Config) { c.Distributor = RoundRobin[Event]() }},
+		{"MaxWorkers", func(c *Config) { c.MaxWorkers = 4 }},
+		{"AdaptiveBuffer", func(c *Config) { c.AdaptiveBuffer.Target = 0.1 }},
+		{"Spill", func(c *Config) { c.Spill = &queue.Queue{} }},
+		{"DeadLetters", func(c *Config) { c.DeadLetters = MemoryDeadLetters }},
+		{"SpillThreshold", func(c *Config) { c.SpillThreshold = 10 }},
+		{"Checkpoint", func(c *Config) { c.Checkpoint.Path = "state.json" }},
+		{"Resume", func(c *Config) { c.Resume.Generated = 5 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{NumWorkers: 2, Limit: 10}
+			tt.set(&cfg)
+			_, err := New(cfg).RunBatched(context.Background(), 4, 0)
+			if err == nil || !strings.Contains(err.Error(), "не поддерживается в пакетном режиме") {
+				t.Errorf("RunBatched = %v, want ошибку о неподдерживаемой настройке", err)
+			}
+		})
+	}
+	// все неподдерживаемые настройки перечисляются сразу
+	err := Config{NumWorkers: 1, VerifySequence: true, Ordered: true}.validateBatched()
+	if err == nil || strings.Count(err.Error(), "\n") != 1 {
+		t.Errorf("validateBatched = %q, want две ошибки", err)
+	}
+}
+
+// BenchmarkRunBatched сравнивает передачу чисел пачками разного размера с
+// передачей по одному числу в Run. Одна операция — одно число, прошедшее
+// конвейер.
+func BenchmarkRunBatched(b *testing.B) {
+	for _, workers := range []int{1, 4} {
+		cfg := Config{NumWorkers: workers}
+		b.Run(fmt.Sprintf("по одному/обработчики=%d", workers), func(b *testing.B) {
+			benchBatched(b, cfg, 0)
+		})
+		for _, size := range []int{16, 256} {
+			b.Run(fmt.Sprintf("пачки=%d/обработчики=%d", size, workers), func(b *testing.B) {
+				benchBatched(b, cfg, size)
+			})
+		}
+	}
+}
+
+// BenchmarkRun сравнивает накладные расходы конвейера без паузы обработки
+// при разном количестве обработчиков, размере буфера и раздаче чисел, как
+// команда bench. Одна операция — одно число, прошедшее конвейер.
+func BenchmarkRun(b *testing.B) {
+	distributors := []struct {
+		name string
+		new  func() Distributor[Event]
+	}{
+		{"shared", Shared[Event]},
+		{"round-robin", RoundRobin[Event]},
+		{"least-loaded", LeastLoaded[Event]},
+		{"work-stealing", WorkStealing[Event]},
+	}
+	for _, workers := range []int{1, 4, 16} {
+		for _, buffer := range []int{0, 64} {
+			for _, d := range distributors {
+				b.Run(fmt.Sprintf("%s/обработчики=%d/буфер=%d", d.name, workers, buffer), func(b *testing.B) {
+					benchBatched(b, Config{NumWorkers: workers, BufferSize: buffer, Distributor: d.new()}, 0)
+				})
+			}
+		}
+	}
+}
+
+// benchBatched пропускает через конвейер с настройками cfg b.N чисел
+// пачками по size штук; size 0 — по одному числу через Run.
+func benchBatched(b *testing.B, cfg Config, size int) {
+	cfg.Limit = int64(b.N)
+	p := New(cfg)
+	b.ReportAllocs()
+	b.ResetTimer()
+	var res Result
+	var err error
+	if size == 0 {
+		res, err = p.Run(context.Background())
+	} else {
+		res, err = p.RunBatched(context.Background(), size, 0)
+	}
+	b.StopTimer()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if res.OutputCount != int64(b.N) {
+		b.Fatalf("дошло %d чисел, want %d", res.OutputCount, b.N)
+	}
+}
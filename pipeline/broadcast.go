@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBroadcastBuffer — размер буфера приёмника BroadcastSink по
+// умолчанию.
+const DefaultBroadcastBuffer = 1024
+
+// SlowSinkPolicy — что делает BroadcastSink с числом, если буфер приёмника
+// заполнен.
+type SlowSinkPolicy int
+
+const (
+	// SlowSinkBlock — ждать, пока в буфере освободится место: медленный
+	// приёмник задерживает остальные и весь конвейер
+	SlowSinkBlock SlowSinkPolicy = iota
+	// SlowSinkDrop — не передавать число этому приёмнику и учесть его в
+	// BroadcastStats.Dropped: остальные приёмники получают числа без
+	// задержки
+	SlowSinkDrop
+)
+
+// String возвращает имя политики для флагов и журнала.
+func (p SlowSinkPolicy) String() string {
+	switch p {
+	case SlowSinkBlock:
+		return "block"
+	case SlowSinkDrop:
+		return "drop"
+	}
+	return fmt.Sprintf("SlowSinkPolicy(%d)", int(p))
+}
+
+// ParseSlowSinkPolicy разбирает политику медленного приёмника: block или
+// drop.
+func ParseSlowSinkPolicy(s string) (SlowSinkPolicy, error) {
+	switch s {
+	case "block":
+		return SlowSinkBlock, nil
+	case "drop":
+		return SlowSinkDrop, nil
+	}
+	return 0, fmt.Errorf("неизвестная политика медленного приёмника %q: ожидается block или drop", s)
+}
+
+// BroadcastTarget — приёмник BroadcastSink.
+type BroadcastTarget struct {
+	Name   string // имя приёмника в ошибках и BroadcastStats
+	Sink   Sink
+	Buffer int // сколько чисел ждут приёмника; 0 — DefaultBroadcastBuffer
+	// Slow — что делать с числом, если буфер заполнен
+	Slow SlowSinkPolicy
+}
+
+// BroadcastStats — статистика приёмника BroadcastSink.
+type BroadcastStats struct {
+	Name    string
+	Written int64 // числа, записанные в приёмник
+	// Dropped — числа, не переданные приёмнику по SlowSinkDrop
+	Dropped int64
+	// Blocked — сколько Write ждал места в буфере по SlowSinkBlock
+	Blocked time.Duration
+}
+
+// broadcastTarget — приёмник BroadcastSink со своей горутиной.
+type broadcastTarget struct {
+	BroadcastTarget
+	in   chan int64
+	done chan struct{} // закрывается, когда горутина завершилась
+
+	written, dropped, blocked atomic.Int64
+
+	errOnce sync.Once
+	err     error         // первая ошибка приёмника
+	failed  chan struct{} // закрывается при первой ошибке
+}
+
+// BroadcastSink — приёмник, передающий каждое число всем своим приёмникам,
+// например в файл и на HTTP-сервер одновременно, чтобы один запуск
+// обслуживал несколько потребителей. У каждого приёмника своя горутина и
+// буфер, поэтому они работают независимо, а заполненный буфер обрабатывается
+// по политике приёмника SlowSinkPolicy. Ошибка любого приёмника
+// возвращается из следующего Write. Flush дописывает все приёмники и
+// завершает их горутины, поэтому после него Write не вызывается. Вызовы
+// Write и Flush не должны пересекаться; Stats можно вызывать в любое время.
+type BroadcastSink struct {
+	clock   Clock
+	targets []*broadcastTarget
+	closed  bool
+}
+
+// NewBroadcastSink создаёт приёмник, передающий числа приёмникам targets и
+// измеряющий ожидание по часам clock; nil — SystemClock.
+func NewBroadcastSink(clock Clock, targets ...BroadcastTarget) (*BroadcastSink, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("не заданы приёмники")
+	}
+	for _, t := range targets {
+		if t.Sink == nil {
+			return nil, fmt.Errorf("приёмник %s не задан", t.Name)
+		}
+		if t.Buffer < 0 {
+			return nil, fmt.Errorf("буфер приёмника %s не может быть отрицательным: %d", t.Name, t.Buffer)
+		}
+		if t.Slow != SlowSinkBlock && t.Slow != SlowSinkDrop {
+			return nil, fmt.Errorf("приёмник %s: неизвестная политика %v", t.Name, t.Slow)
+		}
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	s := &BroadcastSink{clock: clock}
+	for _, t := range targets {
+		if t.Buffer == 0 {
+			t.Buffer = DefaultBroadcastBuffer
+		}
+		bt := &broadcastTarget{
+			BroadcastTarget: t,
+			in:              make(chan int64, t.Buffer),
+			done:            make(chan struct{}),
+			failed:          make(chan struct{}),
+		}
+		go bt.run()
+		s.targets = append(s.targets, bt)
+	}
+	return s, nil
+}
+
+// run записывает числа буфера в приёмник, а после закрытия буфера
+// дописывает его. После ошибки числа только вычитываются, чтобы Write не
+// ждал места в буфере.
+func (t *broadcastTarget) run() {
+	defer close(t.done)
+	// приёмник пишет и после отмены контекста Write: числа уже приняты
+	ctx := context.Background()
+	for v := range t.in {
+		if t.failedErr() != nil {
+			continue
+		}
+		if err := t.Sink.Write(ctx, v); err != nil {
+			t.setErr(err)
+			continue
+		}
+		t.written.Add(1)
+	}
+	if t.failedErr() == nil {
+		if err := t.Sink.Flush(); err != nil {
+			t.setErr(err)
+		}
+	}
+}
+
+// setErr запоминает первую ошибку приёмника.
+func (t *broadcastTarget) setErr(err error) {
+	t.errOnce.Do(func() {
+		t.err = fmt.Errorf("приёмник %s: %w", t.Name, err)
+		close(t.failed)
+	})
+}
+
+// failedErr возвращает первую ошибку приёмника или nil.
+func (t *broadcastTarget) failedErr() error {
+	select {
+	case <-t.failed:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// Write передаёт v в буферы всех приёмников. Ожидание места в буфере по
+// SlowSinkBlock прерывается отменой ctx.
+func (s *BroadcastSink) Write(ctx context.Context, v int64) error {
+	if s.closed {
+		return errors.New("запись в приёмник после Flush")
+	}
+	for _, t := range s.targets {
+		if err := t.failedErr(); err != nil {
+			return err
+		}
+		select {
+		case t.in <- v:
+			continue
+		default:
+		}
+		if t.Slow == SlowSinkDrop {
+			t.dropped.Add(1)
+			continue
+		}
+		start := s.clock.Now()
+		select {
+		case t.in <- v:
+		case <-t.failed:
+			return t.err
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		t.blocked.Add(int64(s.clock.Now().Sub(start)))
+	}
+	return nil
+}
+
+// Flush дожидается, пока приёмники запишут числа из буферов, дописывает их
+// и возвращает их ошибки.
+func (s *BroadcastSink) Flush() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for _, t := range s.targets {
+		close(t.in)
+	}
+	var errs []error
+	for _, t := range s.targets {
+		<-t.done
+		errs = append(errs, t.failedErr())
+	}
+	return errors.Join(errs...)
+}
+
+// Files возвращает файлы, записанные приёмниками, которые сообщают о них,
+// как RotatingFileSink.
+func (s *BroadcastSink) Files() []string {
+	var files []string
+	for _, t := range s.targets {
+		if f, ok := t.Sink.(interface{ Files() []string }); ok {
+			files = append(files, f.Files()...)
+		}
+	}
+	return files
+}
+
+// Stats возвращает статистику приёмников в порядке их задания.
+func (s *BroadcastSink) Stats() []BroadcastStats {
+	stats := make([]BroadcastStats, len(s.targets))
+	for i, t := range s.targets {
+		stats[i] = BroadcastStats{
+			Name:    t.Name,
+			Written: t.written.Load(),
+			Dropped: t.dropped.Load(),
+			Blocked: time.Duration(t.blocked.Load()),
+		}
+	}
+	return stats
+}
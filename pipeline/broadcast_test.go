@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// blockingSink — приёмник, Write которого ждёт закрытия release.
+type blockingSink struct {
+	MemorySink
+	release chan struct{}
+}
+
+func (s *blockingSink) Write(ctx context.Context, v int64) error {
+	<-s.release
+	return s.MemorySink.Write(ctx, v)
+}
+
+// TestBroadcastSink проверяет, что каждый приёмник получает все числа, а
+// приёмник с SlowSinkDrop и заполненным буфером пропускает числа, не
+// задерживая остальные.
+func TestBroadcastSink(t *testing.T) {
+	fast := &MemorySink{}
+	slow := &blockingSink{release: make(chan struct{})}
+	s, err := NewBroadcastSink(nil,
+		BroadcastTarget{Name: "fast", Sink: fast, Buffer: 1},
+		BroadcastTarget{Name: "slow", Sink: slow, Buffer: 2, Slow: SlowSinkDrop},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v := int64(1); v <= 10; v++ {
+		if err := s.Write(context.Background(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(slow.release)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fast.Values(); !slices.Equal(got, ints(1, 10)) {
+		t.Errorf("fast получил %v, want 1..10", got)
+	}
+	stats := s.Stats()
+	if stats[0].Written != 10 || stats[0].Dropped != 0 {
+		t.Errorf("fast: %+v, want 10 записанных", stats[0])
+	}
+	if n := int64(len(slow.Values())); stats[1].Written != n || stats[1].Written+stats[1].Dropped != 10 || stats[1].Dropped == 0 {
+		t.Errorf("slow: %+v, получил %d, want записанные и отброшенные в сумме 10", stats[1], n)
+	}
+	if err := s.Write(context.Background(), 11); err == nil {
+		t.Error("Write после Flush = nil, want ошибку")
+	}
+}
+
+// failingWriteSink — приёмник, который не принимает числа.
+type failingWriteSink struct{}
+
+func (failingWriteSink) Write(context.Context, int64) error { return errOdd }
+func (failingWriteSink) Flush() error                       { return nil }
+
+// TestBroadcastSinkError проверяет, что ошибка приёмника возвращается из
+// Flush и останавливает конвейер.
+func TestBroadcastSinkError(t *testing.T) {
+	s, err := NewBroadcastSink(nil,
+		BroadcastTarget{Name: "memory", Sink: &MemorySink{}},
+		BroadcastTarget{Name: "broken", Sink: failingWriteSink{}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Run(context.Background(), Config{NumWorkers: 2, Limit: 100, Sink: s})
+	var sinkErr *SinkError
+	if !errors.As(err, &sinkErr) || !errors.Is(err, errOdd) || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Run = %v, want *SinkError приёмника broken", err)
+	}
+}
+
+func TestNewBroadcastSinkErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []BroadcastTarget
+	}{
+		{"без приёмников", nil},
+		{"приёмник не задан", []BroadcastTarget{{Name: "a"}}},
+		{"отрицательный буфер", []BroadcastTarget{{Name: "a", Sink: &MemorySink{}, Buffer: -1}}},
+		{"неизвестная политика", []BroadcastTarget{{Name: "a", Sink: &MemorySink{}, Slow: 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBroadcastSink(nil, tt.targets...); err == nil {
+				t.Error("NewBroadcastSink = nil, want ошибку")
+			}
+		})
+	}
+}
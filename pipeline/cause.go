@@ -0,0 +1,28 @@
+package pipeline
+
+import "errors"
+
+// Причины остановки генерации, которые сообщает Result.StopCause. Кроме
+// них причиной может быть ошибка конвейера или причина отмены контекста,
+// переданного в Run (context.Cause).
+var (
+	// ErrTimeout — истёк Config.Timeout.
+	ErrTimeout = errors.New("истёк таймаут генерации")
+	// ErrStopped — вызван Stop без указания причины.
+	ErrStopped = errors.New("конвейер остановлен методом Stop")
+	// ErrLimitReached — сгенерировано Config.Limit чисел.
+	ErrLimitReached = errors.New("сгенерировано заданное количество чисел")
+	// ErrSourceExhausted — источник исчерпан или выдал число больше
+	// Config.MaxValue.
+	ErrSourceExhausted = errors.New("источник чисел исчерпан")
+)
+
+// generationEnd возвращает причину, по которой Generator завершился сам, не
+// дождавшись отмены контекста: generated чисел из limit (0 — без
+// ограничения).
+func generationEnd(generated, limit int64) error {
+	if limit > 0 && generated >= limit {
+		return ErrLimitReached
+	}
+	return ErrSourceExhausted
+}
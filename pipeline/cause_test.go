@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunStopCause проверяет, что Result.StopCause сообщает, почему
+// остановилась генерация.
+func TestRunStopCause(t *testing.T) {
+	signal := errors.New("получен сигнал interrupt")
+	tests := []struct {
+		name  string
+		cfg   Config
+		stop  error // причина StopCause после первых чисел; nil — не вызывать
+		want  error
+		isErr bool // Run возвращает want как ошибку
+	}{
+		{"ограничение", Config{NumWorkers: 2, Limit: 20}, nil, ErrLimitReached, false},
+		{"источник исчерпан", Config{NumWorkers: 2, Source: NewReaderSource(strings.NewReader("1\n2\n3\n"))}, nil, ErrSourceExhausted, false},
+		{"таймаут", Config{NumWorkers: 2, Timeout: 20 * time.Millisecond}, nil, ErrTimeout, false},
+		{"Stop", Config{NumWorkers: 2}, ErrStopped, ErrStopped, false},
+		{"StopCause", Config{NumWorkers: 2}, signal, signal, false},
+		{"ошибка", Config{NumWorkers: 2, Process: func(_ context.Context, v int64) (int64, error) {
+			if v == 5 {
+				return 0, errOdd
+			}
+			return v, nil
+		}}, nil, errOdd, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.cfg)
+			if tt.stop != nil {
+				var seen int
+				p.cfg.Collect = func(int64) error {
+					if seen++; seen == 10 {
+						if tt.stop == ErrStopped {
+							p.Stop()
+						} else {
+							p.StopCause(tt.stop)
+						}
+					}
+					return nil
+				}
+			}
+			res, err := p.Run(context.Background())
+			if tt.isErr != (err != nil) {
+				t.Fatalf("Run = %v", err)
+			}
+			if !errors.Is(res.StopCause, tt.want) {
+				t.Errorf("StopCause = %v, want %v", res.StopCause, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunStopCauseContext проверяет, что причина отмены контекста Run
+// становится причиной остановки.
+func TestRunStopCauseContext(t *testing.T) {
+	cause := errors.New("получен повторный сигнал")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	var seen int
+	res, _ := Run(ctx, Config{NumWorkers: 2, Collect: func(int64) error {
+		if seen++; seen == 10 {
+			cancel(cause)
+		}
+		return nil
+	}})
+	if !errors.Is(res.StopCause, cause) {
+		t.Errorf("StopCause = %v, want %v", res.StopCause, cause)
+	}
+}
+
+func TestRunBatchedStopCause(t *testing.T) {
+	res, err := New(Config{NumWorkers: 2, Limit: 25}).RunBatched(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(res.StopCause, ErrLimitReached) {
+		t.Errorf("StopCause = %v, want %v", res.StopCause, ErrLimitReached)
+	}
+}
@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrChaos — ошибка обработки, внесённая ChaosPolicy.
+var ErrChaos = errors.New("сбой обработки, внесённый хаосом")
+
+// chaosPanic — значение паники, внесённой ChaosPolicy.
+const chaosPanic = "паника, внесённая хаосом"
+
+// ChaosPolicy — случайные задержки, ошибки и паники в обработке чисел,
+// чтобы проверить повторы, приёмник необработанных чисел и перехват паник
+// в условиях, похожих на настоящие сбои. Нулевое значение выключает хаос.
+type ChaosPolicy struct {
+	// DelayProbability — вероятность задержать обработку числа на время
+	// из Delay, от 0 до 1
+	DelayProbability float64
+	// Delay — распределение задержек; обязательно при DelayProbability
+	Delay DelayDistribution
+	// ErrorProbability — вероятность вернуть вместо обработки ErrChaos
+	ErrorProbability float64
+	// PanicProbability — вероятность паники вместо обработки
+	PanicProbability float64
+	// Seed — начальное значение случайных чисел; 0 — от текущего времени
+	Seed int64
+}
+
+// enabled сообщает, включён ли хаос.
+func (c ChaosPolicy) enabled() bool {
+	return c.DelayProbability > 0 || c.ErrorProbability > 0 || c.PanicProbability > 0
+}
+
+// validate проверяет корректность настроек.
+func (c ChaosPolicy) validate() error {
+	for _, p := range []float64{c.DelayProbability, c.ErrorProbability, c.PanicProbability} {
+		if p < 0 || p > 1 {
+			return fmt.Errorf("вероятность хаоса должна быть от 0 до 1: %v", p)
+		}
+	}
+	if c.DelayProbability > 0 && c.Delay == nil {
+		return errors.New("для задержек хаоса нужно распределение Delay")
+	}
+	return nil
+}
+
+// DelayDistribution — распределение случайных задержек: возвращает
+// задержку, выбранную с помощью r.
+type DelayDistribution func(r *rand.Rand) time.Duration
+
+// FixedDelay возвращает распределение, всегда дающее задержку d.
+func FixedDelay(d time.Duration) DelayDistribution {
+	return func(*rand.Rand) time.Duration { return d }
+}
+
+// UniformDelay возвращает равномерное распределение задержек от lo до hi.
+func UniformDelay(lo, hi time.Duration) DelayDistribution {
+	return func(r *rand.Rand) time.Duration {
+		return lo + time.Duration(r.Int63n(int64(hi-lo)+1))
+	}
+}
+
+// ExponentialDelay возвращает экспоненциальное распределение задержек со
+// средним mean: большинство задержек короткие, но встречаются и длинные,
+// как при настоящих сбоях сети.
+func ExponentialDelay(mean time.Duration) DelayDistribution {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(r.ExpFloat64() * float64(mean))
+	}
+}
+
+// ParseDelayDistribution разбирает распределение задержек: длительность,
+// например "5ms", — постоянная задержка, "1ms-10ms" — равномерное
+// распределение, "exp:5ms" — экспоненциальное со средним 5ms.
+func ParseDelayDistribution(s string) (DelayDistribution, error) {
+	if mean, ok := strings.CutPrefix(s, "exp:"); ok {
+		d, err := time.ParseDuration(mean)
+		if err != nil {
+			return nil, err
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("средняя задержка не может быть отрицательной: %v", d)
+		}
+		return ExponentialDelay(d), nil
+	}
+	if lo, hi, ok := strings.Cut(s, "-"); ok && lo != "" {
+		from, err := time.ParseDuration(lo)
+		if err != nil {
+			return nil, err
+		}
+		to, err := time.ParseDuration(hi)
+		if err != nil {
+			return nil, err
+		}
+		if from < 0 || to < from {
+			return nil, fmt.Errorf("некорректный диапазон задержек: %v-%v", from, to)
+		}
+		return UniformDelay(from, to), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("распределение задержек %q: ожидается длительность, диапазон 1ms-10ms или exp:5ms", s)
+	}
+	if d < 0 {
+		return nil, fmt.Errorf("задержка не может быть отрицательной: %v", d)
+	}
+	return FixedDelay(d), nil
+}
+
+// Chaos возвращает middleware, которая вносит в обработку сбои по политике
+// policy: перед обработкой значения с заданными вероятностями делает паузу
+// по часам clock, паникует или возвращает ErrChaos, не вызывая next.
+// Отмена контекста прерывает паузу.
+func Chaos[T any](policy ChaosPolicy, clock Clock) Middleware[T] {
+	seed := policy.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	var mu sync.Mutex
+	rnd := rand.New(rand.NewSource(seed))
+	return func(next StageFunc[T]) StageFunc[T] {
+		return func(ctx context.Context, v T) (T, error) {
+			// все случайные числа выбираются сразу, под одной блокировкой
+			mu.Lock()
+			var delay time.Duration
+			if rnd.Float64() < policy.DelayProbability {
+				delay = policy.Delay(rnd)
+			}
+			panics := rnd.Float64() < policy.PanicProbability
+			fails := rnd.Float64() < policy.ErrorProbability
+			mu.Unlock()
+
+			if delay > 0 {
+				if err := Sleep(ctx, clock, delay); err != nil {
+					var zero T
+					return zero, err
+				}
+			}
+			switch {
+			case panics:
+				panic(chaosPanic)
+			case fails:
+				var zero T
+				return zero, ErrChaos
+			}
+			return next(ctx, v)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseDelayDistribution(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max time.Duration // пределы выбранных задержек
+		wantErr  bool
+	}{
+		{"5ms", 5 * time.Millisecond, 5 * time.Millisecond, false},
+		{"1ms-3ms", time.Millisecond, 3 * time.Millisecond, false},
+		{"exp:0s", 0, 0, false},
+		{"exp:2ms", 0, time.Hour, false},
+		{"3ms-1ms", 0, 0, true},
+		{"-1ms", 0, 0, true},
+		{"exp:-1ms", 0, 0, true},
+		{"exp:", 0, 0, true},
+		{"часто", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			dist, err := ParseDelayDistribution(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDelayDistribution(%q) = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			r := rand.New(rand.NewSource(1))
+			for range 100 {
+				if d := dist(r); d < tt.min || d > tt.max {
+					t.Fatalf("задержка %v вне [%v, %v]", d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+// TestChaos проверяет, что Chaos с вероятностью 1 вносит задержку по часам,
+// ошибку или панику, а с нулевыми вероятностями вызывает обработку как есть.
+func TestChaos(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    ChaosPolicy
+		wantErr   error
+		wantPanic bool
+		wantDelay time.Duration
+	}{
+		{"без сбоев", ChaosPolicy{Seed: 1}, nil, false, 0},
+		{"задержка", ChaosPolicy{DelayProbability: 1, Delay: FixedDelay(time.Second), Seed: 1}, nil, false, time.Second},
+		{"ошибка", ChaosPolicy{ErrorProbability: 1, Seed: 1}, ErrChaos, false, 0},
+		{"паника", ChaosPolicy{PanicProbability: 1, Seed: 1}, nil, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Unix(0, 0)
+			clock := NewManualClock(start)
+			process := Chaos[int64](tt.policy, clock)(Square)
+			type result struct {
+				v   int64
+				err error
+			}
+			done := make(chan result, 1)
+			go func() {
+				v, err := protectValue(func() (int64, error) { return process(context.Background(), 3) })
+				done <- result{v, err}
+			}()
+			if tt.wantDelay > 0 {
+				for clock.Waiters() == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				clock.Advance(tt.wantDelay)
+			}
+			res := <-done
+			var pe *PanicError
+			switch {
+			case tt.wantPanic:
+				if !errors.As(res.err, &pe) {
+					t.Errorf("обработка = %v, want *PanicError", res.err)
+				}
+			case !errors.Is(res.err, tt.wantErr):
+				t.Errorf("обработка = %v, want %v", res.err, tt.wantErr)
+			case tt.wantErr == nil && res.v != 9:
+				t.Errorf("обработка = %d, want 9", res.v)
+			}
+			if got := clock.Now().Sub(start); got != tt.wantDelay {
+				t.Errorf("задержка %v, want %v", got, tt.wantDelay)
+			}
+		})
+	}
+}
+
+// protectValue вызывает fn, превращая её панику в *PanicError.
+func protectValue(fn func() (int64, error)) (v int64, err error) {
+	err = protect(func() error {
+		v, err = fn()
+		return err
+	})
+	return v, err
+}
+
+// TestRunChaos проверяет, что ошибки и паники хаоса с DeadLetters учитываются
+// как необработанные числа и не нарушают проверку.
+func TestRunChaos(t *testing.T) {
+	res, err := Run(context.Background(), Config{
+		NumWorkers:  3,
+		Limit:       500,
+		DeadLetters: MemoryDeadLetters,
+		Retry:       RetryPolicy{Attempts: 2},
+		Chaos:       ChaosPolicy{ErrorProbability: 0.2, PanicProbability: 0.05, Seed: 3},
+	})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	var panics int
+	for _, dl := range res.DeadLetters {
+		var pe *PanicError
+		if errors.As(dl.Err, &pe) {
+			panics++
+		}
+	}
+	if res.FailedCount == 0 || panics == 0 {
+		t.Errorf("необработано %d чисел, из них паник %d, want больше 0", res.FailedCount, panics)
+	}
+}
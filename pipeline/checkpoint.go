@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Checkpoint — состояние генерации, сохранённое в файл: сколько чисел
+// получено из источника и их сумма с начала первого запуска. Конвейер,
+// запущенный с Config.Resume, пропускает эти числа источника и продолжает
+// генерацию с того места, где она остановилась. Числа, отброшенные при
+// остановке, повторно не генерируются.
+type Checkpoint struct {
+	Generated int64     `json:"generated"` // количество сгенерированных чисел
+	Sum       int64     `json:"sum"`       // сумма сгенерированных чисел
+	Last      int64     `json:"last"`      // последнее сгенерированное число
+	At        time.Time `json:"at"`        // время сохранения
+}
+
+// CheckpointPolicy — сохранение состояния генерации. Нулевое значение
+// выключает сохранение.
+type CheckpointPolicy struct {
+	Path     string        // файл состояния; пустая строка — не сохранять
+	Interval time.Duration // период сохранения во время работы; 0 — 1 с
+}
+
+// defaultCheckpointInterval — период сохранения по умолчанию.
+const defaultCheckpointInterval = time.Second
+
+// enabled сообщает, включено ли сохранение.
+func (c CheckpointPolicy) enabled() bool {
+	return c.Path != ""
+}
+
+// withDefaults возвращает настройки с заполненными значениями по умолчанию.
+func (c CheckpointPolicy) withDefaults() CheckpointPolicy {
+	if c.Interval == 0 {
+		c.Interval = defaultCheckpointInterval
+	}
+	return c
+}
+
+// validate проверяет корректность настроек.
+func (c CheckpointPolicy) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("период сохранения состояния не может быть отрицательным: %v", c.Interval)
+	}
+	return nil
+}
+
+// LoadCheckpoint читает состояние генерации из файла path. Если файла нет,
+// возвращается ошибка, для которой errors.Is(err, os.ErrNotExist).
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Checkpoint{}, fmt.Errorf("повреждён файл состояния %s: %w", path, err)
+	}
+	if c.Generated < 0 {
+		return Checkpoint{}, fmt.Errorf("повреждён файл состояния %s: отрицательное количество чисел", path)
+	}
+	return c, nil
+}
+
+// Save атомарно записывает состояние в файл path: сначала во временный
+// файл рядом, затем переименовывает его.
+func (c Checkpoint) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// checkpointer накапливает состояние генерации и периодически сохраняет
+// его. Методы можно вызывать из разных горутин.
+type checkpointer struct {
+	mu     sync.Mutex
+	cp     Checkpoint
+	policy CheckpointPolicy
+	clock  Clock
+}
+
+// newCheckpointer создаёт накопитель, продолжающий состояние from.
+func newCheckpointer(policy CheckpointPolicy, from Checkpoint, clock Clock) *checkpointer {
+	return &checkpointer{cp: from, policy: policy.withDefaults(), clock: clock}
+}
+
+// record учитывает сгенерированное число v.
+func (c *checkpointer) record(v int64) {
+	c.mu.Lock()
+	c.cp.Generated++
+	c.cp.Sum += v
+	c.cp.Last = v
+	c.mu.Unlock()
+}
+
+// save сохраняет текущее состояние и возвращает его.
+func (c *checkpointer) save() (Checkpoint, error) {
+	c.mu.Lock()
+	cp := c.cp
+	c.mu.Unlock()
+	cp.At = c.clock.Now()
+	return cp, cp.Save(c.policy.Path)
+}
+
+// run сохраняет состояние раз в Interval, пока не отменён ctx или не
+// закрыт done. Ошибка сохранения передаётся в fail.
+func (c *checkpointer) run(ctx context.Context, done <-chan struct{}, fail func(error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-c.clock.After(c.policy.Interval):
+		}
+		if _, err := c.save(); err != nil {
+			fail(fmt.Errorf("сохранение состояния: %w", err))
+			return
+		}
+	}
+}
+
+// Skipper — источник, который умеет быстро пропускать значения.
+type Skipper interface {
+	// Skip пропускает n значений.
+	Skip(n int64)
+}
+
+// resumeSource возвращает источник, который перед первым значением
+// пропускает skip значений src: методом Skip, если src его поддерживает,
+// или получая их через Next. Если limit больше 0, источник исчерпывается
+// после limit значений с начала первого запуска.
+func resumeSource(src Source[int64], skip, limit int64) Source[int64] {
+	if limit > 0 && skip >= limit {
+		return SourceFunc[int64](func(context.Context) (int64, bool) { return 0, false })
+	}
+	if skip == 0 {
+		return src
+	}
+	skipped := false
+	return SourceFunc[int64](func(ctx context.Context) (int64, bool) {
+		if !skipped {
+			skipped = true
+			if s, ok := src.(Skipper); ok {
+				s.Skip(skip)
+			} else {
+				for i := int64(0); i < skip; i++ {
+					if _, ok := src.Next(ctx); !ok {
+						return 0, false
+					}
+				}
+			}
+		}
+		return src.Next(ctx)
+	})
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if _, err := LoadCheckpoint(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("LoadCheckpoint без файла = %v, want os.ErrNotExist", err)
+	}
+	want := Checkpoint{Generated: 10, Sum: 55, Last: 10, At: time.Unix(100, 0).UTC()}
+	if err := want.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("LoadCheckpoint = %+v, want %+v", got, want)
+	}
+	// временные файлы Save не остаются рядом
+	if files, _ := os.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("в каталоге %d файлов, want 1", len(files))
+	}
+
+	for name, data := range map[string]string{
+		"не JSON":       "{",
+		"отрицательное": `{"generated":-1}`,
+	} {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCheckpoint(path); err == nil {
+			t.Errorf("%s: LoadCheckpoint не вернул ошибку", name)
+		}
+	}
+}
+
+func TestResumeSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         Source[int64]
+		skip, limit int64
+		read        int // сколько чисел запросить
+		want        []int64
+	}{
+		{"без пропуска", Sequential(), 0, 5, 5, ints(1, 5)},
+		{"через Next", Fibonacci(), 3, 6, 3, []int64{3, 5, 8}},
+		{"через Skip", Sequential(), 3, 6, 3, ints(4, 6)},
+		{"всё сгенерировано", Sequential(), 6, 6, 3, nil},
+		{"источник короче", NewReaderSource(strings.NewReader("1\n2\n")), 5, 0, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := resumeSource(tt.src, tt.skip, tt.limit)
+			var got []int64
+			for range tt.read {
+				v, ok := src.Next(context.Background())
+				if !ok {
+					break
+				}
+				got = append(got, v)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("resumeSource = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunResume проверяет, что запуск, продолжающий сохранённое
+// состояние, генерирует только оставшиеся числа и сохраняет итоговое
+// состояние.
+func TestRunResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{NumWorkers: 2, Limit: 100, Checkpoint: CheckpointPolicy{Path: path}}
+	first := cfg
+	first.Limit = 40
+	if _, err := New(first).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Generated != 40 || cp.Sum != 820 || cp.Last != 40 {
+		t.Fatalf("состояние первого запуска %+v, want 40 чисел с суммой 820", cp)
+	}
+
+	cfg.Resume = cp
+	res, err := New(cfg).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.InputCount != 60 || res.InputSum != 5050-820 {
+		t.Errorf("продолжение: %d чисел с суммой %d, want 60 и %d", res.InputCount, res.InputSum, 5050-820)
+	}
+	if cp, err = LoadCheckpoint(path); err != nil || cp.Generated != 100 || cp.Sum != 5050 {
+		t.Errorf("итоговое состояние %+v, %v, want 100 чисел с суммой 5050", cp, err)
+	}
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock — источник времени конвейера. Позволяет подменить реальное время в
+// тестах и бенчмарках.
+type Clock interface {
+	// Now возвращает текущее время.
+	Now() time.Time
+	// After возвращает канал, в который будет отправлено время по
+	// истечении d.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock — реальное время из пакета time.
+var SystemClock Clock = systemClock{}
+
+// systemClock реализует Clock через пакет time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Sleep ждёт d по часам clock или отмены контекста ctx. Возвращает
+// ctx.Err(), если контекст отменён раньше.
+func Sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return nil
+	}
+}
+
+// ManualClock — часы, время которых двигается только вызовом Advance.
+// Подходят для детерминированных тестов. Безопасны для конкурентного
+// использования.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+// manualWaiter — ожидание, зарегистрированное ManualClock.After.
+type manualWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewManualClock создаёт ManualClock, показывающие время start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now возвращает текущее время часов.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After возвращает канал, который сработает, когда Advance передвинет часы
+// на d или больше.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, manualWaiter{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance передвигает часы на d и срабатывает все наступившие ожидания.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// Waiters возвращает количество ожиданий, которые ещё не сработали. Помогает
+// тестам дождаться, пока горутины дойдут до паузы.
+func (c *ManualClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
@@ -0,0 +1,42 @@
+package pipeline
+
+// Conflate пересылает значения из канала in в канал out, храня не более одного
+// непрочитанного значения. Если потребитель не успел забрать предыдущее
+// значение, оно заменяется новым, так что out всегда получает самое свежее.
+// Параметры
+// in - канал, откуда будут прочитаны значения
+// out - канал, куда будут записаны последние значения
+// Возвращает количество отброшенных (заменённых) значений.
+func Conflate[T any](in <-chan T, out chan<- T) int64 {
+	defer close(out) // перед выходом из функции закрываем канал out
+
+	var (
+		latest  T     // последнее полученное, но не отправленное значение
+		pending bool  // есть ли в слоте неотправленное значение
+		dropped int64 // количество отброшенных значений
+	)
+	for {
+		if !pending {
+			v, ok := <-in
+			if !ok {
+				return dropped
+			}
+			latest, pending = v, true
+			continue
+		}
+
+		select {
+		case v, ok := <-in:
+			if !ok {
+				// отдаём последнее значение перед завершением
+				out <- latest
+				return dropped
+			}
+			// потребитель не забрал предыдущее значение — перезаписываем слот
+			latest = v
+			dropped++
+		case out <- latest:
+			pending = false
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestConflate проверяет, что потребитель, не читавший out во время
+// всплеска, получает только последнее значение.
+func TestConflate(t *testing.T) {
+	tests := []struct {
+		name        string
+		burst       int64
+		want        []int64
+		wantDropped int64
+	}{
+		{"пусто", 0, nil, 0},
+		{"одно значение", 1, []int64{1}, 0},
+		{"всплеск", 10, []int64{10}, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, out := make(chan int64), make(chan int64)
+			dropped := make(chan int64, 1)
+			go func() { dropped <- Conflate(in, out) }()
+			// in не буферизован: каждое значение принято Conflate до
+			// отправки следующего, а out никто не читает
+			for i := int64(1); i <= tt.burst; i++ {
+				in <- i
+			}
+			close(in)
+			got := collect(out)
+			if n := <-dropped; !slices.Equal(got, tt.want) || n != tt.wantDropped {
+				t.Errorf("Conflate = %v, отброшено %d, want %v и %d", got, n, tt.want, tt.wantDropped)
+			}
+		})
+	}
+}
+
+// TestConflateSlowConsumer проверяет, что медленный потребитель получает
+// последнее значение каждого всплеска, а отброшенные значения учтены.
+func TestConflateSlowConsumer(t *testing.T) {
+	in, out := make(chan int64), make(chan int64)
+	dropped := make(chan int64, 1)
+	go func() { dropped <- Conflate(in, out) }()
+	var got []int64
+	for burst := int64(0); burst < 3; burst++ {
+		for i := int64(1); i <= 5; i++ {
+			in <- burst*5 + i
+		}
+		// потребитель просыпается только после всплеска
+		got = append(got, <-out)
+	}
+	close(in)
+	got = append(got, collect(out)...)
+	if want := []int64{5, 10, 15}; !slices.Equal(got, want) {
+		t.Errorf("получено %v, want %v", got, want)
+	}
+	if n := <-dropped; n != 12 {
+		t.Errorf("отброшено %d, want 12", n)
+	}
+}
@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// DeadLetterSink принимает числа, обработка которых окончательно не
+// удалась, чтобы их можно было изучить или обработать повторно.
+type DeadLetterSink interface {
+	// Put сохраняет число dl. Ошибка останавливает конвейер, а число
+	// учитывается как отброшенное.
+	Put(ctx context.Context, dl DeadLetter[int64]) error
+}
+
+// MemoryDeadLetters — приёмник, который ничего не делает: числа хранятся
+// только в Result.DeadLetters.
+var MemoryDeadLetters DeadLetterSink = memoryDeadLetters{}
+
+// memoryDeadLetters — реализация MemoryDeadLetters.
+type memoryDeadLetters struct{}
+
+// Put ничего не делает.
+func (memoryDeadLetters) Put(context.Context, DeadLetter[int64]) error {
+	return nil
+}
+
+// DeadLetterChan возвращает приёмник, отправляющий числа в канал ch. Канал
+// нужно читать во время работы конвейера; отмена контекста прерывает
+// ожидание отправки.
+func DeadLetterChan(ch chan<- DeadLetter[int64]) DeadLetterSink {
+	return deadLetterChan(ch)
+}
+
+// deadLetterChan — приёмник DeadLetterChan.
+type deadLetterChan chan<- DeadLetter[int64]
+
+// Put отправляет dl в канал.
+func (ch deadLetterChan) Put(ctx context.Context, dl DeadLetter[int64]) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ch <- dl:
+		return nil
+	}
+}
+
+// DeadLetterFile — приёмник, дописывающий числа в файл по одному JSON-объекту
+// в строке: {"value":..,"attempts":..,"err":".."}. Файл читается
+// ReadDeadLetters. Безопасен для конкурентного использования.
+type DeadLetterFile struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenDeadLetterFile открывает файл path для дописывания, создавая его при
+// необходимости.
+func OpenDeadLetterFile(path string) (*DeadLetterFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetterFile{file: f}, nil
+}
+
+// deadLetterRecord — строка файла DeadLetterFile.
+type deadLetterRecord struct {
+	Value    int64  `json:"value"`
+	Attempts int    `json:"attempts"`
+	Err      string `json:"err"`
+}
+
+// Put дописывает dl в файл.
+func (f *DeadLetterFile) Put(_ context.Context, dl DeadLetter[int64]) error {
+	rec := deadLetterRecord{Value: dl.Value, Attempts: dl.Attempts}
+	if dl.Err != nil {
+		rec.Err = dl.Err.Error()
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close закрывает файл.
+func (f *DeadLetterFile) Close() error {
+	return f.file.Close()
+}
+
+// ReadDeadLetters читает числа, записанные DeadLetterFile, из r. Ошибки
+// восстанавливаются только в виде текста.
+func ReadDeadLetters(r io.Reader) ([]DeadLetter[int64], error) {
+	var letters []DeadLetter[int64]
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec deadLetterRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return letters, fmt.Errorf("строка %d: %w", line, err)
+		}
+		dl := DeadLetter[int64]{Value: rec.Value, Attempts: rec.Attempts}
+		if rec.Err != "" {
+			dl.Err = errors.New(rec.Err)
+		}
+		letters = append(letters, dl)
+	}
+	return letters, sc.Err()
+}
@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDeadLetterFile проверяет, что числа, дописанные DeadLetterFile, в том
+// числе после повторного открытия файла, читаются ReadDeadLetters.
+func TestDeadLetterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	want := []DeadLetter[int64]{
+		{Value: 3, Err: errOdd, Attempts: 2},
+		{Value: 5, Attempts: 1},
+		{Value: -7, Err: errors.New("сбой"), Attempts: 3},
+	}
+	for _, part := range [][]DeadLetter[int64]{want[:2], want[2:]} {
+		f, err := OpenDeadLetterFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, dl := range part {
+			if err := f.Put(context.Background(), dl); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := ReadDeadLetters(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("прочитано %d чисел, want %d", len(got), len(want))
+	}
+	// ошибки восстанавливаются только в виде текста
+	errText := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	for i := range want {
+		if got[i].Value != want[i].Value || got[i].Attempts != want[i].Attempts || errText(got[i].Err) != errText(want[i].Err) {
+			t.Errorf("число %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReadDeadLettersCorrupt(t *testing.T) {
+	letters, err := ReadDeadLetters(strings.NewReader("{\"value\":1,\"attempts\":1}\n\nне json\n"))
+	if err == nil || !strings.Contains(err.Error(), "строка 3") {
+		t.Errorf("ReadDeadLetters = %v, want ошибку в строке 3", err)
+	}
+	if len(letters) != 1 || letters[0].Value != 1 {
+		t.Errorf("прочитано %+v, want число 1 до ошибки", letters)
+	}
+}
+
+// TestDeadLetterChanCancel проверяет, что отмена контекста прерывает
+// ожидание отправки в канал DeadLetterChan.
+func TestDeadLetterChanCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sink := DeadLetterChan(make(chan DeadLetter[int64]))
+	if err := sink.Put(ctx, DeadLetter[int64]{Value: 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Put = %v, want context.Canceled", err)
+	}
+}
+
+// failingSink — приёмник необработанных чисел, который их не принимает.
+type failingSink struct{}
+
+func (failingSink) Put(context.Context, DeadLetter[int64]) error {
+	return errors.New("диск заполнен")
+}
+
+// TestRunDeadLetterSinkError проверяет, что ошибка приёмника необработанных
+// чисел останавливает конвейер как ошибка приёмника, а число учитывается
+// как отброшенное.
+func TestRunDeadLetterSinkError(t *testing.T) {
+	res, err := Run(context.Background(), Config{
+		NumWorkers:  2,
+		Limit:       20,
+		DeadLetters: failingSink{},
+		Process: func(_ context.Context, v int64) (int64, error) {
+			if v == 5 {
+				return 0, errOdd
+			}
+			return v, nil
+		},
+	})
+	var sinkErr *SinkError
+	if !errors.As(err, &sinkErr) || !strings.Contains(err.Error(), "диск заполнен") {
+		t.Fatalf("Run = %v, want *SinkError", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	if res.FailedCount != 0 || len(res.DeadLetters) != 0 || res.DroppedCount == 0 {
+		t.Errorf("не обработано %d, в Result %d, отброшено %d, want 0, 0 и не 0", res.FailedCount, len(res.DeadLetters), res.DroppedCount)
+	}
+}
+
+func TestRunMemoryDeadLetters(t *testing.T) {
+	res, err := Run(context.Background(), Config{
+		NumWorkers:  2,
+		Limit:       10,
+		DeadLetters: MemoryDeadLetters,
+		Process: func(_ context.Context, v int64) (int64, error) {
+			if v%5 == 0 {
+				return 0, errOdd
+			}
+			return v, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	var sum int64
+	for _, dl := range res.DeadLetters {
+		sum += dl.Value
+	}
+	if len(res.DeadLetters) != 2 || sum != 15 || res.FailedSum != 15 {
+		t.Errorf("необработанные %+v, FailedSum %d, want 5 и 10", res.DeadLetters, res.FailedSum)
+	}
+}
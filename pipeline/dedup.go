@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+)
+
+// DefaultDedupFalsePositive — доля уникальных чисел, которые фильтр Блума
+// DedupPolicy по умолчанию ошибочно считает повторами.
+const DefaultDedupFalsePositive = 0.01
+
+// DedupPolicy — подавление повторно доставленных чисел перед Collect и
+// Sink: число результирующего канала, равное одному из недавно пришедших,
+// не передаётся дальше, а учитывается в Snapshot.Duplicates. Нужно для
+// источников, которые могут доставить сообщение повторно. Память
+// ограничена окном Window. Нулевое значение выключает подавление.
+type DedupPolicy struct {
+	// Window — сколько последних различных чисел помнить; повтор числа,
+	// вышедшего из окна, не подавляется
+	Window int
+	// Bloom — помнить числа в фильтрах Блума вместо точного LRU-списка:
+	// памяти нужно на порядок меньше, но уникальное число с вероятностью
+	// FalsePositive ошибочно считается повтором, а окно помнит от Window
+	// до 2·Window последних чисел
+	Bloom bool
+	// FalsePositive — допустимая доля ложных повторов фильтра Блума; 0 —
+	// DefaultDedupFalsePositive
+	FalsePositive float64
+}
+
+// enabled сообщает, включено ли подавление повторов.
+func (c DedupPolicy) enabled() bool {
+	return c.Window > 0
+}
+
+// validate проверяет корректность настроек.
+func (c DedupPolicy) validate() error {
+	if c.Window < 0 {
+		return fmt.Errorf("окно подавления повторов не может быть отрицательным: %d", c.Window)
+	}
+	if c.FalsePositive < 0 || c.FalsePositive >= 1 {
+		return fmt.Errorf("доля ложных повторов должна быть от 0 до 1: %v", c.FalsePositive)
+	}
+	return nil
+}
+
+// deduper запоминает числа окна подавления повторов.
+type deduper interface {
+	// seen сообщает, было ли v в окне, и запоминает его.
+	seen(v int64) bool
+}
+
+// newDeduper создаёт окно подавления повторов по политике c.
+func (c DedupPolicy) newDeduper() deduper {
+	if !c.Bloom {
+		return &lruDedup{size: c.Window, order: list.New(), index: make(map[int64]*list.Element)}
+	}
+	p := c.FalsePositive
+	if p == 0 {
+		p = DefaultDedupFalsePositive
+	}
+	// размеры фильтра на Window чисел с долей ложных срабатываний p
+	bits := int(math.Ceil(-float64(c.Window) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	hashes := max(int(math.Round(float64(bits)/float64(c.Window)*math.Ln2)), 1)
+	return &bloomDedup{
+		size: c.Window,
+		cur:  newBloomFilter(bits, hashes),
+		prev: newBloomFilter(bits, hashes),
+	}
+}
+
+// lruDedup — точное окно из size последних различных чисел: повтор
+// возвращает число в начало окна.
+type lruDedup struct {
+	size  int
+	order *list.List // числа от недавних к давним
+	index map[int64]*list.Element
+}
+
+func (d *lruDedup) seen(v int64) bool {
+	if el, ok := d.index[v]; ok {
+		d.order.MoveToFront(el)
+		return true
+	}
+	d.index[v] = d.order.PushFront(v)
+	if d.order.Len() > d.size {
+		delete(d.index, d.order.Remove(d.order.Back()).(int64))
+	}
+	return false
+}
+
+// bloomDedup — приблизительное окно из двух поколений фильтров Блума:
+// числа добавляются в cur, а когда в него добавлено size чисел, он
+// становится prev, и прежний prev очищается под новые числа.
+type bloomDedup struct {
+	size      int
+	added     int // чисел, добавленных в cur
+	cur, prev bloomFilter
+}
+
+func (d *bloomDedup) seen(v int64) bool {
+	if d.cur.has(v) {
+		return true
+	}
+	dup := d.prev.has(v)
+	d.cur.add(v)
+	if d.added++; d.added >= d.size {
+		d.cur, d.prev = d.prev, d.cur
+		d.cur.reset()
+		d.added = 0
+	}
+	return dup
+}
+
+// bloomFilter — фильтр Блума из len(bits)·64 битов с hashes хешами.
+type bloomFilter struct {
+	bits   []uint64
+	hashes int
+}
+
+// newBloomFilter создаёт фильтр не меньше чем из n битов.
+func newBloomFilter(n, hashes int) bloomFilter {
+	return bloomFilter{bits: make([]uint64, (n+63)/64), hashes: hashes}
+}
+
+// positions вызывает fn для каждого бита числа v: биты выбираются двойным
+// хешированием.
+func (f bloomFilter) positions(v int64, fn func(word int, mask uint64)) {
+	m := uint64(len(f.bits)) * 64
+	h1 := valueHash(v)
+	h2 := valueHash(int64(h1^0x9e3779b97f4a7c15)) | 1
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		fn(int(bit/64), 1<<(bit%64))
+	}
+}
+
+// add добавляет v в фильтр.
+func (f bloomFilter) add(v int64) {
+	f.positions(v, func(word int, mask uint64) {
+		f.bits[word] |= mask
+	})
+}
+
+// has сообщает, мог ли v быть добавлен в фильтр.
+func (f bloomFilter) has(v int64) bool {
+	has := true
+	f.positions(v, func(word int, mask uint64) {
+		has = has && f.bits[word]&mask != 0
+	})
+	return has
+}
+
+// reset очищает фильтр.
+func (f bloomFilter) reset() {
+	clear(f.bits)
+}
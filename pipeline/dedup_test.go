@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestLRUDedup проверяет, что точное окно подавляет повторы среди size
+// последних различных чисел, а повтор возвращает число в начало окна.
+func TestLRUDedup(t *testing.T) {
+	d := DedupPolicy{Window: 2}.newDeduper()
+	values := []int64{1, 2, 1, 3, 2, 1, 1}
+	want := []bool{false, false, true, false, false, false, true}
+	for i, v := range values {
+		if got := d.seen(v); got != want[i] {
+			t.Errorf("seen(%d) числом %d = %v, want %v", v, i+1, got, want[i])
+		}
+	}
+}
+
+// TestBloomDedup проверяет, что фильтры Блума подавляют все повторы в
+// пределах окна, а уникальные числа считают повторами не чаще допустимого.
+func TestBloomDedup(t *testing.T) {
+	const window = 1000
+	d := DedupPolicy{Window: window, Bloom: true, FalsePositive: 0.01}.newDeduper()
+	var falsePositives int
+	for v := int64(0); v < 10*window; v++ {
+		if d.seen(v) {
+			falsePositives++
+		}
+		// повтор числа, пришедшего window/4 чисел назад: с повторами в
+		// окно добавлено не больше window/2 чисел
+		if v >= window/4 && !d.seen(v-window/4) {
+			t.Fatalf("повтор %d в пределах окна не подавлен", v-window/4)
+		}
+	}
+	// ложные срабатывания считаются с запасом: поколения фильтров
+	// заполняются вдвое сильнее расчётного из-за повторов
+	if falsePositives > 10*window/20 {
+		t.Errorf("ложных повторов %d из %d, want не больше 5%%", falsePositives, 10*window)
+	}
+}
+
+// TestRunDedup проверяет, что повторы не передаются в Collect, а
+// учитываются в Duplicates, и проверка при этом сходится.
+func TestRunDedup(t *testing.T) {
+	for _, bloom := range []bool{false, true} {
+		var got []int64
+		res, err := Run(context.Background(), Config{
+			NumWorkers: 2,
+			Source:     NewReaderSource(strings.NewReader("5 5 7 5 7 9")),
+			Dedup:      DedupPolicy{Window: 10, Bloom: bloom},
+			Collect: func(v int64) error {
+				got = append(got, v)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Run = %v", err)
+		}
+		if err := res.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+		if res.Duplicates != 3 || !slices.Equal(got, []int64{5, 7, 9}) {
+			t.Errorf("bloom %v: подавлено %d, в Collect %v, want 3 и [5 7 9]", bloom, res.Duplicates, got)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Resetter — источник, который можно начать заново, как Sequential,
+// Random, Fibonacci и Primes. Нужен VerifyDeterministic, чтобы каждый
+// запуск получал одну и ту же последовательность.
+type Resetter interface {
+	// Reset начинает последовательность источника заново.
+	Reset()
+}
+
+// VerifyDeterministic запускает конвейер с настройками cfg runs раз и
+// проверяет, что каждый запуск передал в результирующий канал те же числа,
+// что и первый. С Config.Ordered числа должны прийти и в том же порядке;
+// без него порядок между обработчиками не сохраняется, поэтому
+// сравниваются наборы чисел с учётом повторов. Перед каждым запуском
+// источник Config.Source начинается заново методом Reset; nil —
+// Sequential. Количество чисел задаётся Config.Limit: при остановке по
+// Config.Timeout запуски расходятся. Config.Collect, если задана, получает
+// числа всех запусков. Ошибка описывает первое расхождение или ошибку
+// запуска.
+func VerifyDeterministic(cfg Config, runs int) error {
+	if runs < 2 {
+		return fmt.Errorf("для проверки нужно хотя бы два запуска: %d", runs)
+	}
+	if cfg.Limit <= 0 {
+		return fmt.Errorf("для проверки нужно ограничить количество чисел: Limit %d", cfg.Limit)
+	}
+	var resetter Resetter
+	if cfg.Source != nil {
+		r, ok := cfg.Source.(Resetter)
+		if !ok {
+			return errors.New("источник нельзя начать заново: нет метода Reset")
+		}
+		resetter = r
+	}
+
+	var first []int64
+	for run := 1; run <= runs; run++ {
+		if resetter != nil {
+			resetter.Reset()
+		}
+		var got []int64
+		c := cfg
+		c.Collect = func(v int64) error {
+			got = append(got, v)
+			if cfg.Collect != nil {
+				return cfg.Collect(v)
+			}
+			return nil
+		}
+		res, err := Run(context.Background(), c)
+		if err == nil {
+			err = res.Verify()
+		}
+		if err != nil {
+			return fmt.Errorf("запуск %d: %w", run, err)
+		}
+		if !cfg.Ordered {
+			slices.Sort(got)
+		}
+		if run == 1 {
+			first = got
+			continue
+		}
+		if err := compareRuns(first, got); err != nil {
+			return fmt.Errorf("запуск %d расходится с первым: %w", run, err)
+		}
+	}
+	return nil
+}
+
+// compareRuns описывает первое расхождение чисел запуска got с числами
+// первого запуска want; без Ordered оба запуска отсортированы.
+func compareRuns(want, got []int64) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("получено чисел %d, в первом запуске %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return fmt.Errorf("число %d различается: %d вместо %d", i+1, got[i], want[i])
+		}
+	}
+	return nil
+}
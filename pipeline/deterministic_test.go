@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVerifyDeterministic(t *testing.T) {
+	// calls считает вызовы flaky, которая меняет два числа второго запуска,
+	// не меняя их сумму
+	var calls atomic.Int64
+	flaky := func(_ context.Context, v int64) (int64, error) {
+		switch calls.Add(1) {
+		case 150:
+			return v + 1000, nil
+		case 151:
+			return v - 1000, nil
+		}
+		return v, nil
+	}
+	// swapped меняет местами числа 5 и 6 во втором запуске из 20 чисел, не
+	// меняя их набор
+	var swapped atomic.Int64
+	swap := func(_ context.Context, v int64) (int64, error) {
+		if swapped.Add(1) > 20 {
+			switch v {
+			case 5:
+				return 6, nil
+			case 6:
+				return 5, nil
+			}
+		}
+		return v, nil
+	}
+	tests := []struct {
+		name    string
+		cfg     Config
+		runs    int
+		wantErr string
+	}{
+		{"последовательность", Config{NumWorkers: 4, Limit: 100}, 3, ""},
+		{"один обработчик", Config{NumWorkers: 1, Limit: 20}, 2, ""},
+		{"случайные числа", Config{NumWorkers: 4, Limit: 100, Source: Random(7, 1000)}, 3, ""},
+		{"простые числа", Config{NumWorkers: 2, Limit: 100, Source: Primes()}, 2, ""},
+		{"источник без Reset", Config{NumWorkers: 1, Limit: 2, Source: NewReaderSource(strings.NewReader("1 2"))}, 2, "нет метода Reset"},
+		{"мало запусков", Config{NumWorkers: 1, Limit: 100}, 1, "хотя бы два запуска"},
+		{"без ограничения", Config{NumWorkers: 1}, 2, "ограничить количество чисел"},
+		{"расхождение", Config{NumWorkers: 2, Limit: 100, Process: flaky}, 2, "запуск 2 расходится с первым"},
+		{"порядок", Config{NumWorkers: 4, Limit: 20, Ordered: true, Process: swap}, 2, "число 5 различается: 6 вместо 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyDeterministic(tt.cfg, tt.runs)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("VerifyDeterministic = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareRuns(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got []int64
+		wantErr   string
+	}{
+		{"совпадают", []int64{1, 3}, []int64{1, 3}, ""},
+		{"количество", []int64{1, 2, 3}, []int64{1, 2}, "получено чисел 2, в первом запуске 3"},
+		{"набор", []int64{1, 2, 3}, []int64{1, 2, 4}, "4 вместо 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := compareRuns(tt.want, tt.got)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("compareRuns = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
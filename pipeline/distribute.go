@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+)
+
+// Distributor раздаёт значения входного канала обработчикам.
+type Distributor[T any] interface {
+	// Distribute возвращает n каналов, из которых читают обработчики, и
+	// раздаёт по ним значения из in. Каналы закрываются после закрытия in.
+	// При отмене ctx значения, которые не удалось раздать, передаются в
+	// drop, а каналы закрываются, когда in закрыт и прочитан до конца.
+	Distribute(ctx context.Context, in <-chan T, n int, drop func(T)) []<-chan T
+}
+
+// Shared возвращает Distributor, при котором все обработчики конкурируют за
+// чтение из одного общего канала in. Так конвейер работал исходно.
+func Shared[T any]() Distributor[T] {
+	return shared[T]{}
+}
+
+// shared — Distributor с общим каналом.
+type shared[T any] struct{}
+
+func (shared[T]) Distribute(_ context.Context, in <-chan T, n int, _ func(T)) []<-chan T {
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = in
+	}
+	return outs
+}
+
+// RoundRobin возвращает Distributor, который раздаёт значения по очереди в
+// собственный канал каждого обработчика: 1-е значение — первому, 2-е —
+// второму и т.д. Размер буфера каналов обработчиков равен буферу in.
+// Медленный обработчик задерживает раздачу всем остальным.
+func RoundRobin[T any]() Distributor[T] {
+	return distributor[T]{pick: func(outs []chan T, sent int64) int {
+		return int(sent % int64(len(outs)))
+	}}
+}
+
+// LeastLoaded возвращает Distributor, который отдаёт каждое значение
+// обработчику с наименьшей очередью, а если все очереди заполнены — первому
+// освободившемуся. Размер буфера каналов обработчиков равен буферу in.
+func LeastLoaded[T any]() Distributor[T] {
+	return distributor[T]{pick: func(outs []chan T, _ int64) int {
+		best := -1
+		for i, c := range outs {
+			if len(c) < cap(c) && (best < 0 || len(c) < len(outs[best])) {
+				best = i
+			}
+		}
+		return best
+	}}
+}
+
+// distributor — Distributor с собственным каналом у каждого обработчика.
+// pick выбирает канал для очередного значения по числу уже отправленных
+// значений sent; -1 означает отправку в любой канал, готовый принять
+// значение.
+type distributor[T any] struct {
+	pick func(outs []chan T, sent int64) int
+}
+
+func (d distributor[T]) Distribute(ctx context.Context, in <-chan T, n int, drop func(T)) []<-chan T {
+	outs := make([]chan T, n)
+	res := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T, cap(in))
+		res[i] = outs[i]
+	}
+	go func() {
+		defer func() {
+			for _, c := range outs {
+				close(c)
+			}
+		}()
+		sel := newSendSelect(ctx, outs)
+		var sent int64 // количество розданных значений
+		for v := range in {
+			if !sel.send(d.pick(outs, sent), v) {
+				// раздача прервана: всё, что осталось в in, отбрасываем
+				drop(v)
+				for v := range in {
+					drop(v)
+				}
+				return
+			}
+			sent++
+		}
+	}()
+	return res
+}
+
+// sendSelect отправляет значения в каналы outs, пока не отменён ctx. Для
+// отправки в любой готовый канал набор веток reflect.Select собирается
+// один раз, а значение передаётся через общую ячейку slot.
+type sendSelect[T any] struct {
+	ctx   context.Context
+	outs  []chan T
+	cases []reflect.SelectCase // ctx.Done() и отправка в каждый канал outs
+	slot  *T                   // значение, которое отправляют ветки cases
+}
+
+// newSendSelect создаёт sendSelect для каналов outs.
+func newSendSelect[T any](ctx context.Context, outs []chan T) *sendSelect[T] {
+	slot := reflect.New(reflect.TypeFor[T]()).Elem()
+	cases := make([]reflect.SelectCase, len(outs)+1)
+	cases[0] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+	for i, c := range outs {
+		cases[i+1] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(c), Send: slot}
+	}
+	return &sendSelect[T]{ctx: ctx, outs: outs, cases: cases, slot: slot.Addr().Interface().(*T)}
+}
+
+// send отправляет v в канал outs[i] или, если i равно -1, в первый готовый
+// канал. Возвращает false, если отправка прервана отменой ctx.
+func (s *sendSelect[T]) send(i int, v T) bool {
+	if i >= 0 {
+		select {
+		case <-s.ctx.Done():
+			return false
+		case s.outs[i] <- v:
+			return true
+		}
+	}
+	*s.slot = v
+	chosen, _, _ := reflect.Select(s.cases)
+	var zero T
+	*s.slot = zero // не удерживаем отправленное значение
+	return chosen != 0
+}
@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDistributors — раздачи чисел, которые проверяют тесты; у Shared нет
+// собственных каналов обработчиков.
+var testDistributors = []struct {
+	name string
+	d    func() Distributor[int64]
+}{
+	{"shared", Shared[int64]},
+	{"round-robin", RoundRobin[int64]},
+	{"least-loaded", LeastLoaded[int64]},
+	{"work-stealing", WorkStealing[int64]},
+}
+
+// TestDistributors проверяет, что каждая раздача отдаёт обработчикам все
+// числа по одному разу и закрывает их каналы после закрытия входа.
+func TestDistributors(t *testing.T) {
+	for _, dt := range testDistributors {
+		for _, n := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s %d", dt.name, n), func(t *testing.T) {
+				in := make(chan int64, 8)
+				outs := dt.d().Distribute(context.Background(), in, n, func(v int64) {
+					t.Errorf("без отмены отброшено %d", v)
+				})
+				if len(outs) != n {
+					t.Fatalf("каналов %d, want %d", len(outs), n)
+				}
+				go func() {
+					defer close(in)
+					for v := int64(1); v <= 1000; v++ {
+						in <- v
+					}
+				}()
+				var mu sync.Mutex
+				var got []int64
+				var wg sync.WaitGroup
+				for _, out := range outs {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						vs := collect(out)
+						mu.Lock()
+						defer mu.Unlock()
+						got = append(got, vs...)
+					}()
+				}
+				wg.Wait()
+				if !slices.Equal(sorted(got), ints(1, 1000)) {
+					t.Errorf("роздано %d чисел, want 1..1000 по одному разу", len(got))
+				}
+			})
+		}
+	}
+}
+
+// TestRoundRobin проверяет, что RoundRobin раздаёт числа по очереди.
+func TestRoundRobin(t *testing.T) {
+	outs := RoundRobin[int64]().Distribute(context.Background(), channels(1, ints(1, 9))[0], 3, func(int64) {})
+	want := [][]int64{{1, 4, 7}, {2, 5, 8}, {3, 6, 9}}
+	// очерёдность не зависит от читателей
+	for i, out := range outs {
+		if got := collect(out); !slices.Equal(got, want[i]) {
+			t.Errorf("обработчик %d получил %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+// TestLeastLoaded проверяет, что LeastLoaded отдаёт числа в наименее
+// заполненный канал: без читателей числа ложатся поровну.
+func TestLeastLoaded(t *testing.T) {
+	outs := LeastLoaded[int64]().Distribute(context.Background(), channels(1, ints(1, 8))[0], 2, func(int64) {})
+	// читатели не должны освобождать каналы раньше, чем всё роздано
+	for len(outs[0])+len(outs[1]) < 8 {
+		runtime.Gosched()
+	}
+	for i, out := range outs {
+		if got := collect(out); len(got) != 4 {
+			t.Errorf("обработчик %d получил %v, want 4 числа", i, got)
+		}
+	}
+}
+
+// TestWorkStealing проверяет, что обработчик, который читает сам, забирает
+// числа из очереди обработчика, который не читает.
+func TestWorkStealing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan int64, 4)
+	var dropped atomic.Int64
+	outs := WorkStealing[int64]().Distribute(ctx, in, 2, func(int64) { dropped.Add(1) })
+	go func() {
+		for v := int64(1); v <= 20; v++ {
+			in <- v
+		}
+		close(in)
+	}()
+	// из outs[1] никто не читает, кроме одного числа, на котором он ждёт
+	var got []int64
+	for len(got) < 19 {
+		got = append(got, <-outs[0])
+	}
+	cancel()
+	rest := len(collect(outs[0])) + len(collect(outs[1]))
+	if total := len(got) + rest + int(dropped.Load()); total != 20 {
+		t.Errorf("прочитано %d, после отмены %d и отброшено %d, want вместе 20", len(got), rest, dropped.Load())
+	}
+}
+
+// TestDistributeCancel проверяет, что после отмены раздача передаёт
+// оставшиеся числа в drop и закрывает каналы, не дожидаясь читателей.
+func TestDistributeCancel(t *testing.T) {
+	for _, dt := range testDistributors[1:] {
+		t.Run(dt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			in := make(chan int64)
+			var dropped atomic.Int64
+			outs := dt.d().Distribute(ctx, in, 3, func(int64) { dropped.Add(1) })
+			cancel()
+			for v := int64(1); v <= 10; v++ {
+				in <- v
+			}
+			close(in)
+			var got int
+			for _, out := range outs {
+				got += len(collect(out))
+			}
+			if got+int(dropped.Load()) != 10 {
+				t.Errorf("роздано %d и отброшено %d, want вместе 10", got, dropped.Load())
+			}
+		})
+	}
+}
+
+// TestRunDistributors проверяет, что с каждой раздачей конвейер учитывает
+// все числа, в том числе отброшенные после остановки обработчиков.
+func TestRunDistributors(t *testing.T) {
+	distributors := map[string]Distributor[Event]{
+		"round-robin":   RoundRobin[Event](),
+		"least-loaded":  LeastLoaded[Event](),
+		"work-stealing": WorkStealing[Event](),
+	}
+	for name, d := range distributors {
+		for _, drain := range []DrainPolicy{DrainAll, DropRemaining} {
+			t.Run(name+" "+drain.String(), func(t *testing.T) {
+				cfg := Config{NumWorkers: 3, BufferSize: 4, Timeout: 20 * time.Millisecond, WorkerDelay: 100 * time.Microsecond,
+					Drain: drain, Distributor: d, VerifySequence: true}
+				res, err := Run(context.Background(), cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := res.Verify(); err != nil {
+					t.Error(err)
+				}
+				for i, n := range res.PerWorker {
+					if n == 0 {
+						t.Errorf("обработчик %d не получил чисел: %v", i, res.PerWorker)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestPriorityLanes проверяет, что PriorityLanes выдаёт числа из непустых
+// очередей классов в соотношении весов, в каждом классе — по порядку, а
+// после опустения высшего класса — числа низшего.
+func TestPriorityLanes(t *testing.T) {
+	in := make(chan int64, 16)
+	for v := int64(1); v <= 16; v++ {
+		in <- v
+	}
+	close(in)
+	// числа до 8 — класс 0, остальные — класс 1
+	lane := func(v int64) int { return int((v - 1) / 8) }
+	outs := PriorityLanes([]int{3, 1}, lane).Distribute(context.Background(), in, 2, func(v int64) {
+		t.Errorf("без отмены отброшено %d", v)
+	})
+	if outs[0] != outs[1] {
+		t.Fatal("обработчики читают из разных каналов, want общий")
+	}
+	// читаем, только когда все числа разложены по очередям
+	for len(in) > 0 {
+		runtime.Gosched()
+	}
+	want := []int64{1, 2, 9, 3, 4, 5, 10, 6, 7, 8, 11, 12, 13, 14, 15, 16}
+	if got := collect(outs[0]); !slices.Equal(got, want) {
+		t.Errorf("выдано %v, want %v", got, want)
+	}
+}
+
+// TestPriorityLanesCancel проверяет, что после отмены PriorityLanes
+// отбрасывает числа из очередей и из входа.
+func TestPriorityLanesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan int64, 4)
+	var dropped atomic.Int64
+	outs := PriorityLanes([]int{1, 1}, func(v int64) int { return int(v % 2) }).Distribute(ctx, in, 2, func(int64) { dropped.Add(1) })
+	got := 0
+	for v := int64(1); v <= 10; v++ {
+		in <- v
+		if v == 5 {
+			<-outs[0]
+			got++
+			cancel()
+		}
+	}
+	close(in)
+	got += len(collect(outs[0]))
+	if got+int(dropped.Load()) != 10 {
+		t.Errorf("выдано %d и отброшено %d, want вместе 10", got, dropped.Load())
+	}
+}
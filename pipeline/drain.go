@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+)
+
+// DrainPolicy определяет, что происходит с числами, которые уже попали в
+// каналы конвейера, когда генерация остановлена.
+type DrainPolicy struct {
+	mode    drainMode
+	timeout time.Duration // время на дообработку для drainTimeout
+}
+
+// drainMode — вид политики дообработки.
+type drainMode int
+
+const (
+	drainAll drainMode = iota
+	drainTimeout
+	drainDrop
+)
+
+var (
+	// DrainAll дообрабатывает все числа, уже попавшие в каналы. Это политика
+	// по умолчанию (нулевое значение DrainPolicy).
+	DrainAll = DrainPolicy{mode: drainAll}
+	// DropRemaining останавливает обработчики сразу после остановки
+	// генерации; необработанные числа отбрасываются.
+	DropRemaining = DrainPolicy{mode: drainDrop}
+)
+
+// DrainWithTimeout дообрабатывает числа не дольше d после остановки
+// генерации, а затем отбрасывает оставшиеся.
+func DrainWithTimeout(d time.Duration) DrainPolicy {
+	return DrainPolicy{mode: drainTimeout, timeout: d}
+}
+
+// ParseDrainPolicy разбирает политику из строки: "all", "drop" или
+// длительность (например, "50ms") для DrainWithTimeout.
+func ParseDrainPolicy(s string) (DrainPolicy, error) {
+	switch s {
+	case "all":
+		return DrainAll, nil
+	case "drop":
+		return DropRemaining, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return DrainPolicy{}, fmt.Errorf("неизвестная политика дообработки %q", s)
+	}
+	return DrainWithTimeout(d), nil
+}
+
+// String возвращает описание политики в формате ParseDrainPolicy.
+func (p DrainPolicy) String() string {
+	switch p.mode {
+	case drainTimeout:
+		return p.timeout.String()
+	case drainDrop:
+		return "drop"
+	default:
+		return "all"
+	}
+}
+
+// stopAfter возвращает, через какое время после остановки генерации нужно
+// остановить обработчики, и нужно ли это делать вообще.
+func (p DrainPolicy) stopAfter() (time.Duration, bool) {
+	switch p.mode {
+	case drainTimeout:
+		return p.timeout, true
+	case drainDrop:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
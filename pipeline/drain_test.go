@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseDrainPolicy(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    DrainPolicy
+		wantErr bool
+	}{
+		{"all", DrainAll, false},
+		{"drop", DropRemaining, false},
+		{"50ms", DrainWithTimeout(50 * time.Millisecond), false},
+		{"-1s", DrainPolicy{}, true},
+		{"всё", DrainPolicy{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseDrainPolicy(tt.s)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("ParseDrainPolicy(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+			}
+			if err == nil && got.String() != tt.s {
+				t.Errorf("String = %q, want %q", got.String(), tt.s)
+			}
+		})
+	}
+}
+
+// TestRunDrain проверяет, что при каждой политике дообработки все
+// сгенерированные числа либо дошли до приёмника, либо учтены как
+// отброшенные, а DrainAll ничего не отбрасывает.
+func TestRunDrain(t *testing.T) {
+	tests := []struct {
+		name        string
+		drain       DrainPolicy
+		wantDropped bool // должны ли быть отброшенные числа
+	}{
+		{"все", DrainAll, false},
+		{"отбросить", DropRemaining, true},
+		{"с таймаутом", DrainWithTimeout(time.Millisecond), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// большой буфер и медленные обработчики оставляют числа в
+			// каналах к моменту остановки генерации
+			res, err := Run(context.Background(), Config{
+				NumWorkers:  2,
+				BufferSize:  100,
+				Limit:       100,
+				WorkerDelay: 2 * time.Millisecond,
+				Drain:       tt.drain,
+			})
+			if err != nil {
+				t.Fatalf("Run = %v", err)
+			}
+			if err := res.Verify(); err != nil {
+				t.Error(err)
+			}
+			if res.Drain != tt.drain || (res.DroppedCount > 0) != tt.wantDropped {
+				t.Errorf("политика %v, отброшено %d; want %v и отброшенные %v", res.Drain, res.DroppedCount, tt.drain, tt.wantDropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// GeneratorError — ошибка или паника генератора.
+type GeneratorError struct {
+	Err error // причина
+}
+
+// Error описывает ошибку генератора.
+func (e *GeneratorError) Error() string {
+	return "генератор: " + e.Err.Error()
+}
+
+// Unwrap возвращает причину ошибки.
+func (e *GeneratorError) Unwrap() error { return e.Err }
+
+// WorkerError — ошибка или паника обработки числа в обработчике Index.
+type WorkerError struct {
+	Index int   // номер обработчика, начиная с 0
+	Err   error // причина
+}
+
+// Error описывает ошибку обработчика.
+func (e *WorkerError) Error() string {
+	return fmt.Sprintf("обработчик %d: %v", e.Index, e.Err)
+}
+
+// Unwrap возвращает причину ошибки.
+func (e *WorkerError) Unwrap() error { return e.Err }
+
+// SinkError — ошибка или паника Config.Collect при чтении
+// результирующего канала.
+type SinkError struct {
+	Err error // причина
+}
+
+// Error описывает ошибку приёмника.
+func (e *SinkError) Error() string {
+	return "приёмник результатов: " + e.Err.Error()
+}
+
+// Unwrap возвращает причину ошибки.
+func (e *SinkError) Unwrap() error { return e.Err }
+
+// LeakError — горутины конвейера, которые не завершились за
+// Config.LeakTimeout после остановки обработки, например обработчик,
+// который не учитывает отмену контекста.
+type LeakError struct {
+	Goroutines []string // имена незавершившихся горутин
+}
+
+// Error перечисляет незавершившиеся горутины.
+func (e *LeakError) Error() string {
+	if len(e.Goroutines) == 0 {
+		return "утечка горутин конвейера: не завершились горутины раздачи или сборки чисел"
+	}
+	return "утечка горутин конвейера: не завершились " + strings.Join(e.Goroutines, ", ")
+}
+
+// PanicError — паника этапа конвейера, перехваченная и превращённая в
+// ошибку вместе со стеком горутины, чтобы конвейер мог остановиться.
+type PanicError struct {
+	Value any    // значение, переданное в panic
+	Stack []byte // стек горутины в момент паники
+}
+
+// Error возвращает описание паники.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("паника: %v", e.Value)
+}
+
+// protect вызывает fn и превращает панику в нём в *PanicError.
+func protect(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
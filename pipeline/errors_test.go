@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// errOdd — причина ошибок, которые тесты внедряют в этапы конвейера.
+var errOdd = errors.New("нечётное число")
+
+// TestRunStageErrors проверяет, что ошибка Run указывает этап, на котором
+// она произошла, и раскрывается через errors.As и errors.Is до причины.
+func TestRunStageErrors(t *testing.T) {
+	failFive := func(_ context.Context, v int64) (int64, error) {
+		if v == 5 {
+			return 0, errOdd
+		}
+		return v, nil
+	}
+	tests := []struct {
+		name  string
+		cfg   Config
+		check func(t *testing.T, err error)
+	}{
+		{"обработчик", Config{NumWorkers: 1, Process: failFive}, func(t *testing.T, err error) {
+			var we *WorkerError
+			if !errors.As(err, &we) || we.Index != 0 || !errors.Is(err, errOdd) {
+				t.Errorf("Run = %v, want WorkerError обработчика 0 с причиной errOdd", err)
+			}
+		}},
+		{"один из обработчиков", Config{NumWorkers: 4, Process: failFive}, func(t *testing.T, err error) {
+			var we *WorkerError
+			if !errors.As(err, &we) || we.Index < 0 || we.Index >= 4 || !errors.Is(err, errOdd) {
+				t.Errorf("Run = %v, want WorkerError одного из 4 обработчиков с причиной errOdd", err)
+			}
+		}},
+		{"паника обработчика", Config{NumWorkers: 2, Process: func(context.Context, int64) (int64, error) { panic("обработка сломана") }}, func(t *testing.T, err error) {
+			var we *WorkerError
+			var pe *PanicError
+			if !errors.As(err, &we) || !errors.As(err, &pe) || pe.Value != "обработка сломана" || len(pe.Stack) == 0 {
+				t.Errorf("Run = %v, want WorkerError с паникой и стеком", err)
+			}
+		}},
+		{"приёмник", Config{NumWorkers: 2, Collect: func(v int64) error {
+			if v == 3 {
+				return errOdd
+			}
+			return nil
+		}}, func(t *testing.T, err error) {
+			var se *SinkError
+			if !errors.As(err, &se) || !errors.Is(err, errOdd) {
+				t.Errorf("Run = %v, want SinkError с причиной errOdd", err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ошибка останавливает конвейер задолго до таймаута
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			res, err := Run(ctx, tt.cfg)
+			if ctx.Err() != nil {
+				t.Fatal("конвейер не остановился после ошибки этапа")
+			}
+			tt.check(t, err)
+			// необработанные числа учтены как отброшенные
+			if err := res.Verify(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestRunWorkerErrorStopsWorkers проверяет, что ошибка одного обработчика
+// прерывает остальные, а числа, которые они не успели обработать,
+// учитываются как отброшенные.
+func TestRunWorkerErrorStopsWorkers(t *testing.T) {
+	process := func(ctx context.Context, v int64) (int64, error) {
+		if v == 1 {
+			return 0, errOdd
+		}
+		// остальные обработчики ждут, пока конвейер не остановят
+		<-ctx.Done()
+		return v, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := Run(ctx, Config{NumWorkers: 4, BufferSize: 8, Process: process})
+	if ctx.Err() != nil {
+		t.Fatal("обработчики не остановились после ошибки")
+	}
+	if !errors.Is(err, errOdd) {
+		t.Fatalf("Run = %v, want ошибку с причиной errOdd", err)
+	}
+	if res.DroppedCount == 0 {
+		t.Error("DroppedCount = 0, want необработанные числа среди отброшенных")
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Item — значение вместе с метаданными, которые передаются между этапами:
+// по ним измеряется задержка, восстанавливается порядок и учитывается
+// каждое значение. Конвертом пользуются, только если метаданные нужны:
+// Generator и Worker так же передают и сами значения без него, как
+// RunBatched и Flow.
+type Item[T any] struct {
+	Value T         // значение
+	Born  time.Time // время генерации значения
+	Seq   int64     // порядковый номер значения в запуске, начиная с 1
+	// Source — индекс источника значения в Config.Sources; 0, если
+	// источник один
+	Source int
+	// Priority — класс приоритета значения по Config.Priority; 0 — высший
+	Priority int
+	// Attempts — сколько попыток обработки, включая первую, понадобилось
+	// значению в последнем Worker, см. WithRetry; 0 — значение ещё не
+	// обработано
+	Attempts int
+
+	span trace.Span // span трассировки; nil, если трассировка выключена
+	sent time.Time  // время окончания обработки в обработчике
+	pos  int64      // номер значения в его источнике, начиная с 1, для Acker
+}
+
+// attemptsSetter — конверт, в котором Worker отмечает количество попыток
+// обработки.
+type attemptsSetter interface {
+	setAttempts(n int)
+}
+
+// setAttempts отмечает количество попыток обработки значения; nil-конверт
+// не меняется.
+func (it *Item[T]) setAttempts(n int) {
+	if it != nil {
+		it.Attempts = n
+	}
+}
+
+// Event — число вместе со временем его генерации, которое Pipeline
+// передаёт между этапами, чтобы измерить задержку до приёмника.
+type Event = Item[int64]
+
+// StampSource превращает источник значений src в источник Item, отмечая
+// по часам clock время получения каждого значения и нумеруя значения с 1;
+// source — индекс источника в Item.Source.
+func StampSource[T any](src Source[T], source int, clock Clock) Source[Item[T]] {
+	if clock == nil {
+		clock = SystemClock
+	}
+	var seq int64
+	return SourceFunc[Item[T]](func(ctx context.Context) (Item[T], bool) {
+		v, ok := src.Next(ctx)
+		if !ok {
+			return Item[T]{}, false
+		}
+		seq++
+		return Item[T]{Value: v, Born: clock.Now(), Seq: seq, Source: source}, true
+	})
+}
+
+// ItemProcess применяет обработку значений process к значению Item,
+// сохраняя его метаданные, чтобы обрабатывать конверты в Worker той же
+// функцией, что и сами значения.
+func ItemProcess[T any](process func(context.Context, T) (T, error)) func(context.Context, Item[T]) (Item[T], error) {
+	return func(ctx context.Context, it Item[T]) (Item[T], error) {
+		v, err := process(ctx, it.Value)
+		it.Value = v
+		return it, err
+	}
+}
+
+// stamp превращает источник чисел src с индексом source в источник Event с
+// временем генерации числа по часам clock и порядковым номером из счётчика
+// seq, общего для всех источников запуска, и открывает для каждого числа
+// span tr.
+// Если maxValue не 0, источник заканчивается на первом числе, большем
+// maxValue, как в WithMaxValue; само это число уже получено из источника,
+// поэтому передаётся в reject.
+func stamp(src Source[int64], source int, seq *atomic.Int64, clock Clock, maxValue int64, tr *tracing, reject func(Event)) Source[Event] {
+	var pos int64 // количество чисел, полученных из источника
+	return SourceFunc[Event](func(ctx context.Context) (Event, bool) {
+		v, ok := src.Next(ctx)
+		if !ok {
+			return Event{}, false
+		}
+		pos++
+		if maxValue != 0 && v > maxValue {
+			reject(Event{Value: v, Source: source, pos: pos})
+			return Event{}, false
+		}
+		e := Event{Value: v, Born: clock.Now(), Seq: seq.Add(1), Source: source, pos: pos}
+		tr.start(ctx, &e)
+		return e, true
+	})
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStampSource проверяет, что StampSource нумерует значения источника
+// с 1 и отмечает время их получения и индекс источника.
+func TestStampSource(t *testing.T) {
+	clock := NewManualClock(time.Unix(100, 0))
+	src := StampSource(NewReaderSource(strings.NewReader("7 8")), 2, clock)
+	for i, want := range []int64{7, 8} {
+		it, ok := src.Next(context.Background())
+		if !ok || it.Value != want || it.Seq != int64(i+1) || it.Source != 2 || !it.Born.Equal(clock.Now()) || it.Attempts != 0 {
+			t.Errorf("значение %d = %+v, %v", i, it, ok)
+		}
+		clock.Advance(time.Second)
+	}
+	if _, ok := src.Next(context.Background()); ok {
+		t.Error("источник не исчерпан")
+	}
+}
+
+// TestWorkerItemAttempts проверяет, что Worker обрабатывает конверты Item
+// функцией обработки самих значений и отмечает в результате количество
+// попыток.
+func TestWorkerItemAttempts(t *testing.T) {
+	in := make(chan Item[string], 2)
+	in <- Item[string]{Value: "a", Seq: 1}
+	in <- Item[string]{Value: "b", Seq: 2}
+	close(in)
+	out := make(chan Item[string], 2)
+	failed := make(map[string]bool)
+	err := Worker(context.Background(), in, out,
+		WithProcess(ItemProcess(func(_ context.Context, s string) (string, error) {
+			if s == "b" && !failed[s] {
+				failed[s] = true
+				return "", errOdd
+			}
+			return strings.ToUpper(s), nil
+		})),
+		WithRetry[Item[string]](RetryPolicy{Attempts: 3}))
+	if err != nil {
+		t.Fatalf("Worker = %v", err)
+	}
+	got := collect(out)
+	if len(got) != 2 || got[0].Value != "A" || got[0].Attempts != 1 || got[1].Value != "B" || got[1].Seq != 2 || got[1].Attempts != 2 {
+		t.Errorf("результат %+v, want A с 1 попыткой и B с 2", got)
+	}
+}
+
+// TestWorkerItemPointerAttempts проверяет, что Worker отмечает количество
+// попыток и в конвертах, которые передаются по указателю.
+func TestWorkerItemPointerAttempts(t *testing.T) {
+	in := make(chan *Item[int64], 1)
+	in <- &Item[int64]{Value: 1}
+	close(in)
+	out := make(chan *Item[int64], 1)
+	err := Worker(context.Background(), in, out, WithProcess(func(_ context.Context, it *Item[int64]) (*Item[int64], error) {
+		return it, nil
+	}))
+	if err != nil {
+		t.Fatalf("Worker = %v", err)
+	}
+	if it := <-out; it.Attempts != 1 {
+		t.Errorf("попыток %d, want 1", it.Attempts)
+	}
+}
@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// EWMA вычисляет экспоненциально взвешенное скользящее среднее чисел из
+// канала in и пишет его в канал out после каждого полученного числа:
+// s = alpha*v + (1-alpha)*s, где s инициализируется первым значением.
+// Параметры
+// in - канал, откуда будут прочитаны числа
+// out - канал, куда будут записаны сглаженные значения
+// alpha - коэффициент сглаживания, 0 < alpha <= 1
+// Если alpha вне допустимого диапазона, out закрывается и возвращается ошибка.
+func EWMA(in <-chan int64, out chan<- float64, alpha float64) error {
+	defer close(out) // перед выходом из функции закрываем канал out
+
+	if !(alpha > 0 && alpha <= 1) {
+		return fmt.Errorf("недопустимое значение alpha: %v, ожидается 0 < alpha <= 1", alpha)
+	}
+
+	var s float64   // текущее сглаженное значение
+	var seeded bool // получено ли первое значение
+	for v := range in {
+		if !seeded {
+			s, seeded = float64(v), true
+		} else {
+			s = alpha*float64(v) + (1-alpha)*s
+		}
+		out <- s
+	}
+	return nil
+}
+
+// TrendPolicy — скользящее среднее чисел, переданных в Config.Collect и
+// Config.Sink: EWMA с коэффициентом Alpha. Сглаженные значения передаются
+// в Observe через Conflate, поэтому медленный Observe не задерживает
+// приёмник, а получает только самое свежее значение. Нулевое значение
+// выключает расчёт.
+type TrendPolicy struct {
+	// Alpha — коэффициент сглаживания, 0 < Alpha <= 1
+	Alpha float64
+	// Observe получает сглаженные значения в отдельной горутине; nil —
+	// только итог в Result.Trend
+	Observe func(s float64)
+}
+
+// enabled сообщает, включён ли расчёт скользящего среднего.
+func (p TrendPolicy) enabled() bool {
+	return p.Alpha != 0
+}
+
+// validate проверяет корректность настроек.
+func (p TrendPolicy) validate() error {
+	if p.enabled() && !(p.Alpha > 0 && p.Alpha <= 1) {
+		return fmt.Errorf("коэффициент сглаживания должен быть от 0 до 1: %v", p.Alpha)
+	}
+	if !p.enabled() && p.Observe != nil {
+		return errors.New("наблюдатель скользящего среднего задан без коэффициента сглаживания")
+	}
+	return nil
+}
+
+// TrendReport — итоги скользящего среднего Config.Trend.
+type TrendReport struct {
+	// Last — скользящее среднее после последнего числа
+	Last float64
+	// Conflated — сглаженные значения, заменённые более свежими до того,
+	// как их получил Observe
+	Conflated int64
+}
+
+// trendBuffer — сколько чисел может ждать расчёта скользящего среднего.
+const trendBuffer = 256
+
+// trend — скользящее среднее чисел приёмника в горутинах конвейера: EWMA,
+// за ним Conflate и наблюдатель. Вызовы add не пересекаются.
+type trend struct {
+	in     chan int64
+	done   chan struct{}   // закрывается, когда report заполнен
+	abort  <-chan struct{} // прерывает передачу чисел и ожидание итогов
+	report TrendReport
+}
+
+// startTrend запускает расчёт скользящего среднего p в группе g; закрытие
+// abort прерывает передачу чисел и ожидание итогов. nil, если расчёт
+// выключен.
+func startTrend(g *group, p TrendPolicy, abort <-chan struct{}) *trend {
+	if !p.enabled() {
+		return nil
+	}
+	t := &trend{in: make(chan int64, trendBuffer), done: make(chan struct{}), abort: abort}
+	smoothed, latest := make(chan float64), make(chan float64)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	g.Go("скользящее среднее", func() error {
+		defer wg.Done()
+		return EWMA(t.in, smoothed, p.Alpha)
+	})
+	g.Go("скользящее среднее: свежее значение", func() error {
+		defer wg.Done()
+		t.report.Conflated = Conflate(smoothed, latest)
+		return nil
+	})
+	g.Go("скользящее среднее: наблюдатель", func() error {
+		for s := range latest {
+			t.report.Last = s
+			if p.Observe != nil {
+				p.Observe(s)
+			}
+		}
+		wg.Wait()
+		close(t.done)
+		return nil
+	})
+	return t
+}
+
+// add передаёт v в расчёт.
+func (t *trend) add(v int64) {
+	if t == nil {
+		return
+	}
+	select {
+	case t.in <- v:
+	case <-t.abort:
+	}
+}
+
+// close дожидается расчёта по всем переданным числам и возвращает его
+// итоги; nil, если расчёт выключен или не завершился до закрытия stop или
+// abort.
+func (t *trend) close(stop <-chan struct{}) *TrendReport {
+	if t == nil {
+		return nil
+	}
+	close(t.in)
+	if !doneBefore(t.done, stop, t.abort) {
+		return nil
+	}
+	return &t.report
+}
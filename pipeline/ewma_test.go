@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEWMA(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []int64
+		alpha   float64
+		want    []float64
+		wantErr bool
+	}{
+		{"пусто", nil, 0.5, nil, false},
+		{"первое значение", []int64{7}, 0.1, []float64{7}, false},
+		// после скачка с 0 до 100 отставание от нового уровня
+		// уменьшается в 1-alpha раз на каждом числе
+		{"скачок", []int64{0, 100, 100, 100}, 0.5, []float64{0, 50, 75, 87.5}, false},
+		{"без сглаживания", []int64{1, 5, 3}, 1, []float64{1, 5, 3}, false},
+		{"alpha 0", []int64{1}, 0, nil, true},
+		{"alpha больше 1", []int64{1}, 1.5, nil, true},
+		{"alpha NaN", []int64{1}, math.NaN(), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, out := make(chan int64, len(tt.values)), make(chan float64, len(tt.values))
+			for _, v := range tt.values {
+				in <- v
+			}
+			close(in)
+			err := EWMA(in, out, tt.alpha)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EWMA = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := collect(out)
+			if len(got) != len(tt.want) {
+				t.Fatalf("EWMA = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Errorf("EWMA = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestEWMAConvergence проверяет, что после скачка уровня сглаженное
+// значение приближается к новому уровню со скоростью (1-alpha)^n.
+func TestEWMAConvergence(t *testing.T) {
+	const alpha, steps = 0.2, 20
+	in, out := make(chan int64), make(chan float64)
+	go func() {
+		in <- 0
+		for i := 0; i < steps; i++ {
+			in <- 100
+		}
+		close(in)
+	}()
+	go EWMA(in, out, alpha)
+	got := collect(out)
+	for n := 1; n <= steps; n++ {
+		want := 100 * math.Pow(1-alpha, float64(n))
+		if gap := 100 - got[n]; math.Abs(gap-want) > 1e-9 {
+			t.Fatalf("отставание после %d чисел %v, want %v", n, gap, want)
+		}
+	}
+}
+
+// TestRunTrend проверяет, что Run считает скользящее среднее Config.Trend, а
+// медленный наблюдатель получает не все сглаженные значения, но последнее —
+// всегда.
+func TestRunTrend(t *testing.T) {
+	tests := []struct {
+		name    string
+		trend   TrendPolicy
+		want    *TrendReport
+		wantErr bool
+	}{
+		{"выключено", TrendPolicy{}, nil, false},
+		{"без сглаживания", TrendPolicy{Alpha: 1}, &TrendReport{Last: 100}, false},
+		{"недопустимый alpha", TrendPolicy{Alpha: 2}, nil, true},
+		{"наблюдатель без alpha", TrendPolicy{Observe: func(float64) {}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Run(context.Background(), Config{NumWorkers: 1, Limit: 100, Trend: tt.trend})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (res.Trend == nil) != (tt.want == nil) || res.Trend != nil && res.Trend.Last != tt.want.Last {
+				t.Errorf("Trend = %+v, want %+v", res.Trend, tt.want)
+			}
+		})
+	}
+
+	var seen []float64
+	trend := TrendPolicy{Alpha: 0.5, Observe: func(s float64) {
+		seen = append(seen, s)
+		time.Sleep(50 * time.Microsecond)
+	}}
+	res, err := Run(context.Background(), Config{NumWorkers: 1, Limit: 1000, Trend: trend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Trend == nil || len(seen) == 0 || seen[len(seen)-1] != res.Trend.Last || int64(len(seen))+res.Trend.Conflated != 1000 {
+		t.Errorf("Trend = %+v, наблюдатель получил %d значений", res.Trend, len(seen))
+	}
+}
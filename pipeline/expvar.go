@@ -0,0 +1,20 @@
+package pipeline
+
+import "expvar"
+
+// PublishExpvar публикует живую статистику конвейера p как переменную expvar
+// с именем name. Переменная содержит inputCount, inputSum, outputCount,
+// outputSum и perWorker и доступна, например, по HTTP на /debug/vars.
+// Как и expvar.Publish, паникует, если имя уже занято.
+func PublishExpvar(name string, p *Pipeline) {
+	expvar.Publish(name, expvar.Func(func() any {
+		snap := p.Stats()
+		return map[string]any{
+			"inputCount":  snap.InputCount,
+			"inputSum":    snap.InputSum,
+			"outputCount": snap.OutputCount,
+			"outputSum":   snap.OutputSum,
+			"perWorker":   snap.PerWorker,
+		}
+	}))
+}
@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+// TestPublishExpvar проверяет, что переменная expvar отражает статистику
+// конвейера до и после запуска.
+func TestPublishExpvar(t *testing.T) {
+	p := New(Config{NumWorkers: 2, Limit: 10})
+	PublishExpvar("pipeline_test", p)
+	read := func() map[string]any {
+		var vars map[string]any
+		if err := json.Unmarshal([]byte(expvar.Get("pipeline_test").String()), &vars); err != nil {
+			t.Fatal(err)
+		}
+		return vars
+	}
+
+	if got := read()["inputCount"]; got != float64(0) {
+		t.Errorf("до запуска inputCount = %v, want 0", got)
+	}
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	vars := read()
+	if vars["inputCount"] != float64(10) || vars["outputSum"] != float64(55) {
+		t.Errorf("после запуска %v, want inputCount 10 и outputSum 55", vars)
+	}
+	if perWorker, ok := vars["perWorker"].([]any); !ok || len(perWorker) != 2 {
+		t.Errorf("perWorker = %v, want 2 канала", vars["perWorker"])
+	}
+}
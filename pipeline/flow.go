@@ -0,0 +1,335 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Flow — многоэтапный конвейер: числа из источника проходят по очереди
+// через этапы, у каждого из которых свой набор обработчиков и своя сборка
+// результатов, и попадают в приёмник. Flow собирается цепочкой вызовов:
+//
+//	res, err := pipeline.From(pipeline.Sequential()).
+//		Stage(pipeline.Square, 4).
+//		Filter(func(v int64) bool { return v%2 == 0 }, 2).
+//		FlatMap(split, 2).
+//		Sink(func(v int64) { fmt.Println(v) }).
+//		Run(ctx)
+//
+// Flow работает до исчерпания источника или отмены контекста.
+type Flow struct {
+	src    Source[int64]
+	stages []flowStage
+	sink   func(v int64)
+	mws    []Middleware[int64] // middleware для этапов Stage и Filter
+}
+
+// StageKind — вид этапа Flow.
+type StageKind string
+
+// Виды этапов Flow.
+const (
+	StageMap     StageKind = "map"     // одно число на выходе на каждое на входе
+	StageFilter  StageKind = "filter"  // число на входе проходит дальше или отфильтровывается
+	StageFlatMap StageKind = "flatmap" // сколько угодно чисел на выходе на каждое на входе
+)
+
+// flowStage — этап Flow. Обработка этапа любого вида сводится к flatMap;
+// для StageMap и StageFilter она задана через process.
+type flowStage struct {
+	kind    StageKind
+	process StageFunc[int64]
+	flatMap func(ctx context.Context, v int64) ([]int64, error)
+	workers int // количество обработчиков этапа
+}
+
+// From начинает Flow с источника src.
+func From(src Source[int64]) *Flow {
+	return &Flow{src: src}
+}
+
+// Stage добавляет этап, в котором числа обрабатываются функцией process в
+// workers горутинах. Возврат ErrSkip отфильтровывает число, другая ошибка
+// или паника останавливает Flow.
+func (f *Flow) Stage(process func(context.Context, int64) (int64, error), workers int) *Flow {
+	f.stages = append(f.stages, flowStage{kind: StageMap, process: process, workers: workers})
+	return f
+}
+
+// Filter добавляет этап, который в workers горутинах пропускает дальше
+// числа, для которых keep возвращает true, и отфильтровывает остальные.
+func (f *Flow) Filter(keep func(v int64) bool, workers int) *Flow {
+	f.stages = append(f.stages, flowStage{kind: StageFilter, process: Filter(keep), workers: workers})
+	return f
+}
+
+// FlatMap добавляет этап, в котором каждое число превращается функцией fn
+// в сколько угодно чисел, в том числе ни в одно; результаты отправляются
+// дальше по порядку. Возврат ErrSkip равносилен пустому результату, другая
+// ошибка или паника останавливает Flow. Middleware на FlatMap не действуют.
+func (f *Flow) FlatMap(fn func(ctx context.Context, v int64) ([]int64, error), workers int) *Flow {
+	f.stages = append(f.stages, flowStage{kind: StageFlatMap, flatMap: fn, workers: workers})
+	return f
+}
+
+// Use добавляет middleware, которыми оборачивается обработка каждого этапа
+// Stage и Filter, в том числе добавленного раньше. Middleware, добавленные
+// первыми, оказываются внешними.
+func (f *Flow) Use(mws ...Middleware[int64]) *Flow {
+	f.mws = append(f.mws, mws...)
+	return f
+}
+
+// Sink задаёт приёмник, который получает каждое число после последнего
+// этапа. Вызовы sink не пересекаются. Без приёмника числа отбрасываются
+// после учёта в статистике.
+func (f *Flow) Sink(sink func(v int64)) *Flow {
+	f.sink = sink
+	return f
+}
+
+// StageStats — статистика этапа Flow. Числа на входе и на выходе этапа
+// учитываются отдельно, поэтому проверка остаётся точной и для этапов, у
+// которых количество чисел на выходе не совпадает с количеством на входе.
+type StageStats struct {
+	Kind       StageKind
+	InputCount int64 // количество чисел, пришедших на этап
+	Skipped    int64 // количество отфильтрованных чисел на входе
+	DroppedIn  int64 // количество чисел на входе, отброшенных при остановке
+	// Emitted — количество чисел, которые этап должен был отправить
+	// дальше: результатов обработки
+	Emitted     int64
+	DroppedOut  int64   // количество результатов, не отправленных из-за остановки
+	OutputCount int64   // количество чисел, ушедших на следующий этап
+	PerWorker   []int64 // количество ушедших чисел по обработчикам
+}
+
+// verify проверяет, что каждый результат этапа либо ушёл дальше, либо был
+// отброшен, что разбивка по обработчикам сходится, а для этапов один к
+// одному — что каждое пришедшее число дало ровно один результат, если не
+// было отфильтровано или отброшено.
+func (s StageStats) verify() error {
+	if s.Emitted != s.OutputCount+s.DroppedOut {
+		return fmt.Errorf("результатов %d, отправлено %d и отброшено %d", s.Emitted, s.OutputCount, s.DroppedOut)
+	}
+	if s.Kind != StageFlatMap && s.InputCount != s.Emitted+s.Skipped+s.DroppedIn {
+		return fmt.Errorf("пришло чисел %d, результатов %d, отфильтровано %d и отброшено %d", s.InputCount, s.Emitted, s.Skipped, s.DroppedIn)
+	}
+	rest := s.OutputCount
+	for _, v := range s.PerWorker {
+		rest -= v
+	}
+	if rest != 0 {
+		return errors.New("разделение чисел по обработчикам неверное")
+	}
+	return nil
+}
+
+// FlowResult — итоговая статистика Flow.
+type FlowResult struct {
+	GeneratedCount int64 // количество чисел, полученных из источника
+	GeneratedSum   int64 // сумма чисел, полученных из источника
+
+	Stages    []StageStats  // статистика каждого этапа
+	SinkCount int64         // количество чисел, переданных приёмнику
+	Duration  time.Duration // время работы Flow
+}
+
+// Verify проверяет статистику каждого этапа и то, что числа не терялись
+// между этапами. Суммы не сравниваются, так как этапы преобразуют числа.
+func (r FlowResult) Verify() error {
+	prev := r.GeneratedCount
+	for i, s := range r.Stages {
+		if s.InputCount != prev {
+			return fmt.Errorf("этап %d: получено чисел %d, отправлено предыдущим этапом %d", i, s.InputCount, prev)
+		}
+		if err := s.verify(); err != nil {
+			return fmt.Errorf("этап %d (%s): %w", i, s.Kind, err)
+		}
+		prev = s.OutputCount
+	}
+	if r.SinkCount != prev {
+		return fmt.Errorf("приёмник получил чисел %d, отправлено последним этапом %d", r.SinkCount, prev)
+	}
+	return nil
+}
+
+// Run запускает Flow и ждёт его завершения. Числа, которые не успели пройти
+// все этапы из-за отмены ctx или ошибки, учитываются как отброшенные на том
+// этапе, где они находились. Возвращает первую ошибку обработки.
+func (f *Flow) Run(ctx context.Context) (FlowResult, error) {
+	for i, st := range f.stages {
+		if st.workers < 1 {
+			return FlowResult{}, fmt.Errorf("этап %d: количество обработчиков должно быть положительным: %d", i, st.workers)
+		}
+	}
+	src := f.src
+	if src == nil {
+		src = Sequential()
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// g — горутины Flow; первая ошибка любой из них отменяет ctx и
+	// возвращается из Run
+	g := newGroup(cancel)
+
+	start := time.Now()
+	generated := NewStats(1)
+	in := make(chan int64)
+	g.Go("генератор", func() error {
+		Generator(ctx, in, src, WithHooks[int64](GeneratorHookFuncs[int64]{After: generated.RecordIn}))
+		return nil
+	})
+
+	// inputs[k] — канал, из которого читают обработчики этапа k
+	inputs := make([]<-chan int64, len(f.stages))
+	stats := make([]*stageCounters, len(f.stages))
+	var cur <-chan int64 = in
+	for k, st := range f.stages {
+		if st.process != nil {
+			st.process = Wrap(st.process, f.mws...)
+		}
+		inputs[k] = cur
+		cur, stats[k] = runFlowStage(ctx, k, st, cur, g)
+	}
+
+	var sunk int64
+	for v := range cur {
+		if f.sink != nil {
+			f.sink(v)
+		}
+		sunk++
+	}
+
+	// после остановки в каналах между этапами могли остаться числа
+	cancel(nil)
+	for k, c := range inputs {
+		for range c {
+			stats[k].in.Add(1)
+			stats[k].droppedIn.Add(1)
+		}
+	}
+	err := g.Wait()
+
+	res := FlowResult{
+		Stages:    make([]StageStats, len(f.stages)),
+		SinkCount: sunk,
+		Duration:  time.Since(start),
+	}
+	gen := generated.Snapshot()
+	res.GeneratedCount, res.GeneratedSum = gen.InputCount, gen.InputSum
+	for k, s := range stats {
+		res.Stages[k] = s.snapshot(f.stages[k].kind)
+	}
+	return res, err
+}
+
+// stageCounters — счётчики этапа Flow.
+type stageCounters struct {
+	in, skipped, droppedIn, emitted, droppedOut atomic.Int64
+	out                                         shardedCounter // ячейка на обработчик
+}
+
+// snapshot возвращает значения счётчиков этапа вида kind.
+func (c *stageCounters) snapshot(kind StageKind) StageStats {
+	s := StageStats{
+		Kind:       kind,
+		InputCount: c.in.Load(),
+		Skipped:    c.skipped.Load(),
+		DroppedIn:  c.droppedIn.Load(),
+		Emitted:    c.emitted.Load(),
+		DroppedOut: c.droppedOut.Load(),
+		PerWorker:  make([]int64, len(c.out)),
+	}
+	for i := range c.out {
+		s.PerWorker[i] = c.out[i].count.Load()
+		s.OutputCount += s.PerWorker[i]
+	}
+	return s
+}
+
+// runFlowStage запускает обработчики этапа k, читающие из in, и возвращает
+// канал со сборкой их результатов вместе со счётчиками этапа.
+func runFlowStage(ctx context.Context, k int, st flowStage, in <-chan int64, g *group) (<-chan int64, *stageCounters) {
+	c := &stageCounters{out: make(shardedCounter, st.workers)}
+	flatMap := st.flatMap
+	if flatMap == nil {
+		process := st.process
+		flatMap = func(ctx context.Context, v int64) ([]int64, error) {
+			res, err := process(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			return []int64{res}, nil
+		}
+	}
+	outs := make([]<-chan int64, st.workers)
+	for i := range outs {
+		out := make(chan int64)
+		outs[i] = out
+		g.Go(fmt.Sprintf("этап %d, обработчик %d", k, i), func() error {
+			if err := flowWorker(ctx, in, out, flatMap, c); err != nil {
+				return fmt.Errorf("этап %d, обработчик %d: %w", k, i, err)
+			}
+			return nil
+		})
+	}
+	merged := mergeFunc(c.out.add, func(err error) {
+		g.fail(fmt.Errorf("этап %d, сборка результатов: %w", k, err))
+	}, outs...)
+	return merged, c
+}
+
+// flowWorker работает как Worker, но каждое число превращается в
+// сколько угодно результатов, которые учитываются в счётчиках c.
+func flowWorker(ctx context.Context, in <-chan int64, out chan<- int64, flatMap func(context.Context, int64) ([]int64, error), c *stageCounters) error {
+	defer close(out)
+	for {
+		var v int64
+		select {
+		case <-ctx.Done():
+			return nil
+		case val, ok := <-in:
+			if !ok {
+				return nil
+			}
+			v = val
+		}
+		c.in.Add(1)
+
+		// паника обработки превращается в ошибку, а число — в отброшенное
+		var res []int64
+		err := protect(func() error {
+			var err error
+			res, err = flatMap(ctx, v)
+			return err
+		})
+		switch {
+		case errors.Is(err, ErrSkip):
+			c.skipped.Add(1)
+			continue
+		case err != nil:
+			c.droppedIn.Add(1)
+			if ctx.Err() != nil {
+				// обработка прервана отменой контекста
+				return nil
+			}
+			return err
+		}
+
+		c.emitted.Add(int64(len(res)))
+		for j, r := range res {
+			select {
+			case <-ctx.Done():
+				c.droppedOut.Add(int64(len(res) - j))
+				return nil
+			case out <- r:
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// numbers возвращает источник чисел от 1 до n.
+func numbers(n int) Source[int64] {
+	var b strings.Builder
+	for v := 1; v <= n; v++ {
+		fmt.Fprintln(&b, v)
+	}
+	return NewReaderSource(strings.NewReader(b.String()))
+}
+
+// TestFlow проверяет, что числа проходят все этапы, а статистика каждого
+// этапа сходится.
+func TestFlow(t *testing.T) {
+	var got []int64
+	res, err := From(numbers(100)).
+		Filter(func(v int64) bool { return v%2 == 0 }, 3).
+		Stage(Square, 4).
+		Sink(func(v int64) { got = append(got, v) }).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	var want []int64
+	for v := int64(2); v <= 100; v += 2 {
+		want = append(want, v*v)
+	}
+	if !slices.Equal(sorted(got), want) {
+		t.Errorf("приёмник получил %v, want %v", sorted(got), want)
+	}
+	if res.GeneratedCount != 100 || res.Stages[0].Skipped != 50 || len(res.Stages[1].PerWorker) != 4 || res.SinkCount != 50 {
+		t.Errorf("FlowResult = %+v", res)
+	}
+}
+
+// TestFlowError проверяет, что ошибка этапа останавливает Flow, а числа,
+// не прошедшие все этапы, учтены как отброшенные.
+func TestFlowError(t *testing.T) {
+	errFail := errors.New("сбой")
+	res, err := From(Sequential()).
+		Stage(Square, 2).
+		Stage(func(_ context.Context, v int64) (int64, error) {
+			if v > 10000 {
+				return 0, errFail
+			}
+			return v, nil
+		}, 2).
+		Run(context.Background())
+	if !errors.Is(err, errFail) || !strings.Contains(err.Error(), "этап 1") {
+		t.Fatalf("Run = %v, want ошибку этапа 1", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestFlowWorkers проверяет, что этап без обработчиков не запускается.
+func TestFlowWorkers(t *testing.T) {
+	if _, err := From(numbers(1)).Stage(Square, 0).Run(context.Background()); err == nil {
+		t.Error("Run с этапом без обработчиков не вернул ошибку")
+	}
+}
+
+// TestFlowUse проверяет, что middleware Use действуют на все этапы, в том
+// числе добавленные раньше.
+func TestFlowUse(t *testing.T) {
+	var calls atomic.Int64
+	count := func(next StageFunc[int64]) StageFunc[int64] {
+		return func(ctx context.Context, v int64) (int64, error) {
+			calls.Add(1)
+			return next(ctx, v)
+		}
+	}
+	res, err := From(numbers(10)).Stage(Square, 2).Use(count).Stage(Hash, 2).Run(context.Background())
+	if err != nil || res.SinkCount != 10 || calls.Load() != 20 {
+		t.Errorf("Run = %v, в приёмник %d чисел, middleware вызвана %d раз, want 10 и 20", err, res.SinkCount, calls.Load())
+	}
+}
+
+// TestFlowFlatMap проверяет, что этап FlatMap может выдать на каждое число
+// сколько угодно результатов, а статистика сходится по числам на входе и
+// на выходе.
+func TestFlowFlatMap(t *testing.T) {
+	var got []int64
+	res, err := From(numbers(10)).
+		FlatMap(func(_ context.Context, v int64) ([]int64, error) {
+			// v превращается в v копий числа v, нечётные отфильтровываются
+			if v%2 != 0 {
+				return nil, ErrSkip
+			}
+			return slices.Repeat([]int64{v}, int(v)), nil
+		}, 3).
+		Stage(Square, 2).
+		Sink(func(v int64) { got = append(got, v) }).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	st := res.Stages[0]
+	if st.Kind != StageFlatMap || st.InputCount != 10 || st.Skipped != 5 || st.Emitted != 30 || st.OutputCount != 30 || len(got) != 30 {
+		t.Errorf("этап FlatMap = %+v, в приёмник %d чисел, want 10 на входе, 5 отфильтровано, 30 результатов", st, len(got))
+	}
+}
+
+// TestFlowVerify проверяет, что Verify находит расхождения в статистике
+// этапов.
+func TestFlowVerify(t *testing.T) {
+	ok := StageStats{Kind: StageMap, InputCount: 5, Skipped: 1, Emitted: 4, OutputCount: 4, PerWorker: []int64{3, 1}}
+	tests := []struct {
+		name    string
+		res     FlowResult
+		wantErr string
+	}{
+		{"сходится", FlowResult{GeneratedCount: 5, Stages: []StageStats{ok}, SinkCount: 4}, ""},
+		{"потеря между этапами", FlowResult{GeneratedCount: 6, Stages: []StageStats{ok}, SinkCount: 4}, "отправлено предыдущим"},
+		{"потеря на выходе", FlowResult{GeneratedCount: 5, Stages: []StageStats{{Kind: StageMap, InputCount: 5, Emitted: 5, OutputCount: 4, PerWorker: []int64{4}}}, SinkCount: 4}, "результатов 5"},
+		{"потеря результата", FlowResult{GeneratedCount: 5, Stages: []StageStats{{Kind: StageFilter, InputCount: 5, Emitted: 3, OutputCount: 3, PerWorker: []int64{3}}}, SinkCount: 3}, "пришло чисел 5"},
+		{"разбивка", FlowResult{GeneratedCount: 5, Stages: []StageStats{{Kind: StageFlatMap, InputCount: 5, Emitted: 9, OutputCount: 9, PerWorker: []int64{8}}}, SinkCount: 9}, "по обработчикам"},
+		{"приёмник", FlowResult{GeneratedCount: 5, Stages: []StageStats{ok}, SinkCount: 3}, "приёмник"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.res.Verify()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Verify = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
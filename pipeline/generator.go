@@ -0,0 +1,276 @@
+package pipeline
+
+import (
+	"cmp"
+	"context"
+	"sync"
+)
+
+// Generator получает значения из источника src и отправляет их в канал ch.
+// Генерация прекращается при отмене контекста, когда источник исчерпан или
+// достигнуто ограничение WithLimit или WithMaxValue.
+// Параметры
+// ctx - контекст
+// ch - канал, куда будут отправлены значения
+// src - источник значений, например Sequential()
+// opts - дополнительные настройки генератора, например WithReadiness или
+// WithHooks, через которые подсчитываются сгенерированные значения
+func Generator[T any](ctx context.Context, ch chan<- T, src Source[T], opts ...GeneratorOption[T]) {
+	defer close(ch) // перед выходом из функции закрываем канал ch
+
+	generate(ctx, ch, src, newGeneratorOptions(opts))
+}
+
+// MergeSources запускает по Generator для каждого источника srcs
+// одновременно и отправляет значения всех источников в один канал ch,
+// который закрывается, когда завершились все генераторы. Настройки opts
+// применяются к каждому генератору отдельно: WithLimit ограничивает
+// количество значений каждого источника, ограничитель WithRateLimit общий
+// для всех, WithReadiness ждёт именно закрытия канала, а обработчики
+// WithHooks вызываются из разных горутин одновременно, и OnStop — по разу
+// на каждый источник.
+func MergeSources[T any](ctx context.Context, ch chan<- T, srcs []Source[T], opts ...GeneratorOption[T]) {
+	defer close(ch) // перед выходом из функции закрываем канал ch
+
+	o := newGeneratorOptions(opts)
+	var wg sync.WaitGroup
+	for _, src := range srcs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			generate(ctx, ch, src, o)
+		}()
+	}
+	wg.Wait()
+}
+
+// generate — цикл Generator, не закрывающий канал ch.
+func generate[T any](ctx context.Context, ch chan<- T, src Source[T], o generatorOptions[T]) {
+	var last T // последнее отправленное значение
+	defer func() {
+		for _, h := range o.hooks {
+			h.OnStop(last)
+		}
+	}()
+
+	if !o.waitReady(ctx) {
+		return
+	}
+
+	var sent int64 // количество отправленных значений
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		// ждём разрешения ограничителя до получения значения, чтобы при
+		// отмене контекста ничего не потерять
+		for _, limiter := range o.limiters {
+			if limiter.Wait(ctx) != nil {
+				return
+			}
+		}
+		if o.limit > 0 && sent >= o.limit {
+			return
+		}
+		current, ok := src.Next(ctx) // текущее значение, которое будет отправлено в канал
+		if !ok {
+			return
+		}
+		if o.exceeds != nil && o.exceeds(current) {
+			return
+		}
+		for _, h := range o.hooks {
+			h.BeforeSend(current)
+		}
+		// отправка тоже ждёт отмены контекста, чтобы генератор не завис,
+		// если значение некому прочитать
+		select {
+		case <-ctx.Done():
+			if o.unsent != nil {
+				o.unsent(current)
+			}
+			return
+		case ch <- current:
+			last = current
+			for _, h := range o.hooks {
+				h.AfterSend(current)
+			}
+			sent++
+		}
+	}
+}
+
+// GeneratorBatched генерирует ту же последовательность 1,2,3 и т.д., что и
+// Generator, но вызывает fn не для каждого числа, а один раз на пачку из
+// batchSize отправленных чисел. Неполная последняя пачка передаётся в fn
+// перед выходом, в том числе при отмене контекста, поэтому итоговые
+// количество и сумма всегда точные.
+// Параметры
+// ctx - контекст
+// ch - канал, куда будут отправлены числа
+// batchSize - размер пачки; значения меньше 1 трактуются как 1
+// fn - функция, получающая количество и сумму чисел пачки
+func GeneratorBatched(ctx context.Context, ch chan<- int64, batchSize int, fn func(count, sum int64)) {
+	defer close(ch) // перед выходом из функции закрываем канал ch
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var count, sum int64 // количество и сумма чисел текущей пачки
+	// flush передаёт накопленную пачку в fn и обнуляет счётчики
+	flush := func() {
+		if count > 0 {
+			fn(count, sum)
+			count, sum = 0, 0
+		}
+	}
+	defer flush() // отдаём неполную пачку при любом выходе
+
+	var current int64 = 1 // текущее число, которое будет отправлено в канал
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ch <- current:
+			count++
+			sum += current
+			current++
+			if count == int64(batchSize) {
+				flush()
+			}
+		}
+	}
+}
+
+// GeneratorOption задаёт дополнительную настройку Generator со значениями
+// типа T.
+type GeneratorOption[T any] func(*generatorOptions[T])
+
+// generatorOptions — набор настроек Generator.
+type generatorOptions[T any] struct {
+	ready    <-chan struct{}    // сигнал готовности к началу генерации
+	limiters []Limiter          // ограничители частоты генерации
+	limit    int64              // максимальное количество значений; 0 — без ограничения
+	exceeds  func(T) bool       // сообщает о превышении максимального значения
+	unsent   func(T)            // вызывается для полученного, но не отправленного значения
+	hooks    []GeneratorHook[T] // обработчики в порядке добавления
+}
+
+// GeneratorHook наблюдает за генерацией, например подсчитывает, записывает
+// в журнал или сохраняет сгенерированные значения. Обработчиков может быть
+// несколько, см. WithHooks; каждый вызывается в горутине генератора, и
+// медленный обработчик задерживает генерацию.
+type GeneratorHook[T any] interface {
+	// BeforeSend вызывается для значения, полученного из источника, перед
+	// его отправкой в канал. Значение может так и не быть отправлено из-за
+	// отмены контекста, см. WithOnUnsent.
+	BeforeSend(v T)
+	// AfterSend вызывается для значения, отправленного в канал; только
+	// такие значения считаются сгенерированными.
+	AfterSend(v T)
+	// OnStop вызывается один раз по окончании генерации, до закрытия
+	// канала, с последним отправленным значением; если значений не было —
+	// с нулевым.
+	OnStop(last T)
+}
+
+// GeneratorHookFuncs — GeneratorHook из функций; незаданные функции не
+// вызываются.
+type GeneratorHookFuncs[T any] struct {
+	Before func(v T)    // BeforeSend
+	After  func(v T)    // AfterSend
+	Stop   func(last T) // OnStop
+}
+
+func (h GeneratorHookFuncs[T]) BeforeSend(v T) {
+	if h.Before != nil {
+		h.Before(v)
+	}
+}
+
+func (h GeneratorHookFuncs[T]) AfterSend(v T) {
+	if h.After != nil {
+		h.After(v)
+	}
+}
+
+func (h GeneratorHookFuncs[T]) OnStop(last T) {
+	if h.Stop != nil {
+		h.Stop(last)
+	}
+}
+
+// WithHooks добавляет обработчики событий генерации hooks; они вызываются
+// в порядке добавления, в том числе если опция задана несколько раз.
+func WithHooks[T any](hooks ...GeneratorHook[T]) GeneratorOption[T] {
+	return func(o *generatorOptions[T]) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
+
+// newGeneratorOptions применяет opts к настройкам по умолчанию.
+func newGeneratorOptions[T any](opts []GeneratorOption[T]) generatorOptions[T] {
+	var o generatorOptions[T]
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// waitReady ждёт сигнала готовности, но не дольше, чем живёт контекст ctx.
+// Возвращает false, если контекст отменён раньше.
+func (o generatorOptions[T]) waitReady(ctx context.Context) bool {
+	if o.ready == nil {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-o.ready:
+		return true
+	}
+}
+
+// WithReadiness откладывает отправку первого числа до закрытия канала ready
+// (или отправки в него значения), чтобы нижестоящие этапы успели
+// подготовиться. Отмена контекста прерывает ожидание.
+func WithReadiness[T any](ready <-chan struct{}) GeneratorOption[T] {
+	return func(o *generatorOptions[T]) {
+		o.ready = ready
+	}
+}
+
+// WithRateLimit ограничивает частоту генерации: перед получением каждого
+// значения Generator ждёт разрешения limiter. Если опция задана несколько
+// раз, разрешения ждут у всех ограничителей по порядку.
+func WithRateLimit[T any](limiter Limiter) GeneratorOption[T] {
+	return func(o *generatorOptions[T]) {
+		o.limiters = append(o.limiters, limiter)
+	}
+}
+
+// WithOnUnsent задаёт функцию, которая вызывается для значения, полученного
+// из источника, но не отправленного из-за отмены контекста, например чтобы
+// учесть выданный ему номер.
+func WithOnUnsent[T any](fn func(T)) GeneratorOption[T] {
+	return func(o *generatorOptions[T]) {
+		o.unsent = fn
+	}
+}
+
+// WithLimit останавливает генерацию после отправки n значений. n <= 0
+// снимает ограничение.
+func WithLimit[T any](n int64) GeneratorOption[T] {
+	return func(o *generatorOptions[T]) {
+		o.limit = n
+	}
+}
+
+// WithMaxValue останавливает генерацию на первом значении, большем max;
+// само это значение не отправляется.
+func WithMaxValue[T cmp.Ordered](max T) GeneratorOption[T] {
+	return func(o *generatorOptions[T]) {
+		o.exceeds = func(v T) bool { return v > max }
+	}
+}
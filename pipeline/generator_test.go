@@ -0,0 +1,448 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// collect читает канал ch до закрытия и возвращает прочитанные значения.
+func collect[T any](ch <-chan T) []T {
+	var got []T
+	for v := range ch {
+		got = append(got, v)
+	}
+	return got
+}
+
+// TestGeneratorBatched проверяет размеры пачек, переданных в fn, и то,
+// что неполная пачка передаётся при отмене контекста.
+func TestGeneratorBatched(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch int
+		n     int64   // сколько чисел прочитать до отмены
+		want  []int64 // размеры пачек в порядке вызовов fn
+	}{
+		{"неполная последняя пачка", 10, 25, []int64{10, 10, 5}},
+		{"ровно пачка", 7, 7, []int64{7}},
+		{"по одному", 1, 3, []int64{1, 1, 1}},
+		{"размер меньше 1", 0, 2, []int64{1, 1}},
+		{"без чисел", 5, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ch := make(chan int64)
+			var sizes []int64
+			var sum int64
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				GeneratorBatched(ctx, ch, tt.batch, func(c, s int64) {
+					sizes = append(sizes, c)
+					sum += s
+				})
+			}()
+			var want int64
+			for i := int64(0); i < tt.n; i++ {
+				want += <-ch
+			}
+			// генератор ждёт отправки следующего числа, которое никто не
+			// читает, и видит только отмену
+			cancel()
+			<-done
+			if rest := collect(ch); len(rest) > 0 {
+				t.Fatalf("после отмены отправлены %v", rest)
+			}
+			if !slices.Equal(sizes, tt.want) || sum != want {
+				t.Errorf("пачки %v с суммой %d, want %v и %d", sizes, sum, tt.want, want)
+			}
+		})
+	}
+}
+
+// TestGeneratorReadiness проверяет, что Generator не отправляет чисел до
+// сигнала готовности, начинает сразу после него, а отмена контекста
+// прерывает ожидание.
+func TestGeneratorReadiness(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	ch := make(chan int64, 1)
+	var sent atomic.Int64
+	go Generator(ctx, ch, Sequential(), WithReadiness[int64](ready), WithHooks[int64](GeneratorHookFuncs[int64]{After: func(int64) { sent.Add(1) }}))
+
+	select {
+	case v := <-ch:
+		t.Fatalf("до сигнала готовности получено число %d", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := sent.Load(); n != 0 {
+		t.Fatalf("до сигнала готовности отправлено чисел: %d", n)
+	}
+
+	close(ready)
+	for want := int64(1); want <= 3; want++ {
+		select {
+		case v := <-ch:
+			if v != want {
+				t.Fatalf("получено %d, want %d", v, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("после сигнала готовности числа не приходят")
+		}
+	}
+	cancel()
+	collect(ch)
+
+	// без сигнала готовности генератор завершается при отмене контекста
+	waiting, stop := context.WithCancel(context.Background())
+	done := make(chan int64, 1)
+	go Generator(waiting, done, Sequential(), WithReadiness[int64](make(chan struct{})))
+	stop()
+	select {
+	case v, ok := <-done:
+		if ok {
+			t.Errorf("после отмены получено число %d", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("генератор не завершился после отмены контекста")
+	}
+}
+
+// TestRunReadiness проверяет, что конвейер с Config.Ready не передаёт
+// чисел в приёмник до сигнала готовности.
+func TestRunReadiness(t *testing.T) {
+	ready := make(chan struct{})
+	var collected atomic.Int64
+	cfg := Config{NumWorkers: 2, Limit: 100, Ready: ready, Collect: func(int64) error {
+		collected.Add(1)
+		return nil
+	}}
+	type done struct {
+		stats Result
+		err   error
+	}
+	finished := make(chan done, 1)
+	go func() {
+		stats, err := Run(context.Background(), cfg)
+		finished <- done{stats, err}
+	}()
+
+	select {
+	case <-finished:
+		t.Fatal("Run завершился до сигнала готовности")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := collected.Load(); n != 0 {
+		t.Fatalf("до сигнала готовности в приёмник пришло чисел: %d", n)
+	}
+
+	close(ready)
+	d := <-finished
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	if d.stats.OutputCount != 100 || collected.Load() != 100 {
+		t.Errorf("после сигнала готовности OutputCount %d, в приёмнике %d; want 100", d.stats.OutputCount, collected.Load())
+	}
+}
+
+// TestGenerator проверяет, что Generator отправляет значения источника по
+// порядку и сообщает обработчику WithHooks каждое из них, в том числе
+// значения не числового типа.
+func TestGenerator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan string)
+	var sent []string
+	seq := Sequential()
+	src := SourceFunc[string](func(ctx context.Context) (string, bool) {
+		v, ok := seq.Next(ctx)
+		return strconv.FormatInt(v, 10), ok
+	})
+	go Generator(ctx, ch, src, WithHooks[string](GeneratorHookFuncs[string]{After: func(s string) {
+		sent = append(sent, s)
+	}}))
+	var got []string
+	for range 3 {
+		got = append(got, <-ch)
+	}
+	cancel()
+	// значение, отправленное до того, как генератор увидел отмену
+	got = append(got, collect(ch)...)
+	if !slices.Equal(got[:3], []string{"1", "2", "3"}) || !slices.Equal(sent, got) {
+		t.Errorf("получено %q, обработчик получил %q; want начало 1, 2, 3 и одинаковые значения", got, sent)
+	}
+}
+
+// TestGeneratorCancelBlocked проверяет, что Generator, которому некому
+// отправить значение, завершается после отмены контекста и не учитывает
+// неотправленное значение.
+func TestGeneratorCancelBlocked(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan int64)
+	var sent []int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Generator(ctx, ch, Sequential(), WithHooks[int64](GeneratorHookFuncs[int64]{After: func(v int64) { sent = append(sent, v) }}))
+	}()
+	got := []int64{<-ch, <-ch}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Generator не завершился после отмены")
+	}
+	got = append(got, collect(ch)...)
+	if !slices.Equal(sent, got) || !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("получено %v, обработчик получил %v; want [1 2] и одинаковые значения", got, sent)
+	}
+}
+
+// quotaLimiter разрешает quota событий, а затем отказывает.
+type quotaLimiter struct {
+	quota int
+}
+
+func (l *quotaLimiter) Wait(context.Context) error {
+	if l.quota == 0 {
+		return context.Canceled
+	}
+	l.quota--
+	return nil
+}
+
+// TestGeneratorRateLimit проверяет, что Generator ждёт разрешения
+// ограничителя перед каждым значением и завершается, если ограничитель
+// отказал, не теряя значений источника.
+func TestGeneratorRateLimit(t *testing.T) {
+	ch := make(chan int64, 10)
+	src := Sequential()
+	Generator(context.Background(), ch, src, WithRateLimit[int64](&quotaLimiter{quota: 3}))
+	if got := collect(ch); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("Generator = %v, want [1 2 3]", got)
+	}
+	if v, _ := src.Next(context.Background()); v != 4 {
+		t.Errorf("следующее значение источника %d, want 4", v)
+	}
+}
+
+// TestGeneratorBounds проверяет, что WithLimit и WithMaxValue
+// останавливают генерацию и закрывают канал.
+func TestGeneratorBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []GeneratorOption[int64]
+		want []int64
+	}{
+		{"без ограничений, источник исчерпан", nil, ints(1, 10)},
+		{"limit", []GeneratorOption[int64]{WithLimit[int64](3)}, ints(1, 3)},
+		{"limit 0 — без ограничения", []GeneratorOption[int64]{WithLimit[int64](0)}, ints(1, 10)},
+		{"max value", []GeneratorOption[int64]{WithMaxValue[int64](4)}, ints(1, 4)},
+		{"раньше срабатывает limit", []GeneratorOption[int64]{WithLimit[int64](2), WithMaxValue[int64](4)}, ints(1, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan int64, 10)
+			var sum int64
+			opts := append(tt.opts, WithHooks[int64](GeneratorHookFuncs[int64]{After: func(v int64) { sum += v }}))
+			Generator(context.Background(), ch, NewReaderSource(strings.NewReader("1 2 3 4 5 6 7 8 9 10")), opts...)
+			got := collect(ch)
+			var want int64
+			for _, v := range tt.want {
+				want += v
+			}
+			if !slices.Equal(got, tt.want) || sum != want {
+				t.Errorf("Generator = %v с суммой %d, want %v с суммой %d", got, sum, tt.want, want)
+			}
+		})
+	}
+}
+
+// TestGeneratorItemOptions проверяет настройки Generator с конвертами Item:
+// тип значений опций проверяется при компиляции.
+func TestGeneratorItemOptions(t *testing.T) {
+	ch := make(chan Event, 10)
+	var last Event
+	Generator(context.Background(), ch, StampSource(Sequential(), 2, nil),
+		WithLimit[Event](3), WithHooks[Event](GeneratorHookFuncs[Event]{Stop: func(e Event) { last = e }}))
+	got := collect(ch)
+	if len(got) != 3 || got[2].Value != 3 || got[2].Source != 2 || last.Value != 3 {
+		t.Errorf("Generator = %+v, последнее %+v, want 3 конверта источника 2 до числа 3", got, last)
+	}
+}
+
+// TestRunMaxValue проверяет, что конвейер останавливает генерацию на
+// первом числе больше Config.MaxValue.
+func TestRunMaxValue(t *testing.T) {
+	stats, err := Run(context.Background(), Config{NumWorkers: 3, MaxValue: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.InputCount != 100 || stats.OutputSum != 5050 {
+		t.Errorf("сгенерировано %d, сумма %d, want 100 и 5050", stats.InputCount, stats.OutputSum)
+	}
+}
+
+// TestMergeSources проверяет, что MergeSources отправляет в канал значения
+// всех источников, сообщает OnStop по разу на каждый источник и закрывает
+// канал, когда все источники исчерпаны.
+func TestMergeSources(t *testing.T) {
+	ch := make(chan int64)
+	srcs := []Source[int64]{
+		NewReaderSource(strings.NewReader("1 2 3 4 5")),
+		NewReaderSource(strings.NewReader("100 200")),
+	}
+	var (
+		mu   sync.Mutex
+		sent []int64
+		last []int64
+	)
+	go MergeSources(context.Background(), ch, srcs, WithHooks[int64](GeneratorHookFuncs[int64]{
+		After: func(v int64) {
+			mu.Lock()
+			sent = append(sent, v)
+			mu.Unlock()
+		},
+		Stop: func(v int64) {
+			mu.Lock()
+			last = append(last, v)
+			mu.Unlock()
+		},
+	}))
+	got := collect(ch)
+	slices.Sort(got)
+	if !slices.Equal(got, []int64{1, 2, 3, 4, 5, 100, 200}) {
+		t.Errorf("MergeSources = %v, want числа обоих источников", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	slices.Sort(sent)
+	slices.Sort(last)
+	if !slices.Equal(sent, got) || !slices.Equal(last, []int64{5, 200}) {
+		t.Errorf("обработчик получил %v, последние %v; want все числа и [5 200]", sent, last)
+	}
+}
+
+// TestGeneratorOnUnsent проверяет, что значение, полученное из источника,
+// но не отправленное из-за отмены контекста, передаётся в WithOnUnsent.
+func TestGeneratorOnUnsent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	seq := Sequential()
+	// источник отменяет контекст, выдав второе значение, которое уже
+	// некому прочитать
+	src := SourceFunc[int64](func(ctx context.Context) (int64, bool) {
+		v, ok := seq.Next(ctx)
+		if v == 2 {
+			cancel()
+		}
+		return v, ok
+	})
+	ch := make(chan int64)
+	var unsent []int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Generator(ctx, ch, src, WithOnUnsent(func(v int64) { unsent = append(unsent, v) }))
+	}()
+	<-ch
+	<-done
+	if !slices.Equal(unsent, []int64{2}) {
+		t.Errorf("неотправленные %v, want [2]", unsent)
+	}
+}
+
+// recordingHook записывает события генерации в общий журнал с именем
+// обработчика.
+type recordingHook struct {
+	name string
+	log  *[]string
+}
+
+func (h recordingHook) BeforeSend(v int64) {
+	*h.log = append(*h.log, fmt.Sprintf("%s before %d", h.name, v))
+}
+
+func (h recordingHook) AfterSend(v int64) {
+	*h.log = append(*h.log, fmt.Sprintf("%s after %d", h.name, v))
+}
+
+func (h recordingHook) OnStop(last int64) {
+	*h.log = append(*h.log, fmt.Sprintf("%s stop %d", h.name, last))
+}
+
+// TestGeneratorHooks проверяет, что обработчики WithHooks вызываются в
+// порядке добавления до и после отправки каждого значения и один раз при
+// остановке с последним отправленным значением, а значение, не
+// отправленное из-за отмены, сообщается только BeforeSend.
+func TestGeneratorHooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	seq := Sequential()
+	src := SourceFunc[int64](func(ctx context.Context) (int64, bool) {
+		v, ok := seq.Next(ctx)
+		if v == 3 {
+			cancel()
+		}
+		return v, ok
+	})
+	var log []string
+	ch := make(chan int64, 2)
+	Generator(ctx, ch, src,
+		WithHooks[int64](recordingHook{"a", &log}),
+		WithHooks[int64](recordingHook{"b", &log}))
+	want := []string{
+		"a before 1", "b before 1", "a after 1", "b after 1",
+		"a before 2", "b before 2", "a after 2", "b after 2",
+		"a before 3", "b before 3",
+		"a stop 2", "b stop 2",
+	}
+	if !slices.Equal(log, want) {
+		t.Errorf("события %q, want %q", log, want)
+	}
+	if got := collect(ch); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("отправлено %v, want [1 2]", got)
+	}
+}
+
+// TestRunGeneratorHooks проверяет, что Config.GeneratorHooks получает
+// каждое сгенерированное число конвейера и его остановку, а пакетный режим
+// их не поддерживает.
+func TestRunGeneratorHooks(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		sum   int64
+		stops int
+	)
+	hook := GeneratorHookFuncs[Event]{
+		After: func(e Event) {
+			mu.Lock()
+			sum += e.Value
+			mu.Unlock()
+		},
+		Stop: func(Event) {
+			mu.Lock()
+			stops++
+			mu.Unlock()
+		},
+	}
+	res, err := Run(context.Background(), Config{NumWorkers: 2, Limit: 10, GeneratorHooks: []GeneratorHook[Event]{hook}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != res.InputSum || stops != 1 {
+		t.Errorf("обработчик получил сумму %d и %d остановок, want %d и 1", sum, stops, res.InputSum)
+	}
+	_, err = New(Config{NumWorkers: 2, Limit: 10, GeneratorHooks: []GeneratorHook[Event]{hook}}).RunBatched(context.Background(), 2, 0)
+	if err == nil || !strings.Contains(err.Error(), "обработчики событий генерации") {
+		t.Errorf("RunBatched = %v, want ошибку про обработчики событий генерации", err)
+	}
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// group запускает горутины конвейера и собирает их ошибки, как
+// errgroup.Group: каждая ошибка передаётся onError, который останавливает
+// остальные горутины, а первая возвращается из Wait. Паника горутины
+// превращается в *PanicError. Группа помнит имена работающих горутин,
+// чтобы WaitUntil мог сообщить об утечке.
+type group struct {
+	wg      sync.WaitGroup
+	onError func(error) // вызывается для каждой ошибки
+
+	mu      sync.Mutex
+	err     error          // первая ошибка
+	next    int            // номер следующей горутины
+	running map[int]string // имена работающих горутин по номерам
+}
+
+// newGroup создаёт группу, которая передаёт ошибки горутин в onError.
+func newGroup(onError func(error)) *group {
+	return &group{onError: onError, running: make(map[int]string)}
+}
+
+// Go запускает fn в отдельной горутине группы с именем name; ошибка fn
+// передаётся в fail.
+func (g *group) Go(name string, fn func() error) {
+	g.wg.Add(1)
+	g.mu.Lock()
+	id := g.next
+	g.next++
+	g.running[id] = name
+	g.mu.Unlock()
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			g.mu.Lock()
+			delete(g.running, id)
+			g.mu.Unlock()
+		}()
+		if err := protect(fn); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// fail запоминает err, если это первая ошибка, и передаёт её в onError.
+// Горутины, которым нужно остановить конвейер раньше своего завершения,
+// вызывают fail сами.
+func (g *group) fail(err error) {
+	g.mu.Lock()
+	if g.err == nil {
+		g.err = err
+	}
+	g.mu.Unlock()
+	g.onError(err)
+}
+
+// Wait ждёт завершения всех горутин группы и возвращает первую ошибку.
+func (g *group) Wait() error {
+	g.wg.Wait()
+	return g.firstErr()
+}
+
+// WaitUntil ждёт завершения всех горутин группы, как Wait, но не дольше
+// закрытия deadline; nil — без ограничения. Если горутины не завершились,
+// к первой ошибке добавляется *LeakError с их именами.
+func (g *group) WaitUntil(deadline <-chan struct{}) error {
+	if deadline == nil {
+		return g.Wait()
+	}
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return g.firstErr()
+	case <-deadline:
+	}
+	return errors.Join(g.firstErr(), &LeakError{Goroutines: g.names()})
+}
+
+// names возвращает имена работающих горутин группы по алфавиту.
+func (g *group) names() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, 0, len(g.running))
+	for _, name := range g.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// firstErr возвращает первую ошибку горутин группы.
+func (g *group) firstErr() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
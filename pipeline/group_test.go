@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		fns       []func() error
+		wantErr   error
+		wantPanic bool
+		wantCalls int64
+	}{
+		{"без ошибок", []func() error{
+			func() error { return nil },
+			func() error { return nil },
+		}, nil, false, 0},
+		{"ошибка", []func() error{
+			func() error { return nil },
+			func() error { return errOdd },
+		}, errOdd, false, 1},
+		{"паника", []func() error{
+			func() error { panic("сбой") },
+		}, nil, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			g := newGroup(func(error) { calls.Add(1) })
+			for _, fn := range tt.fns {
+				g.Go("горутина", fn)
+			}
+			err := g.Wait()
+			var pe *PanicError
+			switch {
+			case tt.wantPanic && !errors.As(err, &pe):
+				t.Errorf("Wait = %v, want *PanicError", err)
+			case !tt.wantPanic && !errors.Is(err, tt.wantErr):
+				t.Errorf("Wait = %v, want %v", err, tt.wantErr)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("onError вызвана %d раз, want %d", calls.Load(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+// TestGroupFirstError проверяет, что Wait возвращает первую ошибку, а
+// onError получает каждую.
+func TestGroupFirstError(t *testing.T) {
+	var calls atomic.Int64
+	g := newGroup(func(error) { calls.Add(1) })
+	second := errors.New("вторая")
+	g.fail(errOdd)
+	done := make(chan struct{})
+	g.Go("горутина", func() error {
+		defer close(done)
+		return second
+	})
+	<-done
+	if err := g.Wait(); !errors.Is(err, errOdd) {
+		t.Errorf("Wait = %v, want %v", err, errOdd)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("onError вызвана %d раз, want 2", calls.Load())
+	}
+}
+
+// TestGroupWaitUntil проверяет, что WaitUntil перестаёт ждать горутины по
+// закрытию deadline и перечисляет незавершившиеся в *LeakError вместе с
+// первой ошибкой.
+func TestGroupWaitUntil(t *testing.T) {
+	g := newGroup(func(error) {})
+	release := make(chan struct{})
+	defer close(release)
+	g.fail(errOdd)
+	g.Go("генератор", func() error { <-release; return nil })
+	g.Go("обработчик 1", func() error { <-release; return nil })
+	deadline := make(chan struct{})
+	close(deadline)
+	err := g.WaitUntil(deadline)
+	var leak *LeakError
+	if !errors.As(err, &leak) || !errors.Is(err, errOdd) {
+		t.Fatalf("WaitUntil = %v, want *LeakError и %v", err, errOdd)
+	}
+	if want := []string{"генератор", "обработчик 1"}; !slices.Equal(leak.Goroutines, want) {
+		t.Errorf("незавершившиеся горутины %q, want %q", leak.Goroutines, want)
+	}
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// histSubBits — сколько старших бит значения после ведущего определяют
+// поддиапазон гистограммы: 2^histSubBits поддиапазонов на каждую степень
+// двойки дают относительную погрешность не больше 1/2^histSubBits.
+const histSubBits = 4
+
+const (
+	histSub     = 1 << histSubBits                 // поддиапазонов на степень двойки
+	histBuckets = (64 - histSubBits + 1) * histSub // всего корзин
+)
+
+// Histogram — гистограмма длительностей с логарифмическими корзинами.
+// Занимает фиксированную память, точна до ~6% и безопасна для
+// конкурентного использования.
+type Histogram struct {
+	buckets [histBuckets]atomic.Int64
+	count   atomic.Int64
+	sum     atomic.Int64 // сумма в наносекундах
+	min     atomic.Int64
+	max     atomic.Int64
+}
+
+// NewHistogram создаёт пустую Histogram.
+func NewHistogram() *Histogram {
+	h := &Histogram{}
+	h.min.Store(math.MaxInt64)
+	return h
+}
+
+// Record учитывает длительность d; отрицательные значения считаются нулём.
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	h.buckets[histIndex(uint64(v))].Add(1)
+	h.count.Add(1)
+	h.sum.Add(v)
+	for {
+		cur := h.min.Load()
+		if v >= cur || h.min.CompareAndSwap(cur, v) {
+			break
+		}
+	}
+	for {
+		cur := h.max.Load()
+		if v <= cur || h.max.CompareAndSwap(cur, v) {
+			break
+		}
+	}
+}
+
+// Quantile возвращает оценку квантиля q (0 <= q <= 1). Для пустой гистограммы
+// возвращается 0.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	total := h.count.Load()
+	if total == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(1, q))
+	rank := int64(math.Ceil(q * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i := range h.buckets {
+		seen += h.buckets[i].Load()
+		if seen >= rank {
+			lower, width := histBounds(i)
+			v := int64(lower + width/2)
+			return time.Duration(min(max(v, h.min.Load()), h.max.Load()))
+		}
+	}
+	return time.Duration(h.max.Load())
+}
+
+// Summary возвращает сводку по гистограмме.
+func (h *Histogram) Summary() LatencySummary {
+	count := h.count.Load()
+	if count == 0 {
+		return LatencySummary{}
+	}
+	return LatencySummary{
+		Count: count,
+		Min:   time.Duration(h.min.Load()),
+		Max:   time.Duration(h.max.Load()),
+		Mean:  time.Duration(h.sum.Load() / count),
+		P50:   h.Quantile(0.50),
+		P95:   h.Quantile(0.95),
+		P99:   h.Quantile(0.99),
+	}
+}
+
+// LatencySummary — сводка по распределению задержек.
+type LatencySummary struct {
+	Count         int64 // количество измерений
+	Min, Max      time.Duration
+	Mean          time.Duration
+	P50, P95, P99 time.Duration
+}
+
+// histIndex возвращает номер корзины для значения v.
+func histIndex(v uint64) int {
+	if v < histSub {
+		return int(v)
+	}
+	exp := bits.Len64(v) - 1
+	mantissa := int(v>>(exp-histSubBits)) - histSub
+	return (exp-histSubBits+1)*histSub + mantissa
+}
+
+// histBounds возвращает нижнюю границу и ширину корзины i.
+func histBounds(i int) (lower, width uint64) {
+	if i < histSub {
+		return uint64(i), 1
+	}
+	exp := i/histSub - 1 + histSubBits
+	mantissa := uint64(i % histSub)
+	shift := exp - histSubBits
+	return (histSub + mantissa) << shift, 1 << shift
+}
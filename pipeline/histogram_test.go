@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []time.Duration
+		want   LatencySummary
+	}{
+		{"пусто", nil, LatencySummary{}},
+		{"одно значение", []time.Duration{5 * time.Millisecond}, LatencySummary{
+			Count: 1, Min: 5 * time.Millisecond, Max: 5 * time.Millisecond, Mean: 5 * time.Millisecond,
+			P50: 5 * time.Millisecond, P95: 5 * time.Millisecond, P99: 5 * time.Millisecond,
+		}},
+		{"отрицательное — ноль", []time.Duration{-time.Second, 0}, LatencySummary{Count: 2}},
+		// маленькие значения попадают в точные корзины
+		{"точные корзины", []time.Duration{1, 2, 3, 4}, LatencySummary{Count: 4, Min: 1, Max: 4, Mean: 2, P50: 2, P95: 4, P99: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistogram()
+			for _, d := range tt.values {
+				h.Record(d)
+			}
+			if got := h.Summary(); got != tt.want {
+				t.Errorf("Summary = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHistogramQuantile проверяет, что квантили 1..1e6 мкс отличаются от
+// точных не больше погрешности корзин.
+func TestHistogramQuantile(t *testing.T) {
+	h := NewHistogram()
+	const n = 1_000_000
+	for i := 1; i <= n; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	for _, q := range []float64{0, 0.01, 0.5, 0.95, 0.99, 1} {
+		want := math.Max(1, math.Ceil(q*n)) * float64(time.Microsecond)
+		got := float64(h.Quantile(q))
+		if math.Abs(got-want)/want > 1.0/histSub {
+			t.Errorf("Quantile(%v) = %v, want %v ± %.0f%%", q, time.Duration(got), time.Duration(want), 100.0/histSub)
+		}
+	}
+	if got, want := h.Quantile(2), h.Quantile(1); got != want {
+		t.Errorf("Quantile(2) = %v, want как Quantile(1) %v", got, want)
+	}
+}
+
+// TestHistIndex проверяет, что корзины histIndex и histBounds сходятся:
+// значение лежит в границах своей корзины, а корзины идут подряд.
+func TestHistIndex(t *testing.T) {
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 1000, 1 << 40, math.MaxInt64, math.MaxUint64} {
+		i := histIndex(v)
+		lower, width := histBounds(i)
+		if i < 0 || i >= histBuckets || v < lower || v-lower >= width {
+			t.Errorf("histIndex(%d) = %d с границами [%d, +%d)", v, i, lower, width)
+		}
+	}
+	for i := 1; i < histBuckets; i++ {
+		prev, width := histBounds(i - 1)
+		if lower, _ := histBounds(i); lower != prev+width {
+			t.Fatalf("корзина %d начинается с %d, want %d", i, lower, prev+width)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPSource — источник чисел, которые присылают HTTP-запросами POST. Тело
+// запроса — JSON-массив чисел [1,2,3] или числа по одному в строке (JSON
+// lines). Запрос ждёт, пока генератор заберёт каждое число, и возвращает
+// {"accepted": n} — количество принятых чисел, поэтому клиент знает, какие
+// числа попали в конвейер. После Close новые и ожидающие запросы получают
+// ответ 503 с количеством уже принятых чисел. HTTPSource — http.Handler;
+// обычно его регистрируют на пути /values.
+type HTTPSource struct {
+	*PushSource
+}
+
+// NewHTTPSource создаёт источник чисел из HTTP-запросов.
+func NewHTTPSource() *HTTPSource {
+	return &HTTPSource{PushSource: NewPushSource()}
+}
+
+// httpAccepted — ответ HTTPSource.
+type httpAccepted struct {
+	Accepted int    `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ServeHTTP принимает числа из тела запроса POST.
+func (s *HTTPSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "ожидается POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var accepted int
+	err := decodeValues(r.Body, func(v int64) error {
+		if err := s.Push(r.Context(), v); err != nil {
+			return err
+		}
+		accepted++
+		return nil
+	})
+	res := httpAccepted{Accepted: accepted}
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, ErrSourceClosed):
+		status = http.StatusServiceUnavailable
+	case err != nil:
+		status = http.StatusBadRequest
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// decodeValues читает из r JSON-массив чисел или числа по одному в строке и
+// передаёт каждое в fn; ошибка fn прерывает чтение.
+func decodeValues(r io.Reader, fn func(v int64) error) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	emit := func(n json.Number) error {
+		v, err := n.Int64()
+		if err != nil {
+			return err
+		}
+		return fn(v)
+	}
+	// массив отличаем от JSON lines по первому токену
+	tok, err := dec.Token()
+	switch {
+	case err == io.EOF:
+		return nil
+	case err != nil:
+		return err
+	case tok == json.Delim('['):
+		for dec.More() {
+			var n json.Number
+			if err := dec.Decode(&n); err != nil {
+				return err
+			}
+			if err := emit(n); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token()
+		return err
+	}
+	n, ok := tok.(json.Number)
+	if !ok {
+		return fmt.Errorf("ожидается число или массив чисел: %v", tok)
+	}
+	for {
+		if err := emit(n); err != nil {
+			return err
+		}
+		switch err := dec.Decode(&n); {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// HTTPSink — приёмник, отправляющий числа пачками запросами POST с телом —
+// JSON-массивом чисел, например в HTTPSource другого конвейера. Неудачные
+// запросы повторяются по политике RetryPolicy: по умолчанию — после
+// сетевых ошибок и ответов 429 и 5xx. Вызовы методов не должны
+// пересекаться.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+	size   int
+	retry  RetryPolicy
+	clock  Clock
+	batch  []int64 // накопленные числа
+}
+
+// NewHTTPSink создаёт приёмник, отправляющий пачки по size чисел на адрес
+// url. size меньше 1 трактуется как 1; client nil — http.DefaultClient.
+// Паузы между повторами отсчитываются по SystemClock.
+func NewHTTPSink(url string, size int, retry RetryPolicy, client *http.Client) (*HTTPSink, error) {
+	if err := retry.validate(); err != nil {
+		return nil, err
+	}
+	if size < 1 {
+		size = 1
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if retry.Retryable == nil {
+		retry.Retryable = retryableHTTP
+	}
+	return &HTTPSink{url: url, client: client, size: size, retry: retry, clock: SystemClock}, nil
+}
+
+// Write добавляет v в пачку и отправляет её, когда она заполнена.
+func (s *HTTPSink) Write(ctx context.Context, v int64) error {
+	s.batch = append(s.batch, v)
+	if len(s.batch) < s.size {
+		return nil
+	}
+	return s.send(ctx)
+}
+
+// Flush отправляет неполную последнюю пачку.
+func (s *HTTPSink) Flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	return s.send(context.Background())
+}
+
+// send отправляет накопленную пачку с повторами.
+func (s *HTTPSink) send(ctx context.Context) error {
+	body, err := json.Marshal(s.batch)
+	if err != nil {
+		return err
+	}
+	s.batch = s.batch[:0]
+	_, attempts, err := processWithRetry(ctx, s.post, body, s.retry, s.clock, nil)
+	if err != nil {
+		return fmt.Errorf("отправка %s, попыток %d: %w", s.url, attempts, err)
+	}
+	return nil
+}
+
+// post отправляет тело body одним запросом.
+func (s *HTTPSink) post(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPStatusError{Code: resp.StatusCode}
+	}
+	return body, nil
+}
+
+// HTTPStatusError — ответ с кодом, отличным от 2xx.
+type HTTPStatusError struct {
+	Code int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("ответ %d %s", e.Code, http.StatusText(e.Code))
+}
+
+// retryableHTTP сообщает, стоит ли повторять запрос после ошибки err:
+// после ответов 429 и 5xx и ошибок без ответа.
+func retryableHTTP(err error) bool {
+	var status *HTTPStatusError
+	if errors.As(err, &status) {
+		return status.Code == http.StatusTooManyRequests || status.Code >= 500
+	}
+	return true
+}
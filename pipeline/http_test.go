@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		want       []int64
+	}{
+		{"массив", http.MethodPost, "[1, 2, -3]", http.StatusOK, []int64{1, 2, -3}},
+		{"по одному в строке", http.MethodPost, "4\n5\n6\n", http.StatusOK, []int64{4, 5, 6}},
+		{"пустое тело", http.MethodPost, "", http.StatusOK, nil},
+		{"ошибка после числа", http.MethodPost, "[7, \"восемь\"]", http.StatusBadRequest, []int64{7}},
+		{"не число", http.MethodPost, "{}", http.StatusBadRequest, nil},
+		{"GET", http.MethodGet, "", http.StatusMethodNotAllowed, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := NewHTTPSource()
+			got := make(chan []int64)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				var vs []int64
+				for v, ok := src.Next(ctx); ok; v, ok = src.Next(ctx) {
+					vs = append(vs, v)
+				}
+				got <- vs
+			}()
+			rec := httptest.NewRecorder()
+			src.ServeHTTP(rec, httptest.NewRequest(tt.method, "/values", strings.NewReader(tt.body)))
+			cancel()
+			if vs := <-got; !slices.Equal(vs, tt.want) {
+				t.Errorf("получены числа %v, want %v", vs, tt.want)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("код ответа %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.method != http.MethodPost {
+				return
+			}
+			var res httpAccepted
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil || res.Accepted != len(tt.want) {
+				t.Errorf("ответ %+v, %v, want принято %d", res, err, len(tt.want))
+			}
+		})
+	}
+}
+
+// TestHTTPSourceClose проверяет, что Close исчерпывает источник и отвечает
+// 503 на запросы, числа которых генератор не забрал.
+func TestHTTPSourceClose(t *testing.T) {
+	src := NewHTTPSource()
+	src.Close()
+	src.Close()
+	if _, ok := src.Next(context.Background()); ok {
+		t.Error("Next после Close вернул число")
+	}
+	rec := httptest.NewRecorder()
+	src.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/values", strings.NewReader("[1]")))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("код ответа %d, want 503", rec.Code)
+	}
+}
+
+// TestHTTPSink проверяет отправку пачек и повтор запросов после ответов
+// 5xx, но не 4xx.
+func TestHTTPSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		codes     []int // коды первых ответов; дальше 200
+		wantCalls int
+		wantErr   bool
+	}{
+		{"без ошибок", nil, 3, false},
+		{"повтор после 503", []int{http.StatusServiceUnavailable}, 4, false},
+		{"без повтора после 400", []int{http.StatusBadRequest}, 1, true},
+		{"попытки исчерпаны", []int{500, 500, 500}, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls int
+			var got [][]int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				if calls <= len(tt.codes) {
+					w.WriteHeader(tt.codes[calls-1])
+					return
+				}
+				var batch []int64
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &batch)
+				got = append(got, batch)
+			}))
+			defer srv.Close()
+
+			sink, err := NewHTTPSink(srv.URL, 2, RetryPolicy{Attempts: 3, Backoff: time.Millisecond}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = func() error {
+				for v := int64(1); v <= 5; v++ {
+					if err := sink.Write(context.Background(), v); err != nil {
+						return err
+					}
+				}
+				return sink.Flush()
+			}()
+			var status *HTTPStatusError
+			if tt.wantErr != (err != nil) || (err != nil && !errors.As(err, &status)) {
+				t.Fatalf("ошибка %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("запросов %d, want %d", calls, tt.wantCalls)
+			}
+			if !tt.wantErr && !slices.EqualFunc(got, [][]int64{{1, 2}, {3, 4}, {5}}, slices.Equal) {
+				t.Errorf("получены пачки %v", got)
+			}
+		})
+	}
+}
+
+// TestRunHTTP проверяет, что числа, присланные в HTTPSource, доходят через
+// конвейер до HTTPSink.
+func TestRunHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var got []int64
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []int64
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		got = append(got, batch...)
+		mu.Unlock()
+	}))
+	defer dst.Close()
+	sink, err := NewHTTPSink(dst.URL, 3, RetryPolicy{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewHTTPSource()
+	srv := httptest.NewServer(src)
+	defer srv.Close()
+
+	done := make(chan error)
+	go func() {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader("[1,2,3,4,5,6,7,8,9,10]"))
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	res, err := Run(context.Background(), Config{NumWorkers: 2, Limit: 10, Source: src, Sink: sink})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(got)
+	if res.OutputSum != 55 || !slices.Equal(got, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Errorf("сумма %d, отправлены %v, want 55 и числа от 1 до 10", res.OutputSum, got)
+	}
+}
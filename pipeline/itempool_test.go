@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+)
+
+// TestItemPool проверяет конвейер из Generator, Worker и Consume на
+// конвертах из пула: значения доходят до приёмника, отфильтрованные
+// конверты возвращаются через WithOnSkip, а конверты используются повторно.
+func TestItemPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		wantSum int64
+	}{
+		{"одно", 1, 0},
+		{"много", 10000, 2 * 5000 * 5001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pool := NewItemPool[int64]()
+			gen, out := make(chan *Item[int64], 4), make(chan *Item[int64], 4)
+			go Generator(ctx, gen, pool.Source(Sequential(), 0, nil), WithLimit[*Item[int64]](tt.limit))
+			double := func(_ context.Context, it *Item[int64]) (*Item[int64], error) {
+				if it.Value%2 != 0 {
+					return nil, ErrSkip
+				}
+				it.Value *= 2
+				return it, nil
+			}
+			go Worker(ctx, gen, out, WithProcess(double), WithOnSkip(pool.Put))
+			var sum int64
+			if err := pool.Consume(ctx, out, func(_ context.Context, v int64) error {
+				sum += v
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if sum != tt.wantSum {
+				t.Errorf("сумма %d, want %d", sum, tt.wantSum)
+			}
+			if tt.limit > 100 && pool.Allocated() >= tt.limit/2 {
+				t.Errorf("создано %d конвертов на %d чисел", pool.Allocated(), tt.limit)
+			}
+		})
+	}
+}
+
+func TestItemPoolPut(t *testing.T) {
+	pool := NewItemPool[int64]()
+	it := pool.Get()
+	it.Value, it.Seq, it.Attempts = 5, 1, 2
+	pool.Put(it)
+	if *it != (Item[int64]{}) {
+		t.Errorf("Put оставил в конверте %+v", *it)
+	}
+}
+
+// benchConsume измеряет отправку b.N конвертов send, пока consume читает их
+// в своей горутине, и дожидается его после finish.
+func benchConsume(b *testing.B, consume func(), send func(i int), finish func()) {
+	b.ReportAllocs()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consume()
+	}()
+	for i := 0; i < b.N; i++ {
+		send(i)
+	}
+	finish()
+	<-done
+}
+
+// BenchmarkItemEnvelope сравнивает выделения памяти на конверт при передаче
+// через канал по значению, по указателю и по указателю из ItemPool.
+func BenchmarkItemEnvelope(b *testing.B) {
+	// sum — сумма значений конвертов, чтобы компилятор не выбросил их
+	// чтение; её пишет только горутина приёмника
+	var sum int64
+	b.Run("по значению", func(b *testing.B) {
+		ch := make(chan Item[int64], 64)
+		benchConsume(b, func() {
+			for it := range ch {
+				sum += it.Value
+			}
+		}, func(i int) { ch <- Item[int64]{Value: int64(i), Seq: int64(i)} }, func() { close(ch) })
+	})
+	b.Run("по указателю", func(b *testing.B) {
+		ch := make(chan *Item[int64], 64)
+		benchConsume(b, func() {
+			for it := range ch {
+				sum += it.Value
+			}
+		}, func(i int) { ch <- &Item[int64]{Value: int64(i), Seq: int64(i)} }, func() { close(ch) })
+	})
+	b.Run("из пула", func(b *testing.B) {
+		pool := NewItemPool[int64]()
+		ch := make(chan *Item[int64], 64)
+		benchConsume(b, func() {
+			pool.Consume(context.Background(), ch, func(_ context.Context, v int64) error {
+				sum += v
+				return nil
+			})
+		}, func(i int) {
+			it := pool.Get()
+			it.Value, it.Seq = int64(i), int64(i)
+			ch <- it
+		}, func() { close(ch) })
+	})
+}
@@ -0,0 +1,246 @@
+// Package kafkaio подключает конвейер к Kafka: источник чисел из темы с
+// фиксацией смещений группы потребителей и приёмник, записывающий числа
+// результирующего канала в тему. Числа передаются в значении сообщения
+// десятичной записью.
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultCommitInterval — период фиксации смещений по умолчанию.
+const DefaultCommitInterval = time.Second
+
+// Source — источник чисел из темы Kafka. Source реализует pipeline.Acker:
+// смещение раздела фиксируется, только когда обработаны все полученные до
+// него числа этого раздела. Отброшенное число останавливает фиксацию
+// своего раздела до конца работы, поэтому после перезапуска оно и все
+// следующие сообщения раздела будут получены снова: доставка «хотя бы один
+// раз». Сообщение, которое не удалось разобрать, исчерпывает источник;
+// ошибку возвращает Err.
+type Source struct {
+	r *kafka.Reader
+
+	mu     sync.Mutex
+	n      int64                   // количество полученных чисел
+	msgs   map[int64]kafka.Message // полученные, но не учтённые сообщения
+	parts  map[int]*partition      // неподтверждённые смещения по разделам
+	err    error                   // первая ошибка чтения, разбора или фиксации
+	closed bool
+
+	stop chan struct{} // закрывается методом Close
+	done chan struct{} // закрывается по завершении периодической фиксации
+}
+
+// partition — смещения раздела, полученные, но ещё не зафиксированные.
+type partition struct {
+	topic   string
+	offsets []int64        // смещения в порядке получения
+	acked   map[int64]bool // учтённые смещения: true — число обработано
+}
+
+// NewSource создаёт источник, читающий тему с настройками cfg. cfg.GroupID
+// обязателен: смещения фиксируются за группой потребителей каждые
+// interval (0 — DefaultCommitInterval) и при Close.
+func NewSource(cfg kafka.ReaderConfig, interval time.Duration) (*Source, error) {
+	if cfg.GroupID == "" {
+		return nil, errors.New("для фиксации смещений нужна группа потребителей GroupID")
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("период фиксации смещений не может быть отрицательным: %v", interval)
+	}
+	if interval == 0 {
+		interval = DefaultCommitInterval
+	}
+	// смещения фиксируются явно методом CommitMessages
+	cfg.CommitInterval = 0
+	s := &Source{
+		r:     kafka.NewReader(cfg),
+		msgs:  make(map[int64]kafka.Message),
+		parts: make(map[int]*partition),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go s.commitLoop(interval)
+	return s, nil
+}
+
+// Next возвращает число из очередного сообщения, ожидая его до отмены ctx.
+func (s *Source) Next(ctx context.Context) (int64, bool) {
+	if s.Err() != nil {
+		return 0, false
+	}
+	msg, err := s.r.FetchMessage(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.fail(fmt.Errorf("получение сообщения: %w", err))
+		}
+		return 0, false
+	}
+	v, err := strconv.ParseInt(string(msg.Value), 10, 64)
+	if err != nil {
+		s.fail(fmt.Errorf("сообщение %q раздела %d, смещение %d: %w", msg.Value, msg.Partition, msg.Offset, err))
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	s.msgs[s.n] = msg
+	p := s.parts[msg.Partition]
+	if p == nil {
+		p = &partition{topic: msg.Topic, acked: make(map[int64]bool)}
+		s.parts[msg.Partition] = p
+	}
+	p.offsets = append(p.offsets, msg.Offset)
+	return v, true
+}
+
+// Ack отмечает сообщение n-го числа учтённым; смещение фиксируется позже.
+func (s *Source) Ack(n int64, processed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg, ok := s.msgs[n]
+	if !ok {
+		return
+	}
+	delete(s.msgs, n)
+	s.parts[msg.Partition].acked[msg.Offset] = processed
+}
+
+// commitLoop фиксирует смещения каждые interval до вызова Close.
+func (s *Source) commitLoop(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.commit(context.Background()); err != nil {
+				s.fail(err)
+			}
+		}
+	}
+}
+
+// commit фиксирует для каждого раздела смещение последнего сообщения, до
+// которого включительно все числа обработаны.
+func (s *Source) commit(ctx context.Context) error {
+	s.mu.Lock()
+	var commits []kafka.Message
+	for id, p := range s.parts {
+		last := int64(-1)
+		for len(p.offsets) > 0 {
+			processed, ok := p.acked[p.offsets[0]]
+			if !ok || !processed {
+				break
+			}
+			last = p.offsets[0]
+			delete(p.acked, last)
+			p.offsets = p.offsets[1:]
+		}
+		if last >= 0 {
+			commits = append(commits, kafka.Message{Topic: p.topic, Partition: id, Offset: last})
+		}
+	}
+	s.mu.Unlock()
+	if len(commits) == 0 {
+		return nil
+	}
+	if err := s.r.CommitMessages(ctx, commits...); err != nil {
+		return fmt.Errorf("фиксация смещений: %w", err)
+	}
+	return nil
+}
+
+// fail запоминает первую ошибку.
+func (s *Source) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Err возвращает первую ошибку чтения, разбора сообщения или фиксации
+// смещений.
+func (s *Source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close фиксирует смещения учтённых чисел и закрывает источник. Вызывается
+// после завершения Run, когда все числа учтены.
+func (s *Source) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	close(s.stop)
+	<-s.done
+	err := s.commit(context.Background())
+	if cerr := s.r.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// DefaultBatchSize — размер пачки Sink по умолчанию.
+const DefaultBatchSize = 100
+
+// Sink — приёмник, записывающий числа в тему Kafka пачками. Вызовы методов
+// не должны пересекаться.
+type Sink struct {
+	w     *kafka.Writer
+	size  int
+	batch []kafka.Message // накопленные числа
+}
+
+// NewSink создаёт приёмник, записывающий числа через w пачками по size
+// (меньше 1 — DefaultBatchSize). Тема задаётся в w.Topic.
+func NewSink(w *kafka.Writer, size int) *Sink {
+	if size < 1 {
+		size = DefaultBatchSize
+	}
+	return &Sink{w: w, size: size}
+}
+
+// Write добавляет v в пачку и записывает её, когда она заполнена.
+func (s *Sink) Write(ctx context.Context, v int64) error {
+	s.batch = append(s.batch, kafka.Message{Value: strconv.AppendInt(nil, v, 10)})
+	if len(s.batch) < s.size {
+		return nil
+	}
+	return s.send(ctx)
+}
+
+// Flush записывает неполную последнюю пачку.
+func (s *Sink) Flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	return s.send(context.Background())
+}
+
+// send записывает накопленную пачку.
+func (s *Sink) send(ctx context.Context) error {
+	// при асинхронной записи Writer продолжает использовать пачку
+	err := s.w.WriteMessages(ctx, s.batch...)
+	s.batch = nil
+	if err != nil {
+		return fmt.Errorf("запись в Kafka: %w", err)
+	}
+	return nil
+}
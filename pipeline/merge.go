@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Merge собирает значения из всех каналов ins в один канал и возвращает его.
+// Возвращаемый канал закрывается, когда закрыты все каналы ins.
+// Параметры
+// ins - каналы, откуда будут прочитаны значения
+func Merge[T any](ins ...<-chan T) <-chan T {
+	return MergeFunc(nil, ins...)
+}
+
+// MergeFunc работает как Merge, но дополнительно вызывает fn для каждого
+// значения после его записи в результирующий канал.
+// Параметры
+// fn - функция, получающая индекс исходного канала в ins и значение;
+// может быть nil. Вызывается конкурентно из разных горутин, но для одного
+// индекса i — всегда из одной и той же.
+// ins - каналы, откуда будут прочитаны значения
+func MergeFunc[T any](fn func(i int, v T), ins ...<-chan T) <-chan T {
+	return mergeFunc(fn, nil, ins...)
+}
+
+// MergeContext работает как Merge, но при отмене ctx прекращает пересылку:
+// значения, которые не удалось отправить, и всё, что ещё придёт из ins,
+// передаются в drop (может быть nil), чтобы писатели ins не заблокировались.
+// Возвращаемый канал закрывается, когда закрыты и прочитаны все каналы ins.
+// Параметры
+// ctx - контекст
+// drop - функция, получающая непересланные значения; может быть nil
+// ins - каналы, откуда будут прочитаны значения
+func MergeContext[T any](ctx context.Context, drop func(T), ins ...<-chan T) <-chan T {
+	m := newMerger[T](nil, nil, len(ins))
+	m.done, m.drop = ctx.Done(), drop
+	for i, c := range ins {
+		m.add(i, c, nil)
+	}
+	m.seal()
+	return m.out
+}
+
+// Split раздаёт значения канала in по n каналам: каждое значение получает
+// первый канал, готовый его принять, поэтому медленный читатель не
+// задерживает остальных. Буфер каждого канала равен буферу in. Каналы
+// закрываются после закрытия in; при отмене ctx оставшиеся значения
+// передаются в drop (может быть nil). Split — обратная операция к Merge.
+// Параметры
+// ctx - контекст
+// in - канал, откуда будут прочитаны значения
+// n - количество каналов; значения меньше 1 трактуются как 1
+// drop - функция, получающая нерозданные значения; может быть nil
+func Split[T any](ctx context.Context, in <-chan T, n int, drop func(T)) []<-chan T {
+	if n < 1 {
+		n = 1
+	}
+	if drop == nil {
+		drop = func(T) {}
+	}
+	d := distributor[T]{pick: func([]chan T, int64) int { return -1 }}
+	return d.Distribute(ctx, in, n, drop)
+}
+
+// mergeFunc работает как MergeFunc. Если задан onPanic, паника в fn
+// перехватывается и передаётся в onPanic как *PanicError, после чего
+// горутина продолжает пересылать значения канала уже без вызова fn, чтобы
+// вышестоящие этапы не заблокировались. Без onPanic паника не
+// перехватывается.
+func mergeFunc[T any](fn func(i int, v T), onPanic func(error), ins ...<-chan T) <-chan T {
+	m := newMerger(fn, onPanic, len(ins))
+	for i, c := range ins {
+		m.add(i, c, nil)
+	}
+	m.seal()
+	return m.out
+}
+
+// merger — сборка значений из каналов, которые можно добавлять и после её
+// запуска. Результирующий канал out закрывается, когда сборка запечатана
+// методом seal и все добавленные каналы закрыты и прочитаны; после этого
+// add больше не принимает каналы.
+type merger[T any] struct {
+	out     chan T // результирующий канал
+	fn      func(i int, v T)
+	onPanic func(error)
+	done    <-chan struct{} // закрытие прекращает пересылку; nil — пересылать до конца
+	drop    func(T)         // получает непересланные значения после закрытия done
+
+	mu      sync.Mutex
+	running int  // количество горутин, пересылающих значения
+	sealed  bool // новых каналов не будет
+	closed  bool // out закрыт
+}
+
+// newMerger создаёт сборку с буфером результирующего канала size; fn и
+// onPanic имеют тот же смысл, что и в mergeFunc.
+func newMerger[T any](fn func(i int, v T), onPanic func(error), size int) *merger[T] {
+	return &merger[T]{out: make(chan T, size), fn: fn, onPanic: onPanic}
+}
+
+// add начинает пересылать значения канала in с индексом i; done, если
+// задан, вызывается, когда in закрыт и все его значения пересланы.
+// Возвращает false, если результирующий канал уже закрыт.
+func (m *merger[T]) add(i int, in <-chan T, done func()) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return false
+	}
+	m.running++
+	go m.forward(i, in, done)
+	return true
+}
+
+// seal сообщает, что новых каналов не будет: out закроется, как только
+// будут прочитаны все уже добавленные.
+func (m *merger[T]) seal() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sealed = true
+	m.closeIfDone()
+}
+
+// forward пересылает значения канала in в out.
+func (m *merger[T]) forward(i int, in <-chan T, done func()) {
+	defer func() {
+		if done != nil {
+			done()
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.running--
+		m.closeIfDone()
+	}()
+
+	observe := m.fn
+	for v := range in {
+		select {
+		case <-m.done:
+			// пересылка прекращена: дочитываем in, чтобы не блокировать писателя
+			m.discard(v)
+			for v := range in {
+				m.discard(v)
+			}
+			return
+		case m.out <- v:
+		}
+		if observe == nil {
+			continue
+		}
+		if m.onPanic == nil {
+			observe(i, v)
+			continue
+		}
+		err := protect(func() error {
+			observe(i, v)
+			return nil
+		})
+		if err != nil {
+			m.onPanic(err)
+			observe = nil
+		}
+	}
+}
+
+// discard передаёт непересланное значение в drop, если он задан.
+func (m *merger[T]) discard(v T) {
+	if m.drop != nil {
+		m.drop(v)
+	}
+}
+
+// closeIfDone закрывает out, если значений больше не будет. Вызывается с
+// захваченным m.mu.
+func (m *merger[T]) closeIfDone() {
+	if m.running == 0 && m.sealed && !m.closed {
+		m.closed = true
+		close(m.out)
+	}
+}
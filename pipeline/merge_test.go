@@ -0,0 +1,254 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// channels раздаёт числа values по n закрытым буферизованным каналам по
+// кругу.
+func channels(n int, values []int64) []<-chan int64 {
+	chs := make([]chan int64, n)
+	for i := range chs {
+		chs[i] = make(chan int64, len(values))
+	}
+	for i, v := range values {
+		chs[i%n] <- v
+	}
+	ins := make([]<-chan int64, n)
+	for i, ch := range chs {
+		close(ch)
+		ins[i] = ch
+	}
+	return ins
+}
+
+// sorted возвращает отсортированную копию vs.
+func sorted(vs []int64) []int64 {
+	vs = slices.Clone(vs)
+	slices.Sort(vs)
+	return vs
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		values []int64
+	}{
+		{"без каналов", 0, nil},
+		{"пустые каналы", 3, nil},
+		{"один канал", 1, ints(1, 100)},
+		{"несколько каналов", 4, ints(1, 1000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sorted(collect(Merge(channels(tt.n, tt.values)...))); !slices.Equal(got, tt.values) {
+				t.Errorf("Merge = %v, want %v", got, tt.values)
+			}
+
+			var mu sync.Mutex
+			seen := make([][]int64, tt.n)
+			out := MergeFunc(func(i int, v int64) {
+				mu.Lock()
+				defer mu.Unlock()
+				seen[i] = append(seen[i], v)
+			}, channels(tt.n, tt.values)...)
+			got := collect(out)
+			mu.Lock()
+			defer mu.Unlock()
+			for i, vs := range seen {
+				for _, v := range vs {
+					if (v-1)%int64(tt.n) != int64(i) {
+						t.Errorf("MergeFunc передала %d с индексом %d", v, i)
+					}
+				}
+			}
+			if len(slices.Concat(seen...)) != len(got) || len(got) != len(tt.values) {
+				t.Errorf("MergeFunc: fn получила %d чисел, в канале %d, want %d", len(slices.Concat(seen...)), len(got), len(tt.values))
+			}
+		})
+	}
+}
+
+// TestMergePanic проверяет, что паника в fn передаётся в onPanic, а
+// значения продолжают пересылаться.
+func TestMergePanic(t *testing.T) {
+	var panics atomic.Int32
+	out := mergeFunc(func(_ int, v int64) {
+		if v == 3 {
+			panic("сбой")
+		}
+	}, func(err error) {
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Errorf("onPanic получила %v, want *PanicError", err)
+		}
+		panics.Add(1)
+	}, channels(2, ints(1, 10))...)
+	if got := sorted(collect(out)); !slices.Equal(got, ints(1, 10)) || panics.Load() != 1 {
+		t.Errorf("mergeFunc = %v, паник %d, want 1..10 и 1", got, panics.Load())
+	}
+}
+
+// TestMergerAdd проверяет, что каналы можно добавлять в запущенную сборку,
+// done вызывается после пересылки всех значений канала, а после закрытия
+// результирующего канала add каналы не принимает.
+func TestMergerAdd(t *testing.T) {
+	m := newMerger[int64](nil, nil, 0)
+	var done atomic.Int32
+	for i, in := range channels(3, ints(1, 30)) {
+		if !m.add(i, in, func() { done.Add(1) }) {
+			t.Fatalf("add(%d) = false до закрытия сборки", i)
+		}
+	}
+	var got []int64
+	for len(got) < 30 {
+		got = append(got, <-m.out)
+	}
+	m.seal()
+	if _, ok := <-m.out; ok {
+		t.Fatal("результирующий канал не закрыт после seal")
+	}
+	if !slices.Equal(sorted(got), ints(1, 30)) || done.Load() != 3 {
+		t.Errorf("собрано %v, done вызван %d раз, want 1..30 и 3", got, done.Load())
+	}
+	if m.add(3, make(chan int64), nil) {
+		t.Error("add после закрытия сборки = true")
+	}
+}
+
+func TestMergeContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		read   int // сколько значений прочитать до отмены; -1 — без отмены
+		n      int
+		values []int64
+	}{
+		{"без отмены", -1, 3, ints(1, 300)},
+		{"отмена до чтения", 0, 3, ints(1, 300)},
+		{"отмена посреди чтения", 50, 3, ints(1, 300)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var mu sync.Mutex
+			var dropped []int64
+			// каналы без буфера: писатели ждут, пока сборка прочитает или
+			// отбросит каждое значение
+			ins := make([]<-chan int64, tt.n)
+			var writers sync.WaitGroup
+			for i := range ins {
+				ch := make(chan int64)
+				ins[i] = ch
+				writers.Add(1)
+				go func() {
+					defer writers.Done()
+					defer close(ch)
+					for _, v := range tt.values[i*len(tt.values)/tt.n : (i+1)*len(tt.values)/tt.n] {
+						ch <- v
+					}
+				}()
+			}
+			out := MergeContext(ctx, func(v int64) {
+				mu.Lock()
+				defer mu.Unlock()
+				dropped = append(dropped, v)
+			}, ins...)
+
+			var got []int64
+			if tt.read >= 0 {
+				for range tt.read {
+					got = append(got, <-out)
+				}
+				cancel()
+			}
+			got = append(got, collect(out)...)
+			// после закрытия out все писатели завершены
+			writers.Wait()
+			mu.Lock()
+			defer mu.Unlock()
+			if all := sorted(slices.Concat(got, dropped)); !slices.Equal(all, tt.values) {
+				t.Errorf("переслано %d и отброшено %d чисел, вместе не 1..%d", len(got), len(dropped), len(tt.values))
+			}
+			if tt.read < 0 && len(dropped) > 0 {
+				t.Errorf("без отмены отброшено %v", dropped)
+			}
+			if tt.read >= 0 && len(got) < tt.read {
+				t.Errorf("переслано %d, want не меньше %d", len(got), tt.read)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		want   int // количество каналов
+		values []int64
+	}{
+		{"n меньше 1", 0, 1, ints(1, 10)},
+		{"один канал", 1, 1, ints(1, 100)},
+		{"несколько каналов", 4, 4, ints(1, 1000)},
+		{"пусто", 3, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outs := Split(context.Background(), channels(1, tt.values)[0], tt.n, nil)
+			if len(outs) != tt.want {
+				t.Fatalf("Split вернула %d каналов, want %d", len(outs), tt.want)
+			}
+			// Merge читает каналы одновременно и закрывается после закрытия
+			// всех outs
+			if got := sorted(collect(Merge(outs...))); !slices.Equal(got, tt.values) {
+				t.Errorf("Split раздала %v, want %v", got, tt.values)
+			}
+		})
+	}
+}
+
+// TestSplitSlowReader проверяет, что медленный читатель одного канала не
+// задерживает остальные, а после отмены ctx нерозданные значения уходят в
+// drop.
+func TestSplitSlowReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan int64)
+	var dropped atomic.Int64
+	outs := Split(ctx, in, 2, func(int64) { dropped.Add(1) })
+
+	// из outs[1] никто не читает: все значения получает outs[0]
+	go func() {
+		for v := int64(1); v <= 100; v++ {
+			in <- v
+		}
+	}()
+	var got []int64
+	for range 100 {
+		got = append(got, <-outs[0])
+	}
+	if !slices.Equal(got, ints(1, 100)) {
+		t.Errorf("outs[0] получил %v, want 1..100", got)
+	}
+
+	cancel()
+	go func() {
+		for v := int64(101); v <= 110; v++ {
+			in <- v
+		}
+		close(in)
+	}()
+	var rest int
+	for _, out := range outs {
+		rest += len(collect(out))
+	}
+	if rest+int(dropped.Load()) != 10 {
+		t.Errorf("после отмены роздано %d и отброшено %d, want вместе 10", rest, dropped.Load())
+	}
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusMetrics — метрики конвейера в формате Prometheus. Включаются
+// заданием Config.Metrics; без этого конвейер не тратит время на метрики.
+// Один экземпляр можно использовать в нескольких последовательных запусках:
+// счётчики накапливаются.
+type PrometheusMetrics struct {
+	generated  prometheus.Counter
+	processed  *prometheus.CounterVec
+	queueDepth *prometheus.GaugeVec
+	latency    prometheus.Histogram
+}
+
+// NewPrometheusMetrics создаёт метрики конвейера и регистрирует их в reg.
+// Метрики:
+//   - pipeline_generated_total — количество сгенерированных чисел;
+//   - pipeline_processed_total{worker} — количество чисел, прошедших через
+//     обработчик;
+//   - pipeline_queue_depth{channel} — заполненность буферов каналов "in"
+//     (chIn) и "out" (результирующий канал);
+//   - pipeline_latency_seconds — задержка от получения числа из источника до
+//     результирующего канала.
+func NewPrometheusMetrics(reg prometheus.Registerer) (*PrometheusMetrics, error) {
+	m := &PrometheusMetrics{
+		generated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pipeline_generated_total",
+			Help: "Количество сгенерированных чисел.",
+		}),
+		processed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pipeline_processed_total",
+			Help: "Количество чисел, прошедших через обработчик.",
+		}, []string{"worker"}),
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pipeline_queue_depth",
+			Help: "Количество чисел в буфере канала.",
+		}, []string{"channel"}),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "pipeline_latency_seconds",
+			Help:    "Задержка от получения числа из источника до результирующего канала.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.generated, m.processed, m.queueDepth, m.latency} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// metricsSampleInterval — период обновления pipeline_queue_depth.
+const metricsSampleInterval = 100 * time.Millisecond
+
+// workers возвращает счётчики обработанных чисел для n обработчиков.
+func (m *PrometheusMetrics) workers(n int) []prometheus.Counter {
+	counters := make([]prometheus.Counter, n)
+	for i := range counters {
+		counters[i] = m.processed.WithLabelValues(strconv.Itoa(i))
+	}
+	return counters
+}
+
+// sampleQueues периодически обновляет pipeline_queue_depth, пока не отменён
+// контекст ctx.
+func (m *PrometheusMetrics) sampleQueues(ctx context.Context, clock Clock, in, out <-chan Event) {
+	inDepth := m.queueDepth.WithLabelValues("in")
+	outDepth := m.queueDepth.WithLabelValues("out")
+	for {
+		inDepth.Set(float64(len(in)))
+		outDepth.Set(float64(len(out)))
+		if Sleep(ctx, clock, metricsSampleInterval) != nil {
+			inDepth.Set(0)
+			outDepth.Set(0)
+			return
+		}
+	}
+}
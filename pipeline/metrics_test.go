@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestRunMetrics проверяет, что метрики Prometheus сходятся со статистикой
+// запуска и накапливаются между запусками.
+func TestRunMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewPrometheusMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{NumWorkers: 3, Limit: 100, Metrics: m}
+	for run := 1; run <= 2; run++ {
+		res, err := Run(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := res.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := testutil.ToFloat64(m.generated), float64(run*100); got != want {
+			t.Errorf("запуск %d: pipeline_generated_total = %v, want %v", run, got, want)
+		}
+		var processed float64
+		for _, c := range m.workers(cfg.NumWorkers) {
+			processed += testutil.ToFloat64(c)
+		}
+		if want := float64(run * 100); processed != want {
+			t.Errorf("запуск %d: pipeline_processed_total = %v, want %v", run, processed, want)
+		}
+	}
+	if n := testutil.CollectAndCount(m.latency); n != 1 {
+		t.Errorf("pipeline_latency_seconds: %d метрик, want 1", n)
+	}
+
+	// повторная регистрация в том же реестре — ошибка
+	if _, err := NewPrometheusMetrics(reg); err == nil {
+		t.Error("NewPrometheusMetrics: повторная регистрация без ошибки")
+	}
+}
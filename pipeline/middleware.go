@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// StageFunc — обработка числа или другого значения на этапе конвейера, та
+// же, что принимают Config.Process, WithProcess и Flow.Stage.
+type StageFunc[T any] func(ctx context.Context, v T) (T, error)
+
+// Middleware оборачивает обработку next дополнительным поведением —
+// журналом, замером времени, повторами, метриками — не меняя ни саму
+// обработку, ни Worker.
+type Middleware[T any] func(next StageFunc[T]) StageFunc[T]
+
+// Wrap оборачивает process цепочкой mws. Первая middleware оказывается
+// внешней: она первой получает значение и последней — результат.
+func Wrap[T any](process StageFunc[T], mws ...Middleware[T]) StageFunc[T] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		process = mws[i](process)
+	}
+	return process
+}
+
+// Timing возвращает middleware, которая измеряет по часам clock время
+// каждой обработки и передаёт его в observe вместе с ошибкой обработки.
+func Timing[T any](clock Clock, observe func(d time.Duration, err error)) Middleware[T] {
+	return func(next StageFunc[T]) StageFunc[T] {
+		return func(ctx context.Context, v T) (T, error) {
+			start := clock.Now()
+			res, err := next(ctx, v)
+			observe(clock.Now().Sub(start), err)
+			return res, err
+		}
+	}
+}
+
+// Logging возвращает middleware, которая записывает в logger каждую
+// обработку на уровне Debug, а ошибки, кроме ErrSkip, — на уровне Error.
+// stage — имя этапа в записях журнала.
+func Logging[T any](logger *slog.Logger, stage string) Middleware[T] {
+	return func(next StageFunc[T]) StageFunc[T] {
+		return func(ctx context.Context, v T) (T, error) {
+			res, err := next(ctx, v)
+			switch {
+			case err == nil:
+				logger.DebugContext(ctx, "обработано", "stage", stage, "value", v, "result", res)
+			case errors.Is(err, ErrSkip):
+				logger.DebugContext(ctx, "отфильтровано", "stage", stage, "value", v)
+			default:
+				logger.ErrorContext(ctx, "ошибка обработки", "stage", stage, "value", v, "err", err)
+			}
+			return res, err
+		}
+	}
+}
+
+// Retry возвращает middleware, которая повторяет неудачную обработку до
+// attempts раз всего, делая между попытками паузу backoff по часам clock.
+// ErrSkip и ошибки после отмены контекста не повторяются. Возвращается
+// ошибка последней попытки.
+func Retry[T any](attempts int, backoff time.Duration, clock Clock) Middleware[T] {
+	return func(next StageFunc[T]) StageFunc[T] {
+		return func(ctx context.Context, v T) (T, error) {
+			res, err := next(ctx, v)
+			for i := 1; i < attempts && err != nil && !errors.Is(err, ErrSkip) && ctx.Err() == nil; i++ {
+				if Sleep(ctx, clock, backoff) != nil {
+					break
+				}
+				res, err = next(ctx, v)
+			}
+			return res, err
+		}
+	}
+}
+
+// Slowdown возвращает middleware, которая замедляет обработку в factor
+// раз: после обработки делается пауза (factor-1)·d, где d — время
+// обработки по часам clock. Так моделируется более медленный узел.
+// factor не больше 1 обработку не меняет. Отмена контекста прерывает
+// паузу, но результат обработки возвращается.
+func Slowdown[T any](factor float64, clock Clock) Middleware[T] {
+	return func(next StageFunc[T]) StageFunc[T] {
+		if factor <= 1 {
+			return next
+		}
+		return func(ctx context.Context, v T) (T, error) {
+			start := clock.Now()
+			res, err := next(ctx, v)
+			Sleep(ctx, clock, time.Duration(float64(clock.Now().Sub(start))*(factor-1)))
+			return res, err
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWrap проверяет порядок middleware: первая оказывается внешней.
+func TestWrap(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware[int64] {
+		return func(next StageFunc[int64]) StageFunc[int64] {
+			return func(ctx context.Context, v int64) (int64, error) {
+				calls = append(calls, name+">")
+				res, err := next(ctx, v)
+				calls = append(calls, "<"+name)
+				return res, err
+			}
+		}
+	}
+	process := Wrap(Square, mw("a"), mw("b"))
+	if v, err := process(context.Background(), 3); v != 9 || err != nil {
+		t.Errorf("process(3) = %d, %v, want 9", v, err)
+	}
+	if want := []string{"a>", "b>", "<b", "<a"}; !slices.Equal(calls, want) {
+		t.Errorf("вызовы %v, want %v", calls, want)
+	}
+}
+
+// TestTiming проверяет, что Timing передаёт время обработки по часам и
+// её ошибку.
+func TestTiming(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	errFail := errors.New("сбой")
+	var got time.Duration
+	var gotErr error
+	process := Wrap(func(context.Context, int64) (int64, error) {
+		clock.Advance(5 * time.Millisecond)
+		return 0, errFail
+	}, Timing[int64](clock, func(d time.Duration, err error) { got, gotErr = d, err }))
+	process(context.Background(), 1)
+	if got != 5*time.Millisecond || gotErr != errFail {
+		t.Errorf("Timing передал %v и %v, want 5ms и %v", got, gotErr, errFail)
+	}
+}
+
+// TestLogging проверяет уровни записей Logging.
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	process := Wrap(func(_ context.Context, v int64) (int64, error) {
+		switch v {
+		case 1:
+			return v, nil
+		case 2:
+			return v, ErrSkip
+		}
+		return v, errors.New("сбой")
+	}, Logging[int64](logger, "square"))
+	for v := int64(1); v <= 3; v++ {
+		process(context.Background(), v)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{"level=DEBUG msg=обработано", "level=DEBUG msg=отфильтровано", "level=ERROR msg=\"ошибка обработки\""}
+	if len(lines) != len(want) {
+		t.Fatalf("журнал:\n%s", buf.String())
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, want[i]) || !strings.Contains(line, "stage=square") {
+			t.Errorf("запись %d = %q, want %q и stage=square", i, line, want[i])
+		}
+	}
+}
+
+// TestRetry проверяет, что Retry повторяет ошибки до attempts раз, но не
+// повторяет ErrSkip.
+func TestRetry(t *testing.T) {
+	errFail := errors.New("сбой")
+	tests := []struct {
+		name      string
+		fails     int   // сколько первых попыток неудачны
+		err       error // ошибка неудачной попытки
+		wantCalls int
+		wantErr   error
+	}{
+		{"успех сразу", 0, errFail, 1, nil},
+		{"успех с третьей", 2, errFail, 3, nil},
+		{"все неудачны", 5, errFail, 3, errFail},
+		{"ErrSkip", 5, ErrSkip, 1, ErrSkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			process := Wrap(func(_ context.Context, v int64) (int64, error) {
+				calls++
+				if calls <= tt.fails {
+					return 0, tt.err
+				}
+				return v, nil
+			}, Retry[int64](3, 0, SystemClock))
+			_, err := process(context.Background(), 1)
+			if calls != tt.wantCalls || !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Errorf("попыток %d, ошибка %v, want %d и %v", calls, err, tt.wantCalls, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSlowdown проверяет, что Slowdown после обработки делает паузу
+// (factor-1)·d по часам, а factor не больше 1 обработку не замедляет.
+func TestSlowdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		factor float64
+		want   time.Duration // время обработки вместе с паузой
+	}{
+		{"без замедления", 1, 2 * time.Millisecond},
+		{"меньше 1", 0.5, 2 * time.Millisecond},
+		{"в 3 раза", 3, 6 * time.Millisecond},
+		{"в 1,5 раза", 1.5, 3 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Unix(0, 0)
+			clock := NewManualClock(start)
+			process := Slowdown[int64](tt.factor, clock)(func(_ context.Context, v int64) (int64, error) {
+				clock.Advance(2 * time.Millisecond)
+				return v * v, nil
+			})
+			done := make(chan int64, 1)
+			go func() {
+				v, _ := process(context.Background(), 3)
+				done <- v
+			}()
+			if tt.want > 2*time.Millisecond {
+				for clock.Waiters() == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				clock.Advance(tt.want - 2*time.Millisecond)
+			}
+			if v := <-done; v != 9 {
+				t.Errorf("process(3) = %d, want 9", v)
+			}
+			if got := clock.Now().Sub(start); got != tt.want {
+				t.Errorf("обработка длилась %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
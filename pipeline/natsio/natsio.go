@@ -0,0 +1,199 @@
+// Package natsio подключает конвейер к NATS: источник чисел из подписки на
+// тему и приёмник, публикующий числа результирующего канала. Числа
+// передаются в теле сообщения десятичной записью.
+package natsio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// maxPending — сколько сообщений может ждать генератора. Для JetStream это
+// и наибольшее количество неподтверждённых сообщений потребителя: сервер не
+// присылает больше, чем помещается в буфер, поэтому сообщения не теряются.
+const maxPending = 1024
+
+// Source — источник чисел из подписки NATS. При подписке JetStream Source
+// реализует pipeline.Acker: сообщение подтверждается, когда число
+// обработано, и возвращается для повторной доставки, когда число
+// отброшено. Сообщение, которое не удалось разобрать, отклоняется без
+// повторной доставки и исчерпывает источник; ошибку возвращает Err.
+type Source struct {
+	sub       *nats.Subscription
+	msgs      chan *nats.Msg
+	jetStream bool
+
+	mu      sync.Mutex
+	n       int64               // количество полученных чисел
+	pending map[int64]*nats.Msg // полученные, но не учтённые сообщения JetStream
+	err     error               // первая ошибка разбора или подтверждения
+}
+
+// Subscribe подписывается на тему subject обычной подпиской NATS. NATS
+// доставляет такие сообщения не больше одного раза и не ждёт
+// подтверждений; сообщения, пришедшие, пока генератор не успевает их
+// забирать, теряются.
+func Subscribe(nc *nats.Conn, subject string) (*Source, error) {
+	s := newSource(false)
+	sub, err := nc.ChanSubscribe(subject, s.msgs)
+	if err != nil {
+		return nil, err
+	}
+	s.sub = sub
+	return s, nil
+}
+
+// SubscribeJetStream подписывается на тему subject потребителем JetStream
+// durable с ручным подтверждением, поэтому после перезапуска доставка
+// продолжается с неподтверждённых сообщений. Если потребителя нет, он
+// создаётся в потоке, хранящем тему subject.
+func SubscribeJetStream(js nats.JetStreamContext, subject, durable string) (*Source, error) {
+	stream, err := js.StreamNameBySubject(subject)
+	if err != nil {
+		return nil, fmt.Errorf("поток темы %s: %w", subject, err)
+	}
+	// потребитель, созданный при подписке, удаляется вместе с ней, поэтому
+	// создаём его явно и только привязываемся к нему
+	_, err = js.ConsumerInfo(stream, durable)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = js.AddConsumer(stream, &nats.ConsumerConfig{
+			Durable:        durable,
+			DeliverSubject: nats.NewInbox(),
+			FilterSubject:  subject,
+			AckPolicy:      nats.AckExplicitPolicy,
+			MaxAckPending:  maxPending,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("потребитель %s: %w", durable, err)
+	}
+	s := newSource(true)
+	sub, err := js.ChanSubscribe(subject, s.msgs, nats.Bind(stream, durable), nats.ManualAck())
+	if err != nil {
+		return nil, err
+	}
+	s.sub = sub
+	return s, nil
+}
+
+// newSource создаёт источник без подписки.
+func newSource(jetStream bool) *Source {
+	return &Source{
+		msgs:      make(chan *nats.Msg, maxPending),
+		jetStream: jetStream,
+		pending:   make(map[int64]*nats.Msg),
+	}
+}
+
+// Next возвращает число из очередного сообщения, ожидая его до отмены ctx.
+func (s *Source) Next(ctx context.Context) (int64, bool) {
+	if s.Err() != nil {
+		return 0, false
+	}
+	var msg *nats.Msg
+	select {
+	case <-ctx.Done():
+		return 0, false
+	case msg = <-s.msgs:
+	}
+	v, err := strconv.ParseInt(string(msg.Data), 10, 64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if s.jetStream {
+			msg.Term()
+		}
+		s.fail(fmt.Errorf("сообщение %q: %w", msg.Data, err))
+		return 0, false
+	}
+	s.n++
+	if s.jetStream {
+		s.pending[s.n] = msg
+	}
+	return v, true
+}
+
+// Ack подтверждает сообщение n-го числа или возвращает его для повторной
+// доставки.
+func (s *Source) Ack(n int64, processed bool) {
+	s.mu.Lock()
+	msg, ok := s.pending[n]
+	delete(s.pending, n)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	var err error
+	if processed {
+		err = msg.Ack()
+	} else {
+		err = msg.Nak()
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.fail(fmt.Errorf("подтверждение сообщения: %w", err))
+		s.mu.Unlock()
+	}
+}
+
+// fail запоминает первую ошибку. Вызывается с захваченным s.mu.
+func (s *Source) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Err возвращает первую ошибку разбора или подтверждения сообщения.
+func (s *Source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close отменяет подписку. Потребитель JetStream durable при этом
+// сохраняется на сервере, а сообщения, которые генератор не успел забрать,
+// возвращаются для повторной доставки.
+func (s *Source) Close() error {
+	err := s.sub.Unsubscribe()
+	if errors.Is(err, nats.ErrConnectionClosed) {
+		return nil
+	}
+	for s.jetStream {
+		select {
+		case msg := <-s.msgs:
+			msg.Nak()
+		default:
+			return err
+		}
+	}
+	return err
+}
+
+// Sink — приёмник, публикующий каждое число в тему NATS. Вызовы методов не
+// должны пересекаться.
+type Sink struct {
+	nc      *nats.Conn
+	subject string
+	buf     []byte // буфер для форматирования числа
+}
+
+// NewSink создаёт приёмник, публикующий числа в тему subject.
+func NewSink(nc *nats.Conn, subject string) *Sink {
+	return &Sink{nc: nc, subject: subject}
+}
+
+// Write публикует v.
+func (s *Sink) Write(_ context.Context, v int64) error {
+	s.buf = strconv.AppendInt(s.buf[:0], v, 10)
+	return s.nc.Publish(s.subject, s.buf)
+}
+
+// Flush ждёт, пока сервер получит все опубликованные числа.
+func (s *Sink) Flush() error {
+	return s.nc.Flush()
+}
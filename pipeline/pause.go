@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// pauseGate — Limiter, который пропускает события, пока не вызван pause, и
+// задерживает их до вызова resume. Безопасен для конкурентного
+// использования.
+type pauseGate struct {
+	mu     sync.Mutex
+	paused bool
+	open   chan struct{} // закрывается при снятии паузы
+}
+
+// newPauseGate создаёт открытый pauseGate.
+func newPauseGate() *pauseGate {
+	open := make(chan struct{})
+	close(open)
+	return &pauseGate{open: open}
+}
+
+// Wait ждёт снятия паузы или отмены контекста.
+func (g *pauseGate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	open := g.open
+	g.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-open:
+		return nil
+	}
+}
+
+// pause ставит паузу; повторный вызов ничего не меняет.
+func (g *pauseGate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		g.paused = true
+		g.open = make(chan struct{})
+	}
+}
+
+// resume снимает паузу; повторный вызов ничего не меняет.
+func (g *pauseGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.paused = false
+		close(g.open)
+	}
+}
+
+// isPaused сообщает, стоит ли пауза.
+func (g *pauseGate) isPaused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestPauseGate проверяет, что pauseGate задерживает Wait от pause до
+// resume, а отмена контекста прерывает ожидание.
+func TestPauseGate(t *testing.T) {
+	g := newPauseGate()
+	if err := g.Wait(context.Background()); err != nil || g.isPaused() {
+		t.Fatalf("Wait без паузы = %v, пауза %v", err, g.isPaused())
+	}
+	g.pause()
+	g.pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := g.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait на паузе = %v, want context.DeadlineExceeded", err)
+	}
+	done := make(chan error)
+	go func() { done <- g.Wait(context.Background()) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Wait вернул %v до resume", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	g.resume()
+	g.resume()
+	if err := <-done; err != nil || g.isPaused() {
+		t.Errorf("Wait после resume = %v, пауза %v", err, g.isPaused())
+	}
+}
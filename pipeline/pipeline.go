@@ -0,0 +1,1518 @@
+// Package pipeline содержит генератор чисел, обрабатывающие горутины и
+// сборку их результатов в один канал (fan-out/fan-in), а также
+// вспомогательные этапы обработки потоков чисел.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/queue"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config — настройки конвейера.
+type Config struct {
+	NumWorkers int           // количество обрабатывающих горутин и каналов
+	Timeout    time.Duration // время генерации чисел; 0 — до отмены контекста
+	BufferSize int           // размер буфера каналов chIn и outs[i]
+	// Warmup — прогрев перед измерением: числа, полученные из источника за
+	// это время, обрабатываются и проверяются как обычно, но не входят в
+	// производительность и задержку Result, чтобы их не искажали запуск
+	// горутин и первые отправки. Timeout отсчитывается после прогрева.
+	// 0 — без прогрева
+	Warmup time.Duration
+	// OutBufferSize — размер буфера каналов outs[i]; 0 — BufferSize,
+	// отрицательное значение — без буфера
+	OutBufferSize int
+	// ResultBufferSize — размер буфера результирующего канала; 0 —
+	// количество обработчиков, отрицательное значение — без буфера
+	ResultBufferSize int
+	Limit            int64 // сколько чисел сгенерировать; 0 — без ограничения
+	// MaxValue — генерация останавливается на первом числе, большем
+	// MaxValue; 0 — без ограничения
+	MaxValue int64
+	// WorkerDelay — пауза обработки каждого числа, если Process не задана;
+	// 0 — без паузы
+	WorkerDelay time.Duration
+	// WorkerDelayFunc, если задана, возвращает паузу для каждого числа
+	// вместо WorkerDelay. Позволяет моделировать переменное время работы.
+	WorkerDelayFunc func() time.Duration
+	// WorkerDelayFactors — во сколько раз медленнее обрабатывает числа
+	// каждый обработчик: обработка, включая паузу WorkerDelay, в
+	// обработчике i длится в WorkerDelayFactors[i] раз дольше, см.
+	// Slowdown. Обработчики сверх длины среза работают без замедления.
+	// Моделирует разнородные узлы, например для сравнения Distributor.
+	WorkerDelayFactors []float64
+	// Rate — ограничение частоты генерации, чисел в секунду; 0 — без
+	// ограничения
+	Rate float64
+	// Burst — сколько чисел можно сгенерировать подряд без ожидания при
+	// заданном Rate; 0 — 1
+	Burst int
+	// Metrics — метрики Prometheus, созданные NewPrometheusMetrics; nil —
+	// метрики не собираются
+	Metrics *PrometheusMetrics
+	// Tracer — трассировщик OpenTelemetry; если задан, путь каждого числа
+	// записывается в отдельный span. nil — трассировка выключена
+	Tracer trace.Tracer
+	// Logger — журнал событий конвейера: запуск, остановка генерации,
+	// ошибки этапов и итоги; nil — события не записываются
+	Logger *slog.Logger
+	// Drain — политика дообработки чисел после остановки генерации
+	Drain DrainPolicy
+	// Source — источник чисел; nil — последовательность 1,2,3 и т.д.
+	// Источники хранят состояние, поэтому для каждого запуска нужен новый.
+	Source Source[int64]
+	// Sources — несколько источников, из которых генераторы одновременно
+	// отправляют числа в chIn; Snapshot.PerSource разбивает по ним
+	// сгенерированные числа, а Limit ограничивает их общее количество.
+	// Задаётся вместо Source.
+	Sources []Source[int64]
+	// GeneratorHooks — обработчики событий генерации, см. GeneratorHook:
+	// вызываются после собственных обработчиков конвейера, которые
+	// подсчитывают числа, и из горутин всех источников Sources одновременно
+	GeneratorHooks []GeneratorHook[Event]
+	// SpillThreshold — если больше 0, между сборкой и приёмником работает
+	// Spillover: числа сверх SpillThreshold, которые приёмник не успевает
+	// прочитать, вытесняются во временный файл в каталоге SpillDir (пустая
+	// строка — системный каталог). Ошибка ввода-вывода возвращается как
+	// *SinkError.
+	SpillThreshold int
+	SpillDir       string
+	// Process — обработка каждого числа в Worker; nil — Delay(WorkerDelay).
+	// Возврат ErrSkip отфильтровывает число. Ошибка или паника обработки
+	// останавливает конвейер и возвращается из Run.
+	Process func(context.Context, int64) (int64, error)
+	// Middleware оборачивают обработку каждого числа, в том числе Process
+	// по умолчанию; первая оказывается внешней
+	Middleware []Middleware[int64]
+	// Collect получает каждое число результирующего канала; nil — числа
+	// только подсчитываются. Ошибка или паника останавливает конвейер.
+	Collect func(int64) error
+	// Sink — приёмник чисел результирующего канала, например Stdout()
+	// или CreateFileSink; получает их в том же порядке, что и Collect.
+	// Вызовы Sink не пересекаются. nil — числа только подсчитываются
+	Sink Sink
+	// WorkerSinks, если задана, возвращает собственный приёмник обработчика
+	// worker, например со своим подключением к базе данных: обработчик сам
+	// передаёт в него каждое обработанное число, не дожидаясь сборки,
+	// поэтому приёмники работают одновременно, а SinkLimit ограничивает,
+	// сколько их операций выполняется сразу. Ошибка приёмника — ошибка
+	// обработки числа. Число, которое остановка не дала отправить в
+	// сборку, учитывается как отброшенное, хотя уже записано. Sink, если
+	// задан, получает те же числа после сборки. Несовместима с Ordered и
+	// Dedup
+	WorkerSinks func(worker int) Sink
+	// SinkLimit — ограничение одновременных операций Sink и WorkerSinks
+	// общим семафором; время его ожидания — Snapshot.SinkBlocked
+	SinkLimit SinkLimit
+	// Reducers — свёртки чисел, переданных в Collect и Sink, например
+	// NewMinMaxReducer или NewTopKReducer: каждая получает все эти числа в
+	// своей горутине, а их итоги — Result.Reduced в том же порядке. С
+	// WorkerSinks числа обработчиков получают только приёмники
+	Reducers []Reducer
+	// Dedup — подавление повторов: число результирующего канала, недавно
+	// уже пришедшее в него, не передаётся в Reservoir, Collect и Sink, см.
+	// DedupPolicy
+	Dedup DedupPolicy
+	// Ordered — передавать числа в Collect в порядке их генерации, а не
+	// в порядке прихода из обработчиков
+	Ordered bool
+	// ReorderWindow — сколько чисел может ждать своей очереди при Ordered;
+	// 0 — без ограничения
+	ReorderWindow int
+	// ReorderOverflow — что делать, когда окно ReorderWindow заполнено:
+	// ReorderBlock задерживает обогнавшие обработчики, ReorderFail
+	// останавливает конвейер с ErrReorderOverflow
+	ReorderOverflow ReorderPolicy
+	// VerifySequence — отмечать номер каждого учтённого числа, чтобы
+	// Result.Sequence показал, какие именно числа потеряны или продублированы
+	VerifySequence bool
+	// CheckMonotonic — проверять через EnsureMonotonic, что числа,
+	// переданные в Collect и Sink, строго возрастают; число, не большее
+	// предыдущего, — нарушение Result.Order.Violations. Числа идут по
+	// порядку генерации только с Ordered или одним обработчиком
+	CheckMonotonic bool
+	// DetectGaps — считать через GapDetector пропущенные целые числа среди
+	// чисел, переданных в Collect и Sink, — Result.Order.Gaps. С
+	// CheckMonotonic пропуски ищутся только среди чисел, не нарушивших
+	// возрастание
+	DetectGaps bool
+	// Trend — скользящее среднее чисел, переданных в Collect и Sink, см.
+	// TrendPolicy
+	Trend TrendPolicy
+	// BigSums — собирать точные суммы Result.BigSums, не ограниченные int64,
+	// и сравнивать при проверке их. Нужно для долгих запусков, в которых
+	// суммы выходят за пределы int64; без него такие запуски не проходят
+	// проверку сумм с ErrSumOverflow
+	BigSums bool
+	// Ack — выдавать каждому числу ID (его Event.Seq) и подтверждать его
+	// окончательный учёт, чтобы Result.Acks показал неподтверждённые и
+	// повторно подтверждённые числа
+	Ack bool
+	// Distributor — раздача чисел из chIn обработчикам; nil — Shared,
+	// общий канал для всех обработчиков
+	Distributor Distributor[Event]
+	// Priority — классы приоритета чисел: обработчики получают числа через
+	// PriorityLanes, а Snapshot.PerPriority, Snapshot.PriorityOut и
+	// Result.PriorityLatency разбивают статистику по классам. Задаётся
+	// без Distributor
+	Priority PriorityPolicy
+	// Autoscale — правила изменения количества обработчиков во время
+	// работы; NumWorkers задаёт начальное количество. Нулевое значение —
+	// количество обработчиков постоянно. Требует Shared.
+	Autoscale AutoscalePolicy
+	// MaxWorkers — наибольшее количество обработчиков, до которого их можно
+	// добавить методом AddWorkers; 0 — NumWorkers. Больше NumWorkers
+	// требует Shared.
+	MaxWorkers int
+	// AdaptiveBuffer — экспериментальная автоматическая настройка буфера
+	// между генератором и обработчиками; нулевое значение — буфер chIn
+	// постоянного размера BufferSize
+	AdaptiveBuffer AdaptiveBufferPolicy
+	// Spill — очередь с вытеснением на диск между генератором и
+	// обработчиками: генератор не ждёт обработчиков, пока в очереди есть
+	// место. nil — генератор пишет прямо в chIn. Числа, оставшиеся в очереди
+	// при остановке конвейера, отбрасываются, поэтому очередь должна быть
+	// пустой: записи, которые queue.Open восстановил после аварийного
+	// завершения, Run не продолжает.
+	Spill *queue.Queue
+	// ItemTimeout — наибольшее время обработки одного числа по реальным
+	// часам, см. WithItemTimeout; 0 — без ограничения
+	ItemTimeout time.Duration
+	// Watchdog — наблюдение за обработчиками, которые обрабатывают одно
+	// число слишком долго
+	Watchdog WatchdogPolicy
+	// Retry — повтор неудачной обработки числа в обработчике
+	Retry RetryPolicy
+	// LeakTimeout — сколько Run ждёт завершения своих горутин после
+	// остановки обработки: если за это время они не завершились, например
+	// обработка не учитывает отмену контекста, Run перестаёт их ждать и
+	// возвращает *LeakError с их именами. 0 — ждать без ограничения
+	LeakTimeout time.Duration
+	// Chaos — случайные задержки, ошибки и паники в обработке чисел для
+	// проверки повторов, DeadLetters и перехвата паник; вносятся внутри
+	// Middleware, поэтому видны им как сбои обработки
+	Chaos ChaosPolicy
+	// DeadLetters — приёмник чисел, обработка которых окончательно не
+	// удалась: вместо остановки конвейера с ошибкой такие числа
+	// передаются в приёмник, сохраняются в Result.DeadLetters и
+	// учитываются как необработанные. nil — первая неудача останавливает
+	// конвейер.
+	DeadLetters DeadLetterSink
+	// Checkpoint — периодическое сохранение состояния генерации в файл
+	Checkpoint CheckpointPolicy
+	// Record — запись хода запуска для воспроизведения, см. LoadReplay;
+	// nil — не записывать
+	Record ReplayRecorder
+	// Resume — состояние, с которого продолжается генерация: первые
+	// Resume.Generated чисел источника пропускаются, а Limit учитывает их
+	// как уже сгенерированные. Нулевое значение — генерация с начала.
+	Resume Checkpoint
+	// Clock — часы для таймаута, пауз обработки и политики дообработки, по
+	// которым также отмечается время генерации чисел и измеряется их
+	// задержка до приёмника; nil — SystemClock.
+	Clock Clock
+	// Ready, если задан, откладывает генерацию до сигнала готовности, см.
+	// WithReadiness.
+	Ready <-chan struct{}
+	// Reservoir, если задан, получает каждое число результирующего канала;
+	// его выборка возвращается в Result.Sample.
+	Reservoir *ReservoirReducer
+}
+
+// DefaultConfig возвращает настройки, с которыми конвейер работал исходно:
+// 5 обработчиков, генерация в течение 1 с, небуферизованные каналы и пауза
+// 1 мс в каждом обработчике.
+func DefaultConfig() Config {
+	return Config{
+		NumWorkers:  5,
+		Timeout:     time.Second,
+		WorkerDelay: DefaultWorkerDelay,
+	}
+}
+
+// Validate проверяет корректность настроек и сообщает обо всех
+// некорректных настройках сразу: ошибки объединяются errors.Join.
+func (c Config) Validate() error {
+	var errs []error
+	if c.NumWorkers < 1 {
+		errs = append(errs, fmt.Errorf("количество обработчиков должно быть положительным: %d", c.NumWorkers))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("таймаут не может быть отрицательным: %v", c.Timeout))
+	}
+	if c.Warmup < 0 {
+		errs = append(errs, fmt.Errorf("время прогрева не может быть отрицательным: %v", c.Warmup))
+	}
+	if c.BufferSize < 0 {
+		errs = append(errs, fmt.Errorf("размер буфера не может быть отрицательным: %d", c.BufferSize))
+	}
+	if c.Limit < 0 {
+		errs = append(errs, fmt.Errorf("количество чисел не может быть отрицательным: %d", c.Limit))
+	}
+	if c.Rate < 0 {
+		errs = append(errs, fmt.Errorf("частота генерации не может быть отрицательной: %v", c.Rate))
+	}
+	if c.Burst < 0 {
+		errs = append(errs, fmt.Errorf("размер всплеска не может быть отрицательным: %d", c.Burst))
+	}
+	if c.ReorderWindow < 0 {
+		errs = append(errs, fmt.Errorf("окно восстановления порядка не может быть отрицательным: %d", c.ReorderWindow))
+	}
+	if !c.Ordered && (c.ReorderWindow != 0 || c.ReorderOverflow != ReorderBlock) {
+		errs = append(errs, errors.New("окно восстановления порядка задано без Ordered"))
+	}
+	if c.ReorderOverflow != ReorderBlock && c.ReorderOverflow != ReorderFail {
+		errs = append(errs, fmt.Errorf("неизвестная политика переполнения окна порядка: %d", c.ReorderOverflow))
+	}
+	if _, isShared := c.Distributor.(shared[Event]); c.ReorderWindow > 0 && c.ReorderOverflow == ReorderBlock && c.Distributor != nil && !isShared {
+		// обработчик, ждущий места в окне, задержал бы раздачу недостающего
+		// числа, если оно ещё в chIn
+		errs = append(errs, errors.New("ожидание места в окне порядка работает только с общим каналом обработчиков"))
+	}
+	if c.ReorderWindow > 0 && c.ReorderOverflow == ReorderBlock && c.Priority.enabled() {
+		// число низшего класса может ждать в своей очереди, пока
+		// обработчики ждут места в окне с числами высшего
+		errs = append(errs, errors.New("ожидание места в окне порядка несовместимо с приоритетами"))
+	}
+	if c.MaxWorkers != 0 && c.MaxWorkers < c.NumWorkers {
+		errs = append(errs, fmt.Errorf("наибольшее количество обработчиков %d меньше начального %d", c.MaxWorkers, c.NumWorkers))
+	}
+	if c.ItemTimeout < 0 {
+		errs = append(errs, fmt.Errorf("время обработки числа не может быть отрицательным: %v", c.ItemTimeout))
+	}
+	if c.LeakTimeout < 0 {
+		errs = append(errs, fmt.Errorf("время ожидания горутин не может быть отрицательным: %v", c.LeakTimeout))
+	}
+	if err := c.Watchdog.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Retry.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Chaos.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.SinkLimit.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.WorkerSinks != nil && (c.Ordered || c.Dedup.enabled()) {
+		errs = append(errs, errors.New("приёмники обработчиков WorkerSinks несовместимы с Ordered и Dedup"))
+	}
+	if err := c.Dedup.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Trend.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Priority.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Priority.enabled() && c.Distributor != nil {
+		errs = append(errs, errPriorityDistributor)
+	}
+	if err := c.Checkpoint.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Source != nil && len(c.Sources) > 0 {
+		errs = append(errs, errors.New("источник задан и в Source, и в Sources"))
+	}
+	if c.Resume.Generated > 0 && len(c.Sources) > 1 {
+		errs = append(errs, errors.New("продолжение генерации возможно только с одним источником"))
+	}
+	if c.Resume.Generated > 0 && (isAcker(c.Source) || slices.ContainsFunc(c.Sources, isAcker)) {
+		errs = append(errs, errors.New("продолжение генерации невозможно с источником, подтверждающим числа"))
+	}
+	if c.Resume.Generated < 0 {
+		errs = append(errs, fmt.Errorf("количество сгенерированных чисел не может быть отрицательным: %d", c.Resume.Generated))
+	}
+	if c.Spill != nil && c.AdaptiveBuffer.enabled() {
+		errs = append(errs, errors.New("очередь с вытеснением на диск несовместима с настройкой буфера AdaptiveBuffer"))
+	}
+	// числа, восстановленные из очереди после перезапуска, не учтены на
+	// входе этого запуска и нарушили бы проверку
+	if c.Spill != nil && c.Spill.Len() > 0 {
+		errs = append(errs, fmt.Errorf("очередь с вытеснением на диск не пуста: %d записей прошлого запуска", c.Spill.Len()))
+	}
+	if err := c.AdaptiveBuffer.validate(c.BufferSize); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Autoscale.validate(c.NumWorkers); err != nil {
+		errs = append(errs, err)
+	}
+	if _, isShared := c.Distributor.(shared[Event]); c.workerCapacity() > c.NumWorkers && c.Distributor != nil && !isShared {
+		errs = append(errs, errors.New("изменение количества обработчиков возможно только с общим каналом обработчиков"))
+	}
+	if c.WorkerDelay < 0 {
+		errs = append(errs, fmt.Errorf("пауза обработчика не может быть отрицательной: %v", c.WorkerDelay))
+	}
+	for i, f := range c.WorkerDelayFactors {
+		if f < 1 {
+			errs = append(errs, fmt.Errorf("замедление обработчика %d должно быть не меньше 1: %v", i, f))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sinkWriter возвращает sinkWriter приёмника sink с ограничением SinkLimit,
+// передающий время ожидания семафора в blocked.
+func (c Config) sinkWriter(ctx context.Context, sink Sink, clock Clock, fail func(error), blocked func(time.Duration)) *sinkWriter {
+	return &sinkWriter{ctx: ctx, sink: sink, fail: fail, limit: c.SinkLimit, clock: clock, blocked: blocked}
+}
+
+// slowdown возвращает обработку process для обработчика i, замедленную по
+// WorkerDelayFactors.
+func (c Config) slowdown(i int, clock Clock, process StageFunc[int64]) StageFunc[int64] {
+	if i >= len(c.WorkerDelayFactors) {
+		return process
+	}
+	return Slowdown[int64](c.WorkerDelayFactors[i], clock)(process)
+}
+
+// leakDeadline возвращает канал, который закрывается через LeakTimeout по
+// часам clock после отмены work, если до этого не закрыт finished; nil,
+// если LeakTimeout не задан.
+func (c Config) leakDeadline(work context.Context, clock Clock, finished <-chan struct{}) <-chan struct{} {
+	if c.LeakTimeout <= 0 {
+		return nil
+	}
+	leaked := make(chan struct{})
+	go func() {
+		select {
+		case <-work.Done():
+		case <-finished:
+			return
+		}
+		select {
+		case <-clock.After(c.LeakTimeout):
+			close(leaked)
+		case <-finished:
+		}
+	}()
+	return leaked
+}
+
+// recvUntil получает значение из ch, но перестаёт ждать, когда закрыт
+// stop; nil — без ограничения. ok равно false, если ch закрыт или
+// ожидание прервано.
+func recvUntil[T any](ch <-chan T, stop <-chan struct{}) (v T, ok bool) {
+	select {
+	case v, ok = <-ch:
+	case <-stop:
+	}
+	return v, ok
+}
+
+// drainUntil передаёт в fn значения ch, пока ch не закрыт или не закрыт
+// stop.
+func drainUntil[T any](ch <-chan T, stop <-chan struct{}, fn func(T)) {
+	for {
+		v, ok := recvUntil(ch, stop)
+		if !ok {
+			return
+		}
+		fn(v)
+	}
+}
+
+// waitUntil ждёт закрытия done, но не дольше закрытия stop.
+func waitUntil(done, stop <-chan struct{}) {
+	select {
+	case <-done:
+	case <-stop:
+	}
+}
+
+// doneBefore ждёт закрытия done, но не дольше закрытия stop или abort, и
+// сообщает, закрыт ли done; если закрыты оба, done важнее.
+func doneBefore(done, stop, abort <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-stop:
+	case <-abort:
+	}
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// bufferSize возвращает размер буфера канала по настройке size: 0 —
+// размер по умолчанию def, отрицательное значение — без буфера.
+func bufferSize(size, def int) int {
+	switch {
+	case size == 0:
+		return def
+	case size < 0:
+		return 0
+	}
+	return size
+}
+
+// workerCapacity возвращает наибольшее количество обработчиков, которое
+// может работать одновременно.
+func (c Config) workerCapacity() int {
+	capacity := max(c.NumWorkers, c.MaxWorkers)
+	if c.Autoscale.enabled() {
+		capacity = max(capacity, c.Autoscale.MaxWorkers)
+	}
+	return capacity
+}
+
+// Pipeline связывает Generator, NumWorkers горутин Worker и сборку их
+// результатов в единый канал.
+type Pipeline struct {
+	cfg      Config
+	channels []ChannelState // состояние каналов после последнего Run
+
+	stop      chan struct{} // закрывается методом Stop
+	stopOnce  sync.Once
+	stopCause error // причина, переданная в StopCause
+
+	stats atomic.Pointer[Stats]      // статистика текущего или последнего запуска
+	pool  atomic.Pointer[workerPool] // обработчики текущего или последнего запуска
+	state atomic.Pointer[runState]   // состояние текущего или последнего запуска
+	gate  *pauseGate                 // пауза генерации и обработки
+}
+
+// New создаёт конвейер с настройками cfg.
+func New(cfg Config) *Pipeline {
+	return &Pipeline{cfg: cfg, stop: make(chan struct{}), gate: newPauseGate()}
+}
+
+// Pause приостанавливает конвейер, не останавливая его: генератор перестаёт
+// получать числа из источника, а обработчики, закончив текущие числа,
+// перестают читать новые. Числа, уже попавшие в каналы, остаются в них.
+// Config.Timeout продолжает отсчитываться во время паузы, но дообработка
+// чисел после остановки генерации ждёт Resume; прервать её можно только
+// отменой контекста Run. Pause можно вызвать и до Run; повторный вызов
+// ничего не меняет.
+func (p *Pipeline) Pause() {
+	p.gate.pause()
+}
+
+// Resume снимает паузу, поставленную Pause.
+func (p *Pipeline) Resume() {
+	p.gate.resume()
+}
+
+// Paused сообщает, стоит ли конвейер на паузе.
+func (p *Pipeline) Paused() bool {
+	return p.gate.isPaused()
+}
+
+// Stop останавливает генерацию чисел так же, как истечение Config.Timeout:
+// числа, уже попавшие в каналы, дообрабатываются согласно Config.Drain, и
+// Run возвращает итоговую статистику. Stop можно вызывать из любой горутины
+// и несколько раз.
+func (p *Pipeline) Stop() {
+	p.StopCause(ErrStopped)
+}
+
+// StopCause работает как Stop, но Result.StopCause получает причину cause,
+// например полученный сигнал. Учитывается причина первого вызова Stop или
+// StopCause.
+func (p *Pipeline) StopCause(cause error) {
+	p.stopOnce.Do(func() {
+		p.stopCause = cause
+		close(p.stop)
+	})
+}
+
+// errNotRunning возвращается AddWorkers и RemoveWorkers, если конвейер не
+// работает.
+var errNotRunning = errors.New("конвейер не запущен")
+
+// AddWorkers запускает ещё n обработчиков во время работы Run, но не больше
+// Config.MaxWorkers, и возвращает получившееся количество обработчиков.
+// Новые обработчики сразу начинают читать из chIn, а их результаты попадают
+// в общую сборку. Ячейка обработчика, завершённого RemoveWorkers, занята,
+// пока он не отправит текущее число, поэтому сразу после RemoveWorkers
+// обработчиков может добавиться меньше n.
+func (p *Pipeline) AddWorkers(n int) (int, error) {
+	return p.resizeWorkers(n)
+}
+
+// RemoveWorkers завершает n обработчиков во время работы Run, оставляя хотя
+// бы один, и возвращает получившееся количество обработчиков. Завершаемый
+// обработчик перестаёт читать из chIn, но дообрабатывает и отправляет
+// текущее число, поэтому числа не теряются.
+func (p *Pipeline) RemoveWorkers(n int) (int, error) {
+	return p.resizeWorkers(-n)
+}
+
+// resizeWorkers меняет количество обработчиков на delta.
+func (p *Pipeline) resizeWorkers(delta int) (int, error) {
+	pool := p.pool.Load()
+	if pool == nil {
+		return 0, errNotRunning
+	}
+	n, ok := pool.adjust(delta, ScaleEvent{Manual: true})
+	if !ok {
+		return n, errNotRunning
+	}
+	return n, nil
+}
+
+// Stats возвращает текущую статистику конвейера: во время работы Run — живые
+// значения счётчиков, после — итоговые. До первого запуска возвращается
+// пустой Snapshot.
+func (p *Pipeline) Stats() Snapshot {
+	stats := p.stats.Load()
+	if stats == nil {
+		return Snapshot{}
+	}
+	return stats.Snapshot()
+}
+
+// Result — итоговая статистика работы конвейера.
+type Result struct {
+	Snapshot
+
+	// Latency — задержка от генерации числа до его прихода в приёмник:
+	// ожидание в каналах, обработка и пауза обработчика
+	Latency LatencySummary
+	// PriorityLatency — задержка чисел каждого класса приоритета; nil, если
+	// Config.Priority не задан
+	PriorityLatency []LatencySummary
+	// Sample — выборка Config.Reservoir; nil, если он не задан
+	Sample []int64
+	// Sequence — потерянные и продублированные числа; nil, если
+	// Config.VerifySequence не задан
+	Sequence *SequenceReport
+	// Order — итоги проверки порядка чисел; nil, если не заданы ни
+	// Config.CheckMonotonic, ни Config.DetectGaps, или проверка не
+	// завершилась за LeakTimeout
+	Order *OrderReport
+	// Trend — итоги скользящего среднего; nil, если Config.Trend выключено
+	// или расчёт не завершился за LeakTimeout
+	Trend *TrendReport
+	// Acks — результат подтверждения доставки; nil, если Config.Ack не
+	// задан
+	Acks *AckReport
+	// Scaling — изменения количества обработчиков во время работы
+	Scaling []ScaleEvent
+	// BufferResizes — изменения ёмкости очереди при Config.AdaptiveBuffer
+	BufferResizes []BufferEvent
+	// DeadLetters — числа, обработка которых окончательно не удалась, в
+	// порядке поступления; пусто, если Config.DeadLetters не задан
+	DeadLetters []DeadLetter[int64]
+	// Checkpoint — состояние генерации, сохранённое при остановке; nil,
+	// если Config.Checkpoint не задан
+	Checkpoint *Checkpoint
+	// Files — файлы, записанные приёмником Config.Sink, если он сообщает о
+	// них, как RotatingFileSink
+	Files []string
+	// Reduced — итоги свёрток Config.Reducers в том же порядке; итог
+	// свёртки, не завершившейся за LeakTimeout, — nil
+	Reduced []any
+	// Warmup — итоги прогрева; nil, если Config.Warmup не задан
+	Warmup *WarmupReport
+
+	Drain DrainPolicy // применённая политика дообработки
+	// Transformed — числа обрабатывались Config.Process, поэтому сумма
+	// результирующего канала может не совпадать с суммой сгенерированных
+	Transformed bool
+
+	Duration time.Duration // время работы конвейера
+	// StopCause — причина остановки генерации: ErrTimeout, ErrStopped или
+	// причина StopCause, ErrLimitReached, ErrSourceExhausted, ошибка
+	// конвейера или причина отмены контекста Run
+	StopCause error
+}
+
+// measured возвращает время измерения после прогрева.
+func (r Result) measured() time.Duration {
+	if r.Warmup == nil {
+		return r.Duration
+	}
+	return r.Duration - r.Warmup.Duration
+}
+
+// Throughput возвращает количество чисел результирующего канала в секунду;
+// с Config.Warmup — после прогрева.
+func (r Result) Throughput() float64 {
+	d, n := r.measured(), r.OutputCount
+	if d <= 0 {
+		return 0
+	}
+	if r.Warmup != nil {
+		n -= r.Warmup.Output
+	}
+	return float64(n) / d.Seconds()
+}
+
+// WorkerThroughput возвращает количество чисел в секунду, прошедших через
+// каждый канал outs[i]; с Config.Warmup — после прогрева.
+func (r Result) WorkerThroughput() []float64 {
+	rates := make([]float64, len(r.PerWorker))
+	d := r.measured()
+	if d <= 0 {
+		return rates
+	}
+	for i, n := range r.PerWorker {
+		if r.Warmup != nil && i < len(r.Warmup.PerWorker) {
+			n -= r.Warmup.PerWorker[i]
+		}
+		rates[i] = float64(n) / d.Seconds()
+	}
+	return rates
+}
+
+// Run создаёт конвейер с настройками cfg и запускает его.
+func Run(ctx context.Context, cfg Config) (Result, error) {
+	return New(cfg).Run(ctx)
+}
+
+// RunInto создаёт конвейер с настройками cfg и запускает его методом
+// RunInto.
+func RunInto(ctx context.Context, cfg Config, out chan<- int64) (Result, error) {
+	return New(cfg).RunInto(ctx, out)
+}
+
+// Run запускает конвейер и ждёт завершения всех его горутин. Генерация
+// чисел прекращается, когда исчерпан источник, после Config.Limit чисел,
+// на первом числе больше Config.MaxValue, по истечении Config.Timeout,
+// после вызова Stop или при отмене контекста ctx. После остановки генерации числа, уже попавшие в каналы,
+// дообрабатываются согласно Config.Drain; отмена ctx прерывает и
+// обработчики. Необработанные числа учитываются в Result как отброшенные.
+// Если этап конвейера завершился с ошибкой или паникой, Run останавливает
+// весь конвейер и возвращает первую ошибку — *GeneratorError, *WorkerError
+// или *SinkError, которая раскрывается через errors.Is и errors.As в
+// причину, — и статистику на момент остановки. Ошибка возвращается и при
+// некорректных настройках.
+func (p *Pipeline) Run(ctx context.Context) (Result, error) {
+	return p.run(ctx, nil)
+}
+
+// RunInto работает как Run и дополнительно передаёт числа результирующего
+// канала в канал out вызывающего — те же и в том же порядке, что и
+// Collect. Отправка ждёт читателя, поэтому медленный читатель задерживает
+// конвейер; после отмены ctx числа в out больше не передаются. out не
+// закрывается: им владеет вызывающий, а после возврата RunInto новых чисел
+// в нём не появится.
+func (p *Pipeline) RunInto(ctx context.Context, out chan<- int64) (Result, error) {
+	if out == nil {
+		return Result{}, errors.New("канал результатов RunInto не задан")
+	}
+	return p.run(ctx, out)
+}
+
+// run выполняет запуск конвейера; into, если не nil, получает числа
+// результирующего канала.
+func (p *Pipeline) run(ctx context.Context, into chan<- int64) (Result, error) {
+	cfg := p.cfg
+	if err := cfg.Validate(); err != nil {
+		return Result{}, err
+	}
+	numWorkers := cfg.NumWorkers
+
+	// genCtx останавливает только генерацию — по истечении Config.Timeout,
+	// по Stop, при отмене ctx или ошибке этапа: числа, уже попавшие в
+	// каналы, дообрабатываются согласно Config.Drain. workCtx останавливает
+	// обработчики; он отменяется вместе с ctx или по политике дообработки.
+	clock := cfg.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	start := clock.Now()
+	// причина отмены genCtx — причина остановки генерации
+	genCtx, stopGen := context.WithCancelCause(ctx)
+	defer stopGen(nil)
+	workCtx, stopWork := context.WithCancelCause(ctx)
+	defer stopWork(nil)
+
+	// g — горутины конвейера; ошибка любой из них останавливает весь
+	// конвейер, а первая возвращается из Run. Обработчиков при
+	// масштабировании может смениться сколько угодно, поэтому остальные
+	// ошибки только записываются в журнал.
+	g := newGroup(func(err error) {
+		logger.Error("ошибка этапа", "err", err)
+		stopGen(err)
+		stopWork(err)
+	})
+	fail := g.fail
+	g.Go("таймаут", func() error {
+		// таймаут отсчитывается по часам clock
+		var timeout <-chan time.Time
+		if cfg.Timeout > 0 {
+			timeout = clock.After(cfg.Warmup + cfg.Timeout)
+		}
+		select {
+		case <-p.stop:
+			stopGen(p.stopCause)
+		case <-timeout:
+			stopGen(ErrTimeout)
+		case <-genCtx.Done():
+		}
+		return nil
+	})
+
+	sources := cfg.Sources
+	if len(sources) == 0 {
+		src := cfg.Source
+		if src == nil {
+			src = Sequential()
+		}
+		sources = []Source[int64]{src}
+	}
+	// ackers — источники, которым сообщается судьба каждого числа
+	ackers := make([]Acker, len(sources))
+	for i, src := range sources {
+		ackers[i], _ = src.(Acker)
+	}
+	ack := func(e Event, processed bool) {
+		if a := ackers[e.Source]; a != nil {
+			a.Ack(e.pos, processed)
+		}
+	}
+	if len(cfg.Sources) == 0 {
+		// при продолжении пропускаем уже сгенерированные числа; Limit
+		// ограничивает количество чисел с начала первого запуска
+		sources[0] = resumeSource(sources[0], cfg.Resume.Generated, cfg.Limit)
+	}
+	limit := cfg.Limit
+	if limit > 0 {
+		limit -= cfg.Resume.Generated
+	}
+	// seq нумерует числа всех источников; числа, полученные из источника,
+	// но не отправленные при остановке, собираются в unsent, чтобы учесть
+	// их номера
+	var (
+		seq      atomic.Int64
+		unsentMu sync.Mutex
+		unsent   []Event
+	)
+	var acks *AckTracker
+	if cfg.Ack {
+		acks = NewAckTracker()
+	}
+	var cp *checkpointer
+	if cfg.Checkpoint.enabled() {
+		cp = newCheckpointer(cfg.Checkpoint, cfg.Resume, clock)
+	}
+
+	p.channels = nil
+	// числа передаются между этапами вместе со временем их генерации,
+	// чтобы измерить задержку до приёмника
+	chIn := make(chan Event, cfg.BufferSize)
+	// genOut — канал, в который пишет генератор: chIn или вход управляемой
+	// очереди при AdaptiveBuffer или Spill
+	genOut := chIn
+	adaptive := cfg.AdaptiveBuffer.withDefaults(cfg.BufferSize)
+	var ring *ringQueue[Event]
+	if adaptive.enabled() {
+		genOut, chIn = make(chan Event), make(chan Event)
+		ring = newRingQueue[Event](adaptive.MinSize)
+	}
+	if cfg.Spill != nil {
+		genOut = make(chan Event)
+	}
+
+	// для проверки считаем количество и сумму чисел на каждом этапе, с
+	// ячейкой на каждого обработчика, который может быть запущен
+	capacity := cfg.workerCapacity()
+	stats := NewStats(capacity)
+	if len(sources) > 1 {
+		stats = newSourceStats(capacity, len(sources))
+	}
+	if cfg.BigSums {
+		stats.EnableBigSums()
+	}
+	if cfg.Priority.enabled() {
+		stats.enablePriorities(len(cfg.Priority.Weights))
+	}
+	p.stats.Store(stats)
+	warm := startWarmup(g, cfg.Warmup, clock, stats, genCtx.Done())
+	state := newRunState(clock, g, capacity)
+	p.state.Store(state)
+	defer state.finish()
+
+	tr := newTracing(cfg.Tracer, clock)
+	var seqs *sequenceVerifier
+	if cfg.VerifySequence {
+		seqs = &sequenceVerifier{}
+	}
+
+	genOpts := []GeneratorOption[Event]{WithReadiness[Event](cfg.Ready), WithRateLimit[Event](p.gate), WithOnUnsent(func(e Event) {
+		unsentMu.Lock()
+		unsent = append(unsent, e)
+		unsentMu.Unlock()
+	})}
+	if cfg.Rate > 0 {
+		genOpts = append(genOpts, WithRateLimit[Event](NewTokenBucket(cfg.Rate, cfg.Burst, clock)))
+	}
+
+	// genDone закрывается, когда генерация остановлена
+	genDone := make(chan struct{})
+	// blocked — суммарное время ожидания отправки чисел в chIn для
+	// масштабирования
+	var blocked atomic.Int64
+	logger.Info("конвейер запущен",
+		"workers", numWorkers,
+		"buffer", cfg.BufferSize,
+		"timeout", cfg.Timeout,
+		"warmup", cfg.Warmup,
+		"limit", cfg.Limit,
+		"drain", cfg.Drain.String(),
+		"resumed", cfg.Resume.Generated,
+	)
+	stamped := make([]Source[Event], len(sources))
+	for i, src := range limitSources(sources, limit) {
+		stamped[i] = cfg.Priority.source(sources[i], stamp(src, i, &seq, clock, cfg.MaxValue, tr, func(e Event) { ack(e, false) }))
+	}
+	// генерируем числа, считая параллельно их количество и сумму
+	hooks := []GeneratorHook[Event]{
+		GeneratorHookFuncs[Event]{After: func(e Event) {
+			if len(sources) > 1 {
+				stats.recordSourceIn(e.Source, e.Value)
+			} else {
+				stats.RecordIn(e.Value)
+			}
+			stats.recordPriorityIn(e.Priority)
+			state.last.Store(e.Value)
+		}},
+		// время от получения числа до его отправки — ожидание свободного
+		// обработчика
+		GeneratorHookFuncs[Event]{After: func(e Event) {
+			d := clock.Now().Sub(e.Born)
+			stats.RecordGeneratorBlock(d)
+			if cfg.Autoscale.enabled() {
+				blocked.Add(int64(d))
+			}
+		}},
+	}
+	if cp != nil {
+		hooks = append(hooks, GeneratorHookFuncs[Event]{After: func(e Event) { cp.record(e.Value) }})
+	}
+	if acks != nil {
+		hooks = append(hooks, GeneratorHookFuncs[Event]{After: func(e Event) { acks.Issue(e.Seq) }})
+	}
+	if cfg.Record != nil {
+		hooks = append(hooks, GeneratorHookFuncs[Event]{After: func(e Event) { cfg.Record.RecordValue(e.Seq, e.Value) }})
+	}
+	if cfg.Metrics != nil {
+		hooks = append(hooks, GeneratorHookFuncs[Event]{After: func(Event) { cfg.Metrics.generated.Inc() }})
+	}
+	genOpts = append(genOpts, WithHooks(append(hooks, cfg.GeneratorHooks...)...))
+	g.Go("генератор", func() error {
+		defer close(genDone)
+		err := protect(func() error {
+			MergeSources(genCtx, genOut, stamped, genOpts...)
+			return nil
+		})
+		if err == nil && genCtx.Err() == nil {
+			stopGen(generationEnd(cfg.Resume.Generated+stats.Snapshot().InputCount, cfg.Limit))
+		}
+		logger.Info("генерация остановлена", "generated", stats.Snapshot().InputCount, "cause", context.Cause(genCtx))
+		if err != nil {
+			return &GeneratorError{Err: err}
+		}
+		return nil
+	})
+
+	// после остановки генерации применяем политику дообработки
+	if after, stop := cfg.Drain.stopAfter(); stop {
+		g.Go("дообработка", func() error {
+			select {
+			case <-workCtx.Done():
+				return nil
+			case <-genDone:
+			}
+			if Sleep(workCtx, clock, after) == nil {
+				stopWork(errors.New("истекло время дообработки"))
+			}
+			return nil
+		})
+	}
+
+	process := cfg.Process
+	if process == nil {
+		delay := cfg.WorkerDelayFunc
+		if delay == nil {
+			delay = func() time.Duration { return cfg.WorkerDelay }
+		}
+		process = DelayFunc[int64](clock, delay)
+	}
+	if cfg.Chaos.enabled() {
+		process = Chaos[int64](cfg.Chaos, clock)(process)
+	}
+	process = Wrap(process, cfg.Middleware...)
+	// deliver передаёт число результирующего канала в Reservoir, Collect,
+	// Reducers, Sink и into, кроме подавленных повторов; после ошибки Collect или
+	// Sink числа в них больше не передаются. При Ordered числа проходят
+	// через буфер, восстанавливающий порядок генерации, и deliver
+	// вызывается под его мьютексом
+	collect := cfg.Collect
+	sink := cfg.sinkWriter(ctx, cfg.Sink, clock, fail, func(d time.Duration) {
+		stats.recordSinkBlock(0, d)
+	})
+	// свёртки, проверка порядка и скользящее среднее прерываются отменой
+	// ctx, даже если LeakTimeout не задан
+	reducers := startReducers(g, cfg.Reducers, fail, ctx.Done())
+	order := startOrderCheck(g, cfg.CheckMonotonic, cfg.DetectGaps, ctx.Done())
+	trend := startTrend(g, cfg.Trend, ctx.Done())
+	var dedup deduper
+	if cfg.Dedup.enabled() {
+		dedup = cfg.Dedup.newDeduper()
+	}
+	deliver := func(e Event) {
+		v := e.Value
+		// повтор уже учтён в результирующем канале, но дальше не передаётся
+		if dedup != nil && dedup.seen(v) {
+			stats.recordDuplicate()
+			return
+		}
+		if cfg.Reservoir != nil {
+			cfg.Reservoir.Add(v)
+		}
+		if collect != nil {
+			if err := protect(func() error { return collect(v) }); err != nil {
+				fail(&SinkError{Err: err})
+				collect = nil
+			}
+		}
+		reducers.add(v)
+		order.add(v)
+		trend.add(v)
+		sink.write(v)
+		if into != nil {
+			select {
+			case into <- v:
+			case <-ctx.Done():
+			}
+		}
+	}
+	var reorder *reorderBuffer
+	if cfg.Ordered {
+		reorder = newReorderBuffer(cfg.ReorderWindow, cfg.ReorderOverflow, deliver, func(err error) {
+			fail(&SinkError{Err: err})
+		})
+	}
+	// workerProcess возвращает обработку чисел обработчиком i, замедленную
+	// по WorkerDelayFactors: число обрабатывается с сохранением времени его
+	// генерации, и отмечается время окончания обработки, чтобы измерить
+	// ожидание отправки результата; при Ordered результат ждёт своей
+	// очереди
+	workerProcess := func(i int) func(context.Context, Event) (Event, error) {
+		process := ItemProcess(cfg.slowdown(i, clock, process))
+		eventProcess := func(ctx context.Context, e Event) (Event, error) {
+			e, err := process(ctx, e)
+			e.sent = clock.Now()
+			return e, err
+		}
+		if reorder != nil {
+			return reorder.gate(eventProcess)
+		}
+		return eventProcess
+	}
+
+	// settle отмечает окончательный учёт числа e; processed — число
+	// обработано, а не отброшено
+	settle := func(e Event, processed bool) {
+		if seqs != nil {
+			seqs.mark(e.Seq)
+		}
+		if acks != nil {
+			acks.Ack(e.Seq)
+		}
+		ack(e, processed)
+	}
+	// числа, отброшенные и отфильтрованные обработчиком i, учитываются в
+	// его ячейках статистики
+	dropped := func(i int, e Event) {
+		stats.RecordDrop(i, e.Value)
+		settle(e, false)
+		tr.discarded(e, "dropped")
+		if reorder != nil {
+			reorder.discard(e.Seq)
+		}
+	}
+	skipped := func(i int, e Event) {
+		stats.RecordSkip(i, e.Value)
+		settle(e, true)
+		tr.discarded(e, "skipped")
+		if reorder != nil {
+			reorder.discard(e.Seq)
+		}
+	}
+	failed := func(e Event) {
+		stats.RecordFailed(e.Value)
+		settle(e, true)
+		tr.discarded(e, "failed")
+		if reorder != nil {
+			reorder.discard(e.Seq)
+		}
+	}
+
+	var (
+		resizesMu sync.Mutex
+		resizes   []BufferEvent // изменения ёмкости управляемой очереди
+	)
+	if cp != nil {
+		g.Go("сохранение состояния", func() error {
+			cp.run(workCtx, genDone, fail)
+			return nil
+		})
+	}
+	if cfg.Spill != nil {
+		g.Go("очередь на диске", func() error {
+			spillQueue(workCtx, cfg.Spill, genOut, chIn, func(e Event) { dropped(0, e) }, fail)
+			return nil
+		})
+	}
+	if ring != nil {
+		g.Go("очередь", func() error {
+			ring.run(workCtx, genOut, chIn, func(e Event) { dropped(0, e) })
+			return nil
+		})
+		g.Go("настройка буфера", func() error {
+			adaptive.tune(workCtx, clock, genDone, stats, ring, func(ev BufferEvent) {
+				logger.Info("ёмкость очереди", "at", ev.At, "size", ev.Size, "pressure", ev.Pressure)
+				resizesMu.Lock()
+				resizes = append(resizes, ev)
+				resizesMu.Unlock()
+			})
+			return nil
+		})
+	}
+
+	// dead — числа, обработка которых окончательно не удалась; они
+	// передаются в Config.DeadLetters и сохраняются в letters, а если
+	// приёмник их не принял — отбрасываются
+	var (
+		dead    chan DeadLetter[Event]
+		letters []DeadLetter[int64]
+	)
+	deadDone := make(chan struct{})
+	if cfg.DeadLetters != nil {
+		dead = make(chan DeadLetter[Event])
+		g.Go("необработанные числа", func() error {
+			defer close(deadDone)
+			for dl := range dead {
+				letter := DeadLetter[int64]{Value: dl.Value.Value, Err: dl.Err, Attempts: dl.Attempts}
+				err := protect(func() error { return cfg.DeadLetters.Put(workCtx, letter) })
+				if err != nil {
+					dropped(0, dl.Value)
+					if workCtx.Err() == nil {
+						fail(&SinkError{Err: fmt.Errorf("приёмник необработанных чисел: %w", err)})
+					}
+					continue
+				}
+				letters = append(letters, letter)
+				failed(dl.Value)
+			}
+			return nil
+		})
+	} else {
+		close(deadDone)
+	}
+
+	// queues — каналы, из которых читают обработчики; числа, которые
+	// раздача не успела отдать до остановки обработчиков, учитываются в
+	// ячейке обработчика 0
+	distributor := cfg.Distributor
+	switch {
+	case cfg.Priority.enabled():
+		distributor = PriorityLanes(cfg.Priority.Weights, func(e Event) int { return e.Priority })
+	case distributor == nil:
+		distributor = Shared[Event]()
+	}
+	queues := distributor.Distribute(workCtx, chIn, capacity, func(e Event) { dropped(0, e) })
+
+	// chOut — канал, в который собираются числа из каналов обработчиков;
+	// каналы добавляются в сборку по мере запуска обработчиков
+	var processed []prometheus.Counter
+	if cfg.Metrics != nil {
+		processed = cfg.Metrics.workers(capacity)
+	}
+	merge := newMerger(func(i int, e Event) {
+		stats.RecordOut(i, e.Value)
+		stats.recordPriorityOut(e.Priority)
+		stats.RecordWorkerBlock(i, clock.Now().Sub(e.sent))
+		settle(e, true)
+		if processed != nil {
+			processed[i].Inc()
+		}
+	}, func(err error) {
+		fail(fmt.Errorf("сборка результатов: %w", err))
+	}, bufferSize(cfg.ResultBufferSize, capacity))
+	chOut := merge.out
+
+	// watch — наблюдение за зависшими обработчиками
+	var watch *watchdog
+	if cfg.Watchdog.enabled() {
+		watch = newWatchdog(cfg.Watchdog, capacity, clock, stats, logger)
+		g.Go("наблюдение за обработчиками", func() error {
+			watch.run(workCtx)
+			return nil
+		})
+	}
+
+	// outs — каналы последних обработчиков каждой ячейки, куда
+	// записываются числа из queues[i]
+	var outsMu sync.Mutex
+	outs := make([]chan Event, capacity)
+	state.setDepths(func() QueueDepths {
+		d := QueueDepths{Input: len(chIn), Result: len(chOut), Workers: make([]int, len(queues)), Outputs: make([]int, capacity)}
+		for i, q := range queues {
+			d.Workers[i] = len(q)
+		}
+		outsMu.Lock()
+		for i, out := range outs {
+			d.Outputs[i] = len(out)
+		}
+		outsMu.Unlock()
+		return d
+	})
+	// пул запускает горутину Worker со своим каналом для каждой ячейки;
+	// ячейка освобождается, когда все числа её обработчика собраны и его
+	// горутина вышла: Worker закрывает out раньше, чем горутина сбросит
+	// приёмник и вернётся, а новый обработчик той же ячейки не должен
+	// работать одновременно с ней
+	var pool *workerPool
+	pool = newWorkerPool(capacity, clock, start, func(i int, quit <-chan struct{}) bool {
+		out := make(chan Event, bufferSize(cfg.OutBufferSize, cfg.BufferSize))
+		var pending atomic.Int32
+		pending.Store(2)
+		release := func() {
+			if pending.Add(-1) == 0 {
+				pool.release(i)
+			}
+		}
+		if !merge.add(i, out, release) {
+			return false
+		}
+		outsMu.Lock()
+		outs[i] = out
+		outsMu.Unlock()
+		process := tr.process(i, workerProcess(i))
+		if watch != nil {
+			process = watch.process(i, process)
+		}
+		if cfg.Record != nil {
+			process = recordWorker(cfg.Record, i, process)
+		}
+		var workerSink *sinkWriter
+		if cfg.WorkerSinks != nil {
+			workerSink = cfg.sinkWriter(workCtx, cfg.WorkerSinks(i), clock, fail, func(d time.Duration) {
+				stats.recordSinkBlock(i, d)
+			})
+			process = workerSink.process(process)
+		}
+		opts := []WorkerOption[Event]{
+			WithProcess(process),
+			WithOnDrop(func(e Event) { dropped(i, e) }),
+			WithOnSkip(func(e Event) { skipped(i, e) }),
+			WithQuit[Event](quit),
+			WithLimiter[Event](p.gate),
+			WithRetry[Event](cfg.Retry),
+			WithClock[Event](clock),
+			WithItemTimeout[Event](cfg.ItemTimeout),
+			WithOnRetry[Event](func(attempt int, err error) {
+				stats.RecordRetry(i)
+				logger.Debug("повтор обработки", "worker", i, "attempt", attempt, "err", err)
+			}),
+			WithOnItem[Event](func(t WorkerTiming) {
+				stats.RecordWorkerItem(i, t)
+			}),
+			WithOnState[Event](func(s WorkerState) {
+				state.setWorker(i, s)
+			}),
+		}
+		if dead != nil {
+			opts = append(opts, WithDeadLetter[Event](dead))
+		}
+		g.Go(fmt.Sprintf("обработчик %d", i), func() error {
+			defer release()
+			err := protect(func() error {
+				return Worker(workCtx, queues[i], out, opts...)
+			})
+			if workerSink != nil {
+				workerSink.flush()
+			}
+			if err != nil {
+				// конвейер останавливается сразу, до того как обработчик
+				// дочитает свой канал
+				fail(&WorkerError{Index: i, Err: err})
+			}
+			select {
+			case <-quit:
+				// обработчик завершён пулом: числа общего канала читают
+				// остальные
+				return nil
+			default:
+			}
+			// обработчик завершился сам: числа кончились или работа
+			// прервана, новых обработчиков не будет
+			pool.finish()
+			merge.seal()
+			// обработчик, остановленный ошибкой или политикой дообработки,
+			// дочитывает свой канал, чтобы генератор и раздача не
+			// заблокировались на отправке до своей остановки; прочитанные
+			// числа отброшены
+			for e := range queues[i] {
+				dropped(i, e)
+			}
+			return nil
+		})
+		return true
+	})
+	pool.adjust(numWorkers, ScaleEvent{})
+	p.pool.Store(pool)
+	if cfg.Autoscale.enabled() {
+		g.Go("масштабирование", func() error {
+			cfg.Autoscale.autoscale(workCtx, clock, genDone, &blocked, pool)
+			return nil
+		})
+	}
+
+	// sinkIn — канал, из которого читает приёмник: chOut или очередь
+	// Spillover за ним
+	sinkIn := chOut
+	var chSpill chan Event
+	if cfg.SpillThreshold > 0 {
+		chSpill = make(chan Event)
+		sinkIn = chSpill
+		g.Go("вытеснение результатов", func() error {
+			err := protect(func() error {
+				return Spillover(chOut, chSpill, cfg.SpillThreshold, cfg.SpillDir)
+			})
+			if err != nil {
+				fail(&SinkError{Err: err})
+			}
+			// после ошибки дочитываем chOut, чтобы не заблокировать сборку
+			for range chOut {
+			}
+			return nil
+		})
+	}
+
+	// читаем числа из результирующего канала, учтённые при сборке
+	if cfg.Metrics != nil {
+		g.Go("метрики очередей", func() error {
+			cfg.Metrics.sampleQueues(workCtx, clock, chIn, chOut)
+			return nil
+		})
+	}
+	// leaked закрывается, если после остановки обработки горутины
+	// конвейера не завершились за LeakTimeout; тогда Run больше их не ждёт
+	finished := make(chan struct{})
+	defer close(finished)
+	leaked := cfg.leakDeadline(workCtx, clock, finished)
+	latency := NewHistogram()
+	// задержка по классам приоритета; nil, если приоритеты выключены
+	var priorityLatency []*Histogram
+	for range cfg.Priority.Weights {
+		priorityLatency = append(priorityLatency, NewHistogram())
+	}
+	for {
+		e, ok := recvUntil(sinkIn, leaked)
+		if !ok {
+			break
+		}
+		d := clock.Now().Sub(e.Born)
+		if warm.measured(e.Born) {
+			latency.Record(d)
+			if priorityLatency != nil {
+				priorityLatency[e.Priority].Record(d)
+			}
+		}
+		tr.collected(e)
+		if cfg.Metrics != nil {
+			cfg.Metrics.latency.Observe(d.Seconds())
+		}
+		if reorder != nil {
+			reorder.push(e)
+		} else {
+			deliver(e)
+		}
+	}
+	// числа, не отправленные генератором, получили номера, но не
+	// сгенерированы: отмечаем их номера учтёнными. Генерация к этому
+	// времени закончилась, если не истёк LeakTimeout: chIn закрывается
+	// после неё
+	waitUntil(genDone, leaked)
+	unsentMu.Lock()
+	for _, e := range unsent {
+		tr.discarded(e, "unsent")
+		ack(e, false)
+		if seqs != nil {
+			seqs.mark(e.Seq)
+		}
+		if reorder != nil {
+			reorder.discard(e.Seq)
+		}
+	}
+	unsentMu.Unlock()
+	// новых чисел больше не будет: выдаём те, что ждут недостающих
+	if reorder != nil {
+		reorder.flush()
+	}
+	sink.flush()
+	reduced := reducers.close(leaked)
+	ordered := order.close(leaked)
+	trended := trend.close(leaked)
+	elapsed := clock.Now().Sub(start)
+
+	// обработчики завершились и больше не отправят неудачные числа;
+	// дожидаемся, пока приёмник примет уже отправленные
+	if dead != nil {
+		close(dead)
+	}
+	waitUntil(deadDone, leaked)
+	// генерация и обработка закончены: останавливаем вспомогательные
+	// горутины и дожидаемся всех горутин конвейера. Обработчик,
+	// остановленный ошибкой, мог закрыть свой канал раньше, чем генератор —
+	// chIn. Первая ошибка любой горутины, если она была, возвращается из Run
+	cause := context.Cause(genCtx)
+	stopGen(nil)
+	stopWork(nil)
+	err := g.WaitUntil(leaked)
+	p.channels = append(p.channels, probeChannel("chIn", chIn))
+	outsMu.Lock()
+	for i, c := range outs {
+		if c != nil {
+			p.channels = append(p.channels, probeChannel(fmt.Sprintf("outs[%d]", i), c))
+		}
+	}
+	outsMu.Unlock()
+	p.channels = append(p.channels, probeChannel("chOut", chOut))
+	if chSpill != nil {
+		p.channels = append(p.channels, probeChannel("chSpill", chSpill))
+	}
+
+	// сохраняем итоговое состояние: генерация остановлена, а периодическое
+	// сохранение завершилось вместе с этапами
+	var saved *Checkpoint
+	if cp != nil {
+		state, saveErr := cp.save()
+		if saveErr != nil {
+			saveErr = fmt.Errorf("сохранение состояния: %w", saveErr)
+			logger.Error("ошибка этапа", "err", saveErr)
+			if err == nil {
+				err = saveErr
+			}
+		} else {
+			saved = &state
+			logger.Info("состояние сохранено", "path", cfg.Checkpoint.Path, "generated", state.Generated, "last", state.Last)
+		}
+	}
+
+	var sample []int64
+	if cfg.Reservoir != nil {
+		sample = cfg.Reservoir.Sample()
+	}
+	snap := stats.Snapshot()
+	res := Result{
+		Snapshot:    snap,
+		Latency:     latency.Summary(),
+		Sample:      sample,
+		Drain:       cfg.Drain,
+		Transformed: cfg.Process != nil,
+		Duration:    elapsed,
+		StopCause:   cause,
+		Checkpoint:  saved,
+		DeadLetters: letters,
+		Files:       sink.files(),
+		Reduced:     reduced,
+		Order:       ordered,
+		Trend:       trended,
+		Warmup:      warm.result(snap, start, elapsed),
+	}
+	if res.Warmup != nil && !res.Warmup.Complete {
+		logger.Warn("генерация остановлена до окончания прогрева", "warmup", cfg.Warmup)
+	}
+	for _, h := range priorityLatency {
+		res.PriorityLatency = append(res.PriorityLatency, h.Summary())
+	}
+	if seqs != nil {
+		res.Sequence = seqs.report(seq.Load())
+	}
+	if acks != nil {
+		res.Acks = acks.Report()
+	}
+	res.Scaling = pool.scaling()
+	resizesMu.Lock()
+	res.BufferResizes = resizes
+	resizesMu.Unlock()
+	for _, ev := range res.Scaling {
+		logger.Info("масштабирование", "at", ev.At, "workers", ev.Workers, "pressure", ev.Pressure, "manual", ev.Manual)
+	}
+	rates, util := res.WorkerThroughput(), res.WorkerUtilization()
+	for i, n := range res.PerWorker {
+		logger.Info("итоги обработчика", "worker", i, "count", n, "rate", rates[i],
+			"items", res.WorkerItems[i],
+			"errors", res.WorkerErrors[i],
+			"busy", res.WorkerBusy[i],
+			"idle", res.WorkerIdle[i],
+			"sending", res.WorkerSending[i],
+			"utilization", util[i],
+		)
+	}
+	logger.Info("конвейер остановлен",
+		slog.Group("input", "count", res.InputCount, "sum", res.InputSum),
+		slog.Group("output", "count", res.OutputCount, "sum", res.OutputSum),
+		slog.Group("dropped", "count", res.DroppedCount, "sum", res.DroppedSum),
+		slog.Group("skipped", "count", res.SkippedCount, "sum", res.SkippedSum),
+		slog.Group("failed", "count", res.FailedCount, "sum", res.FailedSum),
+		"duration", res.Duration,
+		"cause", res.StopCause,
+	)
+	if res.Duplicates > 0 {
+		logger.Info("повторы подавлены", "duplicates", res.Duplicates, "window", cfg.Dedup.Window, "bloom", cfg.Dedup.Bloom)
+	}
+	if o := res.Order; o != nil && o.Violations+o.Gaps > 0 {
+		logger.Warn("нарушен порядок чисел", "violations", o.Violations, "gaps", o.Gaps)
+	}
+	if res.SumOverflow {
+		logBigSums(logger, res.Snapshot)
+	}
+	return res, err
+}
+
+// logBigSums предупреждает, что суммы snap переполнили int64, и записывает
+// точные суммы, если они собирались.
+func logBigSums(logger *slog.Logger, snap Snapshot) {
+	if snap.BigSums == nil {
+		logger.Warn("суммы чисел переполнили int64, в журнале они по модулю 2^64")
+		return
+	}
+	logger.Warn("суммы чисел переполнили int64, точные суммы",
+		"input", snap.BigSums.Input.String(),
+		"output", snap.BigSums.Output.String(),
+		"dropped", snap.BigSums.Dropped.String(),
+		"skipped", snap.BigSums.Skipped.String(),
+		"failed", snap.BigSums.Failed.String(),
+	)
+}
+
+// Verify проверяет итоговую статистику так же, как Snapshot.Verify. Если
+// числа преобразовывались (Transformed), суммы не сравниваются. Если
+// проверялись номера чисел, Verify сообщает о потерянных и продублированных,
+// а если подтверждалась доставка — о неподтверждённых.
+func (r Result) Verify() error {
+	if err := r.Snapshot.verify(!r.Transformed); err != nil {
+		return err
+	}
+	if err := r.Sequence.Err(); err != nil {
+		return err
+	}
+	return r.Acks.Err()
+}
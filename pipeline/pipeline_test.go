@@ -0,0 +1,920 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"math/big"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	stats, err := Run(context.Background(), Config{NumWorkers: 3, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := stats.Verify(); err != nil {
+		t.Error(err)
+	}
+	if stats.OutputCount == 0 || len(stats.PerWorker) != 3 {
+		t.Errorf("чисел %d, каналов %d; want больше 0 и 3", stats.OutputCount, len(stats.PerWorker))
+	}
+}
+
+// TestRunLogger проверяет, что итоги запуска записываются в Config.Logger.
+func TestRunLogger(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config{NumWorkers: 2, Limit: 10, Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+	if _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	var last struct {
+		Output struct{ Count, Sum int64 }
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec struct{ Msg string }
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("запись %q: %v", line, err)
+		}
+		msgs = append(msgs, rec.Msg)
+		if rec.Msg == "конвейер остановлен" {
+			if err := json.Unmarshal([]byte(line), &last); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := []string{"конвейер запущен", "генерация остановлена", "итоги обработчика", "итоги обработчика", "конвейер остановлен"}
+	if !slices.Equal(msgs, want) {
+		t.Errorf("записи %q, want %q", msgs, want)
+	}
+	if last.Output.Count != 10 || last.Output.Sum != 55 {
+		t.Errorf("output = %+v, want count 10 и sum 55", last.Output)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"корректные", Config{NumWorkers: 1, Timeout: time.Second, BufferSize: 8}, ""},
+		{"без обработчиков", Config{}, "количество обработчиков"},
+		{"отрицательный таймаут", Config{NumWorkers: 1, Timeout: -time.Second}, "таймаут"},
+		{"отрицательный прогрев", Config{NumWorkers: 1, Warmup: -time.Second}, "время прогрева"},
+		{"отрицательный буфер", Config{NumWorkers: 1, BufferSize: -1}, "размер буфера"},
+		{"отрицательная пауза", Config{NumWorkers: 1, WorkerDelay: -time.Millisecond}, "пауза обработчика"},
+		{"отрицательное количество чисел", Config{NumWorkers: 1, Limit: -1}, "количество чисел"},
+		{"отрицательная частота", Config{NumWorkers: 1, Rate: -1}, "частота генерации"},
+		{"отрицательный всплеск", Config{NumWorkers: 1, Rate: 10, Burst: -1}, "размер всплеска"},
+		{"отрицательное окно порядка", Config{NumWorkers: 1, Ordered: true, ReorderWindow: -1}, "окно восстановления порядка"},
+		{"окно порядка без Ordered", Config{NumWorkers: 1, ReorderWindow: 4}, "без Ordered"},
+		{"ожидание окна с раздачей", Config{NumWorkers: 2, Ordered: true, ReorderWindow: 4, Distributor: RoundRobin[Event]()}, "общим каналом"},
+		{"неизвестная политика окна", Config{NumWorkers: 1, Ordered: true, ReorderOverflow: 7}, "политика переполнения"},
+		{"масштабирование", Config{NumWorkers: 2, Autoscale: AutoscalePolicy{MinWorkers: 1, MaxWorkers: 4}}, ""},
+		{"обработчиков больше предела", Config{NumWorkers: 5, Autoscale: AutoscalePolicy{MaxWorkers: 4}}, "пределах масштабирования"},
+		{"обработчиков меньше предела", Config{NumWorkers: 1, Autoscale: AutoscalePolicy{MinWorkers: 2, MaxWorkers: 4}}, "пределах масштабирования"},
+		{"пороги масштабирования", Config{NumWorkers: 1, Autoscale: AutoscalePolicy{MaxWorkers: 4, ScaleUp: 0.2, ScaleDown: 0.3}}, "пороги масштабирования"},
+		{"предел меньше начального", Config{NumWorkers: 3, MaxWorkers: 2}, "меньше начального"},
+		{"добавление с раздачей", Config{NumWorkers: 1, MaxWorkers: 2, Distributor: LeastLoaded[Event]()}, "общим каналом"},
+		{"масштабирование с раздачей", Config{NumWorkers: 1, Autoscale: AutoscalePolicy{MaxWorkers: 4}, Distributor: RoundRobin[Event]()}, "общим каналом"},
+		{"отрицательное время обработки", Config{NumWorkers: 1, ItemTimeout: -time.Second}, "время обработки числа"},
+		{"отрицательное окно повторов", Config{NumWorkers: 1, Dedup: DedupPolicy{Window: -1}}, "окно подавления повторов"},
+		{"доля ложных повторов", Config{NumWorkers: 1, Dedup: DedupPolicy{Window: 10, Bloom: true, FalsePositive: 1}}, "доля ложных повторов"},
+		{"вес класса приоритета", Config{NumWorkers: 1, Priority: PriorityPolicy{Weights: []int{2, 0}}}, "вес класса приоритета 1"},
+		{"приоритеты с раздачей", Config{NumWorkers: 1, Priority: PriorityPolicy{Weights: []int{2, 1}}, Distributor: RoundRobin[Event]()}, "без Config.Distributor"},
+		{"приоритеты с ожиданием окна", Config{NumWorkers: 1, Ordered: true, ReorderWindow: 4, Priority: PriorityPolicy{Weights: []int{2, 1}}}, "несовместимо с приоритетами"},
+		{"отрицательное время ожидания горутин", Config{NumWorkers: 1, LeakTimeout: -time.Second}, "время ожидания горутин"},
+		{"отрицательное время зависания", Config{NumWorkers: 1, Watchdog: WatchdogPolicy{Stall: -time.Second}}, "время зависания"},
+		{"Source и Sources", Config{NumWorkers: 1, Source: Sequential(), Sources: []Source[int64]{Fibonacci()}}, "и в Sources"},
+		{"продолжение с несколькими источниками", Config{NumWorkers: 1, Sources: []Source[int64]{Sequential(), Fibonacci()}, Resume: Checkpoint{Generated: 5}}, "одним источником"},
+		{"отрицательные попытки", Config{NumWorkers: 1, Retry: RetryPolicy{Attempts: -1}}, "количество попыток"},
+		{"отклонение паузы повтора", Config{NumWorkers: 1, Retry: RetryPolicy{Attempts: 2, Jitter: 2}}, "доля отклонения"},
+		{"замедление меньше 1", Config{NumWorkers: 2, WorkerDelayFactors: []float64{1, 0.5}}, "замедление обработчика 1"},
+		{"вероятность хаоса", Config{NumWorkers: 1, Chaos: ChaosPolicy{ErrorProbability: 1.5}}, "вероятность хаоса"},
+		{"задержки хаоса без распределения", Config{NumWorkers: 1, Chaos: ChaosPolicy{DelayProbability: 0.5}}, "распределение Delay"},
+		{"отрицательный вес операции приёмника", Config{NumWorkers: 1, SinkLimit: SinkLimit{Semaphore: NewSemaphore(1), Weight: -1}}, "вес операции приёмника"},
+		{"приёмники обработчиков с Ordered", Config{NumWorkers: 1, Ordered: true, WorkerSinks: func(int) Sink { return &MemorySink{} }}, "WorkerSinks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantErr == "" {
+				return
+			}
+			if _, err := Run(context.Background(), tt.cfg); err == nil {
+				t.Error("Run с некорректными настройками не вернул ошибку")
+			}
+		})
+	}
+}
+
+// TestConfigValidateAll проверяет, что Validate сообщает обо всех
+// некорректных настройках сразу.
+func TestConfigValidateAll(t *testing.T) {
+	err := Config{Timeout: -time.Second, BufferSize: -1, Rate: -1}.Validate()
+	for _, want := range []string{"количество обработчиков", "таймаут", "размер буфера", "частота генерации"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want ошибку про %s", err, want)
+		}
+	}
+}
+
+// ints возвращает числа от a до b включительно.
+func ints(a, b int64) []int64 {
+	var vs []int64
+	for v := a; v <= b; v++ {
+		vs = append(vs, v)
+	}
+	return vs
+}
+
+// TestRunCancelManyWorkers проверяет, что при маленьком буфере chIn,
+// большом количестве обработчиков и почти немедленной отмене конвейер
+// завершается, а каналы всех обработчиков, в том числе не получивших ни
+// одного числа, закрыты. Отмена прерывает обработчики, а числа, не
+// дошедшие до приёмника, учитываются как отброшенные.
+func TestRunCancelManyWorkers(t *testing.T) {
+	tests := []struct {
+		name string
+		// cancelAfter — после скольких чисел результирующего канала
+		// отменяется контекст; 0 — до запуска
+		cancelAfter int64
+	}{
+		{"отмена до запуска", 0},
+		{"отмена после первого числа", 1},
+		{"отмена после трёх чисел", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var seen int64
+			p := New(Config{NumWorkers: 10, BufferSize: 1, Collect: func(int64) error {
+				if seen++; seen == tt.cancelAfter {
+					cancel()
+				}
+				return nil
+			}})
+			if tt.cancelAfter == 0 {
+				cancel()
+			}
+
+			done := make(chan struct{})
+			var stats Result
+			var err error
+			go func() {
+				defer close(done)
+				stats, err = p.Run(ctx)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("конвейер не завершился после отмены")
+			}
+			if err != nil {
+				t.Fatalf("Run = %v", err)
+			}
+			if err := stats.Verify(); err != nil {
+				t.Error(err)
+			}
+			for _, ch := range p.Channels() {
+				if !ch.Closed || ch.Len != 0 {
+					t.Errorf("канал %s: закрыт %v, значений %d; want закрыт и пуст", ch.Name, ch.Closed, ch.Len)
+				}
+			}
+		})
+	}
+}
+
+// TestRunLatency проверяет, что задержка числа складывается из времени
+// генерации следующих за ним чисел, ожидания обработки предыдущих и его
+// собственной обработки.
+func TestRunLatency(t *testing.T) {
+	const n = 5
+	gen := 2 * time.Millisecond // генерация каждого числа
+	work := []time.Duration{3 * time.Millisecond, time.Millisecond, 4 * time.Millisecond, time.Millisecond, 5 * time.Millisecond}
+	clock := NewManualClock(time.Unix(0, 0))
+	// генерация каждого числа сдвигает часы на gen
+	var last int64
+	src := SourceFunc[int64](func(context.Context) (int64, bool) {
+		if last == n {
+			return 0, false
+		}
+		clock.Advance(gen)
+		last++
+		return last, true
+	})
+	// collected получает каждое число, дошедшее до приёмника
+	collected := make(chan int64, n)
+	var p *Pipeline
+	p = New(Config{NumWorkers: 1, BufferSize: n, Limit: n, Clock: clock, Source: src,
+		Process: func(_ context.Context, v int64) (int64, error) {
+			// число v обрабатывается, когда сгенерированы все числа, а
+			// предыдущее дошло до приёмника
+			for p.Stats().InputCount < n {
+				time.Sleep(50 * time.Microsecond)
+			}
+			if v > 1 {
+				<-collected
+			}
+			clock.Advance(work[v-1])
+			return v, nil
+		},
+		Collect: func(v int64) error {
+			collected <- v
+			return nil
+		},
+	})
+	stats, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+
+	// число k родилось в k*gen, а дошло до приёмника после генерации всех
+	// n чисел и обработки чисел 1..k
+	var want []time.Duration
+	var worked, total time.Duration
+	for k := 1; k <= n; k++ {
+		worked += work[k-1]
+		d := time.Duration(n-k)*gen + worked
+		want = append(want, d)
+		total += d
+	}
+	lat := stats.Latency
+	if lat.Count != n {
+		t.Errorf("Count = %d, want %d", lat.Count, n)
+	}
+	if w := slices.Min(want); lat.Min != w {
+		t.Errorf("Min = %v, want %v", lat.Min, w)
+	}
+	if w := slices.Max(want); lat.Max != w {
+		t.Errorf("Max = %v, want %v", lat.Max, w)
+	}
+	if w := total / n; lat.Mean != w {
+		t.Errorf("Mean = %v, want %v", lat.Mean, w)
+	}
+	// квантили гистограмма оценивает с точностью до 1/16
+	if w := slices.Max(want); lat.P99 < w-w/16 || lat.P99 > w {
+		t.Errorf("P99 = %v, want ≈%v", lat.P99, w)
+	}
+	// конвейер работал, пока генерировались и обрабатывались все числа
+	if w := time.Duration(n)*gen + worked; stats.Duration != w {
+		t.Errorf("Duration = %v, want %v", stats.Duration, w)
+	}
+	if w := float64(n) / stats.Duration.Seconds(); stats.Throughput() != w {
+		t.Errorf("Throughput = %v, want %v", stats.Throughput(), w)
+	}
+}
+
+// TestRunManualClock проверяет, что таймаут и паузы обработки
+// отсчитываются по часам Config.Clock.
+func TestRunManualClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	var delays atomic.Int64
+	cfg := Config{NumWorkers: 2, Timeout: time.Hour, Clock: clock, WorkerDelayFunc: func() time.Duration {
+		delays.Add(1)
+		return 0
+	}}
+	go func() {
+		// ждём, пока конвейер начнёт отсчитывать таймаут
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(time.Hour)
+	}()
+	stats, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delays.Load() != stats.OutputCount {
+		t.Errorf("пауз %d, want по паузе на каждое из %d обработанных чисел", delays.Load(), stats.OutputCount)
+	}
+	if err := stats.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBufferSize(t *testing.T) {
+	tests := []struct {
+		size, def, want int
+	}{
+		{0, 5, 5},
+		{3, 5, 3},
+		{-1, 5, 0},
+	}
+	for _, tt := range tests {
+		if got := bufferSize(tt.size, tt.def); got != tt.want {
+			t.Errorf("bufferSize(%d, %d) = %d, want %d", tt.size, tt.def, got, tt.want)
+		}
+	}
+}
+
+// TestRunBackpressure проверяет, что медленный приёмник без буферов
+// отражается во времени ожидания отправки генератора и обработчиков.
+func TestRunBackpressure(t *testing.T) {
+	cfg := Config{
+		NumWorkers: 2, Limit: 20, OutBufferSize: -1, ResultBufferSize: -1,
+		Collect: func(int64) error {
+			time.Sleep(time.Millisecond)
+			return nil
+		},
+	}
+	stats, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	var workers time.Duration
+	for _, d := range stats.WorkerBlocked {
+		workers += d
+	}
+	if stats.GeneratorBlocked <= 0 || workers <= 0 {
+		t.Errorf("ожидание отправки: генератор %v, обработчики %v, want больше 0", stats.GeneratorBlocked, stats.WorkerBlocked)
+	}
+}
+
+// TestRunInto проверяет, что RunInto передаёт в канал вызывающего все
+// числа результирующего канала и не закрывает его.
+func TestRunInto(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		buf  int
+	}{
+		{"один обработчик", Config{NumWorkers: 1}, 16},
+		{"с буфером каналов", Config{NumWorkers: 4, BufferSize: 8}, 1},
+		{"без буфера", Config{NumWorkers: 4}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Limit = 500
+			out := make(chan int64, tt.buf)
+			type done struct {
+				stats Result
+				err   error
+			}
+			finished := make(chan done, 1)
+			go func() {
+				stats, err := RunInto(context.Background(), tt.cfg, out)
+				finished <- done{stats, err}
+			}()
+			var got []int64
+			var d done
+		read:
+			for {
+				select {
+				case v := <-out:
+					got = append(got, v)
+				case d = <-finished:
+					// числа, отправленные до возврата, уже в буфере out
+					for len(out) > 0 {
+						got = append(got, <-out)
+					}
+					break read
+				}
+			}
+			if d.err != nil {
+				t.Fatal(d.err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, ints(1, 500)) || d.stats.OutputCount != 500 {
+				t.Errorf("получено %d чисел, OutputCount %d, want 1..500", len(got), d.stats.OutputCount)
+			}
+			select {
+			case v, ok := <-out:
+				t.Errorf("после RunInto из out получено %d, открыт %v", v, ok)
+			default:
+			}
+		})
+	}
+
+	if _, err := RunInto(context.Background(), Config{NumWorkers: 1, Limit: 1}, nil); err == nil {
+		t.Error("RunInto с nil-каналом не вернул ошибку")
+	}
+}
+
+// TestRunIntoCancel проверяет, что RunInto возвращается после отмены ctx,
+// даже если out никто не читает.
+func TestRunIntoCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan int64)
+	finished := make(chan error, 1)
+	go func() {
+		_, err := RunInto(ctx, Config{NumWorkers: 2, Limit: 100}, out)
+		finished <- err
+	}()
+	<-out
+	cancel()
+	if err := <-finished; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPipelineStop проверяет, что Stop останавливает генерацию без
+// таймаута и ограничения, а числа, уже попавшие в каналы, дообрабатываются.
+func TestPipelineStop(t *testing.T) {
+	var p *Pipeline
+	var seen int64
+	p = New(Config{NumWorkers: 3, BufferSize: 10, Collect: func(int64) error {
+		if seen++; seen == 50 {
+			p.Stop()
+			p.Stop() // повторный вызов не паникует
+		}
+		return nil
+	}})
+	done := make(chan struct{})
+	var res Result
+	var err error
+	go func() {
+		defer close(done)
+		res, err = p.Run(context.Background())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("конвейер не остановился после Stop")
+	}
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	if res.OutputCount < 50 || res.DroppedCount != 0 {
+		t.Errorf("получено %d чисел, отброшено %d; want не меньше 50 и ни одного", res.OutputCount, res.DroppedCount)
+	}
+}
+
+// TestRunAutoscale проверяет, что при медленных обработчиках, которых
+// генератор всё время ждёт, их количество растёт до предела, а все числа
+// доходят до результирующего канала.
+func TestRunAutoscale(t *testing.T) {
+	res, err := Run(context.Background(), Config{
+		NumWorkers:  1,
+		Timeout:     200 * time.Millisecond,
+		WorkerDelay: 2 * time.Millisecond,
+		Autoscale:   AutoscalePolicy{MaxWorkers: 4, Interval: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	if len(res.Scaling) == 0 || res.Scaling[len(res.Scaling)-1].Workers != 4 {
+		t.Fatalf("Scaling = %v, want рост до 4 обработчиков", res.Scaling)
+	}
+	for i, ev := range res.Scaling {
+		if ev.Workers != i+2 || ev.Pressure < 0.5 {
+			t.Errorf("Scaling[%d] = %+v, want %d обработчиков при давлении от 0.5", i, ev, i+2)
+		}
+	}
+	if len(res.PerWorker) != 4 {
+		t.Errorf("PerWorker = %v, want 4 ячейки", res.PerWorker)
+	}
+}
+
+// TestPipelineAddWorkers проверяет добавление и завершение обработчиков во
+// время работы: до генерации завершённые обработчики не получают чисел, а
+// оставшиеся передают все числа.
+func TestPipelineAddWorkers(t *testing.T) {
+	ready := make(chan struct{})
+	p := New(Config{NumWorkers: 1, MaxWorkers: 4, Limit: 100, Ready: ready})
+	if _, err := p.AddWorkers(1); err == nil {
+		t.Error("AddWorkers до запуска не вернул ошибку")
+	}
+	done := make(chan struct{})
+	var res Result
+	var runErr error
+	go func() {
+		defer close(done)
+		res, runErr = p.Run(context.Background())
+	}()
+	n, err := p.AddWorkers(5)
+	for ; err != nil; n, err = p.AddWorkers(5) {
+		runtime.Gosched()
+	}
+	if n != 4 {
+		t.Errorf("AddWorkers(5) = %d, want 4", n)
+	}
+	if n, err := p.RemoveWorkers(2); n != 2 || err != nil {
+		t.Errorf("RemoveWorkers(2) = %d, %v, want 2", n, err)
+	}
+	close(ready)
+	<-done
+	if runErr != nil {
+		t.Fatalf("Run = %v", runErr)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	if res.OutputCount != 100 || len(res.PerWorker) != 4 || res.PerWorker[2]+res.PerWorker[3] != 0 {
+		t.Errorf("передано %d чисел, по обработчикам %v, want 100 и ничего в ячейках 2 и 3", res.OutputCount, res.PerWorker)
+	}
+	if len(res.Scaling) != 2 || !res.Scaling[0].Manual || res.Scaling[0].Workers != 4 || res.Scaling[1].Workers != 2 {
+		t.Errorf("Scaling = %+v, want ручные изменения до 4 и 2", res.Scaling)
+	}
+	if _, err := p.RemoveWorkers(1); err == nil {
+		t.Error("RemoveWorkers после остановки не вернул ошибку")
+	}
+}
+
+// heldSink — приёмник, Flush которого ждёт закрытия hold.
+type heldSink struct {
+	MemorySink
+	flushing chan<- struct{}
+	hold     <-chan struct{}
+}
+
+func (s *heldSink) Flush() error {
+	close(s.flushing)
+	<-s.hold
+	return s.MemorySink.Flush()
+}
+
+// TestPipelineReuseSlot проверяет, что ячейка завершённого обработчика не
+// достаётся новому, пока горутина прежнего не вышла, хотя его канал уже
+// прочитан.
+func TestPipelineReuseSlot(t *testing.T) {
+	ready, flushing, hold := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	var sinks atomic.Int64
+	p := New(Config{NumWorkers: 2, MaxWorkers: 2, Limit: 100, Ready: ready, WorkerSinks: func(i int) Sink {
+		// приёмник второго обработчика ячейки 1 не задерживается
+		if i == 1 && sinks.Add(1) == 1 {
+			return &heldSink{flushing: flushing, hold: hold}
+		}
+		return &MemorySink{}
+	}})
+	done := make(chan struct{})
+	var res Result
+	var runErr error
+	go func() {
+		defer close(done)
+		res, runErr = p.Run(context.Background())
+	}()
+	n, err := p.RemoveWorkers(1)
+	for ; err != nil; n, err = p.RemoveWorkers(1) {
+		runtime.Gosched()
+	}
+	if n != 1 {
+		t.Fatalf("RemoveWorkers(1) = %d, want 1", n)
+	}
+	<-flushing
+	for range 100 {
+		if n, err := p.AddWorkers(1); n != 1 || err != nil {
+			t.Fatalf("AddWorkers(1) до выхода обработчика = %d, %v, want 1", n, err)
+		}
+		runtime.Gosched()
+	}
+	close(hold)
+	for n != 2 {
+		runtime.Gosched()
+		if n, err = p.AddWorkers(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(ready)
+	<-done
+	if runErr != nil {
+		t.Fatalf("Run = %v", runErr)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	if res.OutputCount != 100 || sinks.Load() != 2 {
+		t.Errorf("передано %d чисел, приёмников ячейки 1 %d, want 100 и 2", res.OutputCount, sinks.Load())
+	}
+}
+
+// TestPipelinePause проверяет, что на паузе, поставленной до запуска,
+// генератор не получает чисел, а после Resume конвейер передаёт все числа.
+func TestPipelinePause(t *testing.T) {
+	p := New(Config{NumWorkers: 2, Limit: 100})
+	p.Pause()
+	if !p.Paused() {
+		t.Fatal("Paused после Pause = false")
+	}
+	done := make(chan struct{})
+	var res Result
+	var err error
+	go func() {
+		defer close(done)
+		res, err = p.Run(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if n := p.Stats().InputCount; n != 0 {
+		t.Errorf("на паузе сгенерировано %d чисел", n)
+	}
+	p.Resume()
+	<-done
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil || res.OutputCount != 100 {
+		t.Errorf("после Resume передано %d чисел, Verify = %v, want 100", res.OutputCount, err)
+	}
+}
+
+// TestRunMiddleware проверяет, что Config.Middleware оборачивают обработку
+// каждого числа.
+func TestRunMiddleware(t *testing.T) {
+	var calls atomic.Int64
+	res, err := Run(context.Background(), Config{NumWorkers: 3, Limit: 50, Middleware: []Middleware[int64]{
+		Timing[int64](SystemClock, func(time.Duration, error) { calls.Add(1) }),
+	}})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil || calls.Load() != 50 {
+		t.Errorf("Verify = %v, middleware вызвана %d раз, want 50", err, calls.Load())
+	}
+}
+
+// TestRunDeadLetters проверяет, что с Config.Retry и Config.DeadLetters
+// неудачная обработка повторяется, окончательно не обработанные числа
+// передаются в приёмник, сохраняются в Result и учитываются при проверке,
+// а конвейер не останавливается.
+func TestRunDeadLetters(t *testing.T) {
+	dead := make(chan DeadLetter[int64])
+	var letters []DeadLetter[int64]
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for dl := range dead {
+			letters = append(letters, dl)
+		}
+	}()
+	res, err := Run(context.Background(), Config{
+		NumWorkers:  3,
+		Limit:       20,
+		Retry:       RetryPolicy{Attempts: 2},
+		DeadLetters: DeadLetterChan(dead),
+		Process: func(_ context.Context, v int64) (int64, error) {
+			if v%2 == 1 {
+				return 0, errOdd
+			}
+			return v, nil
+		},
+	})
+	close(dead)
+	<-done
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	var retries int64
+	for _, n := range res.Retries {
+		retries += n
+	}
+	if res.OutputCount != 10 || res.FailedCount != 10 || res.FailedSum != 100 || retries != 10 || len(letters) != 10 {
+		t.Errorf("дошло %d, не обработано %d с суммой %d, повторов %d, в канале %d, want 10, 10, 100, 10 и 10",
+			res.OutputCount, res.FailedCount, res.FailedSum, retries, len(letters))
+	}
+	if !slices.Equal(res.DeadLetters, letters) {
+		t.Errorf("Result.DeadLetters = %v, want %v", res.DeadLetters, letters)
+	}
+	for _, dl := range letters {
+		if dl.Value%2 != 1 || dl.Attempts != 2 || !errors.Is(dl.Err, errOdd) {
+			t.Errorf("необработанное %+v, want нечётное после 2 попыток", dl)
+		}
+	}
+}
+
+// TestRunSources проверяет, что числа нескольких источников генерируются
+// одновременно, Limit ограничивает их общее количество, разбивка по
+// источникам сходится, а номера чисел, не отправленных при остановке, не
+// считаются потерянными.
+func TestRunSources(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"ограничение", Config{Limit: 200}},
+		{"таймаут", Config{Timeout: 20 * time.Millisecond, BufferSize: 1}},
+		{"порядок", Config{Limit: 200, Ordered: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.NumWorkers = 3
+			cfg.VerifySequence = true
+			cfg.Sources = []Source[int64]{Sequential(), Random(1, 1000), Primes()}
+			res, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Run = %v", err)
+			}
+			if err := res.Verify(); err != nil {
+				t.Fatal(err)
+			}
+			if len(res.PerSource) != 3 {
+				t.Fatalf("PerSource = %v, want 3 источника", res.PerSource)
+			}
+			if cfg.Limit > 0 && res.InputCount != cfg.Limit {
+				t.Errorf("сгенерировано %d чисел, want %d", res.InputCount, cfg.Limit)
+			}
+		})
+	}
+}
+
+// TestRunBigSums проверяет, что запуск, суммы которого переполнили int64,
+// не проходит проверку без Config.BigSums, а с ним проверяется по точным
+// суммам.
+func TestRunBigSums(t *testing.T) {
+	tests := []struct {
+		name    string
+		bigSums bool
+		batch   int
+		wantErr error
+	}{
+		{"без точных сумм", false, 0, ErrSumOverflow},
+		{"точные суммы", true, 0, nil},
+		{"точные суммы пачками", true, 4, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := SourceFunc[int64](func(context.Context) (int64, bool) {
+				return math.MaxInt64 / 2, true
+			})
+			p := New(Config{NumWorkers: 3, Limit: 20, Source: src, BigSums: tt.bigSums})
+			var res Result
+			var err error
+			if tt.batch > 0 {
+				res, err = p.RunBatched(context.Background(), tt.batch, 0)
+			} else {
+				res, err = p.Run(context.Background())
+			}
+			if err != nil {
+				t.Fatalf("Run = %v", err)
+			}
+			if !res.SumOverflow {
+				t.Error("SumOverflow = false, want true")
+			}
+			if err := res.Verify(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+			}
+			want := new(big.Int).Mul(big.NewInt(math.MaxInt64/2), big.NewInt(20))
+			if tt.bigSums && res.BigSums.Output.Cmp(want) != 0 {
+				t.Errorf("точная сумма %s, want %s", res.BigSums.Output, want)
+			}
+		})
+	}
+}
+
+// TestRunWorkerDelayFactors проверяет, что при общем канале обработчик,
+// замедленный WorkerDelayFactors, получает меньше чисел, чем остальные.
+func TestRunWorkerDelayFactors(t *testing.T) {
+	res, err := Run(context.Background(), Config{
+		NumWorkers:         3,
+		Limit:              300,
+		WorkerDelay:        time.Millisecond,
+		WorkerDelayFactors: []float64{10},
+	})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if slow := res.PerWorker[0]; slow >= res.PerWorker[1] || slow >= res.PerWorker[2] {
+		t.Errorf("разбивка по каналам %v, want у обработчика 0 меньше всех", res.PerWorker)
+	}
+}
+
+// TestRunLeakTimeout проверяет, что Run не ждёт бесконечно обработчик,
+// который не учитывает отмену контекста, а через LeakTimeout возвращает
+// *LeakError с его именем.
+func TestRunLeakTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	_, err := Run(context.Background(), Config{
+		NumWorkers:  2,
+		Timeout:     20 * time.Millisecond,
+		Drain:       DropRemaining,
+		LeakTimeout: 20 * time.Millisecond,
+		Process: func(_ context.Context, v int64) (int64, error) {
+			if v == 3 {
+				<-release
+			}
+			return v, nil
+		},
+	})
+	var leak *LeakError
+	if !errors.As(err, &leak) {
+		t.Fatalf("Run = %v, want *LeakError", err)
+	}
+	if len(leak.Goroutines) != 1 || !strings.HasPrefix(leak.Goroutines[0], "обработчик ") {
+		t.Errorf("незавершившиеся горутины %q, want один обработчик", leak.Goroutines)
+	}
+}
+
+// prioritized — источник, который сам задаёт класс приоритета: первые
+// first чисел — класс 0, остальные — класс 1.
+type prioritized struct {
+	Source[int64]
+	first int64
+}
+
+func (p prioritized) Priority(n, _ int64) int {
+	if n <= p.first {
+		return 0
+	}
+	return 1
+}
+
+// TestRunPriority проверяет, что с Config.Priority числа учитываются по
+// классам, которые задают Classify или источник, реализующий Prioritizer.
+func TestRunPriority(t *testing.T) {
+	tests := []struct {
+		name   string
+		source Source[int64]
+		want   []int64
+	}{
+		{"Classify", Sequential(), []int64{50, 50}},
+		{"Prioritizer", prioritized{Source: Sequential(), first: 30}, []int64{30, 70}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Run(context.Background(), Config{
+				NumWorkers: 3,
+				Limit:      100,
+				Source:     tt.source,
+				Priority: PriorityPolicy{
+					Weights:  []int{4, 1},
+					Classify: func(v int64) int { return int(v % 2) },
+				},
+			})
+			if err != nil {
+				t.Fatalf("Run = %v", err)
+			}
+			if err := res.Verify(); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.PerPriority, tt.want) || !slices.Equal(res.PriorityOut, tt.want) {
+				t.Errorf("по классам сгенерировано %v, дошло %v, want %v", res.PerPriority, res.PriorityOut, tt.want)
+			}
+			if len(res.PriorityLatency) != 2 {
+				t.Errorf("задержка по %d классам, want 2", len(res.PriorityLatency))
+			}
+		})
+	}
+}
+
+// TestRunPrioritySpill проверяет, что числа, вытесненные на диск перед
+// приёмником, сохраняют класс приоритета и учитываются в своём классе.
+func TestRunPrioritySpill(t *testing.T) {
+	dir := t.TempDir()
+	spilled := false
+	res, err := Run(context.Background(), Config{
+		NumWorkers:     3,
+		Limit:          100,
+		SpillThreshold: 2,
+		SpillDir:       dir,
+		Priority: PriorityPolicy{
+			Weights:  []int{4, 1},
+			Classify: func(v int64) int { return int(v % 2) },
+		},
+		Collect: func(int64) error {
+			// приёмник ждёт, пока очередь не начнёт вытеснять числа в файл
+			for deadline := time.Now().Add(5 * time.Second); !spilled && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				if files, _ := os.ReadDir(dir); len(files) > 0 {
+					spilled = true
+				}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if !spilled {
+		t.Error("числа не вытеснялись в файл")
+	}
+	if err := res.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{50, 50}
+	if !slices.Equal(res.PriorityOut, want) {
+		t.Errorf("по классам дошло %v, want %v", res.PriorityOut, want)
+	}
+	if len(res.PriorityLatency) != 2 || res.PriorityLatency[0].Count != 50 || res.PriorityLatency[1].Count != 50 {
+		t.Errorf("задержка по классам %+v, want по 50 измерений", res.PriorityLatency)
+	}
+}
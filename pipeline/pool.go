@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// ScaleEvent — изменение количества обработчиков во время работы конвейера.
+type ScaleEvent struct {
+	At       time.Duration // время от запуска конвейера
+	Workers  int           // количество обработчиков после изменения
+	Pressure float64       // давление на входе, при котором принято решение
+	Manual   bool          // изменение сделано AddWorkers или RemoveWorkers
+}
+
+// workerPool управляет обработчиками конвейера, количество которых может
+// меняться во время работы. У каждого обработчика своя ячейка с номером,
+// который служит номером обработчика в статистике. Ячейка завершённого
+// обработчика используется повторно только после release, то есть когда
+// прежний обработчик вышел и его результаты собраны: в одной ячейке никогда
+// не работают два обработчика сразу.
+type workerPool struct {
+	mu      sync.Mutex
+	quits   []chan struct{} // сигнал завершения обработчика ячейки i; nil — обработчика нет
+	leaving []bool          // обработчик ячейки i завершается, ячейка ещё занята
+	count   int             // количество работающих обработчиков
+	done    bool            // обработчики больше не запускаются
+	// start запускает обработчик в ячейке i с сигналом завершения quit;
+	// false — запустить уже нельзя
+	start func(i int, quit <-chan struct{}) bool
+
+	clock  Clock
+	begin  time.Time    // время запуска конвейера
+	events []ScaleEvent // изменения количества обработчиков
+}
+
+// newWorkerPool создаёт пул не больше чем на capacity обработчиков;
+// время изменений отсчитывается от begin.
+func newWorkerPool(capacity int, clock Clock, begin time.Time, start func(i int, quit <-chan struct{}) bool) *workerPool {
+	return &workerPool{
+		quits:   make([]chan struct{}, capacity),
+		leaving: make([]bool, capacity),
+		start:   start,
+		clock:   clock,
+		begin:   begin,
+	}
+}
+
+// workers возвращает количество работающих обработчиков.
+func (p *workerPool) workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.count
+}
+
+// adjust запускает или завершает обработчики, чтобы их количество
+// изменилось на delta, но осталось не меньше одного и не больше ёмкости
+// пула. Новые обработчики занимают свободные ячейки с наименьшими номерами,
+// завершаются обработчики с наибольшими. Завершаемый обработчик
+// дообрабатывает текущее число. Если свободных ячеек не хватает, потому что
+// завершённые обработчики ещё не вышли, обработчиков добавляется меньше.
+// Изменение записывается как ev с заполненными At и Workers. Возвращает
+// получившееся количество обработчиков и false, если пул уже не работает.
+func (p *workerPool) adjust(delta int, ev ScaleEvent) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return p.count, false
+	}
+	n := min(max(p.count+delta, 1), len(p.quits))
+	from := p.count
+	for i := 0; i < len(p.quits) && p.count < n; i++ {
+		if p.quits[i] != nil || p.leaving[i] {
+			continue
+		}
+		quit := make(chan struct{})
+		if !p.start(i, quit) {
+			p.done = true
+			return p.count, false
+		}
+		p.quits[i] = quit
+		p.count++
+	}
+	for i := len(p.quits) - 1; i >= 0 && p.count > n; i-- {
+		if p.quits[i] == nil {
+			continue
+		}
+		close(p.quits[i])
+		p.quits[i] = nil
+		p.leaving[i] = true
+		p.count--
+	}
+	if p.count != from && from != 0 {
+		ev.At = p.clock.Now().Sub(p.begin)
+		ev.Workers = p.count
+		p.events = append(p.events, ev)
+	}
+	return p.count, true
+}
+
+// release освобождает ячейку i завершённого обработчика; вызывается, когда
+// обработчик вышел и все его результаты собраны.
+func (p *workerPool) release(i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.leaving[i] = false
+}
+
+// finish запрещает запуск новых обработчиков; вызывается, когда обработчик
+// завершился сам, то есть входной канал закрыт или работа прервана.
+func (p *workerPool) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = true
+}
+
+// scaling возвращает изменения количества обработчиков.
+func (p *workerPool) scaling() []ScaleEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ScaleEvent(nil), p.events...)
+}
@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestWorkerPool проверяет, что пул занимает ячейки с наименьшими номерами,
+// завершает обработчики с наибольшими и не запускает новый обработчик в
+// ячейке, пока прежний не освободил её.
+func TestWorkerPool(t *testing.T) {
+	var started []int
+	quits := map[int]<-chan struct{}{}
+	pool := newWorkerPool(3, NewManualClock(time.Time{}), time.Time{}, func(i int, quit <-chan struct{}) bool {
+		started = append(started, i)
+		quits[i] = quit
+		return true
+	})
+	if n, _ := pool.adjust(5, ScaleEvent{}); n != 3 || !slices.Equal(started, []int{0, 1, 2}) {
+		t.Fatalf("adjust(5) = %d, запущены %v, want 3 и [0 1 2]", n, started)
+	}
+	if n, _ := pool.adjust(-2, ScaleEvent{Pressure: 0.05}); n != 1 {
+		t.Fatalf("adjust(-2) = %d, want 1", n)
+	}
+	for _, i := range []int{1, 2} {
+		select {
+		case <-quits[i]:
+		default:
+			t.Errorf("обработчик ячейки %d не получил сигнал завершения", i)
+		}
+	}
+	// ячейки 1 и 2 ещё заняты завершающимися обработчиками
+	if n, _ := pool.adjust(2, ScaleEvent{Pressure: 0.9}); n != 1 {
+		t.Fatalf("adjust(2) до release = %d, want 1", n)
+	}
+	pool.release(2)
+	if n, _ := pool.adjust(2, ScaleEvent{Manual: true}); n != 2 || started[len(started)-1] != 2 {
+		t.Fatalf("adjust(2) после release(2) = %d, запущены %v, want 2 и ячейку 2", n, started)
+	}
+	pool.finish()
+	pool.release(1)
+	if n, ok := pool.adjust(1, ScaleEvent{}); n != 2 || ok {
+		t.Errorf("adjust после finish = %d, %v, want 2 и false", n, ok)
+	}
+	want := []ScaleEvent{{Workers: 1, Pressure: 0.05}, {Workers: 2, Manual: true}}
+	if got := pool.scaling(); !slices.Equal(got, want) {
+		t.Errorf("scaling = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PriorityPolicy — классы приоритета чисел: каждое число получает класс, и
+// обработчики берут числа высших классов раньше, но так, что низшие классы
+// не простаивают, пока приходят числа высших. Нулевое значение выключает
+// приоритеты.
+type PriorityPolicy struct {
+	// Weights — веса классов от высшего (класс 0) к низшему; количество
+	// весов — количество классов. Пока числа ждут в нескольких классах,
+	// обработчики получают их в соотношении весов: при 4,1 — четыре числа
+	// класса 0 на одно класса 1
+	Weights []int
+	// Classify возвращает класс числа v; классы вне диапазона приводятся к
+	// ближайшему. Источник, реализующий Prioritizer, сам задаёт классы
+	// своих чисел. nil — класс 0 у чисел остальных источников
+	Classify func(v int64) int
+}
+
+// enabled сообщает, включены ли приоритеты.
+func (c PriorityPolicy) enabled() bool {
+	return len(c.Weights) > 0
+}
+
+// validate проверяет корректность настроек.
+func (c PriorityPolicy) validate() error {
+	for i, w := range c.Weights {
+		if w < 1 {
+			return fmt.Errorf("вес класса приоритета %d должен быть положительным: %d", i, w)
+		}
+	}
+	return nil
+}
+
+// lane возвращает класс p, приведённый к диапазону классов.
+func (c PriorityPolicy) lane(p int) int {
+	return min(max(p, 0), len(c.Weights)-1)
+}
+
+// source возвращает источник src, который отмечает класс приоритета каждого
+// числа по исходному источнику orig или по Classify.
+func (c PriorityPolicy) source(orig Source[int64], src Source[Event]) Source[Event] {
+	if !c.enabled() {
+		return src
+	}
+	prioritizer, _ := orig.(Prioritizer)
+	return SourceFunc[Event](func(ctx context.Context) (Event, bool) {
+		e, ok := src.Next(ctx)
+		if !ok {
+			return e, false
+		}
+		switch {
+		case prioritizer != nil:
+			e.Priority = c.lane(prioritizer.Priority(e.pos, e.Value))
+		case c.Classify != nil:
+			e.Priority = c.lane(c.Classify(e.Value))
+		}
+		return e, true
+	})
+}
+
+// Prioritizer — источник, который сам задаёт класс приоритета своих чисел,
+// например по приоритету сообщения брокера. Run проверяет Config.Source и
+// Config.Sources на этот интерфейс, если задан Config.Priority.
+type Prioritizer interface {
+	// Priority возвращает класс приоритета n-го (начиная с 1) числа v,
+	// возвращённого Next.
+	Priority(n, v int64) int
+}
+
+// errPriorityDistributor — Config.Priority задан вместе со своим Distributor.
+var errPriorityDistributor = errors.New("приоритеты раздаются своим Distributor, поэтому задаются без Config.Distributor")
+
+// PriorityLanes возвращает Distributor с очередью на каждый класс
+// приоритета: значения из in раскладываются по очередям классов lane(v), а
+// обработчики получают их из одного общего канала. Из непустых очередей
+// значения выдаются в соотношении весов weights (класс 0 — первый вес) по
+// плавному взвешенному циклу, поэтому даже очередь с наименьшим весом
+// получает свою долю. Всего в очередях ждёт не больше cap(in) (хотя бы
+// одного) значений на класс; когда они заполнены, чтение из in
+// приостанавливается.
+func PriorityLanes[T any](weights []int, lane func(T) int) Distributor[T] {
+	return priorityLanes[T]{weights: weights, lane: lane}
+}
+
+// priorityLanes — Distributor с очередями классов приоритета.
+type priorityLanes[T any] struct {
+	weights []int
+	lane    func(T) int
+}
+
+func (d priorityLanes[T]) Distribute(ctx context.Context, in <-chan T, n int, drop func(T)) []<-chan T {
+	// канал без буфера, чтобы класс очередного значения выбирался, когда
+	// обработчик уже готов его взять
+	out := make(chan T)
+	res := make([]<-chan T, n)
+	for i := range res {
+		res[i] = out
+	}
+	go func() {
+		defer close(out)
+		queues := make([][]T, len(d.weights))
+		// credit — накопленный вес каждой очереди плавного взвешенного цикла
+		credit := make([]int, len(d.weights))
+		limit := max(cap(in), 1) * len(d.weights)
+		var queued int
+		for in != nil || queued > 0 {
+			recv := in
+			if queued >= limit {
+				recv = nil
+			}
+			var (
+				send chan T
+				next T
+			)
+			l := d.pick(queues, credit)
+			if l >= 0 {
+				send, next = out, queues[l][0]
+			}
+			select {
+			case <-ctx.Done():
+				// раздача прервана: всё, что осталось в очередях и в in,
+				// отбрасываем
+				for _, q := range queues {
+					for _, v := range q {
+						drop(v)
+					}
+				}
+				if in != nil {
+					for v := range in {
+						drop(v)
+					}
+				}
+				return
+			case v, ok := <-recv:
+				if !ok {
+					in = nil
+					continue
+				}
+				k := min(max(d.lane(v), 0), len(queues)-1)
+				queues[k] = append(queues[k], v)
+				queued++
+			case send <- next:
+				d.commit(queues, credit, l)
+				var zero T
+				queues[l][0] = zero
+				queues[l] = queues[l][1:]
+				queued--
+			}
+		}
+	}()
+	return res
+}
+
+// pick возвращает непустую очередь, из которой выдаётся следующее значение:
+// с наибольшим накопленным весом после прибавки её веса, при равенстве —
+// высшего класса; -1, если все очереди пусты.
+func (d priorityLanes[T]) pick(queues [][]T, credit []int) int {
+	best := -1
+	for i, q := range queues {
+		if len(q) > 0 && (best < 0 || credit[i]+d.weights[i] > credit[best]+d.weights[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// commit учитывает выдачу значения из очереди l: непустые очереди получают
+// свой вес, а очередь l отдаёт их сумму.
+func (d priorityLanes[T]) commit(queues [][]T, credit []int, l int) {
+	var total int
+	for i, q := range queues {
+		if len(q) > 0 {
+			credit[i] += d.weights[i]
+			total += d.weights[i]
+		}
+	}
+	credit[l] -= total
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrSourceClosed возвращается PushSource.Push после вызова Close.
+var ErrSourceClosed = errors.New("источник закрыт")
+
+// PushSource — источник чисел, которые передаются ему извне методом Push,
+// например из сетевых запросов. Push ждёт, пока генератор заберёт число,
+// поэтому вызывающий точно знает, какие числа попали в конвейер.
+type PushSource struct {
+	values    chan int64    // числа, ожидающие генератора
+	done      chan struct{} // закрывается методом Close
+	closeOnce sync.Once
+}
+
+// NewPushSource создаёт источник чисел, передаваемых методом Push.
+func NewPushSource() *PushSource {
+	return &PushSource{values: make(chan int64), done: make(chan struct{})}
+}
+
+// Next возвращает очередное переданное число, ожидая его до отмены ctx или
+// вызова Close.
+func (s *PushSource) Next(ctx context.Context) (int64, bool) {
+	select {
+	case <-ctx.Done():
+		return 0, false
+	case <-s.done:
+		return 0, false
+	case v := <-s.values:
+		return v, true
+	}
+}
+
+// Push передаёт v генератору. Возвращает ошибку контекста при отмене ctx
+// и ErrSourceClosed после Close; в обоих случаях число не принято.
+func (s *PushSource) Push(ctx context.Context, v int64) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrSourceClosed
+	case s.values <- v:
+		return nil
+	}
+}
+
+// Close исчерпывает источник: Next возвращает false, а ожидающие и новые
+// вызовы Push — ErrSourceClosed. Повторный вызов ничего не делает.
+func (s *PushSource) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// Done возвращает канал, который закрывается вызовом Close.
+func (s *PushSource) Done() <-chan struct{} {
+	return s.done
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPushSource(t *testing.T) {
+	src := NewPushSource()
+	go func() {
+		for v := int64(1); v <= 3; v++ {
+			if err := src.Push(context.Background(), v); err != nil {
+				t.Errorf("Push(%d) = %v", v, err)
+			}
+		}
+	}()
+	for want := int64(1); want <= 3; want++ {
+		if v, ok := src.Next(context.Background()); !ok || v != want {
+			t.Fatalf("Next = %d, %v, want %d", v, ok, want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := src.Push(ctx, 4); !errors.Is(err, context.Canceled) {
+		t.Errorf("Push с отменённым контекстом = %v, want context.Canceled", err)
+	}
+	if _, ok := src.Next(ctx); ok {
+		t.Error("Next с отменённым контекстом вернул число")
+	}
+
+	src.Close()
+	select {
+	case <-src.Done():
+	default:
+		t.Error("Done не закрыт после Close")
+	}
+	if err := src.Push(context.Background(), 5); !errors.Is(err, ErrSourceClosed) {
+		t.Errorf("Push после Close = %v, want ErrSourceClosed", err)
+	}
+	if _, ok := src.Next(context.Background()); ok {
+		t.Error("Next после Close вернул число")
+	}
+}
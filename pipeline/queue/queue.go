@@ -0,0 +1,411 @@
+// Package queue содержит очередь FIFO с ограниченным расходом памяти:
+// записи сверх заданного количества вытесняются в файлы-сегменты на диске и
+// читаются обратно по мере освобождения очереди. Очередь переживает
+// перезапуск: Open восстанавливает её по сегментам в каталоге.
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrFull возвращается Push, если очередь заняла на диске MaxDiskBytes.
+var ErrFull = errors.New("очередь заполнена")
+
+// Значения Options по умолчанию.
+const (
+	defaultMemoryRecords  = 1024
+	defaultSegmentRecords = 64 * 1024
+)
+
+// Суффикс сегментов и имя файла с позицией чтения.
+const (
+	segmentExt = ".seg"
+	cursorName = "cursor"
+)
+
+// firstSegment — номер первого сегмента. Сегменты с меньшими номерами
+// создаются Close для записей, остававшихся в памяти.
+const firstSegment = 1 << 32
+
+// Options — настройки очереди.
+type Options struct {
+	Dir            string // каталог сегментов; создаётся при необходимости
+	MemoryRecords  int    // сколько записей хранится в памяти; 0 — 1024
+	SegmentRecords int    // сколько записей в одном сегменте; 0 — 65536
+	MaxDiskBytes   int64  // сколько байт можно занять на диске; 0 — без ограничения
+}
+
+// Queue — очередь записей с вытеснением на диск. Формат записи в сегменте:
+// длина в кодировке uvarint, затем сами байты. Позиция чтения сохраняется
+// при переходе к следующему сегменту и в Close, поэтому после аварийного
+// завершения часть записей текущего сегмента может быть выдана повторно.
+// Безопасна для конкурентного использования.
+type Queue struct {
+	mu   sync.Mutex
+	opts Options
+
+	mem  [][]byte   // старейшие записи, пока на диске пусто
+	segs []*segment // сегменты на диске в порядке записи
+	disk int        // количество непрочитанных записей на диске
+	used int64      // байт на диске, включая прочитанные записи текущих сегментов
+}
+
+// segment — файл с записями очереди.
+type segment struct {
+	id      int64
+	file    *os.File
+	records int   // количество записей в сегменте
+	read    int   // количество прочитанных записей
+	rOff    int64 // смещение чтения
+	wOff    int64 // смещение записи
+}
+
+// Open открывает очередь в каталоге opts.Dir, восстанавливая записи,
+// оставшиеся от предыдущего запуска.
+func Open(opts Options) (*Queue, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("не задан каталог очереди")
+	}
+	if opts.MemoryRecords == 0 {
+		opts.MemoryRecords = defaultMemoryRecords
+	}
+	if opts.SegmentRecords == 0 {
+		opts.SegmentRecords = defaultSegmentRecords
+	}
+	if opts.MemoryRecords < 0 || opts.SegmentRecords < 0 || opts.MaxDiskBytes < 0 {
+		return nil, fmt.Errorf("недопустимые настройки очереди: %+v", opts)
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	q := &Queue{opts: opts}
+	if err := q.recover(); err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+	return q, nil
+}
+
+// recover открывает сегменты каталога и пропускает записи, прочитанные до
+// сохранённой позиции.
+func (q *Queue) recover() error {
+	entries, err := os.ReadDir(q.opts.Dir)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	cursorID, cursorRead, err := q.loadCursor()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id < cursorID {
+			// сегмент прочитан полностью до перезапуска
+			if err := os.Remove(q.segmentPath(id)); err != nil {
+				return err
+			}
+			continue
+		}
+		seg, err := q.openSegment(id)
+		if err != nil {
+			return err
+		}
+		q.segs = append(q.segs, seg)
+		q.used += seg.wOff
+		if id == cursorID {
+			for seg.read < cursorRead && seg.read < seg.records {
+				if _, err := seg.next(); err != nil {
+					return err
+				}
+			}
+		}
+		q.disk += seg.records - seg.read
+	}
+	return nil
+}
+
+// openSegment открывает сегмент id и подсчитывает записи в нём. Неполная
+// последняя запись, оставшаяся после аварийного завершения, отбрасывается.
+func (q *Queue) openSegment(id int64) (*segment, error) {
+	f, err := os.OpenFile(q.segmentPath(id), os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segment{id: id, file: f}
+	for {
+		n, err := seg.recordSize(seg.wOff)
+		if err != nil {
+			break
+		}
+		seg.wOff += n
+		seg.records++
+	}
+	if err := f.Truncate(seg.wOff); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return seg, nil
+}
+
+// loadCursor читает сохранённую позицию: номер сегмента и количество
+// прочитанных в нём записей.
+func (q *Queue) loadCursor() (id int64, read int, err error) {
+	data, err := os.ReadFile(filepath.Join(q.opts.Dir, cursorName))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscan(string(data), &id, &read); err != nil {
+		return 0, 0, fmt.Errorf("повреждён файл позиции очереди: %w", err)
+	}
+	return id, read, nil
+}
+
+// saveCursor сохраняет позицию чтения.
+func (q *Queue) saveCursor() error {
+	var id int64
+	var read int
+	if len(q.segs) > 0 {
+		id, read = q.segs[0].id, q.segs[0].read
+	}
+	tmp := filepath.Join(q.opts.Dir, cursorName+".tmp")
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintln(id, read)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(q.opts.Dir, cursorName))
+}
+
+// segmentPath возвращает путь к сегменту id.
+func (q *Queue) segmentPath(id int64) string {
+	return filepath.Join(q.opts.Dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+// Push добавляет запись rec в конец очереди. Запись копируется.
+func (q *Queue) Push(rec []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.disk == 0 && len(q.mem) < q.opts.MemoryRecords {
+		q.mem = append(q.mem, slices.Clone(rec))
+		return nil
+	}
+	return q.pushDisk(rec)
+}
+
+// pushDisk дописывает rec в последний сегмент, создавая новый при
+// необходимости.
+func (q *Queue) pushDisk(rec []byte) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(rec)))
+	size := int64(n + len(rec))
+	if q.opts.MaxDiskBytes > 0 && q.used+size > q.opts.MaxDiskBytes {
+		return ErrFull
+	}
+
+	var last *segment
+	if len(q.segs) > 0 {
+		last = q.segs[len(q.segs)-1]
+	}
+	if last == nil || last.records >= q.opts.SegmentRecords {
+		id := int64(firstSegment)
+		if last != nil {
+			id = last.id + 1
+		}
+		f, err := os.OpenFile(q.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		last = &segment{id: id, file: f}
+		q.segs = append(q.segs, last)
+	}
+	if _, err := last.file.WriteAt(append(buf[:n], rec...), last.wOff); err != nil {
+		return err
+	}
+	last.wOff += size
+	last.records++
+	q.disk++
+	q.used += size
+	return nil
+}
+
+// Pop извлекает запись из начала очереди; ok равно false, если очередь
+// пуста.
+func (q *Queue) Pop() (rec []byte, ok bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.mem) > 0 {
+		rec = q.mem[0]
+		q.mem[0] = nil
+		q.mem = q.mem[1:]
+		return rec, true, nil
+	}
+	if q.disk == 0 {
+		return nil, false, nil
+	}
+	seg := q.segs[0]
+	if rec, err = seg.next(); err != nil {
+		return nil, false, err
+	}
+	q.disk--
+	// прочитанный полностью сегмент удаляется, если в него больше не пишут
+	if seg.read == seg.records && (len(q.segs) > 1 || seg.records >= q.opts.SegmentRecords || q.disk == 0) {
+		if err := q.dropSegment(); err != nil {
+			return rec, true, err
+		}
+	}
+	return rec, true, nil
+}
+
+// dropSegment удаляет первый сегмент и сохраняет позицию чтения.
+func (q *Queue) dropSegment() error {
+	seg := q.segs[0]
+	q.segs = q.segs[1:]
+	q.used -= seg.wOff
+	if err := seg.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(seg.file.Name()); err != nil {
+		return err
+	}
+	return q.saveCursor()
+}
+
+// next читает очередную запись сегмента.
+func (s *segment) next() ([]byte, error) {
+	n, err := s.recordSize(s.rOff)
+	if err != nil {
+		return nil, err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	m, _ := s.file.ReadAt(buf[:], s.rOff)
+	size, hdr := binary.Uvarint(buf[:m])
+	rec := make([]byte, size)
+	if _, err := s.file.ReadAt(rec, s.rOff+int64(hdr)); err != nil {
+		return nil, err
+	}
+	s.rOff += n
+	s.read++
+	return rec, nil
+}
+
+// recordSize возвращает полный размер записи по смещению off или ошибку,
+// если запись неполная.
+func (s *segment) recordSize(off int64) (int64, error) {
+	var buf [binary.MaxVarintLen64]byte
+	m, err := s.file.ReadAt(buf[:], off)
+	if m == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	size, hdr := binary.Uvarint(buf[:m])
+	if hdr <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	total := int64(hdr) + int64(size)
+	if off+total > info.Size() {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return total, nil
+}
+
+// Len возвращает количество записей в очереди.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.mem) + q.disk
+}
+
+// Full сообщает, заняла ли очередь на диске MaxDiskBytes.
+func (q *Queue) Full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.opts.MaxDiskBytes > 0 && q.used >= q.opts.MaxDiskBytes
+}
+
+// Close сохраняет записи, остававшиеся в памяти, в сегмент перед
+// остальными и позицию чтения, чтобы Open восстановил очередь целиком, и
+// закрывает файлы.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err := q.flushMemory()
+	if serr := q.saveCursor(); err == nil {
+		err = serr
+	}
+	if cerr := q.closeFiles(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// flushMemory записывает записи из памяти в новый сегмент с номером меньше
+// номеров всех остальных.
+func (q *Queue) flushMemory() error {
+	if len(q.mem) == 0 {
+		return nil
+	}
+	id := int64(firstSegment - 1)
+	if len(q.segs) > 0 {
+		id = q.segs[0].id - 1
+	}
+	f, err := os.OpenFile(q.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	seg := &segment{id: id, file: f}
+	var buf [binary.MaxVarintLen64]byte
+	for _, rec := range q.mem {
+		n := binary.PutUvarint(buf[:], uint64(len(rec)))
+		if _, err := f.WriteAt(append(buf[:n], rec...), seg.wOff); err != nil {
+			return err
+		}
+		seg.wOff += int64(n + len(rec))
+		seg.records++
+	}
+	q.segs = append([]*segment{seg}, q.segs...)
+	q.disk += len(q.mem)
+	q.used += seg.wOff
+	q.mem = nil
+	return nil
+}
+
+// closeFiles закрывает файлы сегментов.
+func (q *Queue) closeFiles() error {
+	var err error
+	for _, seg := range q.segs {
+		if cerr := seg.file.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	q.segs = nil
+	return err
+}
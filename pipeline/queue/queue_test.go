@@ -0,0 +1,217 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// record возвращает запись с номером i.
+func record(i int) []byte {
+	return []byte(fmt.Sprintf("запись %d", i))
+}
+
+// popAll извлекает все записи очереди и проверяет, что это записи с
+// номерами from..to по порядку.
+func popAll(t *testing.T, q *Queue, from, to int) {
+	t.Helper()
+	for i := from; i <= to; i++ {
+		rec, ok, err := q.Pop()
+		if err != nil || !ok {
+			t.Fatalf("Pop записи %d: ok %v, err %v", i, ok, err)
+		}
+		if string(rec) != string(record(i)) {
+			t.Fatalf("Pop = %q, want %q", rec, record(i))
+		}
+	}
+	if rec, ok, err := q.Pop(); ok || err != nil {
+		t.Fatalf("лишняя запись %q, err %v", rec, err)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Len = %d после извлечения всех записей", q.Len())
+	}
+}
+
+func TestQueueFIFO(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		records int
+	}{
+		{"в памяти", Options{MemoryRecords: 100}, 50},
+		{"на диске", Options{MemoryRecords: 1, SegmentRecords: 4}, 50},
+		{"по записи в сегменте", Options{MemoryRecords: 1, SegmentRecords: 1}, 10},
+		{"много сегментов", Options{MemoryRecords: 3, SegmentRecords: 2}, 101},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Dir = t.TempDir()
+			q, err := Open(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer q.Close()
+			for i := 1; i <= tt.records; i++ {
+				if err := q.Push(record(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if q.Len() != tt.records {
+				t.Fatalf("Len = %d, want %d", q.Len(), tt.records)
+			}
+			popAll(t, q, 1, tt.records)
+		})
+	}
+}
+
+// TestQueueInterleaved проверяет порядок, когда записи добавляются, пока
+// часть уже вытеснена на диск и читается.
+func TestQueueInterleaved(t *testing.T) {
+	q, err := Open(Options{Dir: t.TempDir(), MemoryRecords: 2, SegmentRecords: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	next, want := 1, 1
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 5; i++ {
+			if err := q.Push(record(next)); err != nil {
+				t.Fatal(err)
+			}
+			next++
+		}
+		for i := 0; i < 3; i++ {
+			rec, ok, err := q.Pop()
+			if err != nil || !ok || string(rec) != string(record(want)) {
+				t.Fatalf("Pop = %q, %v, %v, want %q", rec, ok, err, record(want))
+			}
+			want++
+		}
+	}
+	popAll(t, q, want, next-1)
+}
+
+func TestQueueFull(t *testing.T) {
+	q, err := Open(Options{Dir: t.TempDir(), MemoryRecords: 1, MaxDiskBytes: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	pushed := 0
+	for ; pushed < 100; pushed++ {
+		err := q.Push(record(pushed + 1))
+		if errors.Is(err, ErrFull) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// одна запись в памяти, а на диске запись «запись N» с длиной
+	// занимает 15 байт: в 40 байт помещаются две
+	if pushed != 3 {
+		t.Fatalf("в очередь поместилось %d записей, want 3", pushed)
+	}
+	if err := q.Push(record(pushed + 1)); !errors.Is(err, ErrFull) {
+		t.Fatalf("Push в заполненную очередь: %v", err)
+	}
+	popAll(t, q, 1, pushed)
+	if err := q.Push(record(1)); err != nil {
+		t.Fatalf("Push после освобождения: %v", err)
+	}
+}
+
+// TestQueueReopen проверяет, что очередь восстанавливается после Close
+// вместе с записями, остававшимися в памяти, и позицией чтения.
+func TestQueueReopen(t *testing.T) {
+	tests := []struct {
+		name   string
+		pushed int
+		popped int
+	}{
+		{"пустая", 0, 0},
+		{"только память", 3, 1},
+		{"память и диск", 20, 0},
+		{"прочитана часть сегментов", 40, 13},
+		{"прочитана вся", 10, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Dir: t.TempDir(), MemoryRecords: 4, SegmentRecords: 5}
+			q, err := Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= tt.pushed; i++ {
+				if err := q.Push(record(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < tt.popped; i++ {
+				if _, _, err := q.Pop(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := q.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			q, err = Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer q.Close()
+			if q.Len() != tt.pushed-tt.popped {
+				t.Fatalf("Len после Open = %d, want %d", q.Len(), tt.pushed-tt.popped)
+			}
+			popAll(t, q, tt.popped+1, tt.pushed)
+		})
+	}
+}
+
+// TestQueueTornRecord проверяет, что неполная последняя запись сегмента,
+// оставшаяся после аварийного завершения, отбрасывается.
+func TestQueueTornRecord(t *testing.T) {
+	opts := Options{Dir: t.TempDir(), MemoryRecords: 1}
+	q, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		if err := q.Push(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// запись из памяти теряется вместе с процессом: Close не вызывается
+	q.closeFiles()
+	seg := filepath.Join(opts.Dir, fmt.Sprintf("%020d%s", firstSegment, segmentExt))
+	f, err := os.OpenFile(seg, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{20, 'x'}) // заголовок записи в 20 байт и один байт
+	f.Close()
+
+	q, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	popAll(t, q, 2, 4)
+}
+
+func TestOpenInvalid(t *testing.T) {
+	for _, opts := range []Options{
+		{},
+		{Dir: t.TempDir(), MemoryRecords: -1},
+		{Dir: t.TempDir(), SegmentRecords: -1},
+		{Dir: t.TempDir(), MaxDiskBytes: -1},
+	} {
+		if q, err := Open(opts); err == nil {
+			q.Close()
+			t.Errorf("Open(%+v) без ошибки", opts)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter ограничивает частоту событий. Ему удовлетворяет, например,
+// *rate.Limiter из golang.org/x/time/rate.
+type Limiter interface {
+	// Wait блокируется, пока событие не будет разрешено или контекст не
+	// будет отменён.
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket — ограничитель частоты по алгоритму «ведро с токенами»:
+// токены пополняются со скоростью rate в секунду, но не больше burst.
+// Безопасен для конкурентного использования.
+type TokenBucket struct {
+	mu     sync.Mutex
+	clock  Clock
+	rate   float64   // токенов в секунду
+	burst  float64   // ёмкость ведра
+	tokens float64   // токенов сейчас
+	last   time.Time // время последнего пополнения
+}
+
+// NewTokenBucket создаёт TokenBucket, изначально заполненный до burst.
+// Параметры
+// rate - количество событий в секунду; должно быть положительным
+// burst - максимальное количество событий подряд без ожидания; меньше 1
+// трактуется как 1
+// clock - часы; nil — SystemClock
+func NewTokenBucket(rate float64, burst int, clock Clock) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &TokenBucket{
+		clock:  clock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// Wait забирает один токен, при необходимости дожидаясь его появления.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	for {
+		wait, ok := b.take()
+		if ok {
+			return nil
+		}
+		if err := Sleep(ctx, b.clock, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// take пополняет ведро и пытается забрать токен. Если токена нет,
+// возвращает время до его появления.
+func (b *TokenBucket) take() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait <= 0 {
+		wait = time.Nanosecond
+	}
+	return wait, false
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// bucketStep — вызов take после сдвига часов на advance.
+type bucketStep struct {
+	advance  time.Duration
+	wantOK   bool
+	wantWait time.Duration
+}
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		steps []bucketStep
+	}{
+		{"ведро заполнено", 10, 2, []bucketStep{{0, true, 0}, {0, true, 0}, {0, false, 100 * time.Millisecond}, {100 * time.Millisecond, true, 0}}},
+		{"не больше burst", 10, 1, []bucketStep{{time.Hour, true, 0}, {0, false, 100 * time.Millisecond}, {50 * time.Millisecond, false, 50 * time.Millisecond}}},
+		{"burst меньше 1", 1, 0, []bucketStep{{0, true, 0}, {0, false, time.Second}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(time.Unix(0, 0))
+			b := NewTokenBucket(tt.rate, tt.burst, clock)
+			for i, step := range tt.steps {
+				clock.Advance(step.advance)
+				wait, ok := b.take()
+				if ok != step.wantOK || wait != step.wantWait {
+					t.Errorf("шаг %d: take = %v, %v, want %v, %v", i, wait, ok, step.wantWait, step.wantOK)
+				}
+			}
+		})
+	}
+}
+
+// TestTokenBucketWait проверяет, что Wait ждёт токена по часам и
+// прерывается отменой контекста.
+func TestTokenBucketWait(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	b := NewTokenBucket(1, 1, clock)
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- b.Wait(context.Background()) }()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.Wait(ctx); err == nil {
+		t.Error("Wait без токена с отменённым контекстом не вернул ошибку")
+	}
+}
@@ -0,0 +1,329 @@
+package pipeline
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+)
+
+// Reducer — свёртка чисел результирующего канала, например их количество
+// или наибольшие числа. Свёртки Config.Reducers получают числа в своих
+// горутинах, поэтому вызовы Add одной свёртки не пересекаются, а Result
+// вызывается после последнего Add.
+type Reducer interface {
+	// Add учитывает очередное число.
+	Add(v int64)
+	// Result возвращает итог свёртки.
+	Result() any
+}
+
+// CountReducer считает числа; итог — int64.
+type CountReducer struct {
+	n int64
+}
+
+// NewCountReducer создаёт свёртку, считающую числа.
+func NewCountReducer() *CountReducer {
+	return &CountReducer{}
+}
+
+func (r *CountReducer) Add(int64)   { r.n++ }
+func (r *CountReducer) Result() any { return r.n }
+
+// SumReducer складывает числа; итог — int64, при переполнении — по модулю
+// 2^64.
+type SumReducer struct {
+	sum int64
+}
+
+// NewSumReducer создаёт свёртку, складывающую числа.
+func NewSumReducer() *SumReducer {
+	return &SumReducer{}
+}
+
+func (r *SumReducer) Add(v int64) { r.sum += v }
+func (r *SumReducer) Result() any { return r.sum }
+
+// MinMax — наименьшее и наибольшее число, итог MinMaxReducer.
+type MinMax struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+// MinMaxReducer находит наименьшее и наибольшее число; итог — MinMax или
+// nil, если чисел не было.
+type MinMaxReducer struct {
+	mm   MinMax
+	seen bool
+}
+
+// NewMinMaxReducer создаёт свёртку, находящую наименьшее и наибольшее
+// число.
+func NewMinMaxReducer() *MinMaxReducer {
+	return &MinMaxReducer{}
+}
+
+func (r *MinMaxReducer) Add(v int64) {
+	if !r.seen {
+		r.mm, r.seen = MinMax{Min: v, Max: v}, true
+		return
+	}
+	r.mm.Min = min(r.mm.Min, v)
+	r.mm.Max = max(r.mm.Max, v)
+}
+
+func (r *MinMaxReducer) Result() any {
+	if !r.seen {
+		return nil
+	}
+	return r.mm
+}
+
+// MeanReducer находит среднее чисел; итог — float64 или nil, если чисел не
+// было. Сумма чисел считается без переполнения, как в Stats, а делится на
+// их количество только в Result, поэтому среднее точное до округления
+// итога.
+type MeanReducer struct {
+	n   int64
+	sum wideSum
+}
+
+// NewMeanReducer создаёт свёртку, находящую среднее чисел.
+func NewMeanReducer() *MeanReducer {
+	return &MeanReducer{}
+}
+
+func (r *MeanReducer) Add(v int64) {
+	r.n++
+	r.sum.addWide(wideSum{lo: v})
+}
+
+func (r *MeanReducer) Result() any {
+	if r.n == 0 {
+		return nil
+	}
+	mean, _ := new(big.Rat).SetFrac(r.sum.big(), big.NewInt(r.n)).Float64()
+	return mean
+}
+
+// ValueBucket — корзина гистограммы чисел: количество чисел, не больших Le
+// и больших границы предыдущей корзины.
+type ValueBucket struct {
+	Le    int64 `json:"le"`
+	Count int64 `json:"count"`
+}
+
+// HistogramReducer раскладывает числа по корзинам с заданными границами;
+// итог — []ValueBucket с последней корзиной до math.MaxInt64 для чисел
+// больше наибольшей границы.
+type HistogramReducer struct {
+	bounds []int64
+	counts []int64
+}
+
+// NewHistogramReducer создаёт гистограмму чисел с верхними границами
+// корзин bounds по возрастанию; без границ — степени десяти от 1 до 10^18.
+func NewHistogramReducer(bounds ...int64) (*HistogramReducer, error) {
+	if len(bounds) == 0 {
+		// следующая степень десяти переполнила бы int64
+		for b := int64(1); ; b *= 10 {
+			bounds = append(bounds, b)
+			if b > math.MaxInt64/10 {
+				break
+			}
+		}
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return nil, fmt.Errorf("границы корзин гистограммы должны возрастать: %d после %d", bounds[i], bounds[i-1])
+		}
+	}
+	if bounds[len(bounds)-1] != math.MaxInt64 {
+		bounds = append(slices.Clip(bounds), math.MaxInt64)
+	}
+	return &HistogramReducer{bounds: bounds, counts: make([]int64, len(bounds))}, nil
+}
+
+func (r *HistogramReducer) Add(v int64) {
+	i, _ := slices.BinarySearch(r.bounds, v)
+	r.counts[i]++
+}
+
+func (r *HistogramReducer) Result() any {
+	buckets := make([]ValueBucket, len(r.bounds))
+	for i, b := range r.bounds {
+		buckets[i] = ValueBucket{Le: b, Count: r.counts[i]}
+	}
+	return buckets
+}
+
+// TopKReducer оставляет k наибольших чисел; итог — []int64 по убыванию.
+// Память ограничена k числами.
+type TopKReducer struct {
+	k   int
+	top int64Heap // наибольшие числа с наименьшим из них в корне
+}
+
+// NewTopKReducer создаёт свёртку, оставляющую k наибольших чисел.
+func NewTopKReducer(k int) (*TopKReducer, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("количество наибольших чисел должно быть положительным: %d", k)
+	}
+	return &TopKReducer{k: k, top: make(int64Heap, 0, k)}, nil
+}
+
+func (r *TopKReducer) Add(v int64) {
+	if len(r.top) < r.k {
+		heap.Push(&r.top, v)
+		return
+	}
+	if v > r.top[0] {
+		r.top[0] = v
+		heap.Fix(&r.top, 0)
+	}
+}
+
+func (r *TopKReducer) Result() any {
+	top := slices.Clone([]int64(r.top))
+	slices.Sort(top)
+	slices.Reverse(top)
+	return top
+}
+
+// int64Heap реализует heap.Interface с наименьшим числом в корне.
+type int64Heap []int64
+
+func (h int64Heap) Len() int           { return len(h) }
+func (h int64Heap) Less(i, j int) bool { return h[i] < h[j] }
+func (h int64Heap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *int64Heap) Push(x any)        { *h = append(*h, x.(int64)) }
+func (h *int64Heap) Pop() any {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// reducerChunk — сколько чисел разветвитель reducerTee передаёт свёрткам
+// за раз.
+const reducerChunk = 256
+
+// reducerTee — разветвитель чисел результирующего канала на свёртки: числа
+// собираются в пачки, и каждая пачка передаётся всем свёрткам, каждой в её
+// горутину. Медленная свёртка задерживает остальные, только когда
+// заполнен её буфер. Вызовы add не пересекаются.
+type reducerTee struct {
+	reducers []Reducer
+	ins      []chan []int64
+	done     []chan struct{}
+	chunk    []int64
+	abort    <-chan struct{} // прерывает передачу и ожидание свёрток
+}
+
+// startReducers запускает горутины свёрток reducers в группе g; паника
+// свёртки передаётся в fail и останавливает конвейер. Закрытие abort
+// прерывает передачу чисел свёрткам и ожидание их итогов. nil, если
+// свёрток нет.
+func startReducers(g *group, reducers []Reducer, fail func(error), abort <-chan struct{}) *reducerTee {
+	if len(reducers) == 0 {
+		return nil
+	}
+	t := &reducerTee{reducers: reducers, abort: abort}
+	for i, r := range reducers {
+		in, done := make(chan []int64, 4), make(chan struct{})
+		t.ins, t.done = append(t.ins, in), append(t.done, done)
+		g.Go(fmt.Sprintf("свёртка %d", i), func() error {
+			// итог прерванной свёртки неполон, и done не закрывается
+			if reduceChunks(r, in, fail, abort) {
+				close(done)
+			}
+			return nil
+		})
+	}
+	return t
+}
+
+// reduceChunks передаёт числа пачек in в свёртку r до закрытия in или
+// abort и сообщает, дочитан ли in. После паники r пачки только
+// вычитываются, чтобы не задерживать разветвитель.
+func reduceChunks(r Reducer, in <-chan []int64, fail func(error), abort <-chan struct{}) (complete bool) {
+	err := protect(func() error {
+		for {
+			var chunk []int64
+			select {
+			case c, ok := <-in:
+				if !ok {
+					complete = true
+					return nil
+				}
+				chunk = c
+			case <-abort:
+				return nil
+			}
+			for _, v := range chunk {
+				// медленная свёртка прерывается и посреди пачки
+				select {
+				case <-abort:
+					return nil
+				default:
+				}
+				r.Add(v)
+			}
+		}
+	})
+	if err != nil {
+		fail(fmt.Errorf("свёртка: %w", err))
+		for range in {
+		}
+		return true
+	}
+	return complete
+}
+
+// add передаёт v свёрткам.
+func (t *reducerTee) add(v int64) {
+	if t == nil {
+		return
+	}
+	t.chunk = append(t.chunk, v)
+	if len(t.chunk) == reducerChunk {
+		t.send()
+	}
+}
+
+// send передаёт накопленную пачку всем свёрткам; пачка не меняется после
+// отправки, поэтому свёртки читают её одновременно.
+func (t *reducerTee) send() {
+	if len(t.chunk) == 0 {
+		return
+	}
+	for _, in := range t.ins {
+		select {
+		case in <- t.chunk:
+		case <-t.abort:
+		}
+	}
+	t.chunk = make([]int64, 0, reducerChunk)
+}
+
+// close передаёт свёрткам оставшиеся числа, дожидается их и возвращает
+// итоги в порядке свёрток. Свёртка, не завершившаяся до закрытия stop или
+// abort, получает итог nil.
+func (t *reducerTee) close(stop <-chan struct{}) []any {
+	if t == nil {
+		return nil
+	}
+	t.send()
+	for _, in := range t.ins {
+		close(in)
+	}
+	results := make([]any, len(t.reducers))
+	for i, r := range t.reducers {
+		if doneBefore(t.done[i], stop, t.abort) {
+			results[i] = r.Result()
+		}
+	}
+	return results
+}
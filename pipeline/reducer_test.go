@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// reduce передаёт свёртке r числа values и возвращает её итог.
+func reduce(r Reducer, values ...int64) any {
+	for _, v := range values {
+		r.Add(v)
+	}
+	return r.Result()
+}
+
+func TestReducers(t *testing.T) {
+	topK := func(k int) Reducer {
+		r, err := NewTopKReducer(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	tests := []struct {
+		name   string
+		r      Reducer
+		values []int64
+		want   any
+	}{
+		{"count пусто", NewCountReducer(), nil, int64(0)},
+		{"count", NewCountReducer(), ints(1, 300), int64(300)},
+		{"sum", NewSumReducer(), ints(1, 300), int64(45150)},
+		{"minmax пусто", NewMinMaxReducer(), nil, nil},
+		{"minmax", NewMinMaxReducer(), []int64{5, -3, 8, 0}, MinMax{Min: -3, Max: 8}},
+		{"mean пусто", NewMeanReducer(), nil, nil},
+		{"mean", NewMeanReducer(), ints(1, 300), 150.5},
+		{"mean отрицательные", NewMeanReducer(), []int64{-1, -2, -4}, -7.0 / 3},
+		{"mean без переполнения", NewMeanReducer(), []int64{math.MaxInt64, 1}, float64(1 << 62)},
+		{"mean снизу", NewMeanReducer(), []int64{math.MinInt64, math.MinInt64}, float64(math.MinInt64)},
+		{"top", topK(3), []int64{4, 9, 1, 7, 9, 2}, []int64{9, 9, 7}},
+		{"top меньше k", topK(5), []int64{2, 1}, []int64{2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reduce(tt.r, tt.values...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Result = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramReducer(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds []int64
+		values []int64
+		want   []ValueBucket
+	}{
+		{"границы", []int64{0, 10, 100}, []int64{-5, 0, 1, 10, 11, 100, 101, math.MaxInt64}, []ValueBucket{
+			{Le: 0, Count: 2}, {Le: 10, Count: 2}, {Le: 100, Count: 2}, {Le: math.MaxInt64, Count: 2},
+		}},
+		{"последняя граница — MaxInt64", []int64{5, math.MaxInt64}, []int64{5, 6}, []ValueBucket{
+			{Le: 5, Count: 1}, {Le: math.MaxInt64, Count: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewHistogramReducer(tt.bounds...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reduce(r, tt.values...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHistogramReducerDefault проверяет границы по умолчанию: степени
+// десяти от 1 до 10^18 и MaxInt64.
+func TestHistogramReducerDefault(t *testing.T) {
+	r, err := NewHistogramReducer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buckets := reduce(r, 0, 1, 2, 1e18, 1e18+1, math.MaxInt64).([]ValueBucket)
+	if len(buckets) != 20 {
+		t.Fatalf("%d корзин, want 20: %v", len(buckets), buckets)
+	}
+	b := int64(1)
+	for i, bucket := range buckets[:19] {
+		if bucket.Le != b {
+			t.Errorf("граница %d = %d, want %d", i, bucket.Le, b)
+		}
+		b *= 10
+	}
+	counts := map[int]int64{0: 2, 1: 1, 18: 1, 19: 2}
+	for i, bucket := range buckets {
+		if bucket.Count != counts[i] {
+			t.Errorf("корзина %d (le %d): %d чисел, want %d", i, bucket.Le, bucket.Count, counts[i])
+		}
+	}
+}
+
+func TestReducerErrors(t *testing.T) {
+	if _, err := NewHistogramReducer(1, 1); err == nil {
+		t.Error("NewHistogramReducer принимает повторную границу")
+	}
+	if _, err := NewHistogramReducer(10, 5); err == nil {
+		t.Error("NewHistogramReducer принимает убывающие границы")
+	}
+	if _, err := NewTopKReducer(0); err == nil {
+		t.Error("NewTopKReducer принимает k = 0")
+	}
+}
+
+// panicReducer — свёртка, которая паникует на первом числе.
+type panicReducer struct{}
+
+func (panicReducer) Add(int64)   { panic("сбой свёртки") }
+func (panicReducer) Result() any { return nil }
+
+// TestRunReducers проверяет, что свёртки получают все числа
+// результирующего канала и в Run, и в RunBatched, а паника свёртки
+// останавливает конвейер.
+func TestRunReducers(t *testing.T) {
+	runs := map[string]func(*Pipeline) (Result, error){
+		"Run":        func(p *Pipeline) (Result, error) { return p.Run(context.Background()) },
+		"RunBatched": func(p *Pipeline) (Result, error) { return p.RunBatched(context.Background(), 16, 0) },
+	}
+	for name, run := range runs {
+		t.Run(name, func(t *testing.T) {
+			sum := NewSumReducer()
+			top, err := NewTopKReducer(3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := run(New(Config{NumWorkers: 3, Limit: 1000, Reducers: []Reducer{sum, top}}))
+			if err != nil {
+				t.Fatalf("%s = %v", name, err)
+			}
+			want := []any{int64(500500), []int64{1000, 999, 998}}
+			if !reflect.DeepEqual(res.Reduced, want) {
+				t.Errorf("Reduced = %v, want %v", res.Reduced, want)
+			}
+
+			_, err = run(New(Config{NumWorkers: 2, Limit: 100, Reducers: []Reducer{panicReducer{}}}))
+			var pe *PanicError
+			if !errors.As(err, &pe) {
+				t.Errorf("%s с паникой свёртки = %v, want *PanicError", name, err)
+			}
+		})
+	}
+}
+
+// slowReducer — свёртка, которая учитывает каждое число долго.
+type slowReducer struct{ n int64 }
+
+func (r *slowReducer) Add(int64) {
+	time.Sleep(5 * time.Millisecond)
+	r.n++
+}
+
+func (r *slowReducer) Result() any { return r.n }
+
+// TestRunReducersCancel проверяет, что отмена ctx прерывает ожидание
+// медленной свёртки и без LeakTimeout: Run не дожидается, пока свёртка
+// учтёт все числа, а итог свёртки — nil.
+func TestRunReducersCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res, _ := Run(ctx, Config{NumWorkers: 2, Reducers: []Reducer{&slowReducer{}}})
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Run завершился через %v после отмены", d)
+	}
+	if len(res.Reduced) != 1 || res.Reduced[0] != nil {
+		t.Errorf("Reduced = %v, want [<nil>]", res.Reduced)
+	}
+}
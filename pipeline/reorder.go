@@ -0,0 +1,298 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ReorderPolicy — что делает OrderedMerge, когда maxBuffer чисел ждут
+// своей очереди, потому что один из каналов отстал, или Run при
+// Config.Ordered, когда отстал один из обработчиков.
+type ReorderPolicy int
+
+const (
+	// ReorderBlock — задержать каналы, обогнавшие отставший на maxBuffer
+	// чисел: порядок сохраняется, а память буфера ограничена, но
+	// производительность падает до скорости самого медленного обработчика,
+	// и задержка каждого числа растёт на время ожидания. Каждое число
+	// последовательности должно прийти, иначе обогнавшие каналы ждут его
+	// вечно
+	ReorderBlock ReorderPolicy = iota
+	// ReorderFail — остановиться с ErrReorderOverflow: числа, пришедшие
+	// после переполнения, дочитываются и отбрасываются
+	ReorderFail
+)
+
+// ErrReorderOverflow — ошибка OrderedMerge при ReorderFail: в буфере
+// восстановления порядка не осталось места.
+var ErrReorderOverflow = errors.New("переполнен буфер восстановления порядка")
+
+// OrderedMerge собирает числа последовательности 1, 2, 3 и т.д., как у
+// Generator, из каналов ins в канал out строго по возрастанию: число,
+// обогнавшее предыдущие, ждёт их в буфере. Не больше maxBuffer чисел ждут
+// своей очереди, а переполнение буфера разрешается политикой policy; при
+// ReorderBlock в каждом канале ins числа должны идти по возрастанию, как
+// после Worker, читающих общий канал Generator. Числа, которых так и не было, не
+// ждутся после закрытия всех ins: оставшиеся в буфере числа выдаются по
+// возрастанию.
+// Параметры
+// out - канал, куда будут записаны числа; закрывается перед возвратом
+// maxBuffer - сколько чисел может ждать своей очереди
+// policy - что делать при заполнении буфера
+// ins - каналы, откуда будут прочитаны числа
+// Возвращается после закрытия всех каналов ins; при ReorderFail и
+// переполнении — с ошибкой ErrReorderOverflow.
+func OrderedMerge(out chan<- int64, maxBuffer int, policy ReorderPolicy, ins ...<-chan int64) error {
+	defer close(out) // перед выходом из функции закрываем канал out
+
+	if maxBuffer < 1 {
+		return fmt.Errorf("размер буфера восстановления порядка должен быть положительным: %d", maxBuffer)
+	}
+
+	var (
+		mu   sync.Mutex
+		next int64 = 1 // число, которое выдаётся следующим
+		// advanced закрывается и заменяется новым, когда next растёт
+		advanced = make(chan struct{})
+	)
+	// fits ждёт, пока число v поместится в буфер, когда policy — ReorderBlock
+	fits := func(v int64) {
+		for policy == ReorderBlock {
+			mu.Lock()
+			// числа от next+1 до v-1 могут ждать в буфере
+			if v-next < int64(maxBuffer) {
+				mu.Unlock()
+				return
+			}
+			ch := advanced
+			mu.Unlock()
+			<-ch
+		}
+	}
+
+	// arrived — числа всех каналов ins в порядке прихода
+	arrived := make(chan int64)
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	for _, in := range ins {
+		go func(in <-chan int64) {
+			defer wg.Done()
+			for v := range in {
+				fits(v)
+				arrived <- v
+			}
+		}(in)
+	}
+	go func() {
+		wg.Wait()
+		close(arrived)
+	}()
+
+	pending := make(map[int64]struct{}) // числа, ждущие своей очереди
+	var err error
+	for v := range arrived {
+		if err != nil {
+			continue
+		}
+		pending[v] = struct{}{}
+		for {
+			if _, ok := pending[next]; !ok {
+				break
+			}
+			delete(pending, next)
+			out <- next
+			mu.Lock()
+			next++
+			close(advanced)
+			advanced = make(chan struct{})
+			mu.Unlock()
+		}
+		if len(pending) > maxBuffer {
+			err = fmt.Errorf("%w: ждут очереди %d чисел, не пришло число %d", ErrReorderOverflow, len(pending), next)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// каналы закрыты: недостающих чисел уже не будет
+	rest := make([]int64, 0, len(pending))
+	for v := range pending {
+		rest = append(rest, v)
+	}
+	slices.Sort(rest)
+	for _, v := range rest {
+		out <- v
+	}
+	return nil
+}
+
+// reorderBuffer восстанавливает порядок генерации чисел в Run при
+// Config.Ordered: числа выдаются строго по возрастанию Event.Seq. Номера
+// отброшенных и отфильтрованных чисел отмечаются методом discard, чтобы не
+// ждать их. Если window больше 0, своей очереди ждут не больше window
+// чисел: при ReorderBlock обработчики, обогнавшие отставший, ждут в wait,
+// а при ReorderFail переполнение передаётся в fail, после чего недостающие
+// номера пропускаются и выдаётся число с наименьшим номером, а опоздавшие
+// числа выдаются сразу по приходу, нарушая порядок.
+type reorderBuffer struct {
+	mu      sync.Mutex
+	next    int64              // номер следующего выдаваемого числа
+	window  int                // максимальное количество ожидающих чисел; 0 — без ограничения
+	policy  ReorderPolicy      // что делать при переполнении окна
+	pending map[int64]Event    // пришедшие числа, ожидающие своей очереди
+	gone    map[int64]struct{} // номера чисел, которые не придут
+	emit    func(Event)        // получает числа в порядке генерации
+	fail    func(error)        // получает ErrReorderOverflow при ReorderFail
+	failed  bool               // ошибка переполнения уже передана в fail
+	// advanced закрывается и заменяется новым, когда next растёт
+	advanced chan struct{}
+}
+
+// newReorderBuffer создаёт буфер, передающий числа в emit по порядку.
+// Вызовы emit не пересекаются.
+func newReorderBuffer(window int, policy ReorderPolicy, emit func(Event), fail func(error)) *reorderBuffer {
+	return &reorderBuffer{
+		next:     1,
+		window:   window,
+		policy:   policy,
+		pending:  make(map[int64]Event),
+		gone:     make(map[int64]struct{}),
+		emit:     emit,
+		fail:     fail,
+		advanced: make(chan struct{}),
+	}
+}
+
+// wait ждёт, пока число с номером seq поместится в окно, и возвращает
+// ошибку ctx, если он отменён раньше. Без ограничения окна или при
+// ReorderFail не ждёт.
+func (b *reorderBuffer) wait(ctx context.Context, seq int64) error {
+	if b.window <= 0 || b.policy != ReorderBlock {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		// числа с номерами от next до seq-1 могут ждать в буфере
+		if seq-b.next < int64(b.window) {
+			b.mu.Unlock()
+			return nil
+		}
+		advanced := b.advanced
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-advanced:
+		}
+	}
+}
+
+// gate оборачивает обработку process так, что обработанное число ждёт
+// места в окне.
+func (b *reorderBuffer) gate(process func(context.Context, Event) (Event, error)) func(context.Context, Event) (Event, error) {
+	return func(ctx context.Context, e Event) (Event, error) {
+		e, err := process(ctx, e)
+		if err != nil {
+			return e, err
+		}
+		return e, b.wait(ctx, e.Seq)
+	}
+}
+
+// push добавляет пришедшее число и выдаёт все числа, чья очередь наступила.
+func (b *reorderBuffer) push(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e.Seq < b.next {
+		// номер уже пропущен из-за переполнения окна
+		b.emit(e)
+		return
+	}
+	b.pending[e.Seq] = e
+	b.release()
+}
+
+// discard отмечает, что число с номером seq не придёт.
+func (b *reorderBuffer) discard(seq int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if seq < b.next {
+		return
+	}
+	b.gone[seq] = struct{}{}
+	b.release()
+}
+
+// flush выдаёт все оставшиеся числа по порядку, пропуская недостающие
+// номера. Вызывается, когда новых чисел больше не будет.
+func (b *reorderBuffer) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	seqs := make([]int64, 0, len(b.pending))
+	for seq := range b.pending {
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	for _, seq := range seqs {
+		b.emit(b.pending[seq])
+		delete(b.pending, seq)
+		b.next = seq + 1
+	}
+	clear(b.gone)
+	b.advance()
+}
+
+// advance будит обработчики, ждущие в wait. Вызывается с захваченным b.mu.
+func (b *reorderBuffer) advance() {
+	close(b.advanced)
+	b.advanced = make(chan struct{})
+}
+
+// release выдаёт числа, начиная с b.next, пока очередь не упрётся в
+// недостающий номер, а при переполнении окна пропускает недостающие номера.
+// Вызывается с захваченным b.mu.
+func (b *reorderBuffer) release() {
+	next := b.next
+	defer func() {
+		if b.next != next {
+			b.advance()
+		}
+	}()
+	for {
+		if e, ok := b.pending[b.next]; ok {
+			delete(b.pending, b.next)
+			b.emit(e)
+			b.next++
+			continue
+		}
+		if _, ok := b.gone[b.next]; ok {
+			delete(b.gone, b.next)
+			b.next++
+			continue
+		}
+		if b.window <= 0 || len(b.pending) <= b.window || b.policy == ReorderBlock {
+			return
+		}
+		if !b.failed {
+			b.failed = true
+			b.fail(fmt.Errorf("%w: ждут очереди %d чисел, не пришло число %d", ErrReorderOverflow, len(b.pending), b.next))
+		}
+		// окно переполнено: переходим к наименьшему пришедшему номеру
+		lowest := int64(-1)
+		for seq := range b.pending {
+			if lowest < 0 || seq < lowest {
+				lowest = seq
+			}
+		}
+		for seq := range b.gone {
+			if seq < lowest {
+				delete(b.gone, seq)
+			}
+		}
+		b.next = lowest
+	}
+}
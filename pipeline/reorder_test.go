@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestOrderedMerge проверяет восстановление порядка и политики
+// переполнения буфера, когда канал с числом 1 отстаёт от остальных.
+func TestOrderedMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxBuffer int
+		ins       [][]int64 // числа каждого канала в порядке отправки
+		want      []int64
+		wantErr   error
+	}{
+		{"без переполнения", 3, [][]int64{{2, 3, 1, 4}}, []int64{1, 2, 3, 4}, nil},
+		{"несколько каналов", 3, [][]int64{{1, 3, 5}, {2, 4, 6}}, []int64{1, 2, 3, 4, 5, 6}, nil},
+		{"недостающее число", 3, [][]int64{{3, 4, 1}}, []int64{1, 3, 4}, nil},
+		{"переполнение", 3, [][]int64{{2, 3, 4, 5, 1}}, nil, ErrReorderOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ins := make([]<-chan int64, len(tt.ins))
+			for i, vs := range tt.ins {
+				in := make(chan int64, len(vs))
+				for _, v := range vs {
+					in <- v
+				}
+				close(in)
+				ins[i] = in
+			}
+			out := make(chan int64, 10)
+			err := OrderedMerge(out, tt.maxBuffer, ReorderFail, ins...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OrderedMerge = %v, want %v", err, tt.wantErr)
+			}
+			if got := collect(out); err == nil && !slices.Equal(got, tt.want) {
+				t.Errorf("выдано %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("ожидание", func(t *testing.T) {
+		const maxBuffer = 3
+		fast, lag := make(chan int64), make(chan int64)
+		var sent atomic.Int64 // сколько чисел принял обогнавший канал
+		go func() {
+			for v := int64(2); v <= 10; v++ {
+				fast <- v
+				sent.Add(1)
+			}
+			close(fast)
+		}()
+		out := make(chan int64, 10)
+		done := make(chan error)
+		go func() { done <- OrderedMerge(out, maxBuffer, ReorderBlock, fast, lag) }()
+
+		// числа 2 и 3 ждут в буфере, а число 4 задерживается до прихода 1
+		time.Sleep(20 * time.Millisecond)
+		if n := sent.Load(); n > maxBuffer {
+			t.Errorf("до прихода числа 1 принято %d чисел, want не больше %d", n, maxBuffer)
+		}
+		if len(out) != 0 {
+			t.Errorf("до прихода числа 1 выдано %d чисел", len(out))
+		}
+		lag <- 1
+		close(lag)
+		if err := <-done; err != nil {
+			t.Fatalf("OrderedMerge = %v", err)
+		}
+		if got, want := collect(out), []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !slices.Equal(got, want) {
+			t.Errorf("выдано %v, want %v", got, want)
+		}
+	})
+
+	if err := OrderedMerge(make(chan int64), 0, ReorderBlock); err == nil {
+		t.Error("OrderedMerge с пустым буфером без ошибки")
+	}
+}
+
+func TestReorderBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   int
+		policy   ReorderPolicy
+		push     []int64 // номера пришедших чисел; отрицательный — отброшенное число
+		want     []int64
+		wantFail bool
+	}{
+		{"по порядку", 0, ReorderBlock, []int64{2, 3, 1}, []int64{1, 2, 3}, false},
+		{"отброшенное число", 0, ReorderBlock, []int64{3, -2, 1}, []int64{1, 3}, false},
+		{"окно не переполнено", 2, ReorderFail, []int64{2, 3, 1}, []int64{1, 2, 3}, false},
+		{"переполнение — ошибка", 2, ReorderFail, []int64{2, 3, 4, 1}, []int64{2, 3, 4, 1}, true},
+		{"ожидание не пропускает", 2, ReorderBlock, []int64{2, 3, 4, 1}, []int64{1, 2, 3, 4}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			var failed []error
+			b := newReorderBuffer(tt.window, tt.policy, func(e Event) { got = append(got, e.Seq) }, func(err error) { failed = append(failed, err) })
+			for _, seq := range tt.push {
+				if seq < 0 {
+					b.discard(-seq)
+				} else {
+					b.push(Event{Seq: seq})
+				}
+			}
+			b.flush()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("выдано %v, want %v", got, tt.want)
+			}
+			if tt.wantFail != (len(failed) == 1) || len(failed) > 1 || tt.wantFail && !errors.Is(failed[0], ErrReorderOverflow) {
+				t.Errorf("ошибки %v, wantFail %v", failed, tt.wantFail)
+			}
+		})
+	}
+}
+
+func TestReorderBufferWait(t *testing.T) {
+	b := newReorderBuffer(2, ReorderBlock, func(Event) {}, nil)
+	// числа 1 и 2 помещаются в окно от номера 1
+	if err := b.wait(context.Background(), 2); err != nil {
+		t.Fatalf("wait(2) = %v", err)
+	}
+	waited := make(chan error)
+	go func() { waited <- b.wait(context.Background(), 3) }()
+	select {
+	case err := <-waited:
+		t.Fatalf("wait(3) = %v до прихода числа 1", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.push(Event{Seq: 1})
+	if err := <-waited; err != nil {
+		t.Errorf("wait(3) после прихода числа 1 = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.wait(ctx, 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait после отмены = %v, want context.Canceled", err)
+	}
+}
+
+// TestRunOrdered проверяет, что при Ordered Collect получает числа по
+// возрастанию, когда обработчик числа 1 отстаёт от остальных, а
+// отфильтрованные числа не задерживают очередь.
+func TestRunOrdered(t *testing.T) {
+	lagFirst := func(_ context.Context, v int64) (int64, error) {
+		if v == 1 {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if v%3 == 0 {
+			return v, ErrSkip
+		}
+		return v, nil
+	}
+	tests := []struct {
+		name    string
+		window  int
+		policy  ReorderPolicy
+		wantErr error
+	}{
+		{"без ограничения", 0, ReorderBlock, nil},
+		{"ожидание", 8, ReorderBlock, nil},
+		{"ошибка", 8, ReorderFail, ErrReorderOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			cfg := Config{NumWorkers: 4, Limit: 200, Process: lagFirst, Ordered: true, ReorderWindow: tt.window, ReorderOverflow: tt.policy,
+				Collect: func(v int64) error {
+					got = append(got, v)
+					return nil
+				}}
+			res, err := Run(context.Background(), cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.IsSorted(got) || int64(len(got)) != res.OutputCount || res.OutputCount != 134 {
+				t.Errorf("Collect получил %d чисел из %d, упорядочено %v; want 134 по возрастанию", len(got), res.OutputCount, slices.IsSorted(got))
+			}
+		})
+	}
+}
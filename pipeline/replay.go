@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ReplayRecorder записывает ход запуска, чтобы его можно было
+// воспроизвести: числа в порядке их отправки обработчикам и обработчик,
+// которому досталось каждое число. Методы вызываются конкурентно.
+type ReplayRecorder interface {
+	// RecordValue записывает, что число value с номером seq отправлено
+	// обработчикам.
+	RecordValue(seq, value int64)
+	// RecordWorker записывает, что число с номером seq обрабатывает
+	// обработчик worker. При повторах обработки вызывается для каждой
+	// попытки.
+	RecordWorker(seq int64, worker int)
+}
+
+// replayRecord — строка файла ReplayFile: количество обработчиков в первой
+// строке, затем числа и обработчики, которым они достались.
+type replayRecord struct {
+	Workers int    `json:"workers,omitempty"`
+	Seq     int64  `json:"seq,omitempty"`
+	Value   *int64 `json:"value,omitempty"`
+	Worker  *int   `json:"worker,omitempty"`
+}
+
+// ReplayFile — ReplayRecorder, записывающий ход запуска в файл по одному
+// JSON-объекту в строке: {"workers":..} в первой строке, {"seq":..,
+// "value":..} для каждого сгенерированного числа и {"seq":..,"worker":..}
+// для каждого взятого в обработку. Файл читается LoadReplay. Безопасен для
+// конкурентного использования.
+type ReplayFile struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	err  error // первая ошибка записи
+}
+
+// CreateReplayFile создаёт файл path для записи хода запуска с workers
+// обработчиками, а если он существует — очищает его.
+func CreateReplayFile(path string, workers int) (*ReplayFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	r := &ReplayFile{file: f, w: w, enc: json.NewEncoder(w)}
+	r.write(replayRecord{Workers: workers})
+	return r, nil
+}
+
+// RecordValue записывает сгенерированное число.
+func (r *ReplayFile) RecordValue(seq, value int64) {
+	r.write(replayRecord{Seq: seq, Value: &value})
+}
+
+// RecordWorker записывает обработчика числа.
+func (r *ReplayFile) RecordWorker(seq int64, worker int) {
+	r.write(replayRecord{Seq: seq, Worker: &worker})
+}
+
+// write дописывает строку rec, запоминая первую ошибку.
+func (r *ReplayFile) write(rec replayRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(rec); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Close дописывает буфер и закрывает файл. Возвращает и первую ошибку
+// записи.
+func (r *ReplayFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.err
+	if ferr := r.w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Replay — ход запуска, записанный ReplayFile.
+type Replay struct {
+	Workers int     // количество обработчиков
+	Values  []int64 // числа в порядке отправки обработчикам
+	// Assign — обработчик каждого числа Values; -1, если число не попало
+	// в обработку, например было отброшено при остановке
+	Assign []int
+}
+
+// LoadReplay читает ход запуска из файла path, записанного ReplayFile.
+func LoadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &Replay{}
+	var seqs []int64               // номера чисел Values
+	workers := make(map[int64]int) // обработчики по номерам чисел
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec replayRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s, строка %d: %w", path, line, err)
+		}
+		switch {
+		case rec.Workers > 0:
+			r.Workers = rec.Workers
+		case rec.Value != nil:
+			seqs = append(seqs, rec.Seq)
+			r.Values = append(r.Values, *rec.Value)
+		case rec.Worker != nil:
+			// при повторах обработки обработчик записан несколько раз, а
+			// взять число в обработку он может раньше, чем оно записано
+			if _, ok := workers[rec.Seq]; !ok {
+				workers[rec.Seq] = *rec.Worker
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.Assign = make([]int, len(seqs))
+	for i, seq := range seqs {
+		w, ok := workers[seq]
+		if !ok {
+			w = -1
+		}
+		r.Assign[i] = w
+	}
+	if r.Workers < 1 {
+		return nil, fmt.Errorf("%s: не записано количество обработчиков", path)
+	}
+	return r, nil
+}
+
+// Source возвращает источник, выдающий числа Values по порядку.
+func (r *Replay) Source() Source[int64] {
+	var i int
+	return SourceFunc[int64](func(context.Context) (int64, bool) {
+		if i >= len(r.Values) {
+			return 0, false
+		}
+		i++
+		return r.Values[i-1], true
+	})
+}
+
+// Distributor возвращает Distributor, который отдаёт i-е значение
+// обработчику Assign[i], поэтому числа Source попадают к тем же
+// обработчикам, что и в записанном запуске. Число, не попавшее в обработку
+// при записи, или число сверх записанных отдаётся любому готовому
+// обработчику. Размер буфера каналов обработчиков равен буферу in.
+func (r *Replay) Distributor() Distributor[Event] {
+	return distributor[Event]{pick: func(outs []chan Event, sent int64) int {
+		if sent >= int64(len(r.Assign)) || r.Assign[sent] >= len(outs) {
+			return -1
+		}
+		return r.Assign[sent]
+	}}
+}
+
+// recordWorker оборачивает обработку process обработчика worker так, что
+// перед обработкой каждого числа его обработчик записывается в rec.
+func recordWorker(rec ReplayRecorder, worker int, process func(context.Context, Event) (Event, error)) func(context.Context, Event) (Event, error) {
+	return func(ctx context.Context, e Event) (Event, error) {
+		rec.RecordWorker(e.Seq, worker)
+		return process(ctx, e)
+	}
+}
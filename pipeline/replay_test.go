@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReplay проверяет, что запуск, воспроизведённый по записи, получает
+// те же числа в том же порядке и раздаёт их тем же обработчикам.
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	record := func(path string, cfg Config) *Replay {
+		t.Helper()
+		rec, err := CreateReplayFile(path, cfg.NumWorkers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Record = rec
+		res, err := Run(context.Background(), cfg)
+		if err := rec.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := res.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		replay, err := LoadReplay(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return replay
+	}
+	orig := record(filepath.Join(dir, "orig.jsonl"), Config{
+		NumWorkers:  3,
+		Limit:       200,
+		Source:      Random(7, 1000),
+		WorkerDelay: 10 * time.Microsecond,
+	})
+	if orig.Workers != 3 || len(orig.Values) != 200 || slices.Contains(orig.Assign, -1) {
+		t.Fatalf("запись: обработчиков %d, чисел %d, назначения %v", orig.Workers, len(orig.Values), orig.Assign)
+	}
+	again := record(filepath.Join(dir, "again.jsonl"), Config{
+		NumWorkers:  orig.Workers,
+		Source:      orig.Source(),
+		Distributor: orig.Distributor(),
+	})
+	if !slices.Equal(again.Values, orig.Values) {
+		t.Error("воспроизведены другие числа")
+	}
+	if !slices.Equal(again.Assign, orig.Assign) {
+		t.Errorf("назначения обработчиков %v, want %v", again.Assign, orig.Assign)
+	}
+}
+
+func TestLoadReplayErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"без обработчиков", `{"seq":1,"value":5}` + "\n", "количество обработчиков"},
+		{"не json", "{\"workers\":2}\nне json\n", "строка 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rec.jsonl")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadReplay(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadReplay = %v, want ошибку %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestReplayNotProcessed проверяет, что число, не попавшее в обработку при
+// записи, отдаётся любому обработчику.
+func TestReplayNotProcessed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.jsonl")
+	data := `{"workers":2}
+{"seq":1,"value":10}
+{"seq":1,"worker":1}
+{"seq":2,"value":20}
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(r.Values, []int64{10, 20}) || !slices.Equal(r.Assign, []int{1, -1}) {
+		t.Errorf("числа %v, назначения %v", r.Values, r.Assign)
+	}
+}
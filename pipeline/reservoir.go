@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// ReservoirReducer собирает случайную выборку фиксированного размера из
+// потока чисел неизвестной длины (алгоритм A-Res Эфраимидиса — Спиракиса).
+// Каждому числу назначается ключ u^(1/w), где u — случайное число из
+// (0, 1], а w — вес числа; в выборке остаются size чисел с наибольшими
+// ключами. При одинаковых весах выборка равномерная. Безопасен для
+// конкурентного использования.
+type ReservoirReducer struct {
+	mu     sync.Mutex
+	size   int                 // максимальный размер выборки
+	weight func(int64) float64 // вес числа; nil — все веса равны 1
+	rnd    *rand.Rand          // источник случайных чисел
+	items  reservoirHeap       // выборка, упорядоченная по ключу (min-heap)
+}
+
+// NewReservoirReducer создаёт ReservoirReducer.
+// Параметры
+// size - максимальный размер выборки
+// weight - функция веса числа; nil означает равномерную выборку.
+// Числа с неположительным весом в выборку не попадают.
+// seed - начальное значение генератора случайных чисел; одинаковый seed и
+// одинаковый поток дают одинаковую выборку.
+func NewReservoirReducer(size int, weight func(int64) float64, seed int64) *ReservoirReducer {
+	if size < 0 {
+		size = 0
+	}
+	return &ReservoirReducer{
+		size:   size,
+		weight: weight,
+		rnd:    rand.New(rand.NewSource(seed)),
+		items:  make(reservoirHeap, 0, size),
+	}
+}
+
+// Add учитывает очередное число потока.
+func (r *ReservoirReducer) Add(v int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size == 0 {
+		return
+	}
+	w := 1.0
+	if r.weight != nil {
+		w = r.weight(v)
+	}
+	if !(w > 0) {
+		return
+	}
+	// Float64 возвращает числа из [0, 1): у нулевого ключа число не могло
+	// бы попасть в выборку, поэтому u берётся из (0, 1]
+	key := math.Pow(1-r.rnd.Float64(), 1/w)
+	if len(r.items) < r.size {
+		heap.Push(&r.items, reservoirItem{value: v, key: key})
+		return
+	}
+	if key > r.items[0].key {
+		r.items[0] = reservoirItem{value: v, key: key}
+		heap.Fix(&r.items, 0)
+	}
+}
+
+// Sample возвращает копию текущей выборки.
+func (r *ReservoirReducer) Sample() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sample := make([]int64, len(r.items))
+	for i, it := range r.items {
+		sample[i] = it.value
+	}
+	return sample
+}
+
+// Result возвращает выборку, как Sample, чтобы ReservoirReducer можно было
+// использовать как Reducer.
+func (r *ReservoirReducer) Result() any {
+	return r.Sample()
+}
+
+// reservoirItem — число выборки вместе с его случайным ключом.
+type reservoirItem struct {
+	value int64
+	key   float64
+}
+
+// reservoirHeap реализует heap.Interface с минимальным ключом в корне.
+type reservoirHeap []reservoirItem
+
+func (h reservoirHeap) Len() int           { return len(h) }
+func (h reservoirHeap) Less(i, j int) bool { return h[i].key < h[j].key }
+func (h reservoirHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *reservoirHeap) Push(x any)        { *h = append(*h, x.(reservoirItem)) }
+func (h *reservoirHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// sample передаёт values в r и возвращает его выборку.
+func sample(r *ReservoirReducer, values ...int64) []int64 {
+	for _, v := range values {
+		r.Add(v)
+	}
+	return r.Sample()
+}
+
+// TestReservoirReducer проверяет размер выборки, исключение чисел с
+// нулевым весом и повторяемость выборки при одинаковом seed.
+func TestReservoirReducer(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		weight func(int64) float64
+		values []int64
+		want   []int64 // nil — проверяется только размер выборки
+		len    int
+	}{
+		{"пустая выборка", 0, nil, ints(1, 10), []int64{}, 0},
+		{"отрицательный размер", -1, nil, ints(1, 10), []int64{}, 0},
+		{"поток короче выборки", 5, nil, ints(1, 3), ints(1, 3), 3},
+		{"поток длиннее выборки", 5, nil, ints(1, 100), nil, 5},
+		{"нулевые веса", 5, func(v int64) float64 { return float64(v % 2) }, ints(1, 6), []int64{1, 3, 5}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sample(NewReservoirReducer(tt.size, tt.weight, 1), tt.values...)
+			slices.Sort(got)
+			if len(got) != tt.len || tt.want != nil && !slices.Equal(got, tt.want) {
+				t.Errorf("Sample = %v, want %d чисел %v", got, tt.len, tt.want)
+			}
+			for _, v := range got {
+				if !slices.Contains(tt.values, v) {
+					t.Errorf("в выборке %v число %d не из потока", got, v)
+				}
+			}
+		})
+	}
+
+	a := sample(NewReservoirReducer(5, nil, 7), ints(1, 100)...)
+	b := sample(NewReservoirReducer(5, nil, 7), ints(1, 100)...)
+	if !slices.Equal(a, b) {
+		t.Errorf("с одинаковым seed выборки различаются: %v и %v", a, b)
+	}
+}
+
+// TestReservoirUniform проверяет критерием хи-квадрат, что при равных
+// весах каждое число потока попадает в выборку с вероятностью size/n.
+func TestReservoirUniform(t *testing.T) {
+	const (
+		n      = 20
+		size   = 5
+		trials = 20000
+		// критическое значение хи-квадрат для n-1 = 19 степеней свободы и
+		// уровня значимости 0.001
+		critical = 43.82
+	)
+	counts := make([]int, n)
+	for seed := int64(1); seed <= trials; seed++ {
+		for _, v := range sample(NewReservoirReducer(size, nil, seed), ints(1, n)...) {
+			counts[v-1]++
+		}
+	}
+	expected := float64(trials) * size / n
+	var chi2 float64
+	for _, c := range counts {
+		d := float64(c) - expected
+		chi2 += d * d / expected
+	}
+	if chi2 > critical {
+		t.Errorf("хи-квадрат %.2f больше %.2f: числа попадают в выборку неравномерно %v", chi2, critical, counts)
+	}
+}
+
+// TestReservoirWeighted проверяет критерием хи-квадрат, что выборка из
+// одного числа достаётся числу с вероятностью, пропорциональной его весу.
+func TestReservoirWeighted(t *testing.T) {
+	const (
+		n      = 10
+		trials = 20000
+		// критическое значение хи-квадрат для n-1 = 9 степеней свободы и
+		// уровня значимости 0.001
+		critical = 27.88
+	)
+	weight := func(v int64) float64 { return float64(v) }
+	counts := make([]int, n)
+	for seed := int64(1); seed <= trials; seed++ {
+		s := sample(NewReservoirReducer(1, weight, seed), ints(1, n)...)
+		counts[s[0]-1]++
+	}
+	var chi2 float64
+	for i, c := range counts {
+		// вес числа i+1 — i+1 из суммы весов n(n+1)/2
+		expected := float64(trials) * float64(i+1) / (n * (n + 1) / 2)
+		d := float64(c) - expected
+		chi2 += d * d / expected
+	}
+	if chi2 > critical {
+		t.Errorf("хи-квадрат %.2f больше %.2f: выборка не пропорциональна весам %v", chi2, critical, counts)
+	}
+}
+
+// zeroSource — источник случайных чисел, всегда возвращающий 0.
+type zeroSource struct{}
+
+func (zeroSource) Int63() int64 { return 0 }
+func (zeroSource) Seed(int64)   {}
+
+// TestReservoirZeroDraw проверяет, что нулевое случайное число не даёт
+// числу нулевого ключа, с которым оно не вытеснило бы ни одного другого.
+func TestReservoirZeroDraw(t *testing.T) {
+	r := NewReservoirReducer(1, nil, 1)
+	r.rnd = rand.New(zeroSource{})
+	r.Add(5)
+	if r.items[0].key != 1 {
+		t.Errorf("ключ %v, want 1", r.items[0].key)
+	}
+}
+
+// TestRunReservoir проверяет, что Run передаёт числа результирующего канала
+// в Config.Reservoir и возвращает его выборку.
+func TestRunReservoir(t *testing.T) {
+	stats, err := Run(context.Background(), Config{NumWorkers: 3, Limit: 100, Reservoir: NewReservoirReducer(10, nil, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Sample) != 10 {
+		t.Errorf("в выборке %d чисел, want 10", len(stats.Sample))
+	}
+	for _, v := range stats.Sample {
+		if v < 1 || v > 100 {
+			t.Errorf("в выборке %v число %d не из 1..100", stats.Sample, v)
+		}
+	}
+}
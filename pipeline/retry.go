@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RetryPolicy — повтор неудачной обработки значения в Worker. Нулевое
+// значение выключает повторы.
+type RetryPolicy struct {
+	Attempts int // сколько попыток всего, включая первую; 0 или 1 — без повторов
+	// Backoff — пауза перед первым повтором; каждая следующая вдвое
+	// длиннее предыдущей, но не длиннее MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration // наибольшая пауза; 0 — без ограничения
+	// Jitter — доля случайного отклонения паузы: пауза выбирается из
+	// [d*(1-Jitter), d*(1+Jitter)]; 0 — без отклонения
+	Jitter float64
+	// Retryable сообщает, стоит ли повторять обработку после ошибки err;
+	// nil — повторять после любой ошибки. ErrSkip, паника и ошибки после
+	// отмены контекста не повторяются никогда.
+	Retryable func(err error) bool
+}
+
+// enabled сообщает, включены ли повторы.
+func (r RetryPolicy) enabled() bool {
+	return r.Attempts > 1
+}
+
+// validate проверяет корректность настроек.
+func (r RetryPolicy) validate() error {
+	if r.Attempts < 0 {
+		return fmt.Errorf("количество попыток не может быть отрицательным: %d", r.Attempts)
+	}
+	if r.Backoff < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("пауза между попытками не может быть отрицательной: %v, %v", r.Backoff, r.MaxBackoff)
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return fmt.Errorf("доля отклонения паузы должна быть от 0 до 1: %v", r.Jitter)
+	}
+	return nil
+}
+
+// retryable сообщает, повторяется ли обработка после ошибки err.
+func (r RetryPolicy) retryable(ctx context.Context, err error) bool {
+	var panicErr *PanicError
+	if errors.Is(err, ErrSkip) || errors.As(err, &panicErr) || ctx.Err() != nil {
+		return false
+	}
+	return r.Retryable == nil || r.Retryable(err)
+}
+
+// backoff возвращает паузу перед повтором номер retry, начиная с 1.
+func (r RetryPolicy) backoff(retry int) time.Duration {
+	d := r.Backoff
+	for i := 1; i < retry && (r.MaxBackoff == 0 || d < r.MaxBackoff); i++ {
+		d *= 2
+	}
+	if r.MaxBackoff > 0 && d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	if r.Jitter > 0 && d > 0 {
+		jitterMu.Lock()
+		f := 1 + r.Jitter*(2*jitterRand.Float64()-1)
+		jitterMu.Unlock()
+		d = time.Duration(float64(d) * f)
+	}
+	return d
+}
+
+// jitterRand — источник случайных отклонений пауз, общий для всех
+// обработчиков.
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// DeadLetter — значение, обработка которого окончательно не удалась.
+type DeadLetter[T any] struct {
+	Value    T     // исходное значение
+	Err      error // ошибка последней попытки
+	Attempts int   // количество сделанных попыток
+}
+
+// processWithRetry обрабатывает v функцией process, повторяя неудачные
+// попытки по политике policy с паузами по часам clock. Паника обработки
+// превращается в *PanicError и не повторяется. onRetry, если задана,
+// вызывается перед каждым повтором. Возвращает результат, количество
+// попыток и ошибку последней попытки.
+func processWithRetry[T any](ctx context.Context, process func(context.Context, T) (T, error), v T, policy RetryPolicy, clock Clock, onRetry func(attempt int, err error)) (T, int, error) {
+	var res T
+	try := func() error {
+		return protect(func() error {
+			var err error
+			res, err = process(ctx, v)
+			return err
+		})
+	}
+	err := try()
+	attempt := 1
+	for ; attempt < policy.Attempts && err != nil && policy.retryable(ctx, err); attempt++ {
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
+		if Sleep(ctx, clock, policy.backoff(attempt)) != nil {
+			break
+		}
+		err = try()
+	}
+	return res, attempt, err
+}
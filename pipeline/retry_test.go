@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   []time.Duration // паузы перед повторами 1, 2, ...
+	}{
+		{"удвоение", RetryPolicy{Backoff: 10 * time.Millisecond}, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond}},
+		{"ограничение", RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 25 * time.Millisecond}, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond, 25 * time.Millisecond}},
+		{"без паузы", RetryPolicy{}, []time.Duration{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				if got := tt.policy.backoff(i + 1); got != want {
+					t.Errorf("backoff(%d) = %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, Jitter: 0.2}
+	for range 100 {
+		if d := p.backoff(1); d < 80*time.Millisecond || d > 120*time.Millisecond {
+			t.Fatalf("backoff с отклонением 0.2 = %v, want от 80ms до 120ms", d)
+		}
+	}
+}
+
+func TestProcessWithRetry(t *testing.T) {
+	errTemporary := errors.New("временная ошибка")
+	errPermanent := errors.New("постоянная ошибка")
+	// failing возвращает обработку, которая завершается ошибкой err первые
+	// fails раз
+	failing := func(fails int, err error) func(context.Context, int64) (int64, error) {
+		return func(_ context.Context, v int64) (int64, error) {
+			if fails > 0 {
+				fails--
+				return 0, err
+			}
+			return v * 2, nil
+		}
+	}
+	onlyTemporary := func(err error) bool { return errors.Is(err, errTemporary) }
+	tests := []struct {
+		name         string
+		process      func(context.Context, int64) (int64, error)
+		policy       RetryPolicy
+		want         int64
+		wantAttempts int
+		wantErr      error
+	}{
+		{"без ошибок", failing(0, nil), RetryPolicy{Attempts: 3}, 10, 1, nil},
+		{"успех после повторов", failing(2, errTemporary), RetryPolicy{Attempts: 3}, 10, 3, nil},
+		{"попытки кончились", failing(5, errTemporary), RetryPolicy{Attempts: 3}, 0, 3, errTemporary},
+		{"без повторов", failing(1, errTemporary), RetryPolicy{}, 0, 1, errTemporary},
+		{"не повторяется", failing(1, errPermanent), RetryPolicy{Attempts: 3, Retryable: onlyTemporary}, 0, 1, errPermanent},
+		{"ErrSkip", failing(1, ErrSkip), RetryPolicy{Attempts: 3}, 0, 1, ErrSkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var retries int
+			got, attempts, err := processWithRetry(context.Background(), tt.process, 5, tt.policy, SystemClock, func(int, error) { retries++ })
+			if got != tt.want || attempts != tt.wantAttempts || !errors.Is(err, tt.wantErr) || err == nil != (tt.wantErr == nil) {
+				t.Errorf("processWithRetry = %d, %d, %v, want %d, %d, %v", got, attempts, err, tt.want, tt.wantAttempts, tt.wantErr)
+			}
+			if retries != attempts-1 {
+				t.Errorf("onRetry вызвана %d раз, want %d", retries, attempts-1)
+			}
+		})
+	}
+
+	// паника не повторяется
+	panicking := func(context.Context, int64) (int64, error) { panic("сбой") }
+	var pe *PanicError
+	if _, attempts, err := processWithRetry(context.Background(), panicking, 5, RetryPolicy{Attempts: 3}, SystemClock, nil); !errors.As(err, &pe) || attempts != 1 {
+		t.Errorf("паника: err %v, попыток %d, want *PanicError и 1", err, attempts)
+	}
+}
+
+// TestProcessWithRetryCancel проверяет, что отмена контекста прерывает
+// паузу между повторами.
+func TestProcessWithRetryCancel(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	errTemporary := errors.New("временная ошибка")
+	done := make(chan int, 1)
+	go func() {
+		_, attempts, _ := processWithRetry(ctx, func(context.Context, int64) (int64, error) {
+			return 0, errTemporary
+		}, 1, RetryPolicy{Attempts: 5, Backoff: time.Hour}, clock, nil)
+		done <- attempts
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if attempts := <-done; attempts != 1 {
+		t.Errorf("после отмены сделано %d попыток, want 1", attempts)
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy  RetryPolicy
+		wantErr bool
+	}{
+		{RetryPolicy{}, false},
+		{RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Second, Jitter: 1}, false},
+		{RetryPolicy{Attempts: -1}, true},
+		{RetryPolicy{Backoff: -time.Millisecond}, true},
+		{RetryPolicy{MaxBackoff: -time.Millisecond}, true},
+		{RetryPolicy{Jitter: 1.5}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) = %v, wantErr %v", tt.policy, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RotationPolicy — правила смены файлов RotatingFileSink. Нулевое значение —
+// все числа пишутся в один файл.
+type RotationPolicy struct {
+	// MaxBytes — наибольший размер файла: число, которое не помещается в
+	// текущий файл, пишется в новый. 0 — без ограничения
+	MaxBytes int64
+	// Interval — как долго пишется один файл; 0 — без ограничения
+	Interval time.Duration
+	// Compress — сжимать закрытые файлы gzip; несжатый файл удаляется
+	Compress bool
+}
+
+// validate проверяет корректность правил.
+func (r RotationPolicy) validate() error {
+	if r.MaxBytes < 0 {
+		return fmt.Errorf("размер файла не может быть отрицательным: %d", r.MaxBytes)
+	}
+	if r.Interval < 0 {
+		return fmt.Errorf("период смены файлов не может быть отрицательным: %v", r.Interval)
+	}
+	return nil
+}
+
+// RotatingFileSink — приёмник, записывающий числа по одному в строке в
+// файлы path.000001, path.000002 и т.д., начиная новый файл по правилам
+// RotationPolicy. Файл закрывается при смене и в Flush, а при
+// RotationPolicy.Compress сжимается в файл с суффиксом .gz. Следующий
+// после Flush вызов Write начинает новый файл. Вызовы методов не должны
+// пересекаться.
+type RotatingFileSink struct {
+	path   string
+	policy RotationPolicy
+	clock  Clock
+
+	file   *os.File      // текущий файл; nil — файл не открыт
+	w      *bufio.Writer // буфер записи в file
+	size   int64         // количество байт, записанных в file
+	opened time.Time     // время открытия file
+	next   int           // номер следующего файла
+	files  []string      // закрытые файлы
+	buf    []byte        // буфер для форматирования числа
+}
+
+// OpenRotatingFileSink создаёт приёмник, записывающий числа в файлы с
+// префиксом path. Существующие файлы с теми же именами перезаписываются.
+// Время Interval отсчитывается по часам clock; nil — SystemClock.
+func OpenRotatingFileSink(path string, policy RotationPolicy, clock Clock) (*RotatingFileSink, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &RotatingFileSink{path: path, policy: policy, clock: clock, next: 1}, nil
+}
+
+// Write записывает v в текущий файл, при необходимости начиная новый.
+func (s *RotatingFileSink) Write(_ context.Context, v int64) error {
+	s.buf = strconv.AppendInt(s.buf[:0], v, 10)
+	s.buf = append(s.buf, '\n')
+	if s.file != nil && s.rotate(int64(len(s.buf))) {
+		if err := s.closeFile(); err != nil {
+			return err
+		}
+	}
+	if s.file == nil {
+		if err := s.openFile(); err != nil {
+			return err
+		}
+	}
+	n, err := s.w.Write(s.buf)
+	s.size += int64(n)
+	return err
+}
+
+// rotate сообщает, нужно ли начать новый файл перед записью n байт. Пустой
+// файл не сменяется, даже если число больше MaxBytes.
+func (s *RotatingFileSink) rotate(n int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.policy.MaxBytes > 0 && s.size+n > s.policy.MaxBytes {
+		return true
+	}
+	return s.policy.Interval > 0 && s.clock.Now().Sub(s.opened) >= s.policy.Interval
+}
+
+// Flush закрывает текущий файл, чтобы он попал в Files.
+func (s *RotatingFileSink) Flush() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.closeFile()
+}
+
+// Close работает как Flush.
+func (s *RotatingFileSink) Close() error {
+	return s.Flush()
+}
+
+// Files возвращает закрытые файлы в порядке записи.
+func (s *RotatingFileSink) Files() []string {
+	return append([]string(nil), s.files...)
+}
+
+// openFile открывает следующий файл.
+func (s *RotatingFileSink) openFile() error {
+	f, err := os.Create(fmt.Sprintf("%s.%06d", s.path, s.next))
+	if err != nil {
+		return err
+	}
+	s.next++
+	s.file, s.size, s.opened = f, 0, s.clock.Now()
+	if s.w == nil {
+		s.w = bufio.NewWriter(f)
+	} else {
+		s.w.Reset(f)
+	}
+	return nil
+}
+
+// closeFile дописывает буфер, закрывает текущий файл и при необходимости
+// сжимает его.
+func (s *RotatingFileSink) closeFile() error {
+	f := s.file
+	s.file = nil
+	err := s.w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	if s.policy.Compress {
+		if name, err = compressFile(name); err != nil {
+			return err
+		}
+	}
+	s.files = append(s.files, name)
+	return nil
+}
+
+// compressFile сжимает файл path в path.gz, удаляет исходный и возвращает
+// имя сжатого.
+func compressFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	gzPath := path + ".gz"
+	dst, err := os.Create(gzPath)
+	if err != nil {
+		return "", err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(gzPath)
+		return "", err
+	}
+	return gzPath, os.Remove(path)
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSegment возвращает содержимое файла path, распаковывая файлы .gz.
+func readSegment(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileSink(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RotationPolicy
+		tick   time.Duration // сдвиг часов после каждого числа
+		want   []string      // содержимое файлов
+	}{
+		{"без смены", RotationPolicy{}, 0, []string{"1\n22\n333\n4\n"}},
+		{"по размеру", RotationPolicy{MaxBytes: 4}, 0, []string{"1\n", "22\n", "333\n", "4\n"}},
+		{"по размеру с запасом", RotationPolicy{MaxBytes: 7}, 0, []string{"1\n22\n", "333\n4\n"}},
+		{"по времени", RotationPolicy{Interval: 2 * time.Second}, time.Second, []string{"1\n22\n", "333\n4\n"}},
+		{"со сжатием", RotationPolicy{MaxBytes: 7, Compress: true}, 0, []string{"1\n22\n", "333\n4\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out")
+			clock := NewManualClock(time.Unix(0, 0))
+			sink, err := OpenRotatingFileSink(path, tt.policy, clock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range []int64{1, 22, 333, 4} {
+				if err := sink.Write(context.Background(), v); err != nil {
+					t.Fatal(err)
+				}
+				clock.Advance(tt.tick)
+			}
+			if err := sink.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			files := sink.Files()
+			var got []string
+			for i, name := range files {
+				want := path + "." + []string{"000001", "000002", "000003", "000004"}[i]
+				if tt.policy.Compress {
+					want += ".gz"
+				}
+				if name != want {
+					t.Errorf("файл %d = %q, want %q", i, name, want)
+				}
+				got = append(got, readSegment(t, name))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("содержимое файлов %q, want %q", got, tt.want)
+			}
+			if tt.policy.Compress {
+				if _, err := os.Stat(strings.TrimSuffix(files[0], ".gz")); !os.IsNotExist(err) {
+					t.Errorf("несжатый файл не удалён: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRotationPolicyValidate(t *testing.T) {
+	for _, policy := range []RotationPolicy{{MaxBytes: -1}, {Interval: -time.Second}} {
+		if _, err := OpenRotatingFileSink("out", policy, nil); err == nil {
+			t.Errorf("OpenRotatingFileSink(%+v) без ошибки", policy)
+		}
+	}
+}
+
+// TestRunRotatingFileSink проверяет, что файлы RotatingFileSink попадают в
+// Result.Files и вместе содержат все числа результирующего канала.
+func TestRunRotatingFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	sink, err := OpenRotatingFileSink(path, RotationPolicy{MaxBytes: 64}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(context.Background(), Config{NumWorkers: 3, Limit: 100, Sink: sink})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if len(res.Files) < 2 {
+		t.Fatalf("файлы %v, want не меньше двух", res.Files)
+	}
+	var count int
+	for _, name := range res.Files {
+		count += strings.Count(readSegment(t, name), "\n")
+	}
+	if int64(count) != res.OutputCount {
+		t.Errorf("в файлах %d чисел, want %d", count, res.OutputCount)
+	}
+}
@@ -0,0 +1,172 @@
+// Package rpc содержит gRPC-сервис Pipeline из pipeline.proto: клиенты
+// передают в конвейер поток чисел и подписываются на числа его
+// результирующего канала.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pipeline.proto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+	"google.golang.org/grpc/status"
+)
+
+// subscriberBuffer — сколько чисел может ждать отправки подписчику Consume.
+const subscriberBuffer = 64
+
+// Server — реализация сервиса Pipeline. Source и Sink подключаются к
+// конвейеру как Config.Source и Config.Sink; после Run нужно вызвать Close,
+// чтобы завершить потоки Produce.
+type Server struct {
+	UnimplementedPipelineServer
+
+	source *pipeline.PushSource
+
+	mu       sync.Mutex
+	subs     map[*subscriber]struct{} // подписчики Consume
+	finished bool                     // конвейер выдал все числа
+}
+
+// subscriber — подписчик Consume.
+type subscriber struct {
+	values chan int64    // закрывается, когда конвейер выдал все числа
+	done   chan struct{} // закрывается, когда подписчик отключился
+}
+
+// NewServer создаёт сервис.
+func NewServer() *Server {
+	return &Server{source: pipeline.NewPushSource(), subs: make(map[*subscriber]struct{})}
+}
+
+// Source возвращает источник чисел, присланных через Produce.
+func (s *Server) Source() pipeline.Source[int64] {
+	return s.source
+}
+
+// Sink возвращает приёмник, рассылающий числа результирующего канала всем
+// подписчикам Consume. Медленный подписчик задерживает конвейер, а без
+// подписчиков числа отбрасываются.
+func (s *Server) Sink() pipeline.Sink {
+	return serverSink{s}
+}
+
+// Close исчерпывает источник: потоки Produce завершаются ответом с
+// количеством принятых чисел, а новые получают его сразу.
+func (s *Server) Close() {
+	s.source.Close()
+}
+
+// Produce передаёт числа потока в конвейер.
+func (s *Server) Produce(stream Pipeline_ProduceServer) error {
+	// Recv нельзя прервать, поэтому читаем поток в отдельной горутине; она
+	// завершится вместе с потоком после выхода из Produce
+	values := make(chan *Value)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			v, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case <-stream.Context().Done():
+				return
+			case values <- v:
+			}
+		}
+	}()
+
+	var accepted int64
+	for {
+		select {
+		case <-s.source.Done():
+			return stream.SendAndClose(&ProduceSummary{Accepted: accepted})
+		case err := <-errs:
+			if err == io.EOF {
+				return stream.SendAndClose(&ProduceSummary{Accepted: accepted})
+			}
+			return err
+		case v := <-values:
+			err := s.source.Push(stream.Context(), v.Value)
+			if errors.Is(err, pipeline.ErrSourceClosed) {
+				return stream.SendAndClose(&ProduceSummary{Accepted: accepted})
+			}
+			if err != nil {
+				return status.FromContextError(err).Err()
+			}
+			accepted++
+		}
+	}
+}
+
+// Consume отправляет подписчику числа результирующего канала.
+func (s *Server) Consume(_ *ConsumeRequest, stream Pipeline_ConsumeServer) error {
+	sub := &subscriber{values: make(chan int64, subscriberBuffer), done: make(chan struct{})}
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return nil
+	}
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, sub)
+		s.mu.Unlock()
+		close(sub.done)
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case v, ok := <-sub.values:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(&Value{Value: v}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// serverSink — приёмник Server.Sink.
+type serverSink struct {
+	s *Server
+}
+
+// Write отправляет v каждому подписчику.
+func (k serverSink) Write(ctx context.Context, v int64) error {
+	k.s.mu.Lock()
+	subs := make([]*subscriber, 0, len(k.s.subs))
+	for sub := range k.s.subs {
+		subs = append(subs, sub)
+	}
+	k.s.mu.Unlock()
+	for _, sub := range subs {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sub.done:
+		case sub.values <- v:
+		}
+	}
+	return nil
+}
+
+// Flush завершает потоки подписчиков после отправки им оставшихся чисел.
+func (k serverSink) Flush() error {
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+	k.s.finished = true
+	for sub := range k.s.subs {
+		close(sub.values)
+	}
+	return nil
+}
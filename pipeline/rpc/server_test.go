@@ -0,0 +1,138 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial запускает сервис svc на соединении в памяти и возвращает клиента.
+func dial(t *testing.T, svc *Server) PipelineClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	RegisterPipelineServer(srv, svc)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewPipelineClient(conn)
+}
+
+// subscribers возвращает количество подписчиков Consume.
+func (s *Server) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+// TestServer проверяет, что числа потока Produce проходят через конвейер и
+// доходят до подписчика Consume, поток которого завершается вместе с
+// конвейером.
+func TestServer(t *testing.T) {
+	svc := NewServer()
+	client := dial(t, svc)
+	ctx := context.Background()
+
+	sub, err := client.Consume(ctx, &ConsumeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for svc.subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	received := make(chan []int64)
+	go func() {
+		var got []int64
+		for {
+			v, err := sub.Recv()
+			if err != nil {
+				if err != io.EOF {
+					t.Errorf("Consume: %v", err)
+				}
+				received <- got
+				return
+			}
+			got = append(got, v.Value)
+		}
+	}()
+
+	res := make(chan pipeline.Result)
+	go func() {
+		r, err := pipeline.Run(ctx, pipeline.Config{NumWorkers: 2, Limit: 10, Source: svc.Source(), Sink: svc.Sink()})
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+		svc.Close()
+		res <- r
+	}()
+
+	prod, err := client.Produce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v := int64(1); v <= 10; v++ {
+		if err := prod.Send(&Value{Value: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summary, err := prod.CloseAndRecv()
+	if err != nil || summary.Accepted != 10 {
+		t.Fatalf("Produce = %v, %v, want принято 10", summary, err)
+	}
+
+	r := <-res
+	got := <-received
+	slices.Sort(got)
+	if r.OutputSum != 55 || !slices.Equal(got, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Errorf("сумма %d, получены %v, want 55 и числа от 1 до 10", r.OutputSum, got)
+	}
+}
+
+// TestServerClose проверяет, что после Close поток Produce завершается
+// ответом с количеством принятых чисел, а Consume — сразу.
+func TestServerClose(t *testing.T) {
+	svc := NewServer()
+	client := dial(t, svc)
+	ctx := context.Background()
+
+	prod, err := client.Produce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prod.Send(&Value{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := svc.Source().Next(ctx); !ok || v != 1 {
+		t.Fatalf("Next = %d, %v, want 1", v, ok)
+	}
+	svc.Close()
+	summary, err := prod.CloseAndRecv()
+	if err != nil || summary.Accepted != 1 {
+		t.Errorf("Produce = %v, %v, want принято 1", summary, err)
+	}
+
+	if err := svc.Sink().Flush(); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := client.Consume(ctx, &ConsumeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Recv(); err != io.EOF {
+		t.Errorf("Consume после завершения конвейера = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Semaphore — взвешенный семафор: ограничивает суммарный вес одновременно
+// выполняемых операций, например обращений нескольких обработчиков или
+// конвейеров к общей базе данных. Ожидающие получают вес по очереди, поэтому
+// тяжёлая операция не ждёт бесконечно за лёгкими. Безопасен для
+// конкурентного использования.
+type Semaphore struct {
+	mu      sync.Mutex
+	size    int64
+	used    int64
+	waiters list.List // *semaphoreWaiter в порядке прихода
+}
+
+// semaphoreWaiter — ожидание веса n; ready закрывается, когда вес выдан.
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// NewSemaphore создаёт семафор с суммарным весом size.
+func NewSemaphore(size int64) *Semaphore {
+	return &Semaphore{size: size}
+}
+
+// Acquire получает вес n, при необходимости дожидаясь, пока его освободят.
+// При отмене ctx возвращает её причину, не получив веса.
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if n > s.size {
+		s.mu.Unlock()
+		return fmt.Errorf("вес %d больше ёмкости семафора %d", n, s.size)
+	}
+	if s.size-s.used >= n && s.waiters.Len() == 0 {
+		s.used += n
+		s.mu.Unlock()
+		return nil
+	}
+	w := &semaphoreWaiter{n: n, ready: make(chan struct{})}
+	el := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-w.ready:
+			// вес выдан одновременно с отменой: возвращаем его
+			s.used -= n
+		default:
+			s.waiters.Remove(el)
+		}
+		// ушедший из начала очереди мог задерживать следующих
+		s.notify()
+		s.mu.Unlock()
+		return context.Cause(ctx)
+	}
+}
+
+// Release освобождает вес n, полученный Acquire.
+func (s *Semaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used -= n; s.used < 0 {
+		panic("pipeline: Semaphore.Release освобождает больше, чем получено")
+	}
+	s.notify()
+}
+
+// notify выдаёт вес ожидающим по очереди, пока его хватает первому.
+func (s *Semaphore) notify() {
+	for el := s.waiters.Front(); el != nil; el = s.waiters.Front() {
+		w := el.Value.(*semaphoreWaiter)
+		if s.size-s.used < w.n {
+			return
+		}
+		s.used += w.n
+		s.waiters.Remove(el)
+		close(w.ready)
+	}
+}
+
+// SinkLimit — ограничение одновременных операций приёмников: перед каждым
+// вызовом Write и Flush приёмника конвейера берётся вес Weight семафора
+// Semaphore. Один семафор можно передать нескольким конвейерам, например
+// запущенным Supervisor, чтобы ограничить их общую нагрузку на приёмник.
+// Нулевое значение — без ограничения.
+type SinkLimit struct {
+	Semaphore *Semaphore
+	Weight    int64 // вес одной операции; 0 — 1
+}
+
+// validate проверяет корректность настроек.
+func (l SinkLimit) validate() error {
+	if l.Weight < 0 {
+		return fmt.Errorf("вес операции приёмника не может быть отрицательным: %d", l.Weight)
+	}
+	return nil
+}
+
+// do выполняет операцию приёмника fn, получив вес семафора, и возвращает
+// время, которое пришлось его ждать по часам clock.
+func (l SinkLimit) do(ctx context.Context, clock Clock, fn func() error) (time.Duration, error) {
+	if l.Semaphore == nil {
+		return 0, fn()
+	}
+	weight := max(l.Weight, 1)
+	start := clock.Now()
+	if err := l.Semaphore.Acquire(ctx, weight); err != nil {
+		return clock.Now().Sub(start), err
+	}
+	blocked := clock.Now().Sub(start)
+	defer l.Semaphore.Release(weight)
+	return blocked, fn()
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSemaphoreOrder проверяет, что ожидающие получают вес по очереди:
+// лёгкая операция не обгоняет ждущую тяжёлую.
+func TestSemaphoreOrder(t *testing.T) {
+	s := NewSemaphore(3)
+	ctx := context.Background()
+	if err := s.Acquire(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	heavy := make(chan struct{})
+	go func() {
+		s.Acquire(ctx, 3)
+		close(heavy)
+	}()
+	// тяжёлая операция встала в очередь раньше лёгкой
+	for {
+		s.mu.Lock()
+		n := s.waiters.Len()
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	light, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(light, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("лёгкая Acquire = %v, want context.DeadlineExceeded", err)
+	}
+	s.Release(2)
+	<-heavy
+	s.Release(3)
+	if err := s.Acquire(ctx, 4); err == nil {
+		t.Error("Acquire больше ёмкости = nil, want ошибку")
+	}
+}
+
+// countingSink — приёмник, который считает одновременные вызовы Write.
+type countingSink struct {
+	active, peak *atomic.Int64
+	values       []int64
+}
+
+func (s *countingSink) Write(_ context.Context, v int64) error {
+	n := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		p := s.peak.Load()
+		if n <= p || s.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(100 * time.Microsecond)
+	s.values = append(s.values, v)
+	return nil
+}
+
+func (s *countingSink) Flush() error { return nil }
+
+// TestRunWorkerSinks проверяет, что приёмники обработчиков и Sink получают
+// все числа, а SinkLimit ограничивает одновременные операции приёмников
+// обработчиков и учитывает ожидание в SinkBlocked.
+func TestRunWorkerSinks(t *testing.T) {
+	var (
+		active, peak atomic.Int64
+		mu           sync.Mutex
+		sinks        []*countingSink
+		collected    MemorySink
+	)
+	res, err := Run(context.Background(), Config{
+		NumWorkers: 4,
+		Limit:      200,
+		Sink:       &collected,
+		WorkerSinks: func(int) Sink {
+			mu.Lock()
+			defer mu.Unlock()
+			s := &countingSink{active: &active, peak: &peak}
+			sinks = append(sinks, s)
+			return s
+		},
+		SinkLimit: SinkLimit{Semaphore: NewSemaphore(2)},
+	})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	var sum int64
+	for _, s := range sinks {
+		for _, v := range s.values {
+			sum += v
+		}
+	}
+	if len(sinks) != 4 || sum != res.OutputSum {
+		t.Errorf("приёмников %d с суммой %d, want 4 и %d", len(sinks), sum, res.OutputSum)
+	}
+	if n := len(collected.Values()); int64(n) != res.OutputCount {
+		t.Errorf("Sink получил %d чисел, want %d", n, res.OutputCount)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("одновременных операций %d, want не больше 2", peak.Load())
+	}
+	if res.SinkBlocked <= 0 {
+		t.Errorf("SinkBlocked = %v, want больше 0", res.SinkBlocked)
+	}
+}
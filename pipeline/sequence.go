@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// maxSequenceReport — сколько номеров потерянных и продублированных чисел
+// хранит SequenceReport; остальные только подсчитываются.
+const maxSequenceReport = 100
+
+// SequenceReport — результат проверки номеров чисел: какие сгенерированные
+// числа не были учтены ни в результирующем канале, ни как отброшенные или
+// отфильтрованные, и какие были учтены больше одного раза. Номера — это
+// Event.Seq, порядковые номера чисел в источнике; для Sequential они
+// совпадают с самими числами.
+type SequenceReport struct {
+	Lost            []int64 // номера потерянных чисел, не больше maxSequenceReport
+	LostCount       int64   // количество потерянных чисел
+	Duplicated      []int64 // номера продублированных чисел, не больше maxSequenceReport
+	DuplicatedCount int64   // количество лишних появлений чисел
+}
+
+// Err возвращает ошибку, если были потерянные или продублированные числа.
+func (r *SequenceReport) Err() error {
+	if r == nil || (r.LostCount == 0 && r.DuplicatedCount == 0) {
+		return nil
+	}
+	return fmt.Errorf("потеряно чисел %d %v, продублировано %d %v",
+		r.LostCount, r.Lost, r.DuplicatedCount, r.Duplicated)
+}
+
+// sequenceVerifier отмечает в битовом множестве номера учтённых чисел.
+// Методы можно вызывать из разных горутин.
+type sequenceVerifier struct {
+	mu   sync.Mutex
+	seen []uint64 // бит seq-1 установлен, если число с номером seq учтено
+	rep  SequenceReport
+}
+
+// mark отмечает, что число с номером seq учтено.
+func (v *sequenceVerifier) mark(seq int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	i, bit := (seq-1)/64, uint64(1)<<((seq-1)%64)
+	for int64(len(v.seen)) <= i {
+		v.seen = append(v.seen, 0)
+	}
+	if v.seen[i]&bit != 0 {
+		v.rep.DuplicatedCount++
+		if len(v.rep.Duplicated) < maxSequenceReport {
+			v.rep.Duplicated = append(v.rep.Duplicated, seq)
+		}
+		return
+	}
+	v.seen[i] |= bit
+}
+
+// report возвращает результат проверки для generated сгенерированных чисел
+// с номерами 1..generated.
+func (v *sequenceVerifier) report(generated int64) *SequenceReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	rep := v.rep
+	rep.Duplicated = append([]int64(nil), v.rep.Duplicated...)
+	for i := int64(0); i*64 < generated; i++ {
+		var word uint64
+		if i < int64(len(v.seen)) {
+			word = v.seen[i]
+		}
+		// биты номеров за пределами generated считаем учтёнными
+		if n := generated - i*64; n < 64 {
+			word |= ^uint64(0) << n
+		}
+		missing := ^word
+		rep.LostCount += int64(bits.OnesCount64(missing))
+		for missing != 0 && len(rep.Lost) < maxSequenceReport {
+			b := bits.TrailingZeros64(missing)
+			rep.Lost = append(rep.Lost, i*64+int64(b)+1)
+			missing &= missing - 1
+		}
+	}
+	return &rep
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSequenceVerifier(t *testing.T) {
+	tests := []struct {
+		name           string
+		marks          []int64
+		generated      int64
+		wantLost       []int64
+		wantDuplicated []int64
+	}{
+		{"все учтены", ints(1, 100), 100, nil, nil},
+		{"ничего не сгенерировано", nil, 0, nil, nil},
+		{"потери", []int64{1, 3, 64, 66}, 66, slices.Concat([]int64{2}, ints(4, 63), []int64{65}), nil},
+		{"повторы", []int64{1, 2, 2, 3, 2}, 3, nil, []int64{2, 2}},
+		// учтённые номера за пределами generated не считаются потерянными
+		{"за пределами", []int64{1, 2, 70}, 2, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v sequenceVerifier
+			for _, seq := range tt.marks {
+				v.mark(seq)
+			}
+			rep := v.report(tt.generated)
+			if !slices.Equal(rep.Lost, tt.wantLost) || rep.LostCount != int64(len(tt.wantLost)) ||
+				!slices.Equal(rep.Duplicated, tt.wantDuplicated) || rep.DuplicatedCount != int64(len(tt.wantDuplicated)) {
+				t.Errorf("report = %+v, want потеряны %v, продублированы %v", rep, tt.wantLost, tt.wantDuplicated)
+			}
+			if (rep.Err() != nil) != (len(tt.wantLost)+len(tt.wantDuplicated) > 0) {
+				t.Errorf("Err = %v", rep.Err())
+			}
+		})
+	}
+}
+
+// TestSequenceReportLimit проверяет, что номеров сохраняется не больше
+// maxSequenceReport, а подсчитываются все.
+func TestSequenceReportLimit(t *testing.T) {
+	var v sequenceVerifier
+	for range maxSequenceReport + 5 {
+		v.mark(1)
+	}
+	rep := v.report(2*maxSequenceReport + 1)
+	if len(rep.Lost) != maxSequenceReport || rep.LostCount != 2*maxSequenceReport {
+		t.Errorf("потеряно %d номеров из %d, want %d из %d", len(rep.Lost), rep.LostCount, maxSequenceReport, 2*maxSequenceReport)
+	}
+	if len(rep.Duplicated) != maxSequenceReport || rep.DuplicatedCount != maxSequenceReport+4 {
+		t.Errorf("продублировано %d номеров из %d, want %d из %d", len(rep.Duplicated), rep.DuplicatedCount, maxSequenceReport, maxSequenceReport+4)
+	}
+	var nilReport *SequenceReport
+	if nilReport.Err() != nil {
+		t.Error("Err для nil не nil")
+	}
+}
+
+// TestRunVerifySequence проверяет, что отфильтрованные и отброшенные числа
+// учитываются проверкой номеров и не считаются потерянными.
+func TestRunVerifySequence(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"фильтр", Config{NumWorkers: 4, Limit: 500, Process: Filter(func(v int64) bool { return v%2 == 0 })}},
+		{"отбрасывание", Config{NumWorkers: 2, BufferSize: 16, Timeout: 20 * time.Millisecond, WorkerDelay: time.Millisecond, Drain: DropRemaining}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.VerifySequence = true
+			res, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Sequence == nil {
+				t.Fatal("Sequence = nil при VerifySequence")
+			}
+			if err := res.Verify(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
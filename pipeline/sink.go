@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sink принимает числа результирующего канала конвейера.
+type Sink interface {
+	// Write принимает число v. Ошибка останавливает конвейер; число при
+	// этом уже учтено в результирующем канале.
+	Write(ctx context.Context, v int64) error
+	// Flush дописывает накопленные числа. Вызывается один раз после
+	// последнего Write.
+	Flush() error
+}
+
+// Discard — приёмник, который отбрасывает все числа: они только
+// подсчитываются, как без приёмника.
+var Discard Sink = discardSink{}
+
+// discardSink — реализация Discard.
+type discardSink struct{}
+
+// Write ничего не делает.
+func (discardSink) Write(context.Context, int64) error { return nil }
+
+// Flush ничего не делает.
+func (discardSink) Flush() error { return nil }
+
+// WriterSink — приёмник, записывающий числа в io.Writer по одному в строке
+// через буфер. Файл в таком виде читается ReaderSource. Вызовы Write и
+// Flush не должны пересекаться.
+type WriterSink struct {
+	w    *bufio.Writer
+	line bool   // записывать буфер после каждого числа
+	buf  []byte // буфер для форматирования числа
+}
+
+// NewWriterSink создаёт приёмник, записывающий числа в w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: bufio.NewWriter(w)}
+}
+
+// NewLineWriterSink создаёт приёмник, записывающий в w каждое число сразу,
+// без накопления в буфере: следующая команда конвейера оболочки получает
+// числа по мере обработки, но каждое число стоит отдельной записи.
+func NewLineWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: bufio.NewWriter(w), line: true}
+}
+
+// Stdout возвращает приёмник, записывающий числа в стандартный вывод.
+func Stdout() *WriterSink {
+	return NewWriterSink(os.Stdout)
+}
+
+// Write записывает v в буфер.
+func (s *WriterSink) Write(_ context.Context, v int64) error {
+	s.buf = strconv.AppendInt(s.buf[:0], v, 10)
+	s.buf = append(s.buf, '\n')
+	if _, err := s.w.Write(s.buf); err != nil || !s.line {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// Flush записывает буфер в io.Writer.
+func (s *WriterSink) Flush() error {
+	return s.w.Flush()
+}
+
+// FileSink — WriterSink, записывающий числа в файл.
+type FileSink struct {
+	*WriterSink
+	file *os.File
+}
+
+// CreateFileSink создаёт файл path, а если он существует — очищает его.
+func CreateFileSink(path string) (*FileSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{WriterSink: NewWriterSink(f), file: f}, nil
+}
+
+// Close дописывает буфер и закрывает файл.
+func (s *FileSink) Close() error {
+	if err := s.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// MemorySink — приёмник, сохраняющий числа в памяти в порядке получения.
+// Values можно вызывать и во время работы конвейера.
+type MemorySink struct {
+	mu     sync.Mutex
+	values []int64
+}
+
+// Write сохраняет v.
+func (s *MemorySink) Write(_ context.Context, v int64) error {
+	s.mu.Lock()
+	s.values = append(s.values, v)
+	s.mu.Unlock()
+	return nil
+}
+
+// Flush ничего не делает.
+func (s *MemorySink) Flush() error { return nil }
+
+// Values возвращает копию сохранённых чисел.
+func (s *MemorySink) Values() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.values...)
+}
+
+// sinkWriter передаёт числа в Config.Sink. Первая ошибка или паника
+// приёмника останавливает конвейер как *SinkError, после неё числа в
+// приёмник не передаются. Операции приёмника ограничиваются limit, а
+// время ожидания семафора передаётся в blocked.
+type sinkWriter struct {
+	ctx     context.Context
+	sink    Sink
+	fail    func(error)
+	failed  bool
+	limit   SinkLimit
+	clock   Clock
+	blocked func(time.Duration)
+}
+
+// do выполняет операцию приёмника fn с ограничением limit.
+func (w *sinkWriter) do(ctx context.Context, fn func() error) error {
+	d, err := w.limit.do(ctx, w.clock, fn)
+	if w.blocked != nil && d > 0 {
+		w.blocked(d)
+	}
+	return err
+}
+
+// write передаёт v в приёмник.
+func (w *sinkWriter) write(v int64) {
+	if w.sink == nil || w.failed {
+		return
+	}
+	w.check(protect(func() error {
+		return w.do(w.ctx, func() error { return w.sink.Write(w.ctx, v) })
+	}))
+}
+
+// process оборачивает обработку process обработчика так, что каждое
+// обработанное число передаётся в приёмник; ошибка приёмника становится
+// ошибкой обработки.
+func (w *sinkWriter) process(process func(context.Context, Event) (Event, error)) func(context.Context, Event) (Event, error) {
+	return func(ctx context.Context, e Event) (Event, error) {
+		e, err := process(ctx, e)
+		if err != nil {
+			return e, err
+		}
+		if err := w.do(ctx, func() error { return w.sink.Write(ctx, e.Value) }); err != nil {
+			return e, fmt.Errorf("приёмник обработчика: %w", err)
+		}
+		return e, nil
+	}
+}
+
+// files возвращает файлы, записанные приёмником, если он сообщает о них
+// методом Files, как RotatingFileSink.
+func (w *sinkWriter) files() []string {
+	if f, ok := w.sink.(interface{ Files() []string }); ok {
+		return f.Files()
+	}
+	return nil
+}
+
+// flush дописывает накопленные приёмником числа.
+func (w *sinkWriter) flush() {
+	if w.sink == nil || w.failed {
+		return
+	}
+	// накопленное дописывается и после отмены контекста
+	w.check(protect(func() error {
+		return w.do(context.WithoutCancel(w.ctx), w.sink.Flush)
+	}))
+}
+
+// check останавливает конвейер, если приёмник вернул ошибку err.
+func (w *sinkWriter) check(err error) {
+	if err != nil {
+		w.failed = true
+		w.fail(&SinkError{Err: fmt.Errorf("приёмник результатов: %w", err)})
+	}
+}
@@ -0,0 +1,160 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestFileSink проверяет, что числа, записанные FileSink, читаются
+// ReaderSource.
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	sink, err := CreateFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{3, -1, 0, 9223372036854775807}
+	for _, v := range want {
+		if err := sink.Write(context.Background(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	src := NewReaderSource(f)
+	var got []int64
+	for v, ok := src.Next(context.Background()); ok; v, ok = src.Next(context.Background()) {
+		got = append(got, v)
+	}
+	if src.Err() != nil || !slices.Equal(got, want) {
+		t.Errorf("прочитано %v, ошибка %v, want %v", got, src.Err(), want)
+	}
+}
+
+// countingWriter считает вызовы Write.
+type countingWriter struct {
+	strings.Builder
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Builder.Write(p)
+}
+
+// TestWriterSinkBuffering проверяет, что WriterSink накапливает числа в
+// буфере, а NewLineWriterSink записывает каждое сразу.
+func TestWriterSinkBuffering(t *testing.T) {
+	tests := []struct {
+		name       string
+		newSink    func(w io.Writer) *WriterSink
+		wantWrites int // записей до Flush
+	}{
+		{"с буфером", NewWriterSink, 0},
+		{"построчно", NewLineWriterSink, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &countingWriter{}
+			sink := tt.newSink(w)
+			for v := int64(1); v <= 3; v++ {
+				if err := sink.Write(context.Background(), v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if w.writes != tt.wantWrites {
+				t.Errorf("записей до Flush %d, want %d", w.writes, tt.wantWrites)
+			}
+			if err := sink.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if w.String() != "1\n2\n3\n" {
+				t.Errorf("записано %q", w.String())
+			}
+		})
+	}
+}
+
+// TestRunSink проверяет, что Sink получает все числа результирующего
+// канала, в том числе в пакетном режиме, и в порядке генерации при Ordered.
+func TestRunSink(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		run  func(*Pipeline) (Result, error)
+	}{
+		{"Run", Config{NumWorkers: 4, Limit: 100}, func(p *Pipeline) (Result, error) {
+			return p.Run(context.Background())
+		}},
+		{"Ordered", Config{NumWorkers: 4, Limit: 100, Ordered: true}, func(p *Pipeline) (Result, error) {
+			return p.Run(context.Background())
+		}},
+		{"RunBatched", Config{NumWorkers: 4, Limit: 100}, func(p *Pipeline) (Result, error) {
+			return p.RunBatched(context.Background(), 8, 0)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &MemorySink{}
+			tt.cfg.Sink = sink
+			res, err := tt.run(New(tt.cfg))
+			if err != nil {
+				t.Fatalf("Run = %v", err)
+			}
+			got := sink.Values()
+			if tt.cfg.Ordered && !slices.IsSorted(got) {
+				t.Errorf("числа не по порядку: %v", got)
+			}
+			var sum int64
+			for _, v := range got {
+				sum += v
+			}
+			if int64(len(got)) != res.OutputCount || sum != res.OutputSum {
+				t.Errorf("в приёмнике %d чисел с суммой %d, want %d и %d", len(got), sum, res.OutputCount, res.OutputSum)
+			}
+		})
+	}
+}
+
+// errSink — приёмник, который не принимает числа после первых n.
+type errSink struct {
+	MemorySink
+	n int
+}
+
+func (s *errSink) Write(ctx context.Context, v int64) error {
+	if len(s.Values()) >= s.n {
+		return errors.New("диск заполнен")
+	}
+	return s.MemorySink.Write(ctx, v)
+}
+
+// TestRunSinkError проверяет, что ошибка приёмника останавливает конвейер
+// как *SinkError, а числа после неё в приёмник не передаются.
+func TestRunSinkError(t *testing.T) {
+	sink := &errSink{n: 5}
+	res, err := Run(context.Background(), Config{NumWorkers: 2, Limit: 1000, Sink: sink})
+	var sinkErr *SinkError
+	if !errors.As(err, &sinkErr) || !strings.Contains(err.Error(), "диск заполнен") {
+		t.Fatalf("Run = %v, want *SinkError", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	if n := len(sink.Values()); n != 5 {
+		t.Errorf("в приёмнике %d чисел, want 5", n)
+	}
+}
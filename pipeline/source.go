@@ -0,0 +1,205 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+)
+
+// Source — стратегия генерации значений для Generator.
+type Source[T any] interface {
+	// Next возвращает очередное значение. Если значений больше нет,
+	// возвращается false.
+	Next(ctx context.Context) (T, bool)
+}
+
+// SourceFunc позволяет использовать обычную функцию как Source.
+type SourceFunc[T any] func(ctx context.Context) (T, bool)
+
+// Next вызывает f(ctx).
+func (f SourceFunc[T]) Next(ctx context.Context) (T, bool) {
+	return f(ctx)
+}
+
+// Sequential возвращает источник последовательности 1,2,3 и т.д.:
+//
+//	N(0) = 1
+//	N(i) = N(i-1) + 1
+func Sequential() Source[int64] {
+	return &sequential{}
+}
+
+// sequential — источник Sequential.
+type sequential struct {
+	current int64 // последнее выданное число
+}
+
+// Next возвращает следующее число последовательности.
+func (s *sequential) Next(context.Context) (int64, bool) {
+	s.current++
+	return s.current, true
+}
+
+// Skip пропускает n чисел последовательности.
+func (s *sequential) Skip(n int64) {
+	s.current += n
+}
+
+// Reset начинает последовательность заново.
+func (s *sequential) Reset() {
+	s.current = 0
+}
+
+// limitSources ограничивает общее количество значений источников srcs
+// числом limit: значения выдаются, пока не исчерпан общий запас. limit <= 0
+// снимает ограничение.
+func limitSources[T any](srcs []Source[T], limit int64) []Source[T] {
+	if limit <= 0 {
+		return srcs
+	}
+	var left atomic.Int64 // оставшийся запас значений
+	left.Store(limit)
+	limited := make([]Source[T], len(srcs))
+	for i, src := range srcs {
+		limited[i] = SourceFunc[T](func(ctx context.Context) (T, bool) {
+			if left.Add(-1) < 0 {
+				var zero T
+				return zero, false
+			}
+			return src.Next(ctx)
+		})
+	}
+	return limited
+}
+
+// Random возвращает источник случайных чисел из диапазона [0, max).
+// Если max <= 0, числа берутся из всего диапазона [0, math.MaxInt64).
+// Одинаковый seed даёт одинаковую последовательность; Reset начинает её
+// заново.
+func Random(seed, max int64) Source[int64] {
+	return newRestartable(func() Source[int64] {
+		rnd := rand.New(rand.NewSource(seed))
+		return SourceFunc[int64](func(context.Context) (int64, bool) {
+			if max <= 0 {
+				return rnd.Int63(), true
+			}
+			return rnd.Int63n(max), true
+		})
+	})
+}
+
+// Fibonacci возвращает источник чисел Фибоначчи 1,1,2,3,5 и т.д.
+// Источник исчерпывается перед переполнением int64; Reset начинает
+// последовательность заново.
+func Fibonacci() Source[int64] {
+	return newRestartable(func() Source[int64] {
+		var a, b int64 = 0, 1
+		return SourceFunc[int64](func(context.Context) (int64, bool) {
+			if a > math.MaxInt64-b {
+				return 0, false
+			}
+			a, b = b, a+b
+			return a, true
+		})
+	})
+}
+
+// Primes возвращает источник простых чисел 2,3,5,7 и т.д. Простота
+// проверяется делением на уже найденные простые числа, не превосходящие
+// квадратного корня из кандидата. Reset начинает последовательность
+// заново.
+func Primes() Source[int64] {
+	return newRestartable(primes)
+}
+
+// primes создаёт источник Primes без Reset.
+func primes() Source[int64] {
+	var found []int64 // найденные простые числа
+	return SourceFunc[int64](func(context.Context) (int64, bool) {
+		candidate := int64(2)
+		if n := len(found); n > 0 {
+			candidate = found[n-1] + 1
+		}
+		for ; candidate > 0; candidate++ {
+			prime := true
+			for _, p := range found {
+				if p*p > candidate {
+					break
+				}
+				if candidate%p == 0 {
+					prime = false
+					break
+				}
+			}
+			if prime {
+				found = append(found, candidate)
+				return candidate, true
+			}
+		}
+		return 0, false
+	})
+}
+
+// restartable — источник, который Reset создаёт заново функцией start.
+type restartable[T any] struct {
+	start func() Source[T]
+	src   Source[T]
+}
+
+// newRestartable создаёт источник start() с методом Reset.
+func newRestartable[T any](start func() Source[T]) *restartable[T] {
+	return &restartable[T]{start: start, src: start()}
+}
+
+// Next возвращает очередное значение текущего источника.
+func (s *restartable[T]) Next(ctx context.Context) (T, bool) {
+	return s.src.Next(ctx)
+}
+
+// Reset создаёт источник заново.
+func (s *restartable[T]) Reset() {
+	s.src = s.start()
+}
+
+// ReaderSource читает целые числа, разделённые пробельными символами
+// (например, по одному в строке), из io.Reader.
+type ReaderSource struct {
+	scanner *bufio.Scanner
+	read    int   // количество прочитанных чисел
+	err     error // ошибка чтения или разбора
+}
+
+// NewReaderSource создаёт источник, читающий числа из r: файла, os.Stdin и т.п.
+func NewReaderSource(r io.Reader) *ReaderSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	return &ReaderSource{scanner: scanner}
+}
+
+// Next возвращает очередное число. При достижении конца данных или ошибке
+// возвращается false; ошибку можно получить методом Err.
+func (s *ReaderSource) Next(context.Context) (int64, bool) {
+	if s.err != nil || !s.scanner.Scan() {
+		if s.err == nil {
+			s.err = s.scanner.Err()
+		}
+		return 0, false
+	}
+	s.read++
+	v, err := strconv.ParseInt(s.scanner.Text(), 10, 64)
+	if err != nil {
+		s.err = fmt.Errorf("число %d: %w", s.read, err)
+		return 0, false
+	}
+	return v, true
+}
+
+// Err возвращает первую ошибку чтения или разбора, если она была.
+func (s *ReaderSource) Err() error {
+	return s.err
+}
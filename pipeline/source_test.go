@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// take возвращает до n первых значений источника src.
+func take(src Source[int64], n int) []int64 {
+	var got []int64
+	for len(got) < n {
+		v, ok := src.Next(context.Background())
+		if !ok {
+			break
+		}
+		got = append(got, v)
+	}
+	return got
+}
+
+func TestSources(t *testing.T) {
+	tests := []struct {
+		name string
+		src  Source[int64]
+		n    int
+		want []int64
+	}{
+		{"последовательность", Sequential(), 5, []int64{1, 2, 3, 4, 5}},
+		{"Фибоначчи", Fibonacci(), 7, []int64{1, 1, 2, 3, 5, 8, 13}},
+		{"простые числа", Primes(), 8, []int64{2, 3, 5, 7, 11, 13, 17, 19}},
+		{"чтение", NewReaderSource(strings.NewReader("3 -1\n2")), 5, []int64{3, -1, 2}},
+		{"ошибка чтения", NewReaderSource(strings.NewReader("3 x 2")), 5, []int64{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := take(tt.src, tt.n); !slices.Equal(got, tt.want) {
+				t.Errorf("источник выдал %v, want %v", got, tt.want)
+			}
+			if r, ok := tt.src.(Resetter); ok {
+				r.Reset()
+				if got := take(tt.src, tt.n); !slices.Equal(got, tt.want) {
+					t.Errorf("после Reset источник выдал %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if got := take(Fibonacci(), 200); len(got) != 91 || got[90] != 4660046610375530309 {
+		t.Errorf("Фибоначчи до переполнения: %d чисел, want 91 с последним 4660046610375530309", len(got))
+	}
+	r := NewReaderSource(strings.NewReader("1 x"))
+	take(r, 5)
+	if r.Err() == nil || !strings.Contains(r.Err().Error(), "число 2") {
+		t.Errorf("Err = %v, want ошибку разбора числа 2", r.Err())
+	}
+	if a, b := take(Random(7, 100), 20), take(Random(7, 100), 20); !slices.Equal(a, b) || slices.ContainsFunc(a, func(v int64) bool { return v < 0 || v >= 100 }) {
+		t.Errorf("Random(7, 100) выдал %v и %v, want одинаковые числа из [0, 100)", a, b)
+	}
+}
+
+// TestGeneratorSourceExhausted проверяет, что Generator закрывает канал,
+// когда источник исчерпан.
+func TestGeneratorSourceExhausted(t *testing.T) {
+	ch := make(chan int64)
+	go Generator(context.Background(), ch, NewReaderSource(strings.NewReader("5 4 3")))
+	if got := collect(ch); !slices.Equal(got, []int64{5, 4, 3}) {
+		t.Errorf("получено %v, want [5 4 3]", got)
+	}
+}
+
+// TestLimitSources проверяет, что limitSources ограничивает общее
+// количество значений всех источников.
+func TestLimitSources(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int64
+		want  int
+	}{
+		{"без ограничения", 0, 20},
+		{"меньше запаса", 7, 7},
+		{"больше запаса", 50, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			digits := "0 1 2 3 4 5 6 7 8 9"
+			srcs := limitSources([]Source[int64]{NewReaderSource(strings.NewReader(digits)), NewReaderSource(strings.NewReader(digits))}, tt.limit)
+			got := len(take(srcs[0], 4)) + len(take(srcs[1], 100)) + len(take(srcs[0], 100))
+			if got != tt.want {
+				t.Errorf("получено %d значений, want %d", got, tt.want)
+			}
+		})
+	}
+}
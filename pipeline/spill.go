@@ -0,0 +1,314 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/queue"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spillover пересылает числа из канала in в канал out через очередь FIFO.
+// Пока в памяти меньше threshold чисел, они хранятся в слайсе; остальные
+// записываются во временный файл в каталоге dir (пустая строка — системный
+// каталог) и читаются обратно, когда потребитель освобождает очередь.
+// Порядок чисел сохраняется. Файл удаляется перед выходом из функции.
+// Формат записи в файле: 1 байт длины, затем все поля Event, как их
+// кодирует encodeEvent.
+// Born читается из файла без показаний монотонных часов. span трассировки
+// в файл не пишется и хранится в памяти до чтения записи обратно.
+// Параметры
+// in - канал, откуда будут прочитаны числа
+// out - канал, куда будут записаны числа
+// threshold - максимальное количество чисел в памяти
+// dir - каталог для временного файла
+// При ошибке ввода-вывода out закрывается и ошибка возвращается.
+func Spillover(in <-chan Event, out chan<- Event, threshold int, dir string) (err error) {
+	defer close(out) // перед выходом из функции закрываем канал out
+
+	if threshold < 1 {
+		return fmt.Errorf("недопустимый порог очереди: %d", threshold)
+	}
+
+	var (
+		mem     []Event      // очередь в памяти
+		file    *os.File     // файл для вытесненных чисел, создаётся при необходимости
+		wOff    int64        // смещение записи в файле
+		rOff    int64        // смещение чтения из файла
+		onDisk  int          // количество непрочитанных чисел в файле
+		spans   []trace.Span // span чисел в файле в порядке записи
+		buf     [1 + eventFields*binary.MaxVarintLen64]byte
+		closeIn bool // закрыт ли канал in
+	)
+	defer func() {
+		if file != nil {
+			name := file.Name()
+			if cerr := file.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+			if rerr := os.Remove(name); rerr != nil && err == nil {
+				err = rerr
+			}
+		}
+	}()
+
+	// spill дописывает число в конец файла
+	spill := func(e Event) error {
+		if file == nil {
+			f, err := os.CreateTemp(dir, "spill-*.bin")
+			if err != nil {
+				return err
+			}
+			file = f
+		}
+		rec := append(buf[:1], encodeEvent(e)...)
+		rec[0] = byte(len(rec) - 1)
+		if _, err := file.WriteAt(rec, wOff); err != nil {
+			return err
+		}
+		wOff += int64(len(rec))
+		onDisk++
+		spans = append(spans, e.span)
+		return nil
+	}
+
+	// corrupted описывает повреждённую запись по смещению чтения
+	corrupted := func() error {
+		return fmt.Errorf("повреждённая запись в файле %s по смещению %d", file.Name(), rOff)
+	}
+
+	// refill переносит из файла в память до threshold чисел
+	refill := func() error {
+		for onDisk > 0 && len(mem) < threshold {
+			if _, err := file.ReadAt(buf[:1], rOff); err != nil {
+				return err
+			}
+			n := int(buf[0])
+			if n >= len(buf) {
+				return corrupted()
+			}
+			if _, err := file.ReadAt(buf[1:1+n], rOff+1); err != nil {
+				return err
+			}
+			e, err := decodeEvent(buf[1 : 1+n])
+			if err != nil {
+				return corrupted()
+			}
+			e.span, spans[0] = spans[0], nil
+			spans = spans[1:]
+			mem = append(mem, e)
+			rOff += int64(1 + n)
+			onDisk--
+		}
+		if onDisk == 0 && file != nil {
+			// файл прочитан полностью — начинаем его заново
+			wOff, rOff = 0, 0
+			return file.Truncate(0)
+		}
+		return nil
+	}
+
+	for {
+		if len(mem) == 0 && onDisk > 0 {
+			if err := refill(); err != nil {
+				return err
+			}
+		}
+		if closeIn && len(mem) == 0 {
+			return nil
+		}
+
+		// нулевые каналы отключают соответствующие ветки select
+		var src <-chan Event
+		if !closeIn {
+			src = in
+		}
+		var dst chan<- Event
+		var head Event
+		if len(mem) > 0 {
+			dst, head = out, mem[0]
+		}
+
+		select {
+		case v, ok := <-src:
+			if !ok {
+				closeIn = true
+				continue
+			}
+			if onDisk == 0 && len(mem) < threshold {
+				mem = append(mem, v)
+				continue
+			}
+			if err := spill(v); err != nil {
+				return err
+			}
+		case dst <- head:
+			mem = mem[1:]
+		}
+	}
+}
+
+// spillQueue пересылает числа из in в out через очередь q с вытеснением на
+// диск, чтобы генератор не ждал обработчиков, пока очередь не заполнена.
+// Числа записываются в очередь со всеми полями Event, см. encodeEvent;
+// span трассировки хранится в памяти до выхода числа из очереди. Когда
+// очередь заполнена, число из in придерживается и новые не читаются, пока
+// обработчики не освободят место. out закрывается, когда in закрыт и
+// очередь пуста. При отмене ctx или ошибке очереди, которая передаётся в
+// fail, все непереданные числа передаются в drop.
+func spillQueue(ctx context.Context, q *queue.Queue, in <-chan Event, out chan<- Event, drop func(Event), fail func(error)) {
+	defer close(out)
+
+	spans := make(map[int64]trace.Span) // span чисел в очереди
+	var (
+		head, held       Event // первое число очереди и придержанное число из in
+		hasHead, hasHeld bool
+	)
+	// push помещает число в очередь; при заполненной очереди число
+	// придерживается
+	push := func(e Event) error {
+		err := q.Push(encodeEvent(e))
+		if errors.Is(err, queue.ErrFull) {
+			held, hasHeld = e, true
+			return nil
+		}
+		if err == nil && e.span != nil {
+			spans[e.Seq] = e.span
+		}
+		return err
+	}
+	// pop извлекает из очереди первое число
+	pop := func() error {
+		rec, ok, err := q.Pop()
+		if err != nil || !ok {
+			return err
+		}
+		if head, err = decodeEvent(rec); err != nil {
+			return err
+		}
+		head.span = spans[head.Seq]
+		delete(spans, head.Seq)
+		hasHead = true
+		return nil
+	}
+	// dropAll отбрасывает все непереданные числа
+	dropAll := func() {
+		for hasHead {
+			drop(head)
+			hasHead = false
+			if pop() != nil {
+				break
+			}
+		}
+		if hasHeld {
+			drop(held)
+		}
+		if in != nil {
+			for e := range in {
+				drop(e)
+			}
+		}
+	}
+
+	for in != nil || hasHead || hasHeld {
+		recv, send := in, out
+		if hasHeld {
+			recv = nil
+		}
+		if !hasHead {
+			send = nil
+		}
+		var err error
+		select {
+		case <-ctx.Done():
+			dropAll()
+			return
+		case e, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			err = push(e)
+		case send <- head:
+			hasHead = false
+			// место освободилось, придержанное число снова пробуем
+			// поместить в очередь
+			if hasHeld {
+				hasHeld = false
+				err = push(held)
+			}
+		}
+		if err == nil && !hasHead {
+			err = pop()
+		}
+		if err == nil && !hasHead && hasHeld {
+			// придержанное число больше, чем помещается в пустую очередь
+			err = queue.ErrFull
+		}
+		if err != nil {
+			fail(fmt.Errorf("очередь с вытеснением на диск: %w", err))
+			dropAll()
+			return
+		}
+	}
+}
+
+// eventFields — количество полей Event в записи очереди.
+const eventFields = 8
+
+// encodeEvent кодирует поля числа e для очереди: Value, Born, Seq, Source,
+// время окончания обработки, номер в источнике, Priority и Attempts —
+// каждое в кодировке varint, время — в наносекундах Unix, 0 — нулевое
+// время. span в запись не попадает.
+func encodeEvent(e Event) []byte {
+	buf := make([]byte, 0, eventFields*binary.MaxVarintLen64)
+	for _, v := range [eventFields]int64{e.Value, unixNano(e.Born), e.Seq, int64(e.Source), unixNano(e.sent), e.pos, int64(e.Priority), int64(e.Attempts)} {
+		buf = binary.AppendVarint(buf, v)
+	}
+	return buf
+}
+
+// decodeEvent восстанавливает число, закодированное encodeEvent.
+func decodeEvent(rec []byte) (Event, error) {
+	var fields [eventFields]int64
+	for i := range fields {
+		v, n := binary.Varint(rec)
+		if n <= 0 {
+			return Event{}, errors.New("повреждена запись очереди")
+		}
+		fields[i], rec = v, rec[n:]
+	}
+	if len(rec) > 0 {
+		return Event{}, fmt.Errorf("повреждена запись очереди: %d лишних байт", len(rec))
+	}
+	return Event{
+		Value:    fields[0],
+		Born:     fromUnixNano(fields[1]),
+		Seq:      fields[2],
+		Source:   int(fields[3]),
+		Priority: int(fields[6]),
+		Attempts: int(fields[7]),
+		sent:     fromUnixNano(fields[4]),
+		pos:      fields[5],
+	}, nil
+}
+
+// unixNano возвращает время t в наносекундах Unix; 0 — нулевое время.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano восстанавливает время, записанное unixNano.
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,255 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline/queue"
+)
+
+// TestSpillover проверяет, что числа, не поместившиеся в память, проходят
+// через файл без потерь и в исходном порядке, а файл удаляется.
+func TestSpillover(t *testing.T) {
+	dir := t.TempDir()
+	in, out := make(chan Event), make(chan Event)
+	done := make(chan error, 1)
+	go func() { done <- Spillover(in, out, 4, dir) }()
+
+	// потребитель не читает, пока производитель не отправит все числа;
+	// у нечётных чисел нет времени генерации
+	born := time.Unix(1700000000, 123456789)
+	var want []Event
+	for v := int64(1); v <= 1000; v++ {
+		e := Event{Value: v, Seq: v, Source: int(v % 3), Priority: int(v % 4), Attempts: int(v%2) + 1, pos: v * 2}
+		if v%2 == 0 {
+			e.Born = born.Add(time.Duration(v))
+			e.sent = e.Born.Add(time.Millisecond)
+		}
+		want = append(want, e)
+		in <- e
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("файлов в каталоге %d, want 1", len(files))
+	}
+	close(in)
+	got := collect(out)
+	if !slices.EqualFunc(got, want, sameEvent) {
+		t.Errorf("получено %d значений, want 1..1000 по порядку со всеми полями", len(got))
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Spillover = %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("после завершения в каталоге осталось файлов: %d", len(files))
+	}
+
+	if err := Spillover(make(chan Event), make(chan Event), 0, dir); err == nil {
+		t.Error("Spillover с порогом 0 не вернул ошибку")
+	}
+}
+
+// TestRunSpillSlowSink проверяет, что при медленном приёмнике числа
+// вытесняются в файл, все доходят до приёмника, а файл удаляется.
+func TestRunSpillSlowSink(t *testing.T) {
+	dir := t.TempDir()
+	spilled := false
+	var got []int64
+	p := New(Config{NumWorkers: 3, Limit: 300, SpillThreshold: 4, SpillDir: dir, Collect: func(v int64) error {
+		if len(got) == 0 {
+			// приёмник ждёт, пока очередь не начнёт вытеснять числа в файл
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				if files, _ := os.ReadDir(dir); len(files) > 0 {
+					spilled = true
+					break
+				}
+			}
+		}
+		got = append(got, v)
+		return nil
+	}})
+	stats, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if !spilled {
+		t.Error("числа не вытеснялись в файл")
+	}
+	if err := stats.Verify(); err != nil {
+		t.Error(err)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, ints(1, 300)) {
+		t.Errorf("приёмник получил %d чисел, want 1..300", len(got))
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("после Run в каталоге осталось файлов: %d", len(files))
+	}
+	for _, ch := range p.Channels() {
+		if !ch.Closed || ch.Len != 0 {
+			t.Errorf("канал %s: закрыт %v, значений %d; want закрыт и пуст", ch.Name, ch.Closed, ch.Len)
+		}
+	}
+}
+
+func TestEventCodec(t *testing.T) {
+	born := time.Unix(1700000000, 123456789)
+	tests := []struct {
+		name string
+		e    Event
+	}{
+		{"нулевое", Event{}},
+		{"только значение", Event{Value: 42}},
+		{"отрицательные", Event{Value: -7, Seq: -1}},
+		{"все поля", Event{Value: 1 << 62, Born: born, Seq: 99, Source: 2, Priority: 3, Attempts: 4, sent: born.Add(time.Millisecond), pos: 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeEvent(encodeEvent(tt.e))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameEvent(got, tt.e) {
+				t.Errorf("decodeEvent = %+v, want %+v", got, tt.e)
+			}
+		})
+	}
+}
+
+// sameEvent сообщает, совпадают ли все поля a и b, которые переживают
+// вытеснение на диск.
+func sameEvent(a, b Event) bool {
+	return a.Value == b.Value && a.Seq == b.Seq && a.Source == b.Source && a.Priority == b.Priority &&
+		a.Attempts == b.Attempts && a.pos == b.pos && a.Born.Equal(b.Born) && a.sent.Equal(b.sent)
+}
+
+func TestDecodeEventCorrupt(t *testing.T) {
+	rec := encodeEvent(Event{Value: 5, Born: time.Unix(1, 0), Seq: 1})
+	for name, rec := range map[string][]byte{
+		"пустая":      nil,
+		"обрезанная":  rec[:len(rec)-1],
+		"лишние байт": append(slices.Clone(rec), 0),
+	} {
+		if _, err := decodeEvent(rec); err == nil {
+			t.Errorf("%s: decodeEvent без ошибки", name)
+		}
+	}
+}
+
+// TestSpillQueueKeepsFields проверяет, что числа выходят из очереди в
+// порядке поступления со всеми полями, в том числе вытесненные на диск.
+func TestSpillQueueKeepsFields(t *testing.T) {
+	q, err := queue.Open(queue.Options{Dir: t.TempDir(), MemoryRecords: 2, SegmentRecords: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	const n = 50
+	event := func(i int64) Event {
+		return Event{Value: i * 10, Born: time.Unix(0, i), Seq: i, Source: int(i % 2), Priority: int(i % 3), Attempts: 2, sent: time.Unix(0, 2*i), pos: i}
+	}
+	in, out := make(chan Event), make(chan Event)
+	go func() {
+		for i := int64(1); i <= n; i++ {
+			in <- event(i)
+		}
+		close(in)
+	}()
+	var fails []error
+	go spillQueue(context.Background(), q, in, out, func(Event) { t.Error("число отброшено") }, func(err error) { fails = append(fails, err) })
+	// числа читаются после заполнения очереди, чтобы они вытеснялись на диск
+	time.Sleep(10 * time.Millisecond)
+	var i int64
+	for e := range out {
+		i++
+		want := event(i)
+		if !sameEvent(e, want) {
+			t.Fatalf("число %d = %+v, want %+v", i, e, want)
+		}
+	}
+	if i != n || len(fails) > 0 {
+		t.Fatalf("получено %d чисел из %d, ошибки %v", i, n, fails)
+	}
+}
+
+// TestSpillQueueFull проверяет, что при заполненной очереди генератор
+// ждёт обработчиков, а не теряет числа.
+func TestSpillQueueFull(t *testing.T) {
+	q, err := queue.Open(queue.Options{Dir: t.TempDir(), MemoryRecords: 1, MaxDiskBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	res, err := Run(context.Background(), Config{NumWorkers: 2, Limit: 500, WorkerDelay: 10 * time.Microsecond, Spill: q})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if res.OutputCount != 500 || q.Len() != 0 {
+		t.Errorf("дошло %d чисел из 500, в очереди осталось %d", res.OutputCount, q.Len())
+	}
+}
+
+// TestRunSpill проверяет, что конвейер с очередью с вытеснением на диск
+// дообрабатывает все числа и что числа очереди отбрасываются при отмене.
+func TestRunSpill(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		drain bool // все числа должны дойти до приёмника
+	}{
+		{"все числа", Config{NumWorkers: 4, Limit: 2000}, true},
+		{"медленные обработчики", Config{NumWorkers: 1, Limit: 200, WorkerDelay: 100 * time.Microsecond, VerifySequence: true}, true},
+		{"остановка по таймауту", Config{NumWorkers: 1, Timeout: 10 * time.Millisecond, WorkerDelay: time.Millisecond, Drain: DropRemaining}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := queue.Open(queue.Options{Dir: t.TempDir(), MemoryRecords: 8, SegmentRecords: 16})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer q.Close()
+			cfg := tt.cfg
+			cfg.Spill = q
+			res, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := res.Verify(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.drain && res.OutputCount != res.InputCount {
+				t.Errorf("дошло %d чисел из %d", res.OutputCount, res.InputCount)
+			}
+			if q.Len() != 0 {
+				t.Errorf("в очереди осталось %d записей", q.Len())
+			}
+		})
+	}
+}
+
+func TestValidateSpill(t *testing.T) {
+	q, err := queue.Open(queue.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	cfg := DefaultConfig()
+	cfg.Spill = q
+	cfg.AdaptiveBuffer.Target = 0.1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate принимает очередь вместе с AdaptiveBuffer")
+	}
+	cfg.AdaptiveBuffer = AdaptiveBufferPolicy{}
+	if err := q.Push(encodeEvent(Event{Value: 1})); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate принимает непустую очередь")
+	}
+}
@@ -0,0 +1,248 @@
+// Package sqlio сохраняет результаты конвейера в базу данных SQLite или
+// PostgreSQL: в таблицу runs — настройки, длительность и результат проверки
+// каждого запуска, а в таблицу run_values — числа результирующего канала,
+// чтобы запуски можно было сравнивать запросами SQL. Драйвер базы данных
+// регистрируется пакетом, импортированным ради побочного эффекта, например
+// modernc.org/sqlite или github.com/lib/pq.
+package sqlio
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+)
+
+// DefaultBatchSize — размер пачки Sink по умолчанию.
+const DefaultBatchSize = 100
+
+// maxBatchSize — наибольший размер пачки: запрос вставки пачки не должен
+// превышать ограничение SQLite в 999 параметров.
+const maxBatchSize = 999 / 3
+
+// dialect — различия диалектов SQL поддерживаемых баз данных.
+type dialect struct {
+	id        string // объявление столбца-идентификатора таблицы runs
+	real      string // тип чисел с плавающей точкой
+	returning bool   // идентификатор вставленной строки возвращается RETURNING
+	numbered  bool   // параметры запроса нумеруются: $1, $2 и т.д.
+	// timeText — время хранится строкой в формате, который понимают функции
+	// даты SQLite
+	timeText bool
+}
+
+// dialects — диалекты по именам драйверов database/sql.
+var dialects = map[string]dialect{
+	"sqlite":   {id: "INTEGER PRIMARY KEY AUTOINCREMENT", real: "REAL", timeText: true},
+	"sqlite3":  {id: "INTEGER PRIMARY KEY AUTOINCREMENT", real: "REAL", timeText: true},
+	"postgres": {id: "BIGSERIAL PRIMARY KEY", real: "DOUBLE PRECISION", returning: true, numbered: true},
+	"pgx":      {id: "BIGSERIAL PRIMARY KEY", real: "DOUBLE PRECISION", returning: true, numbered: true},
+}
+
+// param возвращает обозначение n-го параметра запроса, начиная с 1.
+func (d dialect) param(n int) string {
+	if d.numbered {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// time возвращает значение параметра запроса для времени t.
+func (d dialect) time(t time.Time) any {
+	if d.timeText {
+		return t.UTC().Format("2006-01-02 15:04:05.000")
+	}
+	return t.UTC()
+}
+
+// schema возвращает запросы создания таблиц.
+func (d dialect) schema() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS runs (
+	id ` + d.id + `,
+	started_at TIMESTAMP NOT NULL,
+	config TEXT NOT NULL,
+	duration_seconds ` + d.real + `,
+	input_count BIGINT,
+	input_sum BIGINT,
+	output_count BIGINT,
+	output_sum BIGINT,
+	dropped_count BIGINT,
+	skipped_count BIGINT,
+	failed_count BIGINT,
+	throughput ` + d.real + `,
+	stop_cause TEXT,
+	verified BOOLEAN,
+	error TEXT
+)`,
+		`CREATE TABLE IF NOT EXISTS run_values (
+	run_id BIGINT NOT NULL REFERENCES runs (id),
+	seq BIGINT NOT NULL,
+	value BIGINT NOT NULL,
+	PRIMARY KEY (run_id, seq)
+)`,
+	}
+}
+
+// DB — база данных с таблицами runs и run_values.
+type DB struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// Open подключается к базе данных dsn драйвером driver и создаёт таблицы,
+// если их нет. Поддерживаются драйверы sqlite, sqlite3, postgres и pgx.
+func Open(ctx context.Context, driver, dsn string) (*DB, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемый драйвер базы данных %q", driver)
+	}
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("драйвер базы данных %q не зарегистрирован: нужен импорт пакета драйвера", driver)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range d.schema() {
+		if _, err := db.ExecContext(ctx, q); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("создание таблиц: %w", err)
+		}
+	}
+	return &DB{db: db, dialect: d}, nil
+}
+
+// Close закрывает подключение к базе данных.
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// Run — запись о запуске конвейера в таблице runs.
+type Run struct {
+	ID int64 // идентификатор строки runs
+
+	db *DB
+}
+
+// StartRun добавляет в runs запись о запуске, начатом в started, с
+// настройками config, сохранёнными в виде JSON.
+func (db *DB) StartRun(ctx context.Context, started time.Time, config map[string]string) (*Run, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	q := "INSERT INTO runs (started_at, config) VALUES (" + db.dialect.param(1) + ", " + db.dialect.param(2) + ")"
+	run := &Run{db: db}
+	if db.dialect.returning {
+		err = db.db.QueryRowContext(ctx, q+" RETURNING id", db.dialect.time(started), string(data)).Scan(&run.ID)
+	} else {
+		var res sql.Result
+		if res, err = db.db.ExecContext(ctx, q, db.dialect.time(started), string(data)); err == nil {
+			run.ID, err = res.LastInsertId()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("запись о запуске: %w", err)
+	}
+	return run, nil
+}
+
+// Finish дописывает в запись о запуске итоги res и результат проверки
+// verifyErr.
+func (r *Run) Finish(ctx context.Context, res pipeline.Result, verifyErr error) error {
+	var stopCause, errText sql.NullString
+	if res.StopCause != nil {
+		stopCause = sql.NullString{String: res.StopCause.Error(), Valid: true}
+	}
+	if verifyErr != nil {
+		errText = sql.NullString{String: verifyErr.Error(), Valid: true}
+	}
+	columns := []string{
+		"duration_seconds", "input_count", "input_sum", "output_count", "output_sum",
+		"dropped_count", "skipped_count", "failed_count", "throughput", "stop_cause",
+		"verified", "error",
+	}
+	args := []any{
+		res.Duration.Seconds(), res.InputCount, res.InputSum, res.OutputCount, res.OutputSum,
+		res.DroppedCount, res.SkippedCount, res.FailedCount, res.Throughput(), stopCause,
+		verifyErr == nil, errText,
+	}
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		set[i] = c + " = " + r.db.dialect.param(i+1)
+	}
+	q := "UPDATE runs SET " + strings.Join(set, ", ") + " WHERE id = " + r.db.dialect.param(len(columns)+1)
+	if _, err := r.db.db.ExecContext(ctx, q, append(args, r.ID)...); err != nil {
+		return fmt.Errorf("итоги запуска %d: %w", r.ID, err)
+	}
+	return nil
+}
+
+// Sink — приёмник, записывающий числа в таблицу run_values пачками, каждую
+// одним запросом. Вызовы методов не должны пересекаться.
+type Sink struct {
+	run   *Run
+	size  int
+	seq   int64   // номер последнего числа в результирующем канале
+	batch []int64 // накопленные числа
+}
+
+// Sink создаёт приёмник чисел запуска r, записывающий пачки по size чисел
+// (меньше 1 — DefaultBatchSize). Слишком большие пачки уменьшаются до
+// ограничения количества параметров запроса.
+func (r *Run) Sink(size int) *Sink {
+	if size < 1 {
+		size = DefaultBatchSize
+	}
+	if size > maxBatchSize {
+		size = maxBatchSize
+	}
+	return &Sink{run: r, size: size}
+}
+
+// Write добавляет v в пачку и записывает её, когда она заполнена.
+func (s *Sink) Write(ctx context.Context, v int64) error {
+	s.batch = append(s.batch, v)
+	if len(s.batch) < s.size {
+		return nil
+	}
+	return s.send(ctx)
+}
+
+// Flush записывает неполную последнюю пачку.
+func (s *Sink) Flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	return s.send(context.Background())
+}
+
+// send записывает накопленную пачку. Номера seq — порядок чисел в
+// результирующем канале, начиная с 1.
+func (s *Sink) send(ctx context.Context) error {
+	d := s.run.db.dialect
+	var q strings.Builder
+	q.WriteString("INSERT INTO run_values (run_id, seq, value) VALUES ")
+	args := make([]any, 0, 3*len(s.batch))
+	for i, v := range s.batch {
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&q, "(%s, %s, %s)", d.param(n+1), d.param(n+2), d.param(n+3))
+		s.seq++
+		args = append(args, s.run.ID, s.seq, v)
+	}
+	s.batch = s.batch[:0]
+	if _, err := s.run.db.db.ExecContext(ctx, q.String(), args...); err != nil {
+		return fmt.Errorf("запись чисел запуска %d: %w", s.run.ID, err)
+	}
+	return nil
+}
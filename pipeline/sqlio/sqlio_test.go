@@ -0,0 +1,136 @@
+package sqlio
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PhilippNikitin/go-project-sprint-9/pipeline"
+	_ "modernc.org/sqlite"
+)
+
+func TestOpenDriver(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+	}{
+		{"неизвестный", "mysql"},
+		{"не зарегистрирован", "pgx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if db, err := Open(context.Background(), tt.driver, ""); err == nil {
+				db.Close()
+				t.Errorf("Open(%q) без ошибки", tt.driver)
+			}
+		})
+	}
+}
+
+// TestRun проверяет, что запуск записывается в runs вместе с итогами, а
+// числа приёмника — в run_values по порядку, в том числе неполная
+// последняя пачка.
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "runs.db")
+	db, err := Open(ctx, "sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name      string
+		values    []int64
+		batch     int
+		verifyErr error
+	}{
+		{"проверка пройдена", []int64{3, 1, 2, 5, 4}, 2, nil},
+		{"проверка не пройдена", []int64{7}, 0, errors.New("суммы не совпадают")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, err := db.StartRun(ctx, time.Now(), map[string]string{"workers": "5"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := run.Sink(tt.batch)
+			var sum int64
+			for _, v := range tt.values {
+				if err := sink.Write(ctx, v); err != nil {
+					t.Fatal(err)
+				}
+				sum += v
+			}
+			if err := sink.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			n := int64(len(tt.values))
+			res := pipeline.Result{Snapshot: pipeline.Snapshot{InputCount: n, InputSum: sum, OutputCount: n, OutputSum: sum}, Duration: time.Second}
+			if err := run.Finish(ctx, res, tt.verifyErr); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				config   string
+				count    int64
+				verified bool
+				errText  sql.NullString
+			)
+			err = db.db.QueryRowContext(ctx, "SELECT config, output_count, verified, error FROM runs WHERE id = ?", run.ID).Scan(&config, &count, &verified, &errText)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config != `{"workers":"5"}` || count != int64(len(tt.values)) || verified != (tt.verifyErr == nil) || errText.Valid != (tt.verifyErr != nil) {
+				t.Errorf("запуск: config %s, output_count %d, verified %v, error %v", config, count, verified, errText)
+			}
+
+			rows, err := db.db.QueryContext(ctx, "SELECT seq, value FROM run_values WHERE run_id = ? ORDER BY seq", run.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var got []int64
+			for rows.Next() {
+				var seq, v int64
+				if err := rows.Scan(&seq, &v); err != nil {
+					t.Fatal(err)
+				}
+				if seq != int64(len(got)+1) {
+					t.Errorf("seq %d, want %d", seq, len(got)+1)
+				}
+				got = append(got, v)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.values) {
+				t.Fatalf("в run_values %v, want %v", got, tt.values)
+			}
+			for i := range got {
+				if got[i] != tt.values[i] {
+					t.Errorf("в run_values %v, want %v", got, tt.values)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSinkBatchSize(t *testing.T) {
+	tests := []struct {
+		size, want int
+	}{
+		{0, DefaultBatchSize},
+		{10, 10},
+		{5000, maxBatchSize},
+	}
+	for _, tt := range tests {
+		if got := (&Run{}).Sink(tt.size).size; got != tt.want {
+			t.Errorf("Sink(%d).size = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
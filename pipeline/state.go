@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChannelState — состояние канала конвейера после завершения Run.
+type ChannelState struct {
+	Name   string // chIn, outs[i], chOut или chSpill
+	Closed bool   // канал закрыт
+	Len    int    // сколько значений осталось в буфере канала
+}
+
+// Channels возвращает состояние каналов chIn, outs[i], chOut и, если задан
+// SpillThreshold, chSpill после последнего завершившегося Run; до этого —
+// nil. Run возвращается только после завершения всех горутин конвейера, и
+// к этому моменту каждый канал должен быть закрыт своим отправителем и
+// дочитан получателем: открытый канал или оставшиеся в нём значения —
+// ошибка порядка закрытия, которую не видно по одному лишь завершению
+// горутин.
+func (p *Pipeline) Channels() []ChannelState {
+	return p.channels
+}
+
+// probeChannel определяет состояние канала ch с именем name. Вызывается,
+// когда все горутины, отправлявшие в ch, завершились: иначе проверка
+// закрытия могла бы забрать отправляемое значение. Закрыт ли канал, в
+// буфере которого остались значения, без их чтения не узнать, поэтому для
+// него Closed всегда false.
+func probeChannel[T any](name string, ch <-chan T) ChannelState {
+	st := ChannelState{Name: name, Len: len(ch)}
+	if st.Len > 0 {
+		return st
+	}
+	select {
+	case _, ok := <-ch:
+		st.Closed = !ok
+	default:
+	}
+	return st
+}
+
+// WorkerState — чем занят обработчик.
+type WorkerState int32
+
+const (
+	// WorkerStopped — обработчик не работает: ещё не запущен, завершён
+	// RemoveWorkers или конвейер остановлен
+	WorkerStopped    WorkerState = iota
+	WorkerWaiting                // ждёт числа
+	WorkerProcessing             // обрабатывает число
+	WorkerSending                // ждёт отправки результата
+)
+
+// String возвращает имя состояния.
+func (s WorkerState) String() string {
+	switch s {
+	case WorkerStopped:
+		return "stopped"
+	case WorkerWaiting:
+		return "waiting"
+	case WorkerProcessing:
+		return "processing"
+	case WorkerSending:
+		return "sending"
+	}
+	return fmt.Sprintf("WorkerState(%d)", int32(s))
+}
+
+// MarshalText возвращает имя состояния, чтобы в JSON состояние State было
+// записано строкой.
+func (s WorkerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// QueueDepths — количество чисел в каналах конвейера.
+type QueueDepths struct {
+	Input int // канал chIn между генератором и раздачей чисел
+	// Workers — очереди, из которых читает каждый обработчик; при Shared
+	// это один и тот же канал chIn
+	Workers []int
+	Outputs []int // каналы outs[i] от обработчиков к сборке
+	Result  int   // результирующий канал chOut
+}
+
+// State — живое состояние конвейера, которое возвращает Pipeline.Snapshot,
+// например для панели или отладочного HTTP-сервера. Все поля сериализуются
+// encoding/json.
+type State struct {
+	Running bool // Run работает
+	Paused  bool // конвейер на паузе, см. Pipeline.Pause
+	// Uptime — время от начала текущего запуска, а после его завершения —
+	// время работы последнего запуска
+	Uptime time.Duration
+	// LastValue — последнее сгенерированное число
+	LastValue int64
+	// Workers — состояние каждой ячейки обработчика; ячеек столько, сколько
+	// обработчиков может работать одновременно
+	Workers []WorkerState
+	// Queues — количество чисел в каналах; в пакетном режиме каналы после
+	// сборки пачек считают пачки
+	Queues QueueDepths
+	// Goroutines — имена работающих горутин конвейера по алфавиту, например
+	// "генератор" и "обработчик 2"
+	Goroutines []string
+	Stats      Snapshot // текущая статистика, как Pipeline.Stats
+}
+
+// runState — состояние запуска конвейера для Pipeline.Snapshot.
+type runState struct {
+	clock   Clock
+	start   time.Time
+	g       *group
+	last    atomic.Int64
+	workers []atomic.Int32 // WorkerState каждой ячейки
+	stopped atomic.Int64   // длительность запуска после завершения; 0 — работает
+
+	mu     sync.Mutex
+	depths func() QueueDepths // количество чисел в каналах
+}
+
+// newRunState создаёт состояние запуска с capacity ячейками обработчиков.
+func newRunState(clock Clock, g *group, capacity int) *runState {
+	return &runState{clock: clock, start: clock.Now(), g: g, workers: make([]atomic.Int32, capacity)}
+}
+
+// setDepths задаёт подсчёт чисел в каналах запуска.
+func (s *runState) setDepths(depths func() QueueDepths) {
+	s.mu.Lock()
+	s.depths = depths
+	s.mu.Unlock()
+}
+
+// setWorker отмечает состояние обработчика i.
+func (s *runState) setWorker(i int, state WorkerState) {
+	s.workers[i].Store(int32(state))
+}
+
+// finish отмечает завершение запуска.
+func (s *runState) finish() {
+	s.stopped.Store(int64(max(s.clock.Now().Sub(s.start), 1)))
+}
+
+// Snapshot возвращает живое состояние конвейера: состояние обработчиков,
+// количество чисел в каналах, работающие горутины и статистику. До первого
+// запуска заполнены только Paused и Stats. Можно вызывать из любой
+// горутины.
+func (p *Pipeline) Snapshot() State {
+	st := State{Paused: p.Paused(), Stats: p.Stats()}
+	s := p.state.Load()
+	if s == nil {
+		return st
+	}
+	if d := s.stopped.Load(); d > 0 {
+		st.Uptime = time.Duration(d)
+	} else {
+		st.Running, st.Uptime = true, s.clock.Now().Sub(s.start)
+	}
+	st.LastValue = s.last.Load()
+	st.Workers = make([]WorkerState, len(s.workers))
+	for i := range s.workers {
+		st.Workers[i] = WorkerState(s.workers[i].Load())
+	}
+	s.mu.Lock()
+	depths := s.depths
+	s.mu.Unlock()
+	if depths != nil {
+		st.Queues = depths()
+	}
+	st.Goroutines = s.g.names()
+	return st
+}
+
+// StateHandler возвращает обработчик HTTP, отвечающий на каждый запрос
+// JSON-объектом с состоянием конвейера p, см. Pipeline.Snapshot.
+func StateHandler(p *Pipeline) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Snapshot())
+	})
+}
@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// TestRunChannelsClosed проверяет, что после Run закрыты и дочитаны все
+// каналы конвейера, в том числе после ошибки этапа и немедленной отмены.
+func TestRunChannelsClosed(t *testing.T) {
+	failAll := func(context.Context, int64) (int64, error) { return 0, errOdd }
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		p    *Pipeline
+	}{
+		{"ограничение", context.Background(), New(Config{NumWorkers: 3, Limit: 50})},
+		{"ошибка всех обработчиков", context.Background(), New(Config{NumWorkers: 3, Process: failAll})},
+		{"ошибка приёмника", context.Background(), New(Config{NumWorkers: 2, Collect: func(int64) error { return errOdd }})},
+		{"отмена до запуска", cancelled, New(Config{NumWorkers: 4})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.p.Channels() != nil {
+				t.Fatal("Channels до Run не nil")
+			}
+			tt.p.Run(tt.ctx)
+			chans := tt.p.Channels()
+			if len(chans) != tt.p.cfg.NumWorkers+2 {
+				t.Fatalf("каналов %d, want %d", len(chans), tt.p.cfg.NumWorkers+2)
+			}
+			for _, ch := range chans {
+				if !ch.Closed || ch.Len != 0 {
+					t.Errorf("канал %s: закрыт %v, значений %d; want закрыт и пуст", ch.Name, ch.Closed, ch.Len)
+				}
+			}
+		})
+	}
+}
+
+func TestProbeChannel(t *testing.T) {
+	open := make(chan int64)
+	closed := make(chan int64)
+	close(closed)
+	buffered := make(chan int64, 2)
+	buffered <- 1
+	tests := []struct {
+		name string
+		ch   chan int64
+		want ChannelState
+	}{
+		{"открыт", open, ChannelState{Name: "открыт"}},
+		{"закрыт", closed, ChannelState{Name: "закрыт", Closed: true}},
+		{"не дочитан", buffered, ChannelState{Name: "не дочитан", Len: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := probeChannel(tt.name, tt.ch); got != tt.want {
+				t.Errorf("probeChannel = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if len(buffered) != 1 {
+		t.Error("probeChannel прочитал значение из буфера")
+	}
+}
+
+// TestPipelineSnapshot проверяет состояние конвейера до запуска, во время
+// обработки и после завершения.
+func TestPipelineSnapshot(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	p := New(Config{NumWorkers: 2, Limit: 20, Process: func(_ context.Context, v int64) (int64, error) {
+		if v == 5 {
+			once.Do(func() { close(started) })
+			<-release
+		}
+		return v, nil
+	}})
+	if st := p.Snapshot(); st.Running || st.Workers != nil || st.Goroutines != nil {
+		t.Errorf("до запуска %+v, want пустое состояние", st)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Run(context.Background())
+		done <- err
+	}()
+	<-started
+	st := p.Snapshot()
+	if !st.Running || len(st.Workers) != 2 || !slices.Contains(st.Workers, WorkerProcessing) {
+		t.Errorf("во время обработки работает %v, обработчики %v, want один в processing", st.Running, st.Workers)
+	}
+	if !slices.Contains(st.Goroutines, "генератор") || !slices.Contains(st.Goroutines, "обработчик 0") {
+		t.Errorf("горутины %q, want генератор и обработчики", st.Goroutines)
+	}
+	// число учитывается после отправки, поэтому 5 может ещё не быть
+	// учтено, но 4 — уже
+	if st.LastValue < 4 || len(st.Queues.Workers) != 2 || len(st.Queues.Outputs) != 2 {
+		t.Errorf("последнее число %d, очереди %+v", st.LastValue, st.Queues)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	st = p.Snapshot()
+	if st.Running || st.Uptime <= 0 || st.LastValue != 20 || st.Stats.OutputCount != 20 {
+		t.Errorf("после запуска %+v, want завершённый запуск 20 чисел", st)
+	}
+	if !slices.Equal(st.Workers, []WorkerState{WorkerStopped, WorkerStopped}) {
+		t.Errorf("обработчики %v, want stopped", st.Workers)
+	}
+	if len(st.Goroutines) != 0 {
+		t.Errorf("после запуска работают %q", st.Goroutines)
+	}
+}
+
+func TestStateHandler(t *testing.T) {
+	p := New(Config{NumWorkers: 2, Limit: 10})
+	if _, err := p.RunBatched(context.Background(), 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	StateHandler(p).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/state", nil))
+	var got struct {
+		Running   bool
+		LastValue int64
+		Workers   []string
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("ответ %q: %v", rec.Body.String(), err)
+	}
+	if got.Running || got.LastValue != 10 || !slices.Equal(got.Workers, []string{"stopped", "stopped"}) {
+		t.Errorf("состояние %+v, want завершённый запуск до 10", got)
+	}
+}
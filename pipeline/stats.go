@@ -0,0 +1,585 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"time"
+)
+
+// Stats собирает статистику конвейера: количество и сумму чисел на входе и
+// на выходе, разбивку по обработчикам, отброшенные, отфильтрованные и
+// необработанные числа, а также повторы обработки и зависания обработчиков.
+// Безопасен для конкурентного использования.
+//
+// Счётчики разбиты на отдельные ячейки по горутинам, которые в них пишут:
+// числа результирующего канала, отброшенные, отфильтрованные, повторы и
+// зависания — по обработчикам. Соседние ячейки разделены строкой кэша, чтобы эти
+// горутины не конкурировали за одни и те же атомарные переменные. Ячейки
+// суммируются в Snapshot.
+//
+// Суммы не переполняются молча: каждая ячейка считает, сколько раз её сумма
+// вышла за пределы int64, поэтому точную сумму можно восстановить, а
+// Snapshot отмечает переполнение в SumOverflow.
+//
+// Кроме сумм, для сгенерированных и учтённых чисел считаются контрольные
+// суммы, не зависящие от порядка чисел, см. Snapshot.InputChecksum.
+type Stats struct {
+	in      shardedCounter // сгенерированные числа
+	sources shardedCounter // сгенерированные числа, ячейка на источник; nil — не разбиваются
+	out     shardedCounter // числа результирующего канала, ячейка на обработчик
+	dropped shardedCounter // отброшенные числа, ячейка на обработчик
+	skipped shardedCounter // отфильтрованные числа, ячейка на обработчик
+	failed  shardedCounter // числа, обработка которых окончательно не удалась
+	retries shardedCounter // повторы обработки, ячейка на обработчик
+	stalls  shardedCounter // зависания, ячейка на обработчик
+
+	// duplicates — повторы, подавленные Config.Dedup
+	duplicates atomic.Int64
+
+	// unhealthy[i] — обработчик i сейчас завис
+	unhealthy []atomic.Bool
+
+	// ожидание отправки в наносекундах: генератора в chIn и обработчиков в
+	// outs[i], ячейка на обработчик
+	genBlocked shardedCounter
+	outBlocked shardedCounter
+	// ожидание семафора SinkLimit в наносекундах, ячейка на обработчик;
+	// сборка учитывается в ячейке 0
+	sinkBlocked shardedCounter
+
+	// время обработчиков в наносекундах по WorkerTiming, ячейка на
+	// обработчик; количество busy — количество взятых в обработку чисел
+	busy    shardedCounter
+	idle    shardedCounter
+	sending shardedCounter
+	errs    shardedCounter // окончательные ошибки обработки
+
+	// числа по классам приоритета, ячейка на класс: сгенерированные и
+	// дошедшие до результирующего канала; nil — не разбиваются
+	priorityIn  shardedCounter
+	priorityOut shardedCounter
+
+	// bigSums — Snapshot заполняет точные суммы BigSums
+	bigSums bool
+}
+
+// NewStats создаёт Stats для конвейера с numWorkers обработчиками.
+func NewStats(numWorkers int) *Stats {
+	return &Stats{
+		in:      make(shardedCounter, 1),
+		out:     make(shardedCounter, numWorkers),
+		dropped: make(shardedCounter, numWorkers),
+		skipped: make(shardedCounter, numWorkers),
+		failed:  make(shardedCounter, 1),
+		retries: make(shardedCounter, numWorkers),
+		stalls:  make(shardedCounter, numWorkers),
+
+		unhealthy: make([]atomic.Bool, numWorkers),
+
+		genBlocked: make(shardedCounter, 1),
+		outBlocked: make(shardedCounter, numWorkers),
+
+		sinkBlocked: make(shardedCounter, numWorkers),
+
+		busy:    make(shardedCounter, numWorkers),
+		idle:    make(shardedCounter, numWorkers),
+		sending: make(shardedCounter, numWorkers),
+		errs:    make(shardedCounter, numWorkers),
+	}
+}
+
+// newSourceStats создаёт Stats для конвейера с numWorkers обработчиками и
+// numSources источниками, который разбивает сгенерированные числа по
+// источникам.
+func newSourceStats(numWorkers, numSources int) *Stats {
+	s := NewStats(numWorkers)
+	s.sources = make(shardedCounter, numSources)
+	return s
+}
+
+// enablePriorities включает разбивку чисел по lanes классам приоритета.
+func (s *Stats) enablePriorities(lanes int) {
+	s.priorityIn = make(shardedCounter, lanes)
+	s.priorityOut = make(shardedCounter, lanes)
+}
+
+// EnableBigSums включает точные суммы Snapshot.BigSums. Их сборка выделяет
+// память при каждом вызове Snapshot, поэтому по умолчанию они выключены.
+func (s *Stats) EnableBigSums() {
+	s.bigSums = true
+}
+
+// RecordIn учитывает сгенерированное число v.
+func (s *Stats) RecordIn(v int64) {
+	s.in.addValue(0, v)
+}
+
+// recordSourceIn учитывает число v, сгенерированное источником source.
+func (s *Stats) recordSourceIn(source int, v int64) {
+	s.sources.add(source, v)
+	s.in.addValue(0, v)
+}
+
+// recordPriorityIn учитывает сгенерированное число класса приоритета
+// priority, если приоритеты включены.
+func (s *Stats) recordPriorityIn(priority int) {
+	if s.priorityIn != nil {
+		s.priorityIn.add(priority, 0)
+	}
+}
+
+// recordPriorityOut учитывает число класса приоритета priority, пришедшее в
+// результирующий канал, если приоритеты включены.
+func (s *Stats) recordPriorityOut(priority int) {
+	if s.priorityOut != nil {
+		s.priorityOut.add(priority, 0)
+	}
+}
+
+// RecordOut учитывает число v, пришедшее в результирующий канал от
+// обработчика workerID.
+func (s *Stats) RecordOut(workerID int, v int64) {
+	s.out.addValue(workerID, v)
+}
+
+// RecordDrop учитывает число v, отброшенное при остановке или ошибке
+// обработчиком workerID; числа, отброшенные вне обработчиков, учитываются
+// с workerID 0.
+func (s *Stats) RecordDrop(workerID int, v int64) {
+	s.dropped.addValue(workerID, v)
+}
+
+// RecordSkip учитывает число v, отфильтрованное обработчиком workerID.
+func (s *Stats) RecordSkip(workerID int, v int64) {
+	s.skipped.addValue(workerID, v)
+}
+
+// RecordFailed учитывает число v, обработка которого окончательно не
+// удалась и которое отправлено в канал недоставленных.
+func (s *Stats) RecordFailed(v int64) {
+	s.failed.addValue(0, v)
+}
+
+// recordDuplicate учитывает повтор, подавленный перед Collect и Sink.
+func (s *Stats) recordDuplicate() {
+	s.duplicates.Add(1)
+}
+
+// RecordRetry учитывает повтор обработки в обработчике workerID.
+func (s *Stats) RecordRetry(workerID int) {
+	s.retries.add(workerID, 0)
+}
+
+// markStalled учитывает зависание обработчика workerID и отмечает его как
+// неисправный.
+func (s *Stats) markStalled(workerID int) {
+	s.stalls.add(workerID, 0)
+	s.unhealthy[workerID].Store(true)
+}
+
+// markHealthy отмечает, что обработчик workerID больше не завис.
+func (s *Stats) markHealthy(workerID int) {
+	s.unhealthy[workerID].Store(false)
+}
+
+// RecordGeneratorBlock учитывает время d, которое генератор ждал отправки
+// числа в chIn.
+func (s *Stats) RecordGeneratorBlock(d time.Duration) {
+	s.genBlocked.add(0, int64(d))
+}
+
+// RecordWorkerBlock учитывает время d, которое результат обработчика
+// workerID ждал отправки в сборку.
+func (s *Stats) RecordWorkerBlock(workerID int, d time.Duration) {
+	s.outBlocked.add(workerID, int64(d))
+}
+
+// recordSinkBlock учитывает время d, которое приёмник обработчика workerID
+// или сборки ждал семафора SinkLimit.
+func (s *Stats) recordSinkBlock(workerID int, d time.Duration) {
+	s.sinkBlocked.add(workerID, int64(d))
+}
+
+// RecordWorkerItem учитывает время t, которое обработчик workerID затратил
+// на одно число, и ошибку его обработки.
+func (s *Stats) RecordWorkerItem(workerID int, t WorkerTiming) {
+	s.busy.add(workerID, int64(t.Busy))
+	s.idle.add(workerID, int64(t.Idle))
+	s.sending.add(workerID, int64(t.Send))
+	if t.Err != nil {
+		s.errs.add(workerID, 0)
+	}
+}
+
+// Snapshot возвращает текущие значения счётчиков. Во время работы
+// конвейера разные счётчики читаются не одновременно, поэтому снимок может
+// не сходиться; после завершения конвейера он точный.
+func (s *Stats) Snapshot() Snapshot {
+	var snap Snapshot
+	in, inCount := s.in.loadWide()
+	snap.InputSum, snap.InputCount = in.lo, inCount
+	snap.InputChecksum = s.in.checksum()
+	if s.sources != nil {
+		snap.PerSource = make([]int64, len(s.sources))
+		for i := range s.sources {
+			snap.PerSource[i] = s.sources[i].count.Load()
+		}
+	}
+	if s.priorityIn != nil {
+		snap.PerPriority = make([]int64, len(s.priorityIn))
+		snap.PriorityOut = make([]int64, len(s.priorityOut))
+		for i := range s.priorityIn {
+			snap.PerPriority[i] = s.priorityIn[i].count.Load()
+			snap.PriorityOut[i] = s.priorityOut[i].count.Load()
+		}
+	}
+	snap.PerWorker = make([]int64, len(s.out))
+	var out wideSum
+	for i := range s.out {
+		out.addWide(s.out[i].wide())
+		snap.OutputChecksum += s.out[i].hash.Load()
+		count := s.out[i].count.Load()
+		snap.OutputCount += count
+		snap.PerWorker[i] = count
+	}
+	snap.OutputSum = out.lo
+	dropped, droppedCount := s.dropped.loadWide()
+	skipped, skippedCount := s.skipped.loadWide()
+	failed, failedCount := s.failed.loadWide()
+	snap.DroppedSum, snap.DroppedCount = dropped.lo, droppedCount
+	snap.SkippedSum, snap.SkippedCount = skipped.lo, skippedCount
+	snap.FailedSum, snap.FailedCount = failed.lo, failedCount
+	snap.Duplicates = s.duplicates.Load()
+	snap.DroppedChecksum = s.dropped.checksum()
+	snap.SkippedChecksum = s.skipped.checksum()
+	snap.FailedChecksum = s.failed.checksum()
+	for _, w := range []wideSum{in, out, dropped, skipped, failed} {
+		snap.SumOverflow = snap.SumOverflow || w.wraps != 0
+	}
+	if s.bigSums {
+		snap.BigSums = &BigSums{
+			Input:   in.big(),
+			Output:  out.big(),
+			Dropped: dropped.big(),
+			Skipped: skipped.big(),
+			Failed:  failed.big(),
+		}
+	}
+	snap.Retries = make([]int64, len(s.retries))
+	for i := range s.retries {
+		snap.Retries[i] = s.retries[i].count.Load()
+	}
+	snap.Stalls = make([]int64, len(s.stalls))
+	snap.Unhealthy = make([]bool, len(s.unhealthy))
+	for i := range s.stalls {
+		snap.Stalls[i] = s.stalls[i].count.Load()
+		snap.Unhealthy[i] = s.unhealthy[i].Load()
+	}
+	blocked, _ := s.genBlocked.load()
+	snap.GeneratorBlocked = time.Duration(blocked)
+	sinkBlocked, _ := s.sinkBlocked.load()
+	snap.SinkBlocked = time.Duration(sinkBlocked)
+	snap.WorkerBlocked = make([]time.Duration, len(s.outBlocked))
+	for i := range s.outBlocked {
+		snap.WorkerBlocked[i] = time.Duration(s.outBlocked[i].sum.Load())
+	}
+	snap.WorkerItems = make([]int64, len(s.busy))
+	snap.WorkerErrors = make([]int64, len(s.busy))
+	snap.WorkerBusy = make([]time.Duration, len(s.busy))
+	snap.WorkerIdle = make([]time.Duration, len(s.busy))
+	snap.WorkerSending = make([]time.Duration, len(s.busy))
+	for i := range s.busy {
+		snap.WorkerItems[i] = s.busy[i].count.Load()
+		snap.WorkerErrors[i] = s.errs[i].count.Load()
+		snap.WorkerBusy[i] = time.Duration(s.busy[i].sum.Load())
+		snap.WorkerIdle[i] = time.Duration(s.idle[i].sum.Load())
+		snap.WorkerSending[i] = time.Duration(s.sending[i].sum.Load())
+	}
+	return snap
+}
+
+// cacheLineSize — размер строки кэша процессора, по которому разносятся
+// ячейки shardedCounter.
+const cacheLineSize = 64
+
+// counterShard — ячейка счётчика, отделённая от соседних строкой кэша,
+// чтобы запись в соседние ячейки не приводила к ложному разделению (false
+// sharing). Go не выравнивает срез ячеек по строке кэша, поэтому ячейка
+// занимает две строки: 32 байта полей и дополнение, после которого поля
+// следующей ячейки начинаются не ближе строки кэша при любом адресе среза.
+type counterShard struct {
+	sum   atomic.Int64
+	count atomic.Int64
+	// wraps — сколько раз sum переполнилась вверх минус сколько раз вниз
+	wraps atomic.Int64
+	// hash — контрольная сумма: сумма valueHash чисел по модулю 2^64
+	hash atomic.Uint64
+	_    [2*cacheLineSize - 32]byte
+}
+
+// wide возвращает сумму ячейки без переполнения.
+func (c *counterShard) wide() wideSum {
+	return wideSum{lo: c.sum.Load(), wraps: c.wraps.Load()}
+}
+
+// shardedCounter — счётчик суммы и количества, разбитый на ячейки.
+type shardedCounter []counterShard
+
+// add прибавляет v к сумме и 1 к количеству в ячейке shard. Переполнение
+// определяется по результату того же атомарного сложения, поэтому не
+// теряется при конкурентных вызовах.
+func (c shardedCounter) add(shard int, v int64) {
+	sum := c[shard].sum.Add(v)
+	if w := wrapOf(sum-v, v, sum); w != 0 {
+		c[shard].wraps.Add(w)
+	}
+	c[shard].count.Add(1)
+}
+
+// addValue учитывает число v: как add, а также прибавляет его хеш к
+// контрольной сумме ячейки shard.
+func (c shardedCounter) addValue(shard int, v int64) {
+	c[shard].hash.Add(valueHash(v))
+	c.add(shard, v)
+}
+
+// checksum возвращает контрольную сумму по всем ячейкам.
+func (c shardedCounter) checksum() uint64 {
+	var sum uint64
+	for i := range c {
+		sum += c[i].hash.Load()
+	}
+	return sum
+}
+
+// valueHash перемешивает биты v (финализатор SplitMix64). Перемешивание
+// взаимно однозначно, а сумма хешей не зависит от порядка чисел, поэтому
+// контрольная сумма — хеш мультимножества чисел: подмена чисел, при которой
+// обычная сумма сходится (например, 1 и 4 вместо 2 и 3), меняет её почти
+// наверняка.
+func valueHash(v int64) uint64 {
+	x := uint64(v)
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// load возвращает сумму по модулю 2^64 и количество по всем ячейкам.
+func (c shardedCounter) load() (sum, count int64) {
+	w, count := c.loadWide()
+	return w.lo, count
+}
+
+// loadWide возвращает сумму без переполнения и количество по всем ячейкам.
+func (c shardedCounter) loadWide() (sum wideSum, count int64) {
+	for i := range c {
+		sum.addWide(c[i].wide())
+		count += c[i].count.Load()
+	}
+	return sum, count
+}
+
+// wrapOf возвращает 1, если сложение old+v=sum переполнило int64 вверх, -1 —
+// если вниз, и 0 без переполнения.
+func wrapOf(old, v, sum int64) int64 {
+	switch {
+	case v > 0 && sum < old:
+		return 1
+	case v < 0 && sum > old:
+		return -1
+	}
+	return 0
+}
+
+// wideSum — 128-битная сумма lo + wraps·2^64: младшие 64 бита и количество
+// переполнений.
+type wideSum struct {
+	lo, wraps int64
+}
+
+// addWide прибавляет к сумме сумму o.
+func (w *wideSum) addWide(o wideSum) {
+	sum := w.lo + o.lo
+	w.wraps += wrapOf(w.lo, o.lo, sum) + o.wraps
+	w.lo = sum
+}
+
+// big возвращает сумму в виде big.Int.
+func (w wideSum) big() *big.Int {
+	b := big.NewInt(w.wraps)
+	b.Lsh(b, 64)
+	return b.Add(b, big.NewInt(w.lo))
+}
+
+// BigSums — точные суммы чисел, которые не ограничены int64.
+type BigSums struct {
+	Input   *big.Int // сумма сгенерированных чисел
+	Output  *big.Int // сумма чисел результирующего канала
+	Dropped *big.Int // сумма отброшенных чисел
+	Skipped *big.Int // сумма отфильтрованных чисел
+	Failed  *big.Int // сумма чисел, обработка которых не удалась
+}
+
+// Snapshot — значения счётчиков Stats на некоторый момент.
+type Snapshot struct {
+	InputSum     int64   // сумма сгенерированных чисел
+	InputCount   int64   // количество сгенерированных чисел
+	OutputSum    int64   // сумма чисел результирующего канала
+	OutputCount  int64   // количество чисел результирующего канала
+	PerWorker    []int64 // количество чисел, прошедших через каждый канал outs[i]
+	PerSource    []int64 // количество чисел каждого источника Config.Sources; nil, если источник один
+	PerPriority  []int64 // количество сгенерированных чисел каждого класса Config.Priority; nil без приоритетов
+	PriorityOut  []int64 // количество чисел результирующего канала каждого класса приоритета
+	DroppedSum   int64   // сумма чисел, отброшенных при остановке
+	DroppedCount int64   // количество чисел, отброшенных при остановке
+	SkippedSum   int64   // сумма чисел, отфильтрованных обработкой
+	SkippedCount int64   // количество чисел, отфильтрованных обработкой
+	FailedSum    int64   // сумма чисел, обработка которых не удалась
+	FailedCount  int64   // количество чисел, обработка которых не удалась
+	Retries      []int64 // количество повторов обработки в каждом обработчике
+	Stalls       []int64 // количество зависаний каждого обработчика
+	Unhealthy    []bool  // обработчик завис на текущем числе
+	// Duplicates — количество повторов, подавленных Config.Dedup: они
+	// входят в OutputCount, но не переданы в Collect и Sink
+	Duplicates int64
+
+	// GeneratorBlocked — суммарное время, которое генератор ждал отправки
+	// чисел в chIn, то есть свободного обработчика или места в буфере
+	GeneratorBlocked time.Duration
+	// WorkerBlocked — суммарное время, которое результаты каждого
+	// обработчика ждали отправки в сборку через outs[i]
+	WorkerBlocked []time.Duration
+	// SinkBlocked — суммарное время, которое операции приёмников ждали
+	// семафора Config.SinkLimit
+	SinkBlocked time.Duration
+
+	// WorkerItems — количество чисел, взятых в обработку каждым
+	// обработчиком, вместе с отфильтрованными и необработанными; в
+	// пакетном режиме — количество пачек
+	WorkerItems []int64
+	// WorkerErrors — количество чисел, обработка которых в каждом
+	// обработчике окончательно не удалась
+	WorkerErrors []int64
+	// WorkerBusy — суммарное время обработки чисел каждым обработчиком
+	WorkerBusy []time.Duration
+	// WorkerIdle — суммарное время, которое каждый обработчик ждал чисел
+	WorkerIdle []time.Duration
+	// WorkerSending — суммарное время, которое каждый обработчик сам ждал
+	// отправки результата; в отличие от WorkerBlocked сюда не входит время
+	// в буфере канала
+	WorkerSending []time.Duration
+
+	// SumOverflow — хотя бы одна из сумм вышла за пределы int64, поэтому
+	// поле *Sum содержит её значение по модулю 2^64
+	SumOverflow bool
+	// BigSums — точные суммы; nil, если Stats.EnableBigSums не вызван
+	BigSums *BigSums
+
+	// контрольные суммы, не зависящие от порядка чисел: суммы хешей
+	// сгенерированных чисел, чисел результирующего канала, отброшенных,
+	// отфильтрованных и необработанных по модулю 2^64
+	InputChecksum   uint64
+	OutputChecksum  uint64
+	DroppedChecksum uint64
+	SkippedChecksum uint64
+	FailedChecksum  uint64
+}
+
+// WorkerUtilization возвращает загрузку каждого обработчика — долю времени
+// обработки в его времени работы (обработка, ожидание чисел и ожидание
+// отправки) от 0 до 1. Заметно отличающаяся загрузка говорит о
+// неравномерном распределении чисел.
+func (s Snapshot) WorkerUtilization() []float64 {
+	util := make([]float64, len(s.WorkerBusy))
+	for i, busy := range s.WorkerBusy {
+		if total := busy + s.WorkerIdle[i] + s.WorkerSending[i]; total > 0 {
+			util[i] = float64(busy) / float64(total)
+		}
+	}
+	return util
+}
+
+// ErrSumOverflow — суммы чисел переполнили int64, а точные суммы не
+// собирались, поэтому сравнить их нельзя.
+var ErrSumOverflow = errors.New("суммы чисел переполнили int64, для проверки нужны точные суммы Config.BigSums")
+
+// Verify проверяет, что каждое сгенерированное число либо дошло до
+// результирующего канала, либо было учтено как отброшенное, отфильтрованное
+// или необработанное, что суммы сходятся, что разбивка по каналам и по
+// классам приоритета сходится с количеством дошедших чисел, а разбивка по
+// источникам и по классам приоритета — с количеством сгенерированных.
+// Контрольные суммы сравниваются так же, как суммы,
+// поэтому обнаруживается и подмена чисел с той же суммой. Если суммы
+// переполнили int64, они сравниваются по BigSums, а без них Verify
+// возвращает ErrSumOverflow.
+func (s Snapshot) Verify() error {
+	return s.verify(true)
+}
+
+// verify выполняет проверку Verify; сравнение сумм и контрольных сумм
+// выполняется, только если sums равно true.
+func (s Snapshot) verify(sums bool) error {
+	if sums {
+		if rest := s.OutputChecksum + s.DroppedChecksum + s.SkippedChecksum + s.FailedChecksum; s.InputChecksum != rest {
+			return fmt.Errorf("контрольные суммы чисел не равны: %016x != %016x", s.InputChecksum, rest)
+		}
+		if err := s.verifySums(); err != nil {
+			return err
+		}
+	}
+	if rest := s.OutputCount + s.DroppedCount + s.SkippedCount + s.FailedCount; s.InputCount != rest {
+		return fmt.Errorf("количество чисел не равно: %d != %d", s.InputCount, rest)
+	}
+	rest := s.OutputCount
+	for _, v := range s.PerWorker {
+		rest -= v
+	}
+	if rest != 0 {
+		return errors.New("разделение чисел по каналам неверное")
+	}
+	if s.PerSource != nil {
+		rest = s.InputCount
+		for _, v := range s.PerSource {
+			rest -= v
+		}
+		if rest != 0 {
+			return errors.New("разделение чисел по источникам неверное")
+		}
+	}
+	if s.PerPriority != nil {
+		in, out := s.InputCount, s.OutputCount
+		for i := range s.PerPriority {
+			in -= s.PerPriority[i]
+			out -= s.PriorityOut[i]
+		}
+		if in != 0 || out != 0 {
+			return errors.New("разделение чисел по классам приоритета неверное")
+		}
+	}
+	return nil
+}
+
+// verifySums проверяет, что сумма сгенерированных чисел равна сумме
+// остальных.
+func (s Snapshot) verifySums() error {
+	if b := s.BigSums; b != nil {
+		rest := new(big.Int).Add(b.Output, b.Dropped)
+		rest.Add(rest, b.Skipped).Add(rest, b.Failed)
+		if b.Input.Cmp(rest) != 0 {
+			return fmt.Errorf("суммы чисел не равны: %s != %s", b.Input, rest)
+		}
+		return nil
+	}
+	if s.SumOverflow {
+		return ErrSumOverflow
+	}
+	if rest := s.OutputSum + s.DroppedSum + s.SkippedSum + s.FailedSum; s.InputSum != rest {
+		return fmt.Errorf("суммы чисел не равны: %d != %d", s.InputSum, rest)
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestStatsVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  func(s *Stats)
+		wantErr bool
+	}{
+		{"пусто", func(*Stats) {}, false},
+		{"все числа дошли", func(s *Stats) {
+			for v := int64(1); v <= 10; v++ {
+				s.RecordIn(v)
+				s.RecordOut(int(v%3), v)
+			}
+		}, false},
+		{"отброшенные и отфильтрованные", func(s *Stats) {
+			for v := int64(1); v <= 12; v++ {
+				s.RecordIn(v)
+				switch w := int(v % 2); v % 3 {
+				case 0:
+					s.RecordOut(w, v)
+				case 1:
+					s.RecordDrop(w, v)
+				case 2:
+					s.RecordSkip(w, v)
+				}
+			}
+		}, false},
+		{"необработанные", func(s *Stats) {
+			s.RecordIn(1)
+			s.RecordIn(2)
+			s.RecordOut(0, 1)
+			s.RecordFailed(2)
+		}, false},
+		{"потеряно число", func(s *Stats) {
+			s.RecordIn(1)
+			s.RecordIn(2)
+			s.RecordOut(0, 1)
+		}, true},
+		{"сумма не сходится", func(s *Stats) {
+			s.RecordIn(2)
+			s.RecordOut(0, 3)
+		}, true},
+		{"подмена чисел с той же суммой", func(s *Stats) {
+			s.RecordIn(2)
+			s.RecordIn(3)
+			s.RecordOut(0, 1)
+			s.RecordOut(1, 4)
+		}, true},
+		{"по источникам", func(s *Stats) {
+			s.sources = make(shardedCounter, 2)
+			s.recordSourceIn(0, 1)
+			s.recordSourceIn(1, 2)
+			s.RecordOut(0, 1)
+			s.RecordOut(1, 2)
+		}, false},
+		{"источник не учтён", func(s *Stats) {
+			s.sources = make(shardedCounter, 2)
+			s.recordSourceIn(0, 1)
+			s.RecordIn(2)
+			s.RecordOut(0, 1)
+			s.RecordOut(1, 2)
+		}, true},
+		{"по классам приоритета", func(s *Stats) {
+			s.enablePriorities(2)
+			s.RecordIn(1)
+			s.recordPriorityIn(1)
+			s.RecordIn(2)
+			s.recordPriorityIn(0)
+			s.RecordOut(0, 1)
+			s.recordPriorityOut(1)
+			s.RecordDrop(0, 2)
+		}, false},
+		{"класс приоритета не учтён", func(s *Stats) {
+			s.enablePriorities(2)
+			s.RecordIn(1)
+			s.recordPriorityIn(1)
+			s.RecordOut(0, 1)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStats(3)
+			tt.record(s)
+			if err := s.Snapshot().Verify(); (err != nil) != tt.wantErr {
+				t.Errorf("Verify = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestStatsConcurrent проверяет, что счётчики каждого обработчика
+// учитываются в своих ячейках и сходятся при конкурентной записи.
+func TestStatsConcurrent(t *testing.T) {
+	const workers, perWorker = 8, 1000
+	s := NewStats(workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int64(0); i < perWorker; i++ {
+				v := int64(w)*perWorker + i
+				s.RecordIn(v)
+				switch i % 3 {
+				case 0:
+					s.RecordOut(w, v)
+				case 1:
+					s.RecordDrop(w, v)
+				case 2:
+					s.RecordSkip(w, v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	snap := s.Snapshot()
+	if err := snap.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	for w, n := range snap.PerWorker {
+		if n != (perWorker+2)/3 {
+			t.Errorf("обработчик %d: %d чисел, want %d", w, n, (perWorker+2)/3)
+		}
+	}
+	for _, c := range []shardedCounter{s.dropped, s.skipped} {
+		for i := range c {
+			if n := c[i].count.Load(); n != perWorker/3 {
+				t.Errorf("ячейка %d: %d чисел, want %d", i, n, perWorker/3)
+			}
+		}
+	}
+}
+
+func TestStatsBlocked(t *testing.T) {
+	s := NewStats(2)
+	s.RecordGeneratorBlock(time.Millisecond)
+	s.RecordGeneratorBlock(2 * time.Millisecond)
+	s.RecordWorkerBlock(1, time.Second)
+	snap := s.Snapshot()
+	if snap.GeneratorBlocked != 3*time.Millisecond {
+		t.Errorf("GeneratorBlocked = %v, want 3ms", snap.GeneratorBlocked)
+	}
+	if want := []time.Duration{0, time.Second}; !slices.Equal(snap.WorkerBlocked, want) {
+		t.Errorf("WorkerBlocked = %v, want %v", snap.WorkerBlocked, want)
+	}
+}
+
+func TestStatsRetries(t *testing.T) {
+	s := NewStats(3)
+	s.RecordRetry(1)
+	s.RecordRetry(1)
+	s.RecordRetry(2)
+	if got, want := s.Snapshot().Retries, []int64{0, 2, 1}; !slices.Equal(got, want) {
+		t.Errorf("Retries = %v, want %v", got, want)
+	}
+}
+
+// TestStatsSumOverflow проверяет, что переполнение сумм int64 отмечается в
+// SumOverflow и не проходит проверку без точных сумм, а с ними суммы
+// сравниваются точно.
+func TestStatsSumOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []int64
+		bigSums  bool
+		wantOver bool
+		wantErr  error
+		wantIn   string // точная сумма; "" — BigSums не заполняются
+	}{
+		{"без переполнения", []int64{1, 2, 3}, false, false, nil, ""},
+		{"переполнение без точных сумм", []int64{math.MaxInt64, math.MaxInt64, 2}, false, true, ErrSumOverflow, ""},
+		{"переполнение с точными суммами", []int64{math.MaxInt64, math.MaxInt64, 2}, true, true, nil, "18446744073709551616"},
+		{"переполнение вниз", []int64{math.MinInt64, -1}, true, true, nil, "-9223372036854775809"},
+		{"переполнение туда и обратно", []int64{math.MaxInt64, 1, -1}, true, false, nil, "9223372036854775807"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStats(2)
+			if tt.bigSums {
+				s.EnableBigSums()
+			}
+			for i, v := range tt.values {
+				s.RecordIn(v)
+				s.RecordOut(i%2, v)
+			}
+			snap := s.Snapshot()
+			if snap.SumOverflow != tt.wantOver {
+				t.Errorf("SumOverflow = %v, want %v", snap.SumOverflow, tt.wantOver)
+			}
+			if err := snap.Verify(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.wantIn == "" && snap.BigSums != nil:
+				t.Errorf("BigSums = %+v, want nil", snap.BigSums)
+			case tt.wantIn != "" && (snap.BigSums == nil || snap.BigSums.Input.String() != tt.wantIn || snap.BigSums.Output.String() != tt.wantIn):
+				t.Errorf("BigSums = %+v, want %s", snap.BigSums, tt.wantIn)
+			}
+		})
+	}
+}
+
+// TestStatsBigSumsMismatch проверяет, что точные суммы, которые не сходятся,
+// не проходят проверку, даже если они совпадают по модулю 2^64.
+func TestStatsBigSumsMismatch(t *testing.T) {
+	s := NewStats(1)
+	s.EnableBigSums()
+	s.RecordIn(math.MaxInt64)
+	s.RecordIn(math.MaxInt64)
+	s.RecordIn(2)
+	s.RecordOut(0, 0)
+	s.RecordOut(0, 0)
+	s.RecordOut(0, 0)
+	snap := s.Snapshot()
+	if snap.InputSum != snap.OutputSum {
+		t.Fatalf("суммы по модулю 2^64 %d и %d, want равные", snap.InputSum, snap.OutputSum)
+	}
+	if err := snap.Verify(); err == nil {
+		t.Error("Verify = nil, want ошибку сумм")
+	}
+}
+
+func TestWideSum(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []wideSum
+		want  string
+	}{
+		{"без переполнения", []wideSum{{lo: 1}, {lo: -3}}, "-2"},
+		{"переполнение при сложении", []wideSum{{lo: math.MaxInt64}, {lo: 1}}, "9223372036854775808"},
+		{"переполнения ячеек", []wideSum{{lo: 5, wraps: 1}, {lo: -5, wraps: -2}}, "-18446744073709551616"},
+		{"вниз", []wideSum{{lo: math.MinInt64}, {lo: math.MinInt64}}, "-18446744073709551616"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w wideSum
+			want, _ := new(big.Int).SetString(tt.want, 10)
+			for _, p := range tt.parts {
+				w.addWide(p)
+			}
+			if got := w.big(); got.Cmp(want) != 0 {
+				t.Errorf("сумма %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestStatsWorkerItems(t *testing.T) {
+	s := NewStats(2)
+	s.RecordWorkerItem(0, WorkerTiming{Idle: time.Millisecond, Busy: 3 * time.Millisecond})
+	s.RecordWorkerItem(0, WorkerTiming{Busy: 2 * time.Millisecond, Send: 4 * time.Millisecond, Err: errOdd})
+	s.RecordWorkerItem(1, WorkerTiming{Idle: 5 * time.Millisecond})
+	snap := s.Snapshot()
+	if !slices.Equal(snap.WorkerItems, []int64{2, 1}) || !slices.Equal(snap.WorkerErrors, []int64{1, 0}) {
+		t.Errorf("чисел %v, ошибок %v, want [2 1] и [1 0]", snap.WorkerItems, snap.WorkerErrors)
+	}
+	if snap.WorkerBusy[0] != 5*time.Millisecond || snap.WorkerIdle[1] != 5*time.Millisecond || snap.WorkerSending[0] != 4*time.Millisecond {
+		t.Errorf("время обработки %v, ожидания %v, отправки %v", snap.WorkerBusy, snap.WorkerIdle, snap.WorkerSending)
+	}
+	if got, want := snap.WorkerUtilization(), []float64{0.5, 0}; !slices.Equal(got, want) {
+		t.Errorf("WorkerUtilization = %v, want %v", got, want)
+	}
+}
+
+func TestCounterShardSize(t *testing.T) {
+	if size := unsafe.Sizeof(counterShard{}); size != 2*cacheLineSize {
+		t.Errorf("размер ячейки %d байт, want %d", size, 2*cacheLineSize)
+	}
+}
+
+// BenchmarkStatsContention сравнивает учёт отброшенных чисел из многих
+// обработчиков в ячейках по обработчику и в одной общей ячейке.
+func BenchmarkStatsContention(b *testing.B) {
+	for _, workers := range []int{32, 64} {
+		for _, bench := range []struct {
+			name  string
+			shard func(w int) int
+		}{
+			{"по обработчику", func(w int) int { return w }},
+			{"общая ячейка", func(int) int { return 0 }},
+		} {
+			b.Run(fmt.Sprintf("%s/%d", bench.name, workers), func(b *testing.B) {
+				b.ReportAllocs()
+				s := NewStats(workers)
+				per := b.N/workers + 1
+				var wg sync.WaitGroup
+				b.ResetTimer()
+				for w := 0; w < workers; w++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						shard := bench.shard(w)
+						for i := 0; i < per; i++ {
+							s.RecordDrop(shard, int64(i))
+						}
+					}()
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
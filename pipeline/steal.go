@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// WorkStealing возвращает Distributor, у которого каждый обработчик имеет
+// собственную очередь: значения раскладываются по очередям по кругу, как в
+// RoundRobin, а обработчик, чья очередь опустела, забирает значения с конца
+// самой длинной чужой очереди. Так быстрые обработчики помогают медленным.
+// В каждой очереди хранится не больше cap(in) значений (не меньше одного).
+func WorkStealing[T any]() Distributor[T] {
+	return workStealing[T]{}
+}
+
+// workStealing — Distributor с перехватом работы.
+type workStealing[T any] struct{}
+
+func (workStealing[T]) Distribute(ctx context.Context, in <-chan T, n int, drop func(T)) []<-chan T {
+	q := &stealQueues[T]{deques: make([][]T, n), limit: max(cap(in), 1)}
+	q.cond = sync.NewCond(&q.mu)
+	stopWatch := context.AfterFunc(ctx, q.cancel)
+
+	outs := make([]chan T, n)
+	res := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		res[i] = outs[i]
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer q.close()
+		i := 0
+		for v := range in {
+			if !q.put(i, v) {
+				drop(v)
+				for v := range in {
+					drop(v)
+				}
+				return
+			}
+			i = (i + 1) % n
+		}
+	}()
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				v, ok := q.take(i)
+				if !ok {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					drop(v)
+					return
+				case outs[i] <- v:
+				}
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		stopWatch()
+		// после отмены в очередях могли остаться значения
+		for _, dq := range q.deques {
+			for _, v := range dq {
+				drop(v)
+			}
+		}
+		for _, c := range outs {
+			close(c)
+		}
+	}()
+	return res
+}
+
+// stealQueues — очереди обработчиков под общим мьютексом.
+type stealQueues[T any] struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	deques    [][]T // очередь каждого обработчика; начало — следующее значение
+	limit     int   // максимальная длина очереди
+	closed    bool  // новых значений не будет
+	cancelled bool  // раздача прервана
+}
+
+// put добавляет v в конец очереди i, ожидая, пока в ней появится место.
+// Возвращает false, если раздача прервана.
+func (q *stealQueues[T]) put(i int, v T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.deques[i]) >= q.limit && !q.cancelled {
+		q.cond.Wait()
+	}
+	if q.cancelled {
+		return false
+	}
+	q.deques[i] = append(q.deques[i], v)
+	q.cond.Broadcast()
+	return true
+}
+
+// take возвращает следующее значение для обработчика i: из начала его
+// очереди или, если она пуста, с конца самой длинной чужой. Ждёт, пока
+// значение появится; ok равно false, если значений больше не будет.
+func (q *stealQueues[T]) take(i int) (v T, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.cancelled {
+		if dq := q.deques[i]; len(dq) > 0 {
+			v, q.deques[i] = dq[0], dq[1:]
+			q.cond.Broadcast()
+			return v, true
+		}
+		victim := -1
+		for j, dq := range q.deques {
+			if len(dq) > 0 && (victim < 0 || len(dq) > len(q.deques[victim])) {
+				victim = j
+			}
+		}
+		if victim >= 0 {
+			dq := q.deques[victim]
+			v, q.deques[victim] = dq[len(dq)-1], dq[:len(dq)-1]
+			q.cond.Broadcast()
+			return v, true
+		}
+		if q.closed {
+			break
+		}
+		q.cond.Wait()
+	}
+	return v, false
+}
+
+// close сообщает, что новых значений не будет.
+func (q *stealQueues[T]) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// cancel прерывает раздачу.
+func (q *stealQueues[T]) cancel() {
+	q.mu.Lock()
+	q.cancelled = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
@@ -0,0 +1,265 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownPipeline — в Supervisor нет конвейера с таким именем.
+var ErrUnknownPipeline = errors.New("неизвестный конвейер")
+
+// ErrPipelineRunning — конвейер Supervisor уже запущен.
+var ErrPipelineRunning = errors.New("конвейер уже запущен")
+
+// Supervisor запускает в одном процессе несколько независимых конвейеров с
+// разными настройками, например чтобы сравнить их рядом. У каждого
+// конвейера своё имя, свои генератор, обработчики и статистика; их можно
+// запускать и останавливать по отдельности, а Status собирает сводку по
+// всем. Безопасен для конкурентного использования.
+type Supervisor struct {
+	mu        sync.Mutex
+	pipelines map[string]*supervised
+	names     []string // имена в порядке добавления
+}
+
+// supervised — конвейер Supervisor и его последний запуск.
+type supervised struct {
+	cfg    Config
+	p      *Pipeline     // последний запуск; nil — не запускался
+	cancel func()        // прерывает обработку последнего запуска
+	done   chan struct{} // закрывается по завершении последнего запуска
+	res    Result
+	err    error
+}
+
+// NewSupervisor создаёт Supervisor без конвейеров.
+func NewSupervisor() *Supervisor {
+	return &Supervisor{pipelines: make(map[string]*supervised)}
+}
+
+// Add добавляет конвейер name с настройками cfg, не запуская его. Журнал
+// cfg.Logger получает атрибут pipeline с именем конвейера.
+func (s *Supervisor) Add(name string, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("конвейер %s: %w", name, err)
+	}
+	if cfg.Logger != nil {
+		cfg.Logger = cfg.Logger.With("pipeline", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pipelines[name]; ok {
+		return fmt.Errorf("конвейер %s уже добавлен", name)
+	}
+	s.pipelines[name] = &supervised{cfg: cfg}
+	s.names = append(s.names, name)
+	return nil
+}
+
+// Names возвращает имена конвейеров в порядке добавления.
+func (s *Supervisor) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.names...)
+}
+
+// lookup возвращает конвейер name.
+func (s *Supervisor) lookup(name string) (*supervised, error) {
+	sp, ok := s.pipelines[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPipeline, name)
+	}
+	return sp, nil
+}
+
+// Start запускает конвейер name в отдельной горутине; его работа
+// прерывается отменой ctx. Остановленный конвейер можно запустить снова:
+// запуск начинается с новой статистикой, но с теми же настройками, поэтому
+// источник Config.Source продолжает с того места, где остановился.
+func (s *Supervisor) Start(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+	if sp.running() {
+		return fmt.Errorf("%w: %s", ErrPipelineRunning, name)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	p := New(sp.cfg)
+	done := make(chan struct{})
+	sp.p, sp.cancel, sp.done = p, cancel, done
+	go func() {
+		defer close(done)
+		defer cancel()
+		res, err := p.Run(runCtx)
+		s.mu.Lock()
+		sp.res, sp.err = res, err
+		s.mu.Unlock()
+	}()
+	return nil
+}
+
+// StartAll запускает все конвейеры, которые ещё не запущены.
+func (s *Supervisor) StartAll(ctx context.Context) error {
+	var errs []error
+	for _, name := range s.Names() {
+		if err := s.Start(ctx, name); err != nil && !errors.Is(err, ErrPipelineRunning) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// running сообщает, работает ли последний запуск конвейера.
+func (sp *supervised) running() bool {
+	if sp.done == nil {
+		return false
+	}
+	select {
+	case <-sp.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Stop останавливает генерацию конвейера name, как Pipeline.Stop: числа
+// дообрабатываются по его политике Drain. Остановка незапущенного
+// конвейера ничего не делает.
+func (s *Supervisor) Stop(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+	if sp.p != nil {
+		sp.p.Stop()
+	}
+	return nil
+}
+
+// StopAll останавливает генерацию всех конвейеров.
+func (s *Supervisor) StopAll() {
+	for _, name := range s.Names() {
+		s.Stop(name)
+	}
+}
+
+// Abort прерывает обработку всех конвейеров, как отмена контекста Run.
+func (s *Supervisor) Abort() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sp := range s.pipelines {
+		if sp.cancel != nil {
+			sp.cancel()
+		}
+	}
+}
+
+// Pipeline возвращает последний запуск конвейера name, например чтобы
+// приостановить его или добавить обработчиков; nil, если он не запускался.
+func (s *Supervisor) Pipeline(name string) (*Pipeline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp, err := s.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return sp.p, nil
+}
+
+// Wait дожидается завершения последнего запуска конвейера name и
+// возвращает его результат; для незапущенного конвейера — пустой Result.
+func (s *Supervisor) Wait(name string) (Result, error) {
+	s.mu.Lock()
+	sp, err := s.lookup(name)
+	var done chan struct{}
+	if err == nil {
+		done = sp.done
+	}
+	s.mu.Unlock()
+	if err != nil || done == nil {
+		return Result{}, err
+	}
+	<-done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sp.res, sp.err
+}
+
+// WaitAll дожидается завершения всех запущенных конвейеров и возвращает
+// их сводку.
+func (s *Supervisor) WaitAll() []PipelineStatus {
+	for _, name := range s.Names() {
+		s.Wait(name)
+	}
+	return s.Status()
+}
+
+// PipelineStatus — состояние конвейера Supervisor.
+type PipelineStatus struct {
+	Name    string
+	Running bool
+	// Stats — живая статистика работающего конвейера или итоговая
+	// статистика завершившегося
+	Stats Snapshot
+	// Result — итоги последнего завершившегося запуска; nil, если конвейер
+	// работает или не запускался
+	Result *Result
+	Err    error // ошибка последнего завершившегося запуска
+}
+
+// Status возвращает состояние всех конвейеров в порядке добавления.
+func (s *Supervisor) Status() []PipelineStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]PipelineStatus, len(s.names))
+	for i, name := range s.names {
+		sp := s.pipelines[name]
+		st := PipelineStatus{Name: name, Running: sp.running()}
+		if sp.p != nil {
+			st.Stats = sp.p.Stats()
+		}
+		if sp.done != nil && !st.Running {
+			res := sp.res
+			st.Result, st.Err = &res, sp.err
+		}
+		statuses[i] = st
+	}
+	return statuses
+}
+
+// TotalStats складывает количества, суммы и контрольные суммы статистики
+// конвейеров statuses, поэтому, если сходится статистика каждого
+// конвейера, Verify проходит и сводка. PerWorker перечисляет обработчиков
+// всех конвейеров подряд, другие разбивки в сводке не заполняются.
+func TotalStats(statuses []PipelineStatus) Snapshot {
+	var t Snapshot
+	for _, st := range statuses {
+		s := st.Stats
+		t.InputCount += s.InputCount
+		t.InputSum += s.InputSum
+		t.OutputCount += s.OutputCount
+		t.OutputSum += s.OutputSum
+		t.PerWorker = append(t.PerWorker, s.PerWorker...)
+		t.DroppedCount += s.DroppedCount
+		t.DroppedSum += s.DroppedSum
+		t.SkippedCount += s.SkippedCount
+		t.SkippedSum += s.SkippedSum
+		t.FailedCount += s.FailedCount
+		t.FailedSum += s.FailedSum
+		t.Duplicates += s.Duplicates
+		t.InputChecksum += s.InputChecksum
+		t.OutputChecksum += s.OutputChecksum
+		t.DroppedChecksum += s.DroppedChecksum
+		t.SkippedChecksum += s.SkippedChecksum
+		t.FailedChecksum += s.FailedChecksum
+		t.SumOverflow = t.SumOverflow || s.SumOverflow
+	}
+	return t
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestSupervisor проверяет, что Supervisor запускает конвейеры независимо,
+// а сводка их статистики проходит проверку.
+func TestSupervisor(t *testing.T) {
+	s := NewSupervisor()
+	if err := s.Add("a", Config{NumWorkers: 2, Limit: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("b", Config{NumWorkers: 3, Limit: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("a", Config{NumWorkers: 1}); err == nil {
+		t.Error("Add повторного имени = nil, want ошибку")
+	}
+	if err := s.Start(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Wait("a")
+	if err != nil || res.OutputCount != 100 {
+		t.Fatalf("Wait(a) = %d чисел, %v, want 100", res.OutputCount, err)
+	}
+	if st := s.Status()[1]; st.Running || st.Result != nil {
+		t.Errorf("b = %+v, want не запускался", st)
+	}
+
+	if err := s.StartAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	statuses := s.WaitAll()
+	for _, st := range statuses {
+		if st.Running || st.Result == nil || st.Err != nil {
+			t.Fatalf("%s: работает %v, итог %v, ошибка %v", st.Name, st.Running, st.Result, st.Err)
+		}
+	}
+	total := TotalStats(statuses)
+	if total.OutputCount != 150 || len(total.PerWorker) != 5 {
+		t.Errorf("сводка: %d чисел, %d обработчиков, want 150 и 5", total.OutputCount, len(total.PerWorker))
+	}
+	if err := (Result{Snapshot: total}).Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestSupervisorStop проверяет остановку генерации одного конвейера и
+// ошибки для неизвестного и уже запущенного конвейера.
+func TestSupervisorStop(t *testing.T) {
+	s := NewSupervisor()
+	if err := s.Add("endless", Config{NumWorkers: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background(), "missing"); !errors.Is(err, ErrUnknownPipeline) {
+		t.Errorf("Start(missing) = %v, want ErrUnknownPipeline", err)
+	}
+	if err := s.Start(context.Background(), "endless"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background(), "endless"); !errors.Is(err, ErrPipelineRunning) {
+		t.Errorf("повторный Start = %v, want ErrPipelineRunning", err)
+	}
+	if err := s.Stop("endless"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Wait("endless")
+	if err != nil || !errors.Is(res.StopCause, ErrStopped) {
+		t.Fatalf("Wait = %v, причина %v, want ErrStopped", err, res.StopCause)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracing записывает путь каждого числа через конвейер в span OpenTelemetry:
+// span начинается при получении числа из источника, получает событие
+// "worker" при попадании в обработчик и завершается в результирующем канале
+// или при отбрасывании. Нулевой *tracing ничего не делает.
+type tracing struct {
+	tracer trace.Tracer
+	clock  Clock
+}
+
+// newTracing возвращает tracing для tracer или nil, если tracer не задан.
+func newTracing(tracer trace.Tracer, clock Clock) *tracing {
+	if tracer == nil {
+		return nil
+	}
+	return &tracing{tracer: tracer, clock: clock}
+}
+
+// start открывает span для числа e.
+func (t *tracing) start(ctx context.Context, e *Event) {
+	if t == nil {
+		return
+	}
+	_, e.span = t.tracer.Start(ctx, "pipeline.value",
+		trace.WithNewRoot(),
+		trace.WithTimestamp(e.Born),
+		trace.WithAttributes(attribute.Int64("value", e.Value)),
+	)
+}
+
+// process оборачивает обработку обработчика workerID так, чтобы отмечать в
+// span попадание числа в обработчик и время ожидания в chIn.
+func (t *tracing) process(workerID int, process func(context.Context, Event) (Event, error)) func(context.Context, Event) (Event, error) {
+	if t == nil {
+		return process
+	}
+	return func(ctx context.Context, e Event) (Event, error) {
+		if e.span != nil {
+			now := t.clock.Now()
+			e.span.AddEvent("worker", trace.WithTimestamp(now), trace.WithAttributes(
+				attribute.Int("worker.id", workerID),
+				attribute.Int64("queue_wait_us", now.Sub(e.Born).Microseconds()),
+			))
+		}
+		return process(ctx, e)
+	}
+}
+
+// collected завершает span числа, пришедшего в результирующий канал.
+func (t *tracing) collected(e Event) {
+	if t == nil || e.span == nil {
+		return
+	}
+	now := t.clock.Now()
+	var wait time.Duration
+	if !e.sent.IsZero() {
+		wait = now.Sub(e.sent)
+	}
+	e.span.AddEvent("collected", trace.WithTimestamp(now), trace.WithAttributes(
+		attribute.Int64("queue_wait_us", wait.Microseconds()),
+		attribute.Int("attempts", e.Attempts),
+	))
+	e.span.End(trace.WithTimestamp(now))
+}
+
+// discarded завершает span числа, которое не дошло до результирующего
+// канала; reason — "dropped" или "skipped".
+func (t *tracing) discarded(e Event, reason string) {
+	if t == nil || e.span == nil {
+		return
+	}
+	now := t.clock.Now()
+	e.span.AddEvent(reason, trace.WithTimestamp(now))
+	if reason == "dropped" {
+		e.span.SetStatus(codes.Error, "число отброшено")
+	}
+	e.span.End(trace.WithTimestamp(now))
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingTracer запоминает события и завершение каждого span.
+type recordingTracer struct {
+	noop.Tracer
+
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, _ string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &recordingSpan{tracer: t}
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return ctx, s
+}
+
+// recordingSpan — span recordingTracer; поля защищены мьютексом трассировщика.
+type recordingSpan struct {
+	noop.Span
+
+	tracer *recordingTracer
+	events []string
+	failed bool
+	ends   int
+}
+
+func (s *recordingSpan) AddEvent(name string, _ ...trace.EventOption) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.events = append(s.events, name)
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.failed = code == codes.Error
+}
+
+func (s *recordingSpan) End(...trace.SpanEndOption) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.ends++
+}
+
+// TestRunTracing проверяет, что span каждого числа получает событие
+// обработчика и завершается ровно один раз — в том числе после вытеснения
+// в файл Spillover.
+func TestRunTracing(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"без очереди", Config{}},
+		{"с вытеснением", Config{SpillThreshold: 1, SpillDir: t.TempDir(), Collect: func(int64) error {
+			time.Sleep(100 * time.Microsecond)
+			return nil
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := &recordingTracer{}
+			cfg := tt.cfg
+			cfg.NumWorkers, cfg.Limit, cfg.BufferSize = 2, 40, 10
+			cfg.Tracer = tracer
+			cfg.Process = Filter(func(v int64) bool { return v%2 == 0 })
+			res, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tracer.spans) != 40 {
+				t.Fatalf("span: %d, want 40", len(tracer.spans))
+			}
+			var collected, skipped int
+			for i, s := range tracer.spans {
+				if s.ends != 1 || s.failed {
+					t.Errorf("span %d: завершён %d раз, ошибка %v", i, s.ends, s.failed)
+				}
+				if len(s.events) != 2 || s.events[0] != "worker" {
+					t.Errorf("span %d: события %q, want worker и итог", i, s.events)
+					continue
+				}
+				switch s.events[1] {
+				case "collected":
+					collected++
+				case "skipped":
+					skipped++
+				}
+			}
+			if int64(collected) != res.OutputCount || int64(skipped) != res.SkippedCount {
+				t.Errorf("collected %d, skipped %d, want %d и %d", collected, skipped, res.OutputCount, res.SkippedCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// ErrSkip возвращается функцией обработки, чтобы отфильтровать значение:
+// Worker не отправляет его дальше и не считает это ошибкой.
+var ErrSkip = errors.New("значение отфильтровано")
+
+// Delay возвращает обработку, которая не меняет значение, а только делает
+// паузу d по реальным часам, имитируя работу. Пауза прерывается отменой
+// контекста.
+func Delay[T any](d time.Duration) func(context.Context, T) (T, error) {
+	return DelayFunc[T](SystemClock, func() time.Duration { return d })
+}
+
+// DelayFunc работает как Delay, но берёт длительность каждой паузы из delay
+// и отсчитывает её по часам clock. Так можно моделировать переменное время
+// работы или подменить часы в тестах.
+func DelayFunc[T any](clock Clock, delay func() time.Duration) func(context.Context, T) (T, error) {
+	return func(ctx context.Context, v T) (T, error) {
+		return v, Sleep(ctx, clock, delay())
+	}
+}
+
+// Chain возвращает обработку, которая последовательно применяет fns.
+// Обработка прекращается на первой ошибке (в том числе ErrSkip).
+func Chain[T any](fns ...func(context.Context, T) (T, error)) func(context.Context, T) (T, error) {
+	return func(ctx context.Context, v T) (T, error) {
+		for _, fn := range fns {
+			var err error
+			if v, err = fn(ctx, v); err != nil {
+				return v, err
+			}
+		}
+		return v, nil
+	}
+}
+
+// Filter возвращает обработку, которая пропускает значения, для которых keep
+// возвращает true, и отфильтровывает остальные с помощью ErrSkip.
+func Filter[T any](keep func(T) bool) func(context.Context, T) (T, error) {
+	return func(_ context.Context, v T) (T, error) {
+		if !keep(v) {
+			return v, ErrSkip
+		}
+		return v, nil
+	}
+}
+
+// maxSquareBase — наибольшее по модулю число, квадрат которого помещается в int64.
+const maxSquareBase = 3037000499
+
+// Square возводит число в квадрат. При переполнении int64 возвращается ошибка.
+func Square(_ context.Context, v int64) (int64, error) {
+	if v > maxSquareBase || v < -maxSquareBase {
+		return 0, fmt.Errorf("переполнение при возведении %d в квадрат", v)
+	}
+	return v * v, nil
+}
+
+// Hash заменяет число его хешем FNV-1a, приведённым к неотрицательному int64.
+func Hash(_ context.Context, v int64) (int64, error) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(v))
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return int64(h.Sum64() &^ (1 << 63)), nil
+}
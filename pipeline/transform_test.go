@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTransforms(t *testing.T) {
+	double := func(_ context.Context, v int64) (int64, error) { return 2 * v, nil }
+	even := Filter(func(v int64) bool { return v%2 == 0 })
+	tests := []struct {
+		name    string
+		fn      func(context.Context, int64) (int64, error)
+		v       int64
+		want    int64
+		wantErr error // errAny — любая ошибка
+	}{
+		{"квадрат", Square, -12, 144, nil},
+		{"квадрат на границе", Square, maxSquareBase, maxSquareBase * maxSquareBase, nil},
+		{"квадрат с переполнением", Square, maxSquareBase + 1, 0, errAny},
+		{"фильтр пропускает", even, 4, 4, nil},
+		{"фильтр отсеивает", even, 3, 3, ErrSkip},
+		{"цепочка", Chain(double, Square), 3, 36, nil},
+		{"цепочка с фильтром", Chain(double, even, Square), 3, 36, nil},
+		{"цепочка прерывается", Chain(even, double), 3, 3, ErrSkip},
+		{"пустая цепочка", Chain[int64](), 7, 7, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn(context.Background(), tt.v)
+			switch {
+			case tt.wantErr == errAny:
+				if err == nil {
+					t.Errorf("ошибка nil, want ошибку")
+				}
+			case !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil):
+				t.Errorf("ошибка %v, want %v", err, tt.wantErr)
+			case got != tt.want:
+				t.Errorf("результат %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	a, _ := Hash(context.Background(), 42)
+	b, _ := Hash(context.Background(), 42)
+	c, _ := Hash(context.Background(), 43)
+	if a != b || a == c {
+		t.Errorf("Hash(42) = %d и %d, Hash(43) = %d", a, b, c)
+	}
+	for _, v := range []int64{-1, 0, math.MinInt64, math.MaxInt64} {
+		if h, _ := Hash(context.Background(), v); h < 0 {
+			t.Errorf("Hash(%d) = %d, want неотрицательное", v, h)
+		}
+	}
+}
+
+// errAny — ошибка-заглушка TestTransforms: подходит любая ошибка.
+var errAny = errors.New("любая ошибка")
+
+// TestDelayFunc проверяет, что DelayFunc ждёт по часам и прерывается
+// отменой контекста, возвращая значение без изменений.
+func TestDelayFunc(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	delay := DelayFunc[int64](clock, func() time.Duration { return time.Second })
+	done := make(chan error, 1)
+	go func() {
+		v, err := delay(context.Background(), 5)
+		if v != 5 {
+			t.Errorf("DelayFunc изменила значение: %d", v)
+		}
+		done <- err
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := delay(ctx, 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("DelayFunc после отмены = %v, want context.Canceled", err)
+	}
+}
+
+// TestRunFilter проверяет, что числа, отфильтрованные обработкой,
+// учитываются в Result и проверка результатов сходится.
+func TestRunFilter(t *testing.T) {
+	res, err := Run(context.Background(), Config{
+		NumWorkers: 3,
+		Limit:      100,
+		Process:    Filter(func(v int64) bool { return v%2 == 0 }),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OutputCount != 50 || res.SkippedCount != 50 || res.SkippedSum != 2500 {
+		t.Errorf("дошло %d, отфильтровано %d с суммой %d, want 50, 50 и 2500", res.OutputCount, res.SkippedCount, res.SkippedSum)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"math"
+	"sync"
+)
+
+// EnsureMonotonic проверяет, что числа из канала in строго возрастают.
+// Число, большее предыдущего принятого, пересылается в out, остальные
+// считаются нарушениями и отправляются в violations. Оба канала
+// закрываются, когда закрывается in.
+// Параметры
+// in - канал, откуда будут прочитаны числа
+// out - канал для чисел, сохраняющих возрастание
+// violations - канал для чисел, нарушающих возрастание
+// Возвращает количество нарушений.
+func EnsureMonotonic(in <-chan int64, out, violations chan<- int64) int64 {
+	// перед выходом из функции закрываем оба канала
+	defer close(violations)
+	defer close(out)
+
+	var (
+		prev  int64 // последнее принятое число
+		seen  bool  // было ли принято хотя бы одно число
+		count int64 // количество нарушений
+	)
+	for v := range in {
+		if seen && v <= prev {
+			violations <- v
+			count++
+			continue
+		}
+		prev, seen = v, true
+		out <- v
+	}
+	return count
+}
+
+// GapDetector находит пропуски в последовательности целых чисел из канала in
+// и считает пропущенные числа. Входная последовательность должна быть
+// отсортирована по возрастанию: для неотсортированных данных результат не
+// имеет смысла. Пропуски считаются по разности соседних чисел, поэтому даже
+// для редких чисел вроде случайных это занимает постоянное время; сами
+// пропущенные числа перебираются, только если задан канал gaps, и не больше
+// maxReported за весь поток. Канал gaps закрывается, когда закрывается in.
+// Параметры
+// in - канал, откуда будут прочитаны отсортированные числа
+// gaps - канал, куда будут записаны пропущенные числа; nil — только подсчёт
+// maxReported - сколько пропущенных чисел отправить в gaps
+// Возвращает общее количество пропущенных чисел; если оно не помещается в
+// int64, возвращается math.MaxInt64.
+func GapDetector(in <-chan int64, gaps chan<- int64, maxReported int64) int64 {
+	if gaps != nil {
+		defer close(gaps) // перед выходом из функции закрываем канал gaps
+	}
+
+	var (
+		prev     int64 // предыдущее число
+		seen     bool  // было ли получено хотя бы одно число
+		total    int64 // количество пропущенных чисел
+		reported int64 // количество чисел, отправленных в gaps
+	)
+	for v := range in {
+		if seen && v > prev {
+			// разность в uint64 не переполняется даже для чисел разных
+			// знаков
+			missing := uint64(v) - uint64(prev) - 1
+			if missing > uint64(math.MaxInt64-total) {
+				total = math.MaxInt64
+			} else {
+				total += int64(missing)
+			}
+			for m := prev + 1; gaps != nil && reported < maxReported && m < v; m++ {
+				gaps <- m
+				reported++
+			}
+		}
+		prev, seen = v, true
+	}
+	return total
+}
+
+// OrderReport — итоги проверки порядка чисел, переданных в Config.Collect
+// и Config.Sink.
+type OrderReport struct {
+	// Violations — числа, не большие предыдущего, при
+	// Config.CheckMonotonic
+	Violations int64
+	// Gaps — пропущенные целые числа при Config.DetectGaps
+	Gaps int64
+}
+
+// orderCheckBuffer — сколько чисел может ждать проверки порядка.
+const orderCheckBuffer = 256
+
+// orderCheck — проверка порядка чисел приёмника в горутинах конвейера:
+// EnsureMonotonic при CheckMonotonic, а за ней, на возрастающих числах,
+// GapDetector при DetectGaps. Вызовы add не пересекаются.
+type orderCheck struct {
+	in     chan int64
+	done   chan struct{}   // закрывается, когда report заполнен
+	abort  <-chan struct{} // прерывает передачу чисел и ожидание итогов
+	report OrderReport
+}
+
+// startOrderCheck запускает проверку порядка в группе g; закрытие abort
+// прерывает передачу чисел и ожидание итогов. nil, если не задана ни
+// проверка возрастания monotonic, ни поиск пропусков gaps.
+func startOrderCheck(g *group, monotonic, gaps bool, abort <-chan struct{}) *orderCheck {
+	if !monotonic && !gaps {
+		return nil
+	}
+	c := &orderCheck{in: make(chan int64, orderCheckBuffer), done: make(chan struct{}), abort: abort}
+	var wg sync.WaitGroup
+	var stream <-chan int64 = c.in
+	if monotonic {
+		in, out, violations := stream, make(chan int64), make(chan int64)
+		wg.Add(1)
+		g.Go("проверка возрастания", func() error {
+			defer wg.Done()
+			c.report.Violations = EnsureMonotonic(in, out, violations)
+			return nil
+		})
+		// сами нарушения не нужны, достаточно их количества
+		g.Go("нарушения возрастания", func() error {
+			for range violations {
+			}
+			return nil
+		})
+		stream = out
+	}
+	in, name := stream, "возрастающие числа"
+	if gaps {
+		name = "поиск пропусков"
+	}
+	wg.Add(1)
+	g.Go(name, func() error {
+		defer wg.Done()
+		if gaps {
+			c.report.Gaps = GapDetector(in, nil, 0)
+			return nil
+		}
+		for range in {
+		}
+		return nil
+	})
+	g.Go("проверка порядка", func() error {
+		wg.Wait()
+		close(c.done)
+		return nil
+	})
+	return c
+}
+
+// add передаёт v на проверку.
+func (c *orderCheck) add(v int64) {
+	if c == nil {
+		return
+	}
+	select {
+	case c.in <- v:
+	case <-c.abort:
+	}
+}
+
+// close дожидается проверки всех переданных чисел и возвращает её итоги;
+// nil, если проверка не задана или не завершилась до закрытия stop или
+// abort.
+func (c *orderCheck) close(stop <-chan struct{}) *OrderReport {
+	if c == nil {
+		return nil
+	}
+	close(c.in)
+	if !doneBefore(c.done, stop, c.abort) {
+		return nil
+	}
+	return &c.report
+}
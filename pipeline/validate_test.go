@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEnsureMonotonic(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         []int64
+		want           []int64
+		wantViolations []int64
+	}{
+		{"пусто", nil, nil, nil},
+		{"возрастают", ints(1, 5), ints(1, 5), nil},
+		{"повтор и спад", []int64{1, 2, 2, 3, 1, 4}, []int64{1, 2, 3, 4}, []int64{2, 1}},
+		{"отрицательные", []int64{-5, -7, -1}, []int64{-5, -1}, []int64{-7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan int64, len(tt.values))
+			out, violations := make(chan int64, len(tt.values)), make(chan int64, len(tt.values))
+			for _, v := range tt.values {
+				in <- v
+			}
+			close(in)
+			n := EnsureMonotonic(in, out, violations)
+			got, bad := collect(out), collect(violations)
+			if !slices.Equal(got, tt.want) || !slices.Equal(bad, tt.wantViolations) || n != int64(len(tt.wantViolations)) {
+				t.Errorf("EnsureMonotonic = %v, нарушения %v (%d), want %v и %v", got, bad, n, tt.want, tt.wantViolations)
+			}
+		})
+	}
+}
+
+// TestGapDetector проверяет подсчёт и перечисление пропущенных чисел.
+func TestGapDetector(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          []int64
+		maxReported int64
+		wantGaps    []int64
+		wantTotal   int64
+	}{
+		{"пропуски", []int64{1, 2, 4, 5, 8}, 10, []int64{3, 6, 7}, 3},
+		{"без пропусков", ints(1, 5), 10, nil, 0},
+		{"пусто", nil, 10, nil, 0},
+		{"ограничение перечисления", []int64{1, 10}, 3, []int64{2, 3, 4}, 8},
+		{"разные знаки", []int64{-3, 2}, 10, []int64{-2, -1, 0, 1}, 4},
+		{"до MaxInt64", []int64{math.MaxInt64 - 3, math.MaxInt64}, 10, []int64{math.MaxInt64 - 2, math.MaxInt64 - 1}, 2},
+		{"переполнение количества", []int64{math.MinInt64, 0, math.MaxInt64}, 0, nil, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, gaps := make(chan int64, len(tt.in)), make(chan int64)
+			for _, v := range tt.in {
+				in <- v
+			}
+			close(in)
+			total := make(chan int64, 1)
+			go func() { total <- GapDetector(in, gaps, tt.maxReported) }()
+			if got := collect(gaps); !slices.Equal(got, tt.wantGaps) {
+				t.Errorf("пропуски %v, want %v", got, tt.wantGaps)
+			}
+			if got := <-total; got != tt.wantTotal {
+				t.Errorf("всего %d, want %d", got, tt.wantTotal)
+			}
+		})
+	}
+}
+
+// TestGapDetectorSparse проверяет, что редкие случайные числа считаются без
+// перебора пропусков.
+func TestGapDetectorSparse(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	values := make([]int64, 1000)
+	for i := range values {
+		values[i] = rng.Int64()
+	}
+	slices.Sort(values)
+	values = slices.Compact(values)
+	want := values[len(values)-1] - values[0] - int64(len(values)-1)
+
+	in := make(chan int64, len(values))
+	for _, v := range values {
+		in <- v
+	}
+	close(in)
+	done := make(chan int64, 1)
+	go func() { done <- GapDetector(in, nil, 0) }()
+	select {
+	case got := <-done:
+		if got != want {
+			t.Errorf("всего %d, want %d", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GapDetector перебирает пропуски")
+	}
+}
+
+// TestRunOrderCheck проверяет, что Run считает через EnsureMonotonic и
+// GapDetector нарушения возрастания и пропуски среди чисел Collect.
+func TestRunOrderCheck(t *testing.T) {
+	even := func(_ context.Context, v int64) (int64, error) {
+		if v%2 != 0 {
+			return 0, ErrSkip
+		}
+		return v, nil
+	}
+	// каждое десятое число обрабатывается дольше, и другие обработчики его
+	// обгоняют
+	slow := func(_ context.Context, v int64) (int64, error) {
+		if v%10 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		return v, nil
+	}
+	tests := []struct {
+		name           string
+		cfg            Config
+		want           *OrderReport
+		wantViolations bool // ожидаются нарушения, количество которых не задано
+	}{
+		{"выключена", Config{NumWorkers: 2}, nil, false},
+		{"один обработчик", Config{NumWorkers: 1, CheckMonotonic: true, DetectGaps: true}, &OrderReport{}, false},
+		{"с сохранением порядка", Config{NumWorkers: 4, Ordered: true, CheckMonotonic: true, DetectGaps: true}, &OrderReport{}, false},
+		{"пропуски", Config{NumWorkers: 1, Process: even, DetectGaps: true}, &OrderReport{Gaps: 499}, false},
+		{"нарушения", Config{NumWorkers: 4, Process: slow, CheckMonotonic: true}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Limit = 1000
+			res, err := Run(context.Background(), tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantViolations {
+				if res.Order == nil || res.Order.Violations == 0 {
+					t.Errorf("Order = %+v, want нарушения возрастания", res.Order)
+				}
+				return
+			}
+			if (res.Order == nil) != (tt.want == nil) || res.Order != nil && *res.Order != *tt.want {
+				t.Errorf("Order = %+v, want %+v", res.Order, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// WarmupReport — итоги прогрева Config.Warmup: работа конвейера до начала
+// измерения, которая не входит в Result.Throughput, Result.WorkerThroughput
+// и задержку Result.Latency.
+type WarmupReport struct {
+	// Duration — время прогрева от запуска конвейера; если генерация
+	// остановилась раньше, прогрев длится весь запуск
+	Duration  time.Duration
+	Generated int64   // числа, сгенерированные за время прогрева
+	Output    int64   // числа, пришедшие за это время в результирующий канал
+	PerWorker []int64 // числа каналов outs[i] за это время
+	// Complete — прогрев закончился до остановки генерации, и после него
+	// шло измерение
+	Complete bool
+}
+
+// warmup — прогрев запуска: по его окончании запоминается статистика,
+// которую производительность не учитывает.
+type warmup struct {
+	end time.Time // числа, полученные из источника раньше, не измеряются
+
+	mu   sync.Mutex
+	done bool      // прогрев закончился
+	at   time.Time // время окончания прогрева
+	snap Snapshot  // статистика по окончании прогрева
+}
+
+// startWarmup запускает в группе g прогрев длительностью d по часам clock:
+// по его окончании запоминается статистика stats. Прогрев прерывается
+// закрытием stop. nil, если d не задана.
+func startWarmup(g *group, d time.Duration, clock Clock, stats *Stats, stop <-chan struct{}) *warmup {
+	if d <= 0 {
+		return nil
+	}
+	w := &warmup{end: clock.Now().Add(d)}
+	g.Go("прогрев", func() error {
+		select {
+		case <-clock.After(d):
+			w.mu.Lock()
+			w.done, w.at, w.snap = true, clock.Now(), stats.Snapshot()
+			w.mu.Unlock()
+		case <-stop:
+		}
+		return nil
+	})
+	return w
+}
+
+// measured сообщает, входит ли в измерение число, полученное из источника
+// в момент born.
+func (w *warmup) measured(born time.Time) bool {
+	return w == nil || !born.Before(w.end)
+}
+
+// result возвращает итоги прогрева запуска, который начался в start и
+// длился elapsed, с итоговой статистикой snap; если прогрев не
+// закончился, он длится весь запуск. nil, если прогрев не задан.
+func (w *warmup) result(snap Snapshot, start time.Time, elapsed time.Duration) *WarmupReport {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d := elapsed
+	if w.done {
+		snap, d = w.snap, min(max(w.at.Sub(start), 0), elapsed)
+	}
+	return &WarmupReport{
+		Duration:  d,
+		Generated: snap.InputCount,
+		Output:    snap.OutputCount,
+		PerWorker: snap.PerWorker,
+		Complete:  w.done,
+	}
+}
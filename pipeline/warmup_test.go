@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResultThroughputWarmup(t *testing.T) {
+	tests := []struct {
+		name       string
+		res        Result
+		want       float64
+		wantWorker []float64
+	}{
+		{"без прогрева", Result{Snapshot: Snapshot{OutputCount: 30, PerWorker: []int64{10, 20}}, Duration: 2 * time.Second}, 15, []float64{5, 10}},
+		{"с прогревом", Result{
+			Snapshot: Snapshot{OutputCount: 30, PerWorker: []int64{10, 20}},
+			Duration: 2 * time.Second,
+			Warmup:   &WarmupReport{Duration: time.Second, Output: 10, PerWorker: []int64{4, 6}, Complete: true},
+		}, 20, []float64{6, 14}},
+		{"прогрев весь запуск", Result{
+			Snapshot: Snapshot{OutputCount: 30, PerWorker: []int64{10, 20}},
+			Duration: 2 * time.Second,
+			Warmup:   &WarmupReport{Duration: 2 * time.Second, Output: 30, PerWorker: []int64{10, 20}},
+		}, 0, []float64{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.res.Throughput(); got != tt.want {
+				t.Errorf("Throughput = %v, want %v", got, tt.want)
+			}
+			if got := tt.res.WorkerThroughput(); !slices.Equal(got, tt.wantWorker) {
+				t.Errorf("WorkerThroughput = %v, want %v", got, tt.wantWorker)
+			}
+		})
+	}
+}
+
+// TestRunWarmup проверяет, что Timeout отсчитывается после прогрева, а
+// числа прогрева проверяются, но не входят в задержку.
+func TestRunWarmup(t *testing.T) {
+	const warm, timeout = 50 * time.Millisecond, 50 * time.Millisecond
+	for _, batched := range []bool{false, true} {
+		p := New(Config{NumWorkers: 2, Warmup: warm, Timeout: timeout, Rate: 1000})
+		var (
+			res Result
+			err error
+		)
+		if batched {
+			res, err = p.RunBatched(context.Background(), 4, time.Millisecond)
+		} else {
+			res, err = p.Run(context.Background())
+		}
+		if err != nil {
+			t.Fatalf("пачки %v: %v", batched, err)
+		}
+		if err := res.Verify(); err != nil {
+			t.Errorf("пачки %v: %v", batched, err)
+		}
+		w := res.Warmup
+		if w == nil || !w.Complete || w.Duration < warm || w.Output == 0 || w.Output > res.OutputCount {
+			t.Fatalf("пачки %v: прогрев %+v, дошло всего %d", batched, w, res.OutputCount)
+		}
+		if res.Duration < warm+timeout {
+			t.Errorf("пачки %v: конвейер работал %v, want не меньше %v", batched, res.Duration, warm+timeout)
+		}
+		if !batched && res.Latency.Count >= res.OutputCount {
+			t.Errorf("задержка измерена у %d чисел из %d, want без чисел прогрева", res.Latency.Count, res.OutputCount)
+		}
+	}
+}
+
+// TestRunWarmupIncomplete проверяет, что прогрев, прерванный остановкой
+// генерации, длится весь запуск и не оставляет чисел для измерения.
+func TestRunWarmupIncomplete(t *testing.T) {
+	res, err := Run(context.Background(), Config{NumWorkers: 2, Warmup: time.Hour, Limit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := res.Warmup
+	if w == nil || w.Complete || w.Duration != res.Duration || w.Output != 20 {
+		t.Fatalf("прогрев %+v, want незавершённый на весь запуск", w)
+	}
+	if res.Throughput() != 0 {
+		t.Errorf("Throughput = %v, want 0", res.Throughput())
+	}
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrStalled — причина отмены контекста обработки числа, которое
+// обрабатывается дольше WatchdogPolicy.Stall.
+var ErrStalled = errors.New("обработка числа зависла")
+
+// WatchdogPolicy — наблюдение за зависшими обработчиками. Нулевое значение
+// выключает наблюдение.
+type WatchdogPolicy struct {
+	// Stall — сколько может обрабатываться одно число, прежде чем
+	// обработчик считается зависшим; 0 — наблюдение выключено
+	Stall time.Duration
+	// Interval — период проверки обработчиков; 0 — четверть Stall
+	Interval time.Duration
+	// Cancel — отменять контекст обработки зависшего числа с причиной
+	// ErrStalled; обработка, которая учитывает контекст, вернёт ошибку
+	Cancel bool
+}
+
+// enabled сообщает, включено ли наблюдение.
+func (w WatchdogPolicy) enabled() bool {
+	return w.Stall > 0
+}
+
+// withDefaults возвращает настройки с заполненными значениями по умолчанию.
+func (w WatchdogPolicy) withDefaults() WatchdogPolicy {
+	if w.Interval == 0 {
+		w.Interval = max(w.Stall/4, time.Millisecond)
+	}
+	return w
+}
+
+// validate проверяет корректность настроек.
+func (w WatchdogPolicy) validate() error {
+	if w.Stall < 0 || w.Interval < 0 {
+		return fmt.Errorf("время зависания и период проверки не могут быть отрицательными: %v, %v", w.Stall, w.Interval)
+	}
+	return nil
+}
+
+// watchdog отмечает, какое число и с какого времени обрабатывает каждый
+// обработчик, и находит зависшие. Методы можно вызывать из разных
+// горутин.
+type watchdog struct {
+	policy WatchdogPolicy
+	clock  Clock
+	stats  *Stats
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	items []watchedItem // текущее число каждого обработчика
+}
+
+// watchedItem — число, которое обрабатывает обработчик.
+type watchedItem struct {
+	busy    bool
+	value   int64
+	started time.Time
+	stalled bool                    // о зависании уже сообщено
+	cancel  context.CancelCauseFunc // отменяет контекст обработки
+}
+
+// newWatchdog создаёт наблюдение за workers обработчиками.
+func newWatchdog(policy WatchdogPolicy, workers int, clock Clock, stats *Stats, logger *slog.Logger) *watchdog {
+	return &watchdog{
+		policy: policy.withDefaults(),
+		clock:  clock,
+		stats:  stats,
+		logger: logger,
+		items:  make([]watchedItem, workers),
+	}
+}
+
+// process оборачивает обработку обработчика workerID так, чтобы
+// наблюдение знало время начала обработки каждого числа и могло отменить
+// её контекст.
+func (w *watchdog) process(workerID int, p func(context.Context, Event) (Event, error)) func(context.Context, Event) (Event, error) {
+	return func(ctx context.Context, e Event) (Event, error) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		w.mu.Lock()
+		w.items[workerID] = watchedItem{busy: true, value: e.Value, started: w.clock.Now(), cancel: cancel}
+		w.mu.Unlock()
+		defer func() {
+			w.mu.Lock()
+			if w.items[workerID].stalled {
+				w.stats.markHealthy(workerID)
+			}
+			w.items[workerID] = watchedItem{}
+			w.mu.Unlock()
+			cancel(nil)
+		}()
+		res, err := p(ctx, e)
+		if err != nil && errors.Is(context.Cause(ctx), ErrStalled) && !errors.Is(err, ErrStalled) {
+			err = fmt.Errorf("%w: %w", ErrStalled, err)
+		}
+		return res, err
+	}
+}
+
+// run раз в Interval проверяет обработчики, пока не отменён ctx.
+func (w *watchdog) run(ctx context.Context) {
+	for Sleep(ctx, w.clock, w.policy.Interval) == nil {
+		w.check()
+	}
+}
+
+// check сообщает об обработчиках, которые обрабатывают одно число дольше
+// Stall, отмечает их в статистике и при Cancel отменяет обработку.
+func (w *watchdog) check() {
+	now := w.clock.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.items {
+		it := &w.items[i]
+		if !it.busy || it.stalled || now.Sub(it.started) < w.policy.Stall {
+			continue
+		}
+		it.stalled = true
+		w.stats.markStalled(i)
+		w.logger.Warn("обработчик завис", "worker", i, "value", it.value, "for", now.Sub(it.started), "cancel", w.policy.Cancel)
+		if w.policy.Cancel {
+			it.cancel(ErrStalled)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// TestWatchdogCheck проверяет, что обработчик считается зависшим, только
+// когда обрабатывает одно число дольше Stall, и что после окончания
+// обработки он снова отмечается как исправный.
+func TestWatchdogCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     WatchdogPolicy
+		elapsed    time.Duration
+		wantStall  bool
+		wantCancel bool
+	}{
+		{"быстрая обработка", WatchdogPolicy{Stall: time.Second}, 500 * time.Millisecond, false, false},
+		{"зависание", WatchdogPolicy{Stall: time.Second}, 2 * time.Second, true, false},
+		{"зависание с отменой", WatchdogPolicy{Stall: time.Second, Cancel: true}, 2 * time.Second, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(time.Unix(0, 0))
+			stats := NewStats(2)
+			w := newWatchdog(tt.policy, 2, clock, stats, slog.New(slog.DiscardHandler))
+			process := w.process(1, func(ctx context.Context, e Event) (Event, error) {
+				clock.Advance(tt.elapsed)
+				w.check()
+				snap := stats.Snapshot()
+				if snap.Unhealthy[1] != tt.wantStall {
+					t.Errorf("Unhealthy во время обработки = %v, want %v", snap.Unhealthy, tt.wantStall)
+				}
+				if cancelled := errors.Is(context.Cause(ctx), ErrStalled); cancelled != tt.wantCancel {
+					t.Errorf("обработка отменена = %v, want %v", cancelled, tt.wantCancel)
+				}
+				return e, ctx.Err()
+			})
+			_, err := process(context.Background(), Event{Value: 7})
+			if tt.wantCancel != errors.Is(err, ErrStalled) {
+				t.Errorf("process = %v, want ErrStalled: %v", err, tt.wantCancel)
+			}
+			snap := stats.Snapshot()
+			var want int64
+			if tt.wantStall {
+				want = 1
+			}
+			if snap.Stalls[1] != want || snap.Stalls[0] != 0 || snap.Unhealthy[1] {
+				t.Errorf("Stalls = %v, Unhealthy = %v, want %d зависаний и исправный обработчик", snap.Stalls, snap.Unhealthy, want)
+			}
+		})
+	}
+}
+
+// TestRunWatchdogCancel проверяет, что обработка зависшего числа
+// отменяется, число учитывается как необработанное, а зависание — в
+// статистике.
+func TestRunWatchdogCancel(t *testing.T) {
+	res, err := Run(context.Background(), Config{
+		NumWorkers:  2,
+		Limit:       10,
+		Watchdog:    WatchdogPolicy{Stall: 20 * time.Millisecond, Cancel: true},
+		DeadLetters: MemoryDeadLetters,
+		Process: func(ctx context.Context, v int64) (int64, error) {
+			if v == 3 {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			}
+			return v, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := res.Verify(); err != nil {
+		t.Error(err)
+	}
+	if len(res.DeadLetters) != 1 || res.DeadLetters[0].Value != 3 || !errors.Is(res.DeadLetters[0].Err, ErrStalled) {
+		t.Errorf("необработанные %+v, want 3 с ErrStalled", res.DeadLetters)
+	}
+	var stalls int64
+	for _, n := range res.Stalls {
+		stalls += n
+	}
+	if stalls != 1 {
+		t.Errorf("Stalls = %v, want одно зависание", res.Stalls)
+	}
+}
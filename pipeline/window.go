@@ -0,0 +1,295 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// WindowPolicy — окна агрегирования WindowSink: по времени (Duration) или
+// по количеству чисел (Count). Окно без сдвига — неперекрывающееся
+// (tumbling), со сдвигом меньше окна — скользящее (sliding): каждое число
+// попадает в несколько окон, а очередное окно выдаётся через каждый сдвиг.
+type WindowPolicy struct {
+	// Duration — длительность окна по времени получения чисел приёмником;
+	// окна выровнены по началу эпохи Unix
+	Duration time.Duration
+	// Slide — сдвиг окна по времени; 0 — Duration. Duration должна делиться
+	// на Slide
+	Slide time.Duration
+	// Count — количество чисел окна; задаётся вместо Duration
+	Count int
+	// Step — сдвиг окна в числах; 0 — Count. Count должно делиться на Step
+	Step int
+}
+
+// validate проверяет корректность настроек.
+func (w WindowPolicy) validate() error {
+	switch {
+	case w.Duration < 0 || w.Slide < 0 || w.Count < 0 || w.Step < 0:
+		return errors.New("размер и сдвиг окна не могут быть отрицательными")
+	case (w.Duration > 0) == (w.Count > 0):
+		return errors.New("окно задаётся либо длительностью, либо количеством чисел")
+	case w.Duration > 0 && (w.Count > 0 || w.Step > 0), w.Count > 0 && w.Slide > 0:
+		return errors.New("сдвиг окна задаётся в тех же единицах, что и его размер")
+	case w.Slide > w.Duration || w.Slide > 0 && w.Duration%w.Slide != 0:
+		return fmt.Errorf("длительность окна %v должна делиться на сдвиг %v", w.Duration, w.Slide)
+	case w.Step > w.Count || w.Step > 0 && w.Count%w.Step != 0:
+		return fmt.Errorf("количество чисел окна %d должно делиться на сдвиг %d", w.Count, w.Step)
+	}
+	return nil
+}
+
+// ParseWindowPolicy разбирает окно агрегирования: размер size и сдвиг slide
+// — длительности, например "1s" и "250ms", или количества чисел, например
+// "100" и "10". Пустой slide — окно без сдвига.
+func ParseWindowPolicy(size, slide string) (WindowPolicy, error) {
+	var w WindowPolicy
+	if n, err := strconv.Atoi(size); err == nil {
+		w.Count = n
+		if slide != "" {
+			if w.Step, err = strconv.Atoi(slide); err != nil {
+				return w, fmt.Errorf("сдвиг окна %q: ожидается количество чисел, как и размер", slide)
+			}
+		}
+		return w, w.validate()
+	}
+	d, err := time.ParseDuration(size)
+	if err != nil {
+		return w, fmt.Errorf("размер окна %q: ожидается длительность, например 1s, или количество чисел", size)
+	}
+	w.Duration = d
+	if slide != "" {
+		if w.Slide, err = time.ParseDuration(slide); err != nil {
+			return w, fmt.Errorf("сдвиг окна %q: ожидается длительность, как и размер", slide)
+		}
+	}
+	return w, w.validate()
+}
+
+// WindowAggregate — итоги одного окна WindowSink.
+type WindowAggregate struct {
+	// Index — номер окна: окна нумеруются по номеру их последнего сдвига,
+	// для окон по времени — от начала эпохи Unix, для окон чисел — от 0
+	Index int64
+	// Start и End — границы окна по времени; нулевые у окон чисел
+	Start, End time.Time
+	Count      int64   // количество чисел окна
+	Sum        int64   // сумма чисел окна
+	Min, Max   int64   // наименьшее и наибольшее число окна
+	Mean       float64 // среднее чисел окна
+}
+
+// WindowEmitter принимает итоги окон WindowSink.
+type WindowEmitter interface {
+	// Emit принимает итоги окна a.
+	Emit(a WindowAggregate) error
+	// Flush дописывает накопленные итоги.
+	Flush() error
+}
+
+// pane — итоги чисел одного сдвига окна.
+type pane struct {
+	index      int64 // номер сдвига
+	count, sum int64
+	min, max   int64
+}
+
+// add учитывает v в итогах сдвига.
+func (p *pane) add(v int64) {
+	if p.count == 0 {
+		p.min, p.max = v, v
+	}
+	p.count++
+	p.sum += v
+	p.min = min(p.min, v)
+	p.max = max(p.max, v)
+}
+
+// WindowSink — приёмник, который вместо самих чисел передаёт в
+// WindowEmitter итоги окон: количество, сумму, наименьшее, наибольшее и
+// среднее число каждого окна. Окно по времени выдаётся, когда приходит число
+// более позднего сдвига, или в Flush; окна без чисел не выдаются. Окно чисел
+// выдаётся, как только заполнен его последний сдвиг, а неполное окно — в
+// Flush. Вызовы методов не должны пересекаться.
+type WindowSink struct {
+	policy WindowPolicy
+	clock  Clock
+	emit   WindowEmitter
+
+	panes   []pane // сдвиги окна по кругу: сдвиг i хранится в panes[i%len]
+	current int64  // номер текущего сдвига
+	started bool   // получено хотя бы одно число
+	seen    int64  // количество полученных чисел для окон чисел
+}
+
+// NewWindowSink создаёт приёмник, выдающий в emit итоги окон policy. Время
+// получения чисел для окон по времени берётся по часам clock; nil —
+// SystemClock.
+func NewWindowSink(policy WindowPolicy, clock Clock, emit WindowEmitter) (*WindowSink, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	if policy.Slide == 0 {
+		policy.Slide = policy.Duration
+	}
+	if policy.Step == 0 {
+		policy.Step = policy.Count
+	}
+	n := policy.Count / max(policy.Step, 1)
+	if policy.Duration > 0 {
+		n = int(policy.Duration / policy.Slide)
+	}
+	return &WindowSink{policy: policy, clock: clock, emit: emit, panes: make([]pane, n)}, nil
+}
+
+// Write учитывает v в окнах, выдавая окна, которые закончились до него.
+func (s *WindowSink) Write(_ context.Context, v int64) error {
+	var index int64
+	if s.policy.Duration > 0 {
+		index = s.clock.Now().UnixNano() / int64(s.policy.Slide)
+	} else {
+		index = s.seen / int64(s.policy.Step)
+		s.seen++
+	}
+	if !s.started {
+		s.started, s.current = true, index
+	}
+	// часы, идущие назад, не возвращают к уже выданным окнам
+	index = max(index, s.current)
+	if index > s.current {
+		if err := s.emitUntil(index); err != nil {
+			return err
+		}
+		s.current = index
+	}
+	p := s.pane(index)
+	p.add(v)
+	// окно чисел выдаётся сразу по заполнении сдвига, кроме неполных
+	// первых окон
+	if s.policy.Count > 0 && p.count == int64(s.policy.Step) && index >= int64(len(s.panes)-1) {
+		return s.emitWindow(index)
+	}
+	return nil
+}
+
+// pane возвращает итоги сдвига index, очищая место прежнего сдвига.
+func (s *WindowSink) pane(index int64) *pane {
+	p := &s.panes[index%int64(len(s.panes))]
+	if p.index != index || p.count == 0 {
+		*p = pane{index: index}
+	}
+	return p
+}
+
+// emitUntil выдаёт окна по времени, закончившиеся до сдвига next: окна,
+// последний сдвиг которых от текущего до next, пока в них остаются числа.
+func (s *WindowSink) emitUntil(next int64) error {
+	if s.policy.Count > 0 {
+		return nil
+	}
+	last := min(next-1, s.current+int64(len(s.panes))-1)
+	for i := s.current; i <= last; i++ {
+		if err := s.emitWindow(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitWindow выдаёт итоги окна, последний сдвиг которого — end, если в нём
+// есть числа.
+func (s *WindowSink) emitWindow(end int64) error {
+	a := WindowAggregate{Index: end}
+	for i := range s.panes {
+		p := &s.panes[i]
+		if p.count == 0 || p.index > end || p.index <= end-int64(len(s.panes)) {
+			continue
+		}
+		if a.Count == 0 {
+			a.Min, a.Max = p.min, p.max
+		}
+		a.Count += p.count
+		a.Sum += p.sum
+		a.Min = min(a.Min, p.min)
+		a.Max = max(a.Max, p.max)
+	}
+	if a.Count == 0 {
+		return nil
+	}
+	a.Mean = float64(a.Sum) / float64(a.Count)
+	if s.policy.Duration > 0 {
+		a.End = time.Unix(0, (end+1)*int64(s.policy.Slide))
+		a.Start = a.End.Add(-s.policy.Duration)
+	}
+	return s.emit.Emit(a)
+}
+
+// Flush выдаёт окна, в которых остались числа, и дописывает итоги в
+// WindowEmitter. Следующее после Flush число начинает окна заново.
+func (s *WindowSink) Flush() error {
+	var err error
+	if s.started {
+		if s.policy.Duration > 0 {
+			err = s.emitUntil(math.MaxInt64)
+		} else if s.pane(s.current).count < int64(s.policy.Step) || s.current < int64(len(s.panes)-1) {
+			// окно с полным последним сдвигом уже выдано в Write, если
+			// оно не одно из неполных первых
+			err = s.emitWindow(s.current)
+		}
+	}
+	clear(s.panes)
+	s.started, s.seen = false, 0
+	if ferr := s.emit.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// WindowWriter — WindowEmitter, записывающий итоги окон в io.Writer по
+// одному JSON-объекту в строке через буфер.
+type WindowWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewWindowWriter создаёт WindowEmitter, записывающий итоги окон в w.
+func NewWindowWriter(w io.Writer) *WindowWriter {
+	bw := bufio.NewWriter(w)
+	return &WindowWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// windowRecord — строка WindowWriter.
+type windowRecord struct {
+	Window int64      `json:"window"`
+	Start  *time.Time `json:"start,omitempty"`
+	End    *time.Time `json:"end,omitempty"`
+	Count  int64      `json:"count"`
+	Sum    int64      `json:"sum"`
+	Min    int64      `json:"min"`
+	Max    int64      `json:"max"`
+	Mean   float64    `json:"mean"`
+}
+
+// Emit записывает итоги окна a в буфер.
+func (w *WindowWriter) Emit(a WindowAggregate) error {
+	rec := windowRecord{Window: a.Index, Count: a.Count, Sum: a.Sum, Min: a.Min, Max: a.Max, Mean: a.Mean}
+	if !a.Start.IsZero() {
+		rec.Start, rec.End = &a.Start, &a.End
+	}
+	return w.enc.Encode(rec)
+}
+
+// Flush записывает буфер в io.Writer.
+func (w *WindowWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+// windowRecorder — WindowEmitter, запоминающий итоги окон.
+type windowRecorder struct {
+	got     []WindowAggregate
+	flushes int
+}
+
+func (r *windowRecorder) Emit(a WindowAggregate) error {
+	r.got = append(r.got, a)
+	return nil
+}
+
+func (r *windowRecorder) Flush() error {
+	r.flushes++
+	return nil
+}
+
+// sums возвращает суммы окон.
+func (r *windowRecorder) sums() []int64 {
+	sums := make([]int64, len(r.got))
+	for i, a := range r.got {
+		sums[i] = a.Sum
+	}
+	return sums
+}
+
+func TestParseWindowPolicy(t *testing.T) {
+	tests := []struct {
+		size, slide string
+		want        WindowPolicy
+		wantErr     bool
+	}{
+		{"100", "", WindowPolicy{Count: 100}, false},
+		{"100", "25", WindowPolicy{Count: 100, Step: 25}, false},
+		{"1s", "", WindowPolicy{Duration: time.Second}, false},
+		{"1s", "250ms", WindowPolicy{Duration: time.Second, Slide: 250 * time.Millisecond}, false},
+		{"100", "30", WindowPolicy{}, true},
+		{"1s", "300ms", WindowPolicy{}, true},
+		{"1s", "2s", WindowPolicy{}, true},
+		{"100", "1s", WindowPolicy{}, true},
+		{"1s", "10", WindowPolicy{}, true},
+		{"0", "", WindowPolicy{}, true},
+		{"-5", "", WindowPolicy{}, true},
+		{"окно", "", WindowPolicy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWindowPolicy(tt.size, tt.slide)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWindowPolicy(%q, %q) = %v, wantErr %v", tt.size, tt.slide, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseWindowPolicy(%q, %q) = %+v, want %+v", tt.size, tt.slide, got, tt.want)
+		}
+	}
+}
+
+// TestWindowSinkCount проверяет окна по количеству чисел: неперекрывающиеся
+// и скользящие, с неполным окном при Flush.
+func TestWindowSinkCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy WindowPolicy
+		want   []int64 // суммы окон чисел 1..7
+	}{
+		{"неперекрывающиеся", WindowPolicy{Count: 3}, []int64{6, 15, 7}},
+		{"скользящие", WindowPolicy{Count: 4, Step: 2}, []int64{10, 18, 18}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec windowRecorder
+			s, err := NewWindowSink(tt.policy, nil, &rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for v := int64(1); v <= 7; v++ {
+				if err := s.Write(context.Background(), v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(rec.sums(), tt.want) || rec.flushes != 1 {
+				t.Errorf("суммы окон %v, Flush %d раз, want %v и 1", rec.sums(), rec.flushes, tt.want)
+			}
+		})
+	}
+}
+
+// TestWindowSinkDuration проверяет окна по времени: итоги выдаются при
+// переходе в следующий сдвиг, окна без чисел пропускаются, а у окна есть
+// границы.
+func TestWindowSinkDuration(t *testing.T) {
+	start := time.Unix(100, 0)
+	clock := NewManualClock(start)
+	var rec windowRecorder
+	s, err := NewWindowSink(WindowPolicy{Duration: time.Second, Slide: 500 * time.Millisecond}, clock, &rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(v int64) {
+		if err := s.Write(context.Background(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(1)
+	write(2)
+	clock.Advance(500 * time.Millisecond)
+	write(3)
+	// три сдвига без чисел
+	clock.Advance(2 * time.Second)
+	write(4)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// окна, которые заканчиваются в 100.5s, 101s, 101.5s, 103s и 103.5s:
+	// каждое число попадает в два окна
+	if want := []int64{3, 6, 3, 4, 4}; !slices.Equal(rec.sums(), want) {
+		t.Fatalf("суммы окон %v, want %v", rec.sums(), want)
+	}
+	a := rec.got[1]
+	if !a.Start.Equal(start) || !a.End.Equal(start.Add(time.Second)) || a.Count != 3 || a.Min != 1 || a.Max != 3 || a.Mean != 2 {
+		t.Errorf("окно %+v, want [100s, 101s) из 1, 2 и 3", a)
+	}
+}
+
+// TestWindowWriter проверяет, что итоги окон записываются JSON-строками, а
+// границы — только у окон по времени.
+func TestWindowWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWindowWriter(&buf)
+	end := time.Unix(1, 0).UTC()
+	w.Emit(WindowAggregate{Index: 1, Count: 2, Sum: 3, Min: 1, Max: 2, Mean: 1.5})
+	w.Emit(WindowAggregate{Index: 2, Start: end.Add(-time.Second), End: end, Count: 1, Sum: 5, Min: 5, Max: 5, Mean: 5})
+	if buf.Len() != 0 {
+		t.Error("итоги записаны до Flush")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&buf)
+	var recs []map[string]any
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 || recs[0]["start"] != nil || recs[0]["mean"] != 1.5 || recs[1]["end"] != "1970-01-01T00:00:01Z" {
+		t.Errorf("строки %v", recs)
+	}
+}
@@ -0,0 +1,330 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultWorkerDelay — пауза обработки по умолчанию: Worker без WithProcess
+// обрабатывает каждое значение преобразованием Delay(DefaultWorkerDelay).
+const DefaultWorkerDelay = time.Millisecond
+
+// Worker читает значение из канала in, обрабатывает его функцией, заданной
+// WithProcess (по умолчанию Delay(DefaultWorkerDelay)), и пишет результат в
+// канал out. Если обработка вернула ErrSkip, значение отфильтровывается и
+// передаётся обработчику WithOnSkip. Неудачная обработка повторяется по
+// политике WithRetry; значение, обработка которого окончательно не
+// удалась, отправляется в канал WithDeadLetter, если он задан. Если
+// значения — конверты Item, в результате отмечается количество попыток
+// Item.Attempts. Worker завершается, когда канал in закрыт, закрыт канал
+// WithQuit, контекст ctx отменён или обработка вернула другую ошибку, а
+// WithDeadLetter не задан. При отмене контекста ожидание как чтения, так и
+// записи прерывается. Значение, которое не удалось обработать или
+// отправить, передаётся в исходном виде обработчику WithOnDrop, если он
+// задан.
+// Параметры
+// ctx - контекст
+// in - канал, откуда будут прочитаны значения
+// out - канал, куда будут значения записаны
+// opts - дополнительные настройки обработчика, например WithProcess
+// Возвращает ошибку обработки; при закрытии in или отмене ctx — nil.
+func Worker[T any](ctx context.Context, in <-chan T, out chan<- T, opts ...WorkerOption[T]) error {
+	defer close(out) // перед выходом из функции закрываем канал out
+
+	o := workerOptions[T]{process: Delay[T](DefaultWorkerDelay), clock: SystemClock}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	process := o.process
+	if o.itemTimeout > 0 {
+		process = withItemTimeout(process, o.itemTimeout)
+	}
+	// drop передаёт необработанное значение в WithOnDrop
+	drop := func(v T) {
+		if o.onDrop != nil {
+			o.onDrop(v)
+		}
+	}
+	// timing — время текущего значения для WithOnItem; mark — начало
+	// текущего этапа
+	var (
+		timing WorkerTiming
+		mark   time.Time
+	)
+	if o.onItem != nil {
+		mark = o.clock.Now()
+	}
+	// lap возвращает время с начала текущего этапа и начинает следующий
+	lap := func() time.Duration {
+		if o.onItem == nil {
+			return 0
+		}
+		now := o.clock.Now()
+		d := now.Sub(mark)
+		mark = now
+		return d
+	}
+	// done сообщает WithOnItem время значения
+	done := func() {
+		if o.onItem != nil {
+			o.onItem(timing)
+		}
+	}
+	// state сообщает WithOnState занятие обработчика
+	state := func(s WorkerState) {
+		if o.onState != nil {
+			o.onState(s)
+		}
+	}
+	defer state(WorkerStopped)
+
+	for {
+		state(WorkerWaiting)
+		// ждём разрешения до чтения, чтобы не держать прочитанное значение
+		if o.limiter != nil && o.limiter.Wait(ctx) != nil {
+			return nil
+		}
+		// закрытый quit важнее готового значения в in
+		select {
+		case <-o.quit:
+			return nil
+		default:
+		}
+		var v T
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-o.quit:
+			return nil
+		case val, ok := <-in:
+			if !ok {
+				return nil
+			}
+			v = val
+		}
+
+		// паника обработки превращается в ошибку, а значение — в отброшенное
+		timing = WorkerTiming{Idle: lap()}
+		state(WorkerProcessing)
+		res, attempts, err := processWithRetry(ctx, process, v, o.retry, o.clock, o.onRetry)
+		timing.Busy = lap()
+		if err != nil && !errors.Is(err, ErrSkip) {
+			timing.Err = err
+		}
+		switch {
+		case errors.Is(err, ErrSkip):
+			if o.onSkip != nil {
+				o.onSkip(v)
+			}
+			done()
+			continue
+		case err != nil && ctx.Err() != nil:
+			// обработка прервана отменой контекста
+			drop(v)
+			return nil
+		case err != nil && o.dead != nil:
+			state(WorkerSending)
+			select {
+			case <-ctx.Done():
+				drop(v)
+				return nil
+			case o.dead <- DeadLetter[T]{Value: v, Err: err, Attempts: attempts}:
+			}
+			timing.Send = lap()
+			done()
+			continue
+		case err != nil:
+			drop(v)
+			done()
+			return err
+		}
+
+		// конверт передаётся по значению или по указателю, как из ItemPool
+		if it, ok := any(&res).(attemptsSetter); ok {
+			it.setAttempts(attempts)
+		} else if it, ok := any(res).(attemptsSetter); ok {
+			it.setAttempts(attempts)
+		}
+		// отправляем обработанное значение в канал out
+		state(WorkerSending)
+		select {
+		case <-ctx.Done():
+			drop(v)
+			return nil
+		case out <- res:
+		}
+		timing.Send = lap()
+		done()
+	}
+}
+
+// ErrItemTimeout — причина отмены контекста обработки значения, которое
+// обрабатывается дольше WithItemTimeout.
+var ErrItemTimeout = errors.New("истекло время обработки значения")
+
+// withItemTimeout ограничивает обработку каждого значения временем d:
+// контекст process отменяется по истечении d с причиной ErrItemTimeout, и
+// ошибка обработки оборачивается в ErrItemTimeout.
+func withItemTimeout[T any](process func(context.Context, T) (T, error), d time.Duration) func(context.Context, T) (T, error) {
+	return func(ctx context.Context, v T) (T, error) {
+		ctx, cancel := context.WithTimeoutCause(ctx, d, ErrItemTimeout)
+		defer cancel()
+		res, err := process(ctx, v)
+		if err != nil && errors.Is(context.Cause(ctx), ErrItemTimeout) && !errors.Is(err, ErrItemTimeout) {
+			err = fmt.Errorf("%w: %w", ErrItemTimeout, err)
+		}
+		return res, err
+	}
+}
+
+// WorkerOption задаёт дополнительную настройку Worker со значениями типа T.
+type WorkerOption[T any] func(*workerOptions[T])
+
+// workerOptions — набор настроек Worker.
+type workerOptions[T any] struct {
+	process func(context.Context, T) (T, error) // обработка значения
+	onDrop  func(T)                             // вызывается для необработанного значения
+	onSkip  func(T)                             // вызывается для отфильтрованного значения
+	quit    <-chan struct{}                     // сигнал завершения после текущего значения
+	limiter Limiter                             // разрешение на чтение очередного значения
+
+	retry   RetryPolicy                  // повтор неудачной обработки
+	clock   Clock                        // часы для пауз между попытками
+	onRetry func(attempt int, err error) // вызывается перед каждым повтором
+	dead    chan<- DeadLetter[T]         // окончательно не обработанные значения
+
+	itemTimeout time.Duration // наибольшее время обработки одного значения
+
+	onItem  func(WorkerTiming) // вызывается после каждого значения
+	onState func(WorkerState)  // вызывается при смене занятия
+}
+
+// WorkerTiming — время, которое Worker затратил на одно значение.
+type WorkerTiming struct {
+	// Idle — ожидание значения из in, включая ожидание разрешения
+	// WithLimiter
+	Idle time.Duration
+	// Busy — обработка вместе с повторами и паузами между ними
+	Busy time.Duration
+	// Send — ожидание отправки результата в out или в канал WithDeadLetter
+	Send time.Duration
+	// Err — окончательная ошибка обработки; nil, если значение обработано
+	// или отфильтровано
+	Err error
+}
+
+// WithOnDrop задаёт функцию, которая вызывается для значения, прочитанного
+// из in, но не обработанного или не отправленного в out из-за ошибки
+// обработки или отмены контекста.
+func WithOnDrop[T any](fn func(T)) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.onDrop = fn
+	}
+}
+
+// WithProcess задаёт функцию обработки значений вместо
+// Delay(DefaultWorkerDelay). Если fn возвращает ошибку, отличную от ErrSkip,
+// или паникует, а WithDeadLetter не задан, Worker завершается и возвращает
+// ошибку; паника возвращается как *PanicError.
+func WithProcess[T any](fn func(context.Context, T) (T, error)) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.process = fn
+	}
+}
+
+// WithOnSkip задаёт функцию, которая вызывается для значения,
+// отфильтрованного обработкой с помощью ErrSkip.
+func WithOnSkip[T any](fn func(T)) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.onSkip = fn
+	}
+}
+
+// WithQuit завершает Worker без потери значений: после закрытия канала quit
+// Worker больше не читает из in, но значение, которое он уже обрабатывает,
+// обрабатывается и отправляется в out как обычно.
+func WithQuit[T any](quit <-chan struct{}) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.quit = quit
+	}
+}
+
+// WithLimiter задаёт ограничитель, разрешения которого Worker ждёт перед
+// чтением каждого значения из in, например для паузы или ограничения
+// частоты обработки. Отмена контекста прерывает ожидание и завершает Worker.
+func WithLimiter[T any](limiter Limiter) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.limiter = limiter
+	}
+}
+
+// WithRetry повторяет неудачную обработку значения по политике policy;
+// паузы между попытками отсчитываются по часам WithClock.
+func WithRetry[T any](policy RetryPolicy) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.retry = policy
+	}
+}
+
+// WithClock задаёт часы, по которым Worker отсчитывает паузы между
+// попытками обработки, вместо SystemClock; nil оставляет SystemClock.
+func WithClock[T any](clock Clock) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
+
+// WithOnRetry задаёт функцию, которая вызывается перед каждым повтором
+// обработки с номером неудачной попытки и её ошибкой, например для подсчёта
+// повторов.
+func WithOnRetry[T any](fn func(attempt int, err error)) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.onRetry = fn
+	}
+}
+
+// WithDeadLetter отправляет в канал dead значения, обработка которых
+// окончательно не удалась, вместо завершения Worker с ошибкой. Отмена
+// контекста прерывает ожидание отправки, и значение передаётся обработчику
+// WithOnDrop.
+func WithDeadLetter[T any](dead chan<- DeadLetter[T]) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.dead = dead
+	}
+}
+
+// WithOnItem задаёт функцию, которая вызывается после каждого обработанного,
+// отфильтрованного или необработанного значения со временем, затраченным
+// на него, например для подсчёта загрузки обработчика. Время измеряется по
+// часам WithClock. Значение, отброшенное из-за отмены контекста, не
+// сообщается.
+func WithOnItem[T any](fn func(WorkerTiming)) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.onItem = fn
+	}
+}
+
+// WithOnState задаёт функцию, которая вызывается, когда Worker начинает
+// ждать значение, обрабатывать его или отправлять результат, и при
+// завершении, например для наблюдения за обработчиками во время работы.
+func WithOnState[T any](fn func(WorkerState)) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.onState = fn
+	}
+}
+
+// WithItemTimeout ограничивает обработку каждого значения временем d по
+// реальным часам: контекст обработки отменяется с причиной ErrItemTimeout,
+// и если обработка вернула ошибку, значение считается необработанным, как
+// при любой другой ошибке, — с повторами WithRetry и отправкой в
+// WithDeadLetter. Обработка, не учитывающая контекст, не прерывается.
+// d <= 0 снимает ограничение.
+func WithItemTimeout[T any](d time.Duration) WorkerOption[T] {
+	return func(o *workerOptions[T]) {
+		o.itemTimeout = d
+	}
+}
@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestWorkerCancel проверяет, что отмена контекста завершает Worker, который
+// ждёт чтения из in или записи в out, и закрывает out.
+func TestWorkerCancel(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []int64 // значения в буфере in; out никто не читает
+	}{
+		{"ожидание чтения", nil},
+		{"ожидание записи", []int64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			in := make(chan int64, len(tt.input))
+			for _, v := range tt.input {
+				in <- v
+			}
+			out := make(chan int64)
+			done := make(chan error, 1)
+			go func() { done <- Worker(ctx, in, out) }()
+			cancel()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Worker = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Worker не завершился после отмены")
+			}
+			if _, ok := <-out; ok {
+				t.Error("канал out не закрыт")
+			}
+		})
+	}
+}
+
+// TestWorkerPanic проверяет, что паника обработки возвращается как
+// *PanicError, а значение, на котором она произошла, отбрасывается.
+func TestWorkerPanic(t *testing.T) {
+	in := make(chan int64, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	close(in)
+	out := make(chan int64, 3)
+	var dropped []int64
+	err := Worker(context.Background(), in, out,
+		WithProcess(func(_ context.Context, v int64) (int64, error) {
+			if v == 2 {
+				panic("сбой")
+			}
+			return v, nil
+		}),
+		WithOnDrop(func(v int64) { dropped = append(dropped, v) }))
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "сбой" {
+		t.Errorf("Worker = %v, want *PanicError", err)
+	}
+	if got := collect(out); !slices.Equal(got, []int64{1}) || !slices.Equal(dropped, []int64{2}) {
+		t.Errorf("Worker передал %v, отбросил %v, want [1] и [2]", got, dropped)
+	}
+}
+
+// TestWorkerQuit проверяет, что после закрытия WithQuit Worker больше не
+// читает значения, даже если они есть в in.
+func TestWorkerQuit(t *testing.T) {
+	in := make(chan int64, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	quit := make(chan struct{})
+	close(quit)
+	out := make(chan int64, 3)
+	if err := Worker(context.Background(), in, out, WithQuit[int64](quit)); err != nil {
+		t.Fatalf("Worker = %v", err)
+	}
+	if got := collect(out); len(got) != 0 || len(in) != 3 {
+		t.Errorf("после WithQuit обработано %v, в in осталось %d, want ничего и 3", got, len(in))
+	}
+}
+
+// TestWorkerDeadLetter проверяет, что Worker повторяет неудачную обработку
+// и отправляет значение, обработка которого окончательно не удалась, в
+// канал WithDeadLetter, продолжая работу.
+func TestWorkerDeadLetter(t *testing.T) {
+	in := make(chan int64, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	close(in)
+	out := make(chan int64, 3)
+	dead := make(chan DeadLetter[int64], 3)
+	var retries []int
+	err := Worker(context.Background(), in, out,
+		WithProcess(func(_ context.Context, v int64) (int64, error) {
+			if v == 2 {
+				return 0, errOdd
+			}
+			return v, nil
+		}),
+		WithRetry[int64](RetryPolicy{Attempts: 3}),
+		WithClock[int64](NewManualClock(time.Unix(0, 0))),
+		WithOnRetry[int64](func(attempt int, _ error) { retries = append(retries, attempt) }),
+		WithDeadLetter(dead))
+	if err != nil {
+		t.Fatalf("Worker = %v", err)
+	}
+	close(dead)
+	if got := collect(out); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("Worker передал %v, want [1 3]", got)
+	}
+	if !slices.Equal(retries, []int{1, 2}) {
+		t.Errorf("повторы %v, want [1 2]", retries)
+	}
+	letters := collect(dead)
+	if len(letters) != 1 || letters[0].Value != 2 || letters[0].Attempts != 3 || !errors.Is(letters[0].Err, errOdd) {
+		t.Errorf("необработанные %+v, want 2 после 3 попыток", letters)
+	}
+}
+
+// TestWorkerOnItem проверяет, что WithOnItem получает время обработки и
+// ошибку каждого обработанного, отфильтрованного и необработанного значения.
+func TestWorkerOnItem(t *testing.T) {
+	in := make(chan int64, 4)
+	for v := int64(1); v <= 4; v++ {
+		in <- v
+	}
+	close(in)
+	out := make(chan int64, 4)
+	dead := make(chan DeadLetter[int64], 4)
+	clock := NewManualClock(time.Unix(0, 0))
+	var timings []WorkerTiming
+	err := Worker(context.Background(), in, out,
+		WithProcess(func(_ context.Context, v int64) (int64, error) {
+			clock.Advance(time.Duration(v) * time.Millisecond)
+			switch v {
+			case 2:
+				return 0, errOdd
+			case 4:
+				return 0, ErrSkip
+			}
+			return v, nil
+		}),
+		WithClock[int64](clock),
+		WithDeadLetter(dead),
+		WithOnItem[int64](func(t WorkerTiming) { timings = append(timings, t) }))
+	if err != nil {
+		t.Fatalf("Worker = %v", err)
+	}
+	if len(timings) != 4 {
+		t.Fatalf("сообщено %d значений, want 4", len(timings))
+	}
+	for i, tm := range timings {
+		v := int64(i + 1)
+		if tm.Busy != time.Duration(v)*time.Millisecond || tm.Idle != 0 || tm.Send != 0 {
+			t.Errorf("значение %d: %+v, want Busy %dms", v, tm, v)
+		}
+		if wantErr := v == 2; (tm.Err != nil) != wantErr {
+			t.Errorf("значение %d: ошибка %v, want %v", v, tm.Err, wantErr)
+		}
+	}
+}
+
+// TestWorkerOnState проверяет, что WithOnState сообщает ожидание,
+// обработку и отправку каждого значения, а затем завершение.
+func TestWorkerOnState(t *testing.T) {
+	in := make(chan int64, 2)
+	in <- 1
+	in <- 2
+	close(in)
+	var states []WorkerState
+	err := Worker(context.Background(), in, make(chan int64, 2),
+		WithProcess(func(_ context.Context, v int64) (int64, error) {
+			if v == 2 {
+				return 0, ErrSkip
+			}
+			return v, nil
+		}),
+		WithOnState[int64](func(s WorkerState) { states = append(states, s) }))
+	if err != nil {
+		t.Fatalf("Worker = %v", err)
+	}
+	want := []WorkerState{
+		WorkerWaiting, WorkerProcessing, WorkerSending,
+		WorkerWaiting, WorkerProcessing,
+		WorkerWaiting, WorkerStopped,
+	}
+	if !slices.Equal(states, want) {
+		t.Errorf("состояния %v, want %v", states, want)
+	}
+}
+
+// TestWorkerItemTimeout проверяет, что обработка, не уложившаяся в
+// WithItemTimeout, прерывается, а значение считается необработанным.
+func TestWorkerItemTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    []int64
+		dead    []int64
+	}{
+		{"без ограничения", 0, []int64{1, 2, 3}, nil},
+		{"с ограничением", 20 * time.Millisecond, []int64{1, 3}, []int64{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan int64, 3)
+			in <- 1
+			in <- 2
+			in <- 3
+			close(in)
+			out := make(chan int64, 3)
+			dead := make(chan DeadLetter[int64], 3)
+			err := Worker(context.Background(), in, out,
+				WithProcess(func(ctx context.Context, v int64) (int64, error) {
+					if v != 2 {
+						return v, nil
+					}
+					select {
+					case <-ctx.Done():
+						return 0, ctx.Err()
+					case <-time.After(200 * time.Millisecond):
+						return v, nil
+					}
+				}),
+				WithItemTimeout[int64](tt.timeout),
+				WithDeadLetter(dead))
+			if err != nil {
+				t.Fatalf("Worker = %v", err)
+			}
+			close(dead)
+			if got := collect(out); !slices.Equal(got, tt.want) {
+				t.Errorf("Worker передал %v, want %v", got, tt.want)
+			}
+			var got []int64
+			for _, dl := range collect(dead) {
+				if !errors.Is(dl.Err, ErrItemTimeout) {
+					t.Errorf("ошибка %v, want ErrItemTimeout", dl.Err)
+				}
+				got = append(got, dl.Value)
+			}
+			if !slices.Equal(got, tt.dead) {
+				t.Errorf("необработанные %v, want %v", got, tt.dead)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// DefaultStatsInterval — период отправки статистики StatsHandler по
+// умолчанию.
+const DefaultStatsInterval = time.Second
+
+// statsMessage — сообщение со статистикой, которое StatsHandler отправляет
+// по WebSocket.
+type statsMessage struct {
+	Time         time.Time `json:"time"`
+	InputCount   int64     `json:"inputCount"`
+	InputSum     int64     `json:"inputSum"`
+	OutputCount  int64     `json:"outputCount"`
+	OutputSum    int64     `json:"outputSum"`
+	DroppedCount int64     `json:"droppedCount"`
+	SkippedCount int64     `json:"skippedCount"`
+	FailedCount  int64     `json:"failedCount"`
+	PerWorker    []int64   `json:"perWorker"`
+	// Throughput — количество чисел результирующего канала в секунду за
+	// период с предыдущего сообщения
+	Throughput float64 `json:"throughput"`
+	// WorkerThroughput — то же для каждого канала outs[i]
+	WorkerThroughput []float64 `json:"perWorkerThroughput"`
+}
+
+// newStatsMessage собирает сообщение из статистики snap, снятой в now, и
+// статистики prev, снятой за elapsed до неё.
+func newStatsMessage(prev, snap Snapshot, now time.Time, elapsed time.Duration) statsMessage {
+	msg := statsMessage{
+		Time:             now,
+		InputCount:       snap.InputCount,
+		InputSum:         snap.InputSum,
+		OutputCount:      snap.OutputCount,
+		OutputSum:        snap.OutputSum,
+		DroppedCount:     snap.DroppedCount,
+		SkippedCount:     snap.SkippedCount,
+		FailedCount:      snap.FailedCount,
+		PerWorker:        snap.PerWorker,
+		WorkerThroughput: make([]float64, len(snap.PerWorker)),
+	}
+	if elapsed <= 0 {
+		return msg
+	}
+	msg.Throughput = float64(snap.OutputCount-prev.OutputCount) / elapsed.Seconds()
+	for i, n := range snap.PerWorker {
+		// при масштабировании обработчики появляются и исчезают
+		if i < len(prev.PerWorker) {
+			n -= prev.PerWorker[i]
+		}
+		msg.WorkerThroughput[i] = float64(n) / elapsed.Seconds()
+	}
+	return msg
+}
+
+// StatsHandler возвращает обработчик HTTP, который принимает соединения
+// WebSocket и каждые interval (0 и меньше — DefaultStatsInterval) по часам
+// clock (nil — SystemClock) отправляет в них JSON-объект с живой
+// статистикой конвейера p: количеством и суммами чисел, разбивкой по
+// каналам и производительностью за последний период. Клиенту ничего не
+// нужно отправлять; соединение закрывается, когда его закрывает клиент.
+// Заголовок Origin не проверяется, поэтому подключаться можно со страниц
+// любых сайтов.
+func StatsHandler(p *Pipeline, interval time.Duration, clock Clock) http.Handler {
+	if interval <= 0 {
+		interval = DefaultStatsInterval
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	// websocket.Server без Handshake принимает соединения с любым Origin
+	return websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		// закрытие соединения клиентом обнаруживается чтением
+		closed := make(chan struct{})
+		go func() {
+			io.Copy(io.Discard, ws)
+			close(closed)
+		}()
+		prev, prevAt := p.Stats(), clock.Now()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-clock.After(interval):
+			}
+			snap, now := p.Stats(), clock.Now()
+			if err := websocket.JSON.Send(ws, newStatsMessage(prev, snap, now, now.Sub(prevAt))); err != nil {
+				return
+			}
+			prev, prevAt = snap, now
+		}
+	}}
+}
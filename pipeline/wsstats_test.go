@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestNewStatsMessage(t *testing.T) {
+	tests := []struct {
+		name           string
+		prev, snap     Snapshot
+		elapsed        time.Duration
+		wantThroughput float64
+		wantPerWorker  []float64
+	}{
+		{"за секунду", Snapshot{OutputCount: 10, PerWorker: []int64{4, 6}}, Snapshot{OutputCount: 30, PerWorker: []int64{14, 16}}, time.Second, 20, []float64{10, 10}},
+		{"за полсекунды", Snapshot{}, Snapshot{OutputCount: 5, PerWorker: []int64{5}}, 500 * time.Millisecond, 10, []float64{10}},
+		{"обработчик добавлен", Snapshot{PerWorker: []int64{2}}, Snapshot{OutputCount: 5, PerWorker: []int64{3, 2}}, time.Second, 5, []float64{1, 2}},
+		{"без периода", Snapshot{}, Snapshot{OutputCount: 5, PerWorker: []int64{5}}, 0, 0, []float64{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := newStatsMessage(tt.prev, tt.snap, time.Time{}, tt.elapsed)
+			if msg.OutputCount != tt.snap.OutputCount || msg.Throughput != tt.wantThroughput || !slices.Equal(msg.WorkerThroughput, tt.wantPerWorker) {
+				t.Errorf("сообщение %+v, want throughput %v и по каналам %v", msg, tt.wantThroughput, tt.wantPerWorker)
+			}
+		})
+	}
+}
+
+// TestStatsHandler проверяет, что подключённый по WebSocket клиент
+// получает статистику каждый период.
+func TestStatsHandler(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	p := New(Config{NumWorkers: 2})
+	srv := httptest.NewServer(StatsHandler(p, time.Second, clock))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	for range 2 {
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(time.Second)
+		var msg statsMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if !msg.Time.Equal(clock.Now()) || msg.OutputCount != 0 {
+			t.Errorf("сообщение %+v, want время %v и пустую статистику", msg, clock.Now())
+		}
+	}
+}